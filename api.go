@@ -2,7 +2,10 @@ package pcs
 
 import (
 	"bytes"
+	"context"
 	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"hash/crc32"
@@ -12,6 +15,7 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
 type Quota struct {
@@ -20,18 +24,21 @@ type Quota struct {
 }
 
 // 获取当前用户空间配额信息
-func (c *Client) GetQuota() (*Quota, *http.Response, error) {
+func (c *Client) GetQuota(ctx context.Context) (*Quota, *http.Response, error) {
 	u, err := c.addOptions("quota", "info", nil)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	quota := new(Quota)
-	resp, err := c.Get(u, quota)
+	quota, resp, err := get[Quota](ctx, c, u)
 	if err != nil {
 		return nil, resp, err
 	}
 
+	if c.quotaLowRatio > 0 && quota.Quota > 0 && float64(quota.Used) >= c.quotaLowRatio*float64(quota.Quota) {
+		c.publish(QuotaLow{Quota: *quota})
+	}
+
 	return quota, resp, nil
 }
 
@@ -45,9 +52,15 @@ type File struct {
 	IsDir uint   `json:"isdir"` // 是否是目录的标识符: “0”为文件, “1”为目录
 }
 
+// IsDirectory 报告 f 是否是目录，f 为 nil 时返回 false
+func (f *File) IsDirectory() bool {
+	return f != nil && f.IsDir != 0
+}
+
 // path: 待上传文件的或者绝对路径/相对路径
-func (c *Client) upload(path string) (io.Reader, string, error) {
-	// code adapted from http://matt.aimonetti.net/posts/2013/07/01/golang-multipart-file-upload-example/
+// ci 不为 nil 时上传的是加密后的内容。
+// 返回的请求体使用池中的缓冲区，由 http.Transport 在发送完毕后 Close 归还。
+func (c *Client) upload(ctx context.Context, path string, ci *Cipher) (*pooledBody, string, error) {
 	fullpath, err := filepath.Abs(path)
 	if err != nil {
 		return nil, "", err
@@ -59,71 +72,164 @@ func (c *Client) upload(path string) (io.Reader, string, error) {
 	}
 	defer file.Close()
 
-	body := &bytes.Buffer{}
-	writer := multipart.NewWriter(body)
-	part, err := writer.CreateFormFile("file", filepath.Base(path))
+	stat, err := file.Stat()
 	if err != nil {
 		return nil, "", err
 	}
 
-	written, err := io.Copy(part, file)
+	var r io.Reader = file
+	size := stat.Size()
+	if ci != nil {
+		f, err := ci.newFile()
+		if err != nil {
+			return nil, "", err
+		}
+		enc := f.encryptReaderAt(file, size)
+		r, size = io.NewSectionReader(enc, 0, enc.Size()), enc.Size()
+	}
+
+	body, contentType, err := c.bufferedBody(ctx, filepath.Base(path), r, size)
 	if err != nil {
 		return nil, "", err
 	}
+	return body, contentType, nil
+}
 
-	contentType := writer.FormDataContentType()
-	writer.Close()
+// multipartBody 将 r 中的 size 字节封装为名为 name 的 multipart 文件表单，并记录其内容的md5，
+// 读取的数据不足 size 时返回 ErrIncompleteFile。
+func multipartBody(name string, r io.Reader, size int64) (*pooledBody, string, error) {
+	// code adapted from http://matt.aimonetti.net/posts/2013/07/01/golang-multipart-file-upload-example/
+	body := &pooledBody{Buffer: getBytesBuffer()}
+	writer := multipart.NewWriter(body)
+	part, err := writer.CreateFormFile("file", name)
+	if err != nil {
+		body.Close()
+		return nil, "", err
+	}
 
-	stat, err := file.Stat()
+	h := md5.New()
+	written, err := copyBuffered(io.MultiWriter(part, h), r)
 	if err != nil {
+		body.Close()
 		return nil, "", err
 	}
-	if written != stat.Size() {
+	body.md5 = hex.EncodeToString(h.Sum(nil))
+
+	contentType := writer.FormDataContentType()
+	writer.Close()
+
+	if written != size {
+		body.Close()
 		return nil, "", ErrIncompleteFile
 	}
 
 	return body, contentType, nil
 }
 
+// OnDup 指定上传时已存在同名文件的处理方式，为空时上传失败。
+type OnDup string
+
+const (
+	OnDupOverwrite OnDup = "overwrite" // 覆盖同名文件
+	OnDupNewCopy   OnDup = "newcopy"   // 生成文件副本并进行重命名，命名规则为“文件名_日期.后缀”
+)
+
 type FileOptions struct {
 	// 上传文件路径（含上传的文件名称)。
 	Path string `url:"path"`
 
-	// 可选值：
-	// overwrite：表示覆盖同名文件；
-	// newcopy：表示生成文件副本并进行重命名，命名规则为“文件名_日期.后缀”。
-	OnDup string `url:"ondup,omitempty"`
+	// 同名文件的处理方式，见 OnDup。
+	OnDup OnDup `url:"ondup,omitempty"`
 }
 
 // 上传单个文件
 // srcPath: 待上传文件的或者绝对路径/相对路径
-func (c *Client) Upload(srcPath string, opt *FileOptions) (*File, *http.Response, error) {
-	body, contentType, err := c.upload(srcPath)
+// 通过 WithEncryption 设置了 Cipher 时上传加密后的内容。
+func (c *Client) Upload(ctx context.Context, srcPath string, opt *FileOptions) (*File, *http.Response, error) {
+	if c.quotaCheck {
+		stat, err := os.Stat(srcPath)
+		if err != nil {
+			return nil, nil, err
+		}
+		size := stat.Size()
+		if c.cipher != nil {
+			size = c.cipher.EncryptedSize(size)
+		}
+		if err := c.checkQuota(ctx, size); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	body, contentType, err := c.upload(ctx, srcPath, c.cipher)
 	if err != nil {
 		return nil, nil, err
 	}
+	return c.uploadFile(ctx, body, contentType, opt)
+}
 
+// uploadFile 上传 multipart 请求体 body，无论成功与否都会关闭 body。
+func (c *Client) uploadFile(ctx context.Context, body *pooledBody, contentType string, opt *FileOptions) (*File, *http.Response, error) {
 	u, err := c.addOptions("file", "upload", opt)
 	if err != nil {
+		body.Close()
 		return nil, nil, err
 	}
+	return c.postBody(ctx, u, body, contentType, false)
+}
 
-	f := new(File)
-	resp, err := c.Post(u, contentType, body, f)
+// postBody 上传 multipart 请求体 body，无论成功与否都会关闭 body。请求带有整个请求体的 Content-MD5 头（RFC 1864），
+// 使服务器可以拒绝传输中损坏的数据；文件内容的md5另记在 body.md5 中，由 uploadBlock 与服务器返回的md5比较。replayable 为 true 时请求可以按 WithRetry 重试，
+// body 在全部尝试结束后才关闭；只有重复执行没有副作用的上传才应如此。
+func (c *Client) postBody(ctx context.Context, u string, body *pooledBody, contentType string, replayable bool) (*File, *http.Response, error) {
+	var r io.Reader = body
+	if replayable {
+		// 每次尝试都从头读取同一份数据，见 replay
+		defer body.Close()
+		r = bytes.NewReader(body.Bytes())
+	}
+	req, err := c.NewUploadRequest("POST", u, r)
 	if err != nil {
-		return nil, resp, err
+		body.Close()
+		return nil, nil, err
 	}
+	sum := md5.Sum(body.Bytes())
+	req.ContentLength = int64(body.Len())
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Content-MD5", base64.StdEncoding.EncodeToString(sum[:]))
 
-	return f, resp, nil
+	if replayable {
+		ctx = idempotent(ctx)
+	}
+	return do[File](ctx, c, req)
 }
 
 // 分片上传—文件分片及上传
-func (c *Client) BlockUpload(srcPath string) (*File, *http.Response, error) {
-	body, contentType, err := c.upload(srcPath)
+// 分片按原样上传，不加密。
+func (c *Client) BlockUpload(ctx context.Context, srcPath string) (*File, *http.Response, error) {
+	body, contentType, err := c.upload(ctx, srcPath, nil)
 	if err != nil {
 		return nil, nil, err
 	}
+	return c.uploadBlock(ctx, body, contentType)
+}
 
+// 分片上传—从 r 中读取 size 字节作为一个分片上传
+// r 可以是文件的一段（如 io.SectionReader）、加密后的数据或网络数据源，分片按原样上传，不加密。
+// r 中的数据不足 size 字节时返回 ErrIncompleteFile，多出的部分不会被读取。
+func (c *Client) BlockUploadReader(ctx context.Context, r io.Reader, size int64) (*File, *http.Response, error) {
+	if size < 0 || size > MaxBlockSize {
+		return nil, nil, invalid("size", "must be between 0 and %d, got %d", int64(MaxBlockSize), size)
+	}
+	body, contentType, err := c.bufferedBody(ctx, "block", r, size)
+	if err != nil {
+		return nil, nil, err
+	}
+	return c.uploadBlock(ctx, body, contentType)
+}
+
+// uploadBlock 以 tmpfile 方式上传一个分片，无论成功与否都会关闭 body。失败时按 WithRetry 重试。
+// 服务器返回的md5与分片内容不符时返回的错误满足 errors.Is(err, ErrChecksumMismatch)。
+func (c *Client) uploadBlock(ctx context.Context, body *pooledBody, contentType string) (*File, *http.Response, error) {
 	opt := struct {
 		Type string `url:"type"`
 	}{
@@ -132,27 +238,29 @@ func (c *Client) BlockUpload(srcPath string) (*File, *http.Response, error) {
 
 	u, err := c.addOptions("file", "upload", &opt)
 	if err != nil {
+		body.Close()
 		return nil, nil, err
 	}
 
-	f := new(File)
-	resp, err := c.Post(u, contentType, body, f)
-	if err != nil {
-		return nil, resp, err
+	// 临时分片可以放心地重复上传，失败时只重试这一个分片，已上传的分片不受影响。
+	// 返回的md5会用于 CreateSuperFile 的 block_list，与上传的内容不符时合并的文件也是错误的
+	sum := body.md5
+	f, resp, err := c.postBody(ctx, u, body, contentType, true)
+	if err == nil && !strings.EqualFold(f.Md5, sum) {
+		return nil, resp, fmt.Errorf("%w: uploaded block %s, server stored %s", ErrChecksumMismatch, sum, f.Md5)
 	}
-
-	return f, resp, nil
+	return f, resp, err
 }
 
 // 分片上传—合并分片文件
 // 与分片文件上传的upload方法配合使用，可实现超大文件（>2G）上传，同时也可用于断点续传的场景。
-func (c *Client) CreateSuperFile(targetPath string, md5 []string, opt *FileOptions) (*File, *http.Response, error) {
-	if len(md5) < 2 || len(md5) > 1024 {
+func (c *Client) CreateSuperFile(ctx context.Context, targetPath string, md5 []string, opt *FileOptions) (*File, *http.Response, error) {
+	if len(md5) < minSuperFileBlocks || len(md5) > maxSuperFileBlocks {
 		return nil, nil, ErrInvalidArgument
 	}
 
 	tmp := make(map[string][]string)
-	tmp["blocklist"] = md5
+	tmp["block_list"] = md5
 	param, err := json.Marshal(tmp)
 	if err != nil {
 		return nil, nil, err
@@ -165,18 +273,12 @@ func (c *Client) CreateSuperFile(targetPath string, md5 []string, opt *FileOptio
 		return nil, nil, err
 	}
 
-	f := new(File)
-	resp, err := c.PostForm(u, data, f)
-	if err != nil {
-		return nil, resp, err
-	}
-
-	return f, resp, nil
+	return postForm[File](ctx, c, u, data)
 }
 
 // 下载单个文件
 // path: 下载文件路径，以/开头的绝对路径。
-func (c *Client) Download(path string) (*http.Response, error) {
+func (c *Client) Download(ctx context.Context, path string) (*http.Response, error) {
 	opt := struct {
 		Path string `url:"path"`
 	}{
@@ -187,7 +289,12 @@ func (c *Client) Download(path string) (*http.Response, error) {
 		return nil, err
 	}
 
-	resp, err := c.Get(u, nil)
+	req, err := c.NewDownloadRequest("GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.Do(ctx, req, nil)
 	if err != nil {
 		return resp, err
 	}
@@ -199,7 +306,7 @@ func (c *Client) Download(path string) (*http.Response, error) {
 // 下载单个文件： 支持断点下载
 // start: byte
 // end: byte
-func (c *Client) PartialDownload(path string, start, end int64) (*http.Response, error) {
+func (c *Client) PartialDownload(ctx context.Context, path string, start, end int64) (*http.Response, error) {
 	if start >= end {
 		return nil, ErrInvalidArgument
 	}
@@ -213,14 +320,14 @@ func (c *Client) PartialDownload(path string, start, end int64) (*http.Response,
 		return nil, err
 	}
 
-	req, err := c.NewRequest("GET", u, nil)
+	req, err := c.NewDownloadRequest("GET", u, nil)
 	if err != nil {
 		return nil, err
 	}
 	ranges := fmt.Sprintf("bytes=%d-%d", start, end)
 	req.Header.Set("Range", ranges)
 
-	resp, err := c.Do(req, nil)
+	resp, err := c.Do(ctx, req, nil)
 	if err != nil {
 		return resp, err
 	}
@@ -230,8 +337,149 @@ func (c *Client) PartialDownload(path string, start, end int64) (*http.Response,
 	return resp, nil
 }
 
+// 下载文件 path 并将内容写入 w，通过 WithEncryption 设置了 Cipher 时写入的是解密后的内容。
+// 通过 WithDownloadCache 设置了缓存时先获取文件的 md5，命中缓存则不再下载，此时返回的
+// *http.Response 为 nil；未命中时下载的内容同时加入缓存。
+func (c *Client) DownloadTo(ctx context.Context, path string, w io.Writer) (*http.Response, error) {
+	var (
+		md5  string
+		size int64
+	)
+	if c.cache != nil {
+		meta, resp, err := c.GetMeta(ctx, path)
+		if err != nil {
+			return resp, err
+		}
+		if meta.File != nil && !meta.IsDirectory() {
+			md5, size = meta.Md5, int64(meta.Size)
+		}
+		if f, ok := c.cache.Open(md5); ok {
+			defer f.Close()
+			return nil, c.decryptTo(w, func(dst io.Writer) error {
+				_, err := copyBuffered(dst, f)
+				return err
+			})
+		}
+	}
+
+	opt := struct {
+		Path string `url:"path"`
+	}{
+		Path: path,
+	}
+	u, err := c.addOptions("file", "download", &opt)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := c.NewDownloadRequest("GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var cw *cacheWriter
+	if md5 != "" {
+		// 缓存只是优化，无法写入时照常下载
+		cw, _ = c.cache.create(md5)
+	}
+	var resp *http.Response
+	err = c.decryptTo(w, func(dst io.Writer) error {
+		if cw != nil {
+			dst = io.MultiWriter(dst, cw)
+		}
+		resp, err = c.Do(ctx, req, dst)
+		return err
+	})
+	if cw != nil {
+		if err == nil && cw.n == size {
+			cw.commit()
+		} else {
+			cw.abort()
+		}
+	}
+	return resp, err
+}
+
+// decryptTo 调用 fn 写出远程文件的内容，设置了 Cipher 时解密后写入 w，否则直接写入 w。
+func (c *Client) decryptTo(w io.Writer, fn func(dst io.Writer) error) error {
+	if c.cipher == nil {
+		return fn(w)
+	}
+
+	pr, pw := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		err := c.cipher.Decrypt(w, pr)
+		pr.CloseWithError(err)
+		done <- err
+	}()
+	err := fn(pw)
+	pw.Close()
+	if derr := <-done; err == nil {
+		err = derr
+	}
+	return err
+}
+
+// downloadRange 下载文件 [start, end] 范围内的内容并写入 w，md5 不为空且命中缓存时从缓存读取。
+// 服务器返回的数据多于或少于请求的范围时返回错误，以免写坏本地文件。
+func (c *Client) downloadRange(ctx context.Context, path, md5 string, start, end int64, w io.Writer) (*http.Response, error) {
+	if c.cache != nil && md5 != "" {
+		if f, ok := c.cache.Open(md5); ok {
+			defer f.Close()
+			n, err := copyBuffered(w, io.NewSectionReader(f, start, end-start+1))
+			if err == nil && n != end-start+1 {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+	}
+
+	opt := struct {
+		Path string `url:"path"`
+	}{
+		Path: path,
+	}
+	u, err := c.addOptions("file", "download", &opt)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := c.NewDownloadRequest("GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end))
+
+	lw := &rangeWriter{w: w, n: end - start + 1}
+	resp, err := c.Do(ctx, req, lw)
+	if err != nil {
+		return resp, err
+	}
+	if lw.n != 0 {
+		return resp, &RequestError{Method: req.Method, URL: req.URL.String(), Err: io.ErrUnexpectedEOF}
+	}
+	return resp, nil
+}
+
+// rangeWriter 最多接受 n 字节，超出时返回 ErrInvalidResponse，
+// 例如服务器忽略 Range 头而返回了整个文件。
+type rangeWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (w *rangeWriter) Write(p []byte) (int, error) {
+	if int64(len(p)) > w.n {
+		return 0, ErrInvalidResponse
+	}
+	n, err := w.w.Write(p)
+	w.n -= int64(n)
+	return n, err
+}
+
 // 创建目录
-func (c *Client) Mkdir(path string) (*File, *http.Response, error) {
+func (c *Client) Mkdir(ctx context.Context, path string) (*File, *http.Response, error) {
 	opt := struct {
 		Path string `url:"path"`
 	}{
@@ -244,8 +492,11 @@ func (c *Client) Mkdir(path string) (*File, *http.Response, error) {
 	}
 
 	f := new(File)
-	resp, err := c.PostForm(u, nil, f)
+	resp, retried, err := c.postIdempotent(ctx, u, f)
 	if err != nil {
+		if f, ok := c.mkdirDone(ctx, path, retried, err); ok {
+			return f, resp, nil
+		}
 		return nil, resp, err
 	}
 
@@ -258,8 +509,13 @@ type FileMeta struct {
 	IfHasSubDir uint   `json:"ifhassubdir"`
 }
 
+// HasSubDir 报告目录是否包含子目录
+func (m *FileMeta) HasSubDir() bool {
+	return m.IfHasSubDir != 0
+}
+
 // 获取单个文件或目录的元信息。
-func (c *Client) GetMeta(path string) (*FileMeta, *http.Response, error) {
+func (c *Client) GetMeta(ctx context.Context, path string) (*FileMeta, *http.Response, error) {
 	opt := struct {
 		Path string `url:"path"`
 	}{
@@ -271,17 +527,22 @@ func (c *Client) GetMeta(path string) (*FileMeta, *http.Response, error) {
 		return nil, nil, err
 	}
 
-	f := new(FileMeta)
-	resp, err := c.PostForm(u, nil, f)
+	metas := struct {
+		List []*FileMeta `json:"list"`
+	}{}
+	resp, err := c.PostForm(idempotent(ctx), u, nil, &metas)
 	if err != nil {
 		return nil, resp, err
 	}
+	if len(metas.List) == 0 {
+		return nil, resp, ErrInvalidResponse
+	}
 
-	return f, resp, nil
+	return metas.List[0], resp, nil
 }
 
 // 批量获取文件/目录的元信息
-func (c *Client) BatchGetMeta(paths []string) ([]*FileMeta, *http.Response, error) {
+func (c *Client) BatchGetMeta(ctx context.Context, paths []string) ([]*FileMeta, *http.Response, error) {
 	if len(paths) == 0 {
 		return nil, nil, ErrInvalidArgument
 	}
@@ -295,7 +556,7 @@ func (c *Client) BatchGetMeta(paths []string) ([]*FileMeta, *http.Response, erro
 	pathMap := make([]map[string]string, len(paths))
 	for i, p := range paths {
 		pathMap[i] = map[string]string{
-			"path": p,
+			"path": c.remotePath(p),
 		}
 	}
 	paramMap["list"] = pathMap
@@ -310,7 +571,7 @@ func (c *Client) BatchGetMeta(paths []string) ([]*FileMeta, *http.Response, erro
 	metas := struct {
 		List []*FileMeta `json:"list"`
 	}{}
-	resp, err := c.PostForm(u, data, &metas)
+	resp, err := c.PostForm(idempotent(ctx), u, data, &metas)
 	if err != nil {
 		return nil, resp, err
 	}
@@ -334,12 +595,13 @@ type ListFilesOptions struct {
 	By string `url:"by,omitempty"`
 
 	// 返回条目控制，参数格式为：n1-n2。
-	// 返回结果集的[n1, n2)之间的条目，缺省返回所有条目；n1从0开始。
+	// 返回结果集的[n1, n2)之间的条目，n1从0开始。缺省时服务端最多返回前1000项，
+	// 更多的条目需要分页请求，见 Files。
 	Limit string `url:"limit,omitempty"`
 }
 
 // 获取目录下的文件列表
-func (c *Client) ListFiles(opt *ListFilesOptions) ([]*File, *http.Response, error) {
+func (c *Client) ListFiles(ctx context.Context, opt *ListFilesOptions) ([]*File, *http.Response, error) {
 	u, err := c.addOptions("file", "list", opt)
 	if err != nil {
 		return nil, nil, err
@@ -349,7 +611,7 @@ func (c *Client) ListFiles(opt *ListFilesOptions) ([]*File, *http.Response, erro
 		List []*File `json:"list"`
 	}{}
 
-	resp, err := c.Get(u, &files)
+	resp, err := c.Get(ctx, u, &files)
 	if err != nil {
 		return nil, resp, err
 	}
@@ -367,7 +629,7 @@ type MoveCopyResponse struct {
 }
 
 // 移动单个文件/目录
-func (c *Client) Move(from, to string) (*MoveCopyResponse, *http.Response, error) {
+func (c *Client) Move(ctx context.Context, from, to string) (*MoveCopyResponse, *http.Response, error) {
 	opt := struct {
 		From string `url:"from"`
 		To   string `url:"to"`
@@ -379,8 +641,11 @@ func (c *Client) Move(from, to string) (*MoveCopyResponse, *http.Response, error
 	}
 
 	m := new(MoveCopyResponse)
-	resp, err := c.PostForm(u, nil, m)
+	resp, retried, err := c.postIdempotent(ctx, u, m)
 	if err != nil {
+		if m, ok := c.moveCopyDone(ctx, true, from, to, retried, err); ok {
+			return m, resp, nil
+		}
 		return nil, resp, err
 	}
 
@@ -388,7 +653,7 @@ func (c *Client) Move(from, to string) (*MoveCopyResponse, *http.Response, error
 }
 
 // 拷贝单个文件/目录
-func (c *Client) Copy(from, to string) (*MoveCopyResponse, *http.Response, error) {
+func (c *Client) Copy(ctx context.Context, from, to string) (*MoveCopyResponse, *http.Response, error) {
 	opt := struct {
 		From string `url:"from"`
 		To   string `url:"to"`
@@ -400,8 +665,11 @@ func (c *Client) Copy(from, to string) (*MoveCopyResponse, *http.Response, error
 	}
 
 	m := new(MoveCopyResponse)
-	resp, err := c.PostForm(u, nil, m)
+	resp, retried, err := c.postIdempotent(ctx, u, m)
 	if err != nil {
+		if m, ok := c.moveCopyDone(ctx, false, from, to, retried, err); ok {
+			return m, resp, nil
+		}
 		return nil, resp, err
 	}
 
@@ -409,7 +677,7 @@ func (c *Client) Copy(from, to string) (*MoveCopyResponse, *http.Response, error
 }
 
 // 删除单个文件/目录
-func (c *Client) Delete(path string) (*http.Response, error) {
+func (c *Client) Delete(ctx context.Context, path string) (*http.Response, error) {
 	opt := struct {
 		Path string `url:"path"`
 	}{
@@ -421,10 +689,11 @@ func (c *Client) Delete(path string) (*http.Response, error) {
 		return nil, err
 	}
 
-	resp, err := c.PostForm(u, nil, nil)
-	if err != nil {
+	resp, retried, err := c.postIdempotent(ctx, u, nil)
+	if err != nil && !deleteDone(retried, err) {
 		return resp, err
 	}
+	c.publish(FileDeleted{Paths: []string{CleanPath(path)}})
 	return resp, nil
 }
 
@@ -433,16 +702,21 @@ type FTPair struct {
 	To   string `json:"to"`
 }
 
-func (c *Client) batchMoveCopyGeneric(method string, pairs []*FTPair) (*MoveCopyResponse, *http.Response, error) {
+func (c *Client) batchMoveCopyGeneric(ctx context.Context, method string, pairs []*FTPair) (*MoveCopyResponse, *http.Response, error) {
 	u, err := c.addOptions("file", method, nil)
 	if err != nil {
 		return nil, nil, err
 	}
 
+	cleaned := make([]*FTPair, len(pairs))
+	for i, p := range pairs {
+		cleaned[i] = &FTPair{From: c.remotePath(p.From), To: c.remotePath(p.To)}
+	}
+
 	tmp := struct {
 		List []*FTPair `json:"list"`
 	}{
-		List: pairs,
+		List: cleaned,
 	}
 	param, err := json.Marshal(&tmp)
 	if err != nil {
@@ -452,27 +726,23 @@ func (c *Client) batchMoveCopyGeneric(method string, pairs []*FTPair) (*MoveCopy
 	data := url.Values{}
 	data.Set("param", string(param))
 
-	v := new(MoveCopyResponse)
-	resp, err := c.PostForm(u, data, v)
-	if err != nil {
-		return nil, resp, err
-	}
-
-	return v, resp, nil
+	return postForm[MoveCopyResponse](ctx, c, u, data)
 }
 
 // 批量移动文件/目录
-func (c *Client) BatchMove(pairs []*FTPair) (*MoveCopyResponse, *http.Response, error) {
-	return c.batchMoveCopyGeneric("move", pairs)
+// 批量操作是单个请求，发出后无法中途停止；ctx 在请求完成前被取消时无法得知哪些条目
+// 已经完成，返回 ctx 的错误，调用方应通过 BatchGetMeta 核对。
+func (c *Client) BatchMove(ctx context.Context, pairs []*FTPair) (*MoveCopyResponse, *http.Response, error) {
+	return c.batchMoveCopyGeneric(ctx, "move", pairs)
 }
 
-// 批量拷贝文件/目录
-func (c *Client) BatchCopy(pairs []*FTPair) (*MoveCopyResponse, *http.Response, error) {
-	return c.batchMoveCopyGeneric("copy", pairs)
+// 批量拷贝文件/目录，见 BatchMove。
+func (c *Client) BatchCopy(ctx context.Context, pairs []*FTPair) (*MoveCopyResponse, *http.Response, error) {
+	return c.batchMoveCopyGeneric(ctx, "copy", pairs)
 }
 
-// 批量删除文件/目录
-func (c *Client) BatchDelete(paths []string) (*http.Response, error) {
+// 批量删除文件/目录，见 BatchMove。
+func (c *Client) BatchDelete(ctx context.Context, paths []string) (*http.Response, error) {
 	u, err := c.addOptions("file", "delete", nil)
 	if err != nil {
 		return nil, err
@@ -480,7 +750,7 @@ func (c *Client) BatchDelete(paths []string) (*http.Response, error) {
 	tmp := struct {
 		List []string `json:"list"`
 	}{
-		List: paths,
+		List: c.remotePaths(paths),
 	}
 	param, err := json.Marshal(&tmp)
 	if err != nil {
@@ -488,10 +758,11 @@ func (c *Client) BatchDelete(paths []string) (*http.Response, error) {
 	}
 	data := url.Values{}
 	data.Set("param", string(param))
-	resp, err := c.PostForm(u, data, nil)
+	resp, err := c.PostForm(ctx, u, data, nil)
 	if err != nil {
 		return resp, err
 	}
+	c.publish(FileDeleted{Paths: cleanPaths(paths)})
 
 	return resp, nil
 }
@@ -511,7 +782,7 @@ type SearchOptions struct {
 }
 
 // 按文件名搜索文件（不支持查找目录）。
-func (c *Client) Search(opt *SearchOptions) ([]*File, *http.Response, error) {
+func (c *Client) Search(ctx context.Context, opt *SearchOptions) ([]*File, *http.Response, error) {
 	u, err := c.addOptions("file", "search", opt)
 	if err != nil {
 		return nil, nil, err
@@ -521,7 +792,7 @@ func (c *Client) Search(opt *SearchOptions) ([]*File, *http.Response, error) {
 		List []*File `json:"list"`
 	}{}
 
-	resp, err := c.Get(u, &files)
+	resp, err := c.Get(ctx, u, &files)
 	if err != nil {
 		return nil, resp, err
 	}
@@ -546,13 +817,13 @@ type ThumbnailOptions struct {
 }
 
 //获取指定图片文件的缩略图
-func (c *Client) Thumbnail(opt *ThumbnailOptions) (*http.Response, error) {
+func (c *Client) Thumbnail(ctx context.Context, opt *ThumbnailOptions) (*http.Response, error) {
 	u, err := c.addOptions("thumbnail", "generate", opt)
 	if err != nil {
 		return nil, err
 	}
 
-	resp, err := c.Get(u, nil)
+	resp, err := c.Get(ctx, u, nil)
 	if err != nil {
 		return resp, err
 	}
@@ -564,7 +835,7 @@ func (c *Client) Thumbnail(opt *ThumbnailOptions) (*http.Response, error) {
 // cursor: 用于标记更新断点。
 //  - 首次调用cursor=null；
 //  - 非首次调用，使用最后一次调用diff接口的返回结果中的cursor。
-func (c *Client) Diff(cursor string) (*http.Response, error) {
+func (c *Client) Diff(ctx context.Context, cursor string) (*http.Response, error) {
 	opt := struct {
 		Cursor string `url:"cursor"`
 	}{
@@ -576,7 +847,7 @@ func (c *Client) Diff(cursor string) (*http.Response, error) {
 		return nil, err
 	}
 
-	resp, err := c.Get(u, nil)
+	resp, err := c.Get(ctx, u, nil)
 	if err != nil {
 		return resp, err
 	}
@@ -590,7 +861,10 @@ func (c *Client) Diff(cursor string) (*http.Response, error) {
 // path: 格式必须为m3u8,m3u,asf,avi,flv,gif,mkv,mov,mp4,m4a,3gp,3g2,mj2,mpeg,ts,rm,rmvb,webm
 // typ: 目前支持以下格式：
 //      M3U8_320_240、M3U8_480_224、M3U8_480_360、M3U8_640_480和M3U8_854_480
-func (c *Client) Streaming(path, typ string) (*http.Response, error) {
+func (c *Client) Streaming(ctx context.Context, path, typ string) (*http.Response, error) {
+	if !streamingTypes[typ] {
+		return nil, invalid("type", "unsupported streaming type %q", typ)
+	}
 	opt := struct {
 		Path string `url:"path"`
 		Type string `url:"type"`
@@ -600,7 +874,7 @@ func (c *Client) Streaming(path, typ string) (*http.Response, error) {
 		return nil, err
 	}
 
-	resp, err := c.Get(u, nil)
+	resp, err := c.Get(ctx, u, nil)
 	if err != nil {
 		return resp, err
 	}
@@ -630,23 +904,17 @@ type ListStreamOptions struct {
 }
 
 // 获取流式文件列表
-func (c *Client) ListStream(opt *ListStreamOptions) (*StreamFile, *http.Response, error) {
+func (c *Client) ListStream(ctx context.Context, opt *ListStreamOptions) (*StreamFile, *http.Response, error) {
 	u, err := c.addOptions("stream", "list", opt)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	sf := new(StreamFile)
-	resp, err := c.Get(u, sf)
-	if err != nil {
-		return nil, resp, err
-	}
-
-	return sf, resp, nil
+	return get[StreamFile](ctx, c, u)
 }
 
 // 下载流式文件
-func (c *Client) DownloadStream(path string) (*http.Response, error) {
+func (c *Client) DownloadStream(ctx context.Context, path string) (*http.Response, error) {
 	opt := struct {
 		Path string `url:"path"`
 	}{path}
@@ -656,55 +924,50 @@ func (c *Client) DownloadStream(path string) (*http.Response, error) {
 		return nil, err
 	}
 
-	resp, err := c.Get(u, nil)
+	req, err := c.NewDownloadRequest("GET", u, nil)
 	if err != nil {
-		return resp, err
+		return nil, err
 	}
 
-	// f, _ := os.Create("./test1.png")
-	// f.Write(data)
-	// f.Close()
-
-	//TODO: 需注意处理好 302 跳转问题。
+	resp, err := c.Do(ctx, req, nil)
+	if err != nil {
+		return resp, err
+	}
 
+	// 302 跳转由 http.Client 跟随，跳转到 PCS 其他域名时会保留 access_token。
 	return resp, nil
 }
 
 // 计算文件的各种值
+// 文件内容以流的方式依次经过 md5 与 crc32，不会整体读入内存；校验段为文件的前 256KB。
+// 通过 WithHashCache 设置了缓存时，未修改的文件直接使用缓存的值。
 func (c *Client) SumFile(path string) (contentLen int, contentMd5, sliceMd5 string, contentCrc32 uint32, err error) {
-	f, err := os.Open(path)
+	e, err := c.hashes.Sum(path)
 	if err != nil {
 		return 0, "", "", 0, err
 	}
+	return int(e.Size), e.Md5, e.SliceMd5, e.Crc32, nil
+}
+
+// sumReader 一次读取 r 的全部内容，计算 SumFile 返回的各项值。
+func sumReader(r io.Reader) (n int64, contentMd5, sliceMd5 string, contentCrc32 uint32, err error) {
+	contentHash := md5.New()
+	sliceHash := md5.New()
+	crc := crc32.NewIEEE()
+	w := io.MultiWriter(contentHash, crc)
 
-	buf := &bytes.Buffer{}
-	_, err = io.Copy(buf, f)
+	head, err := copyBuffered(io.MultiWriter(w, sliceHash), io.LimitReader(r, minRapidUploadFile))
 	if err != nil {
 		return 0, "", "", 0, err
 	}
-
-	// 1
-	contentLen = buf.Len()
-
-	// 2
-	h := md5.New()
-	h.Write(buf.Bytes())
-	contentMd5 = fmt.Sprintf("%x", h.Sum(nil))
-
-	// 3
-	contentCrc32 = crc32.ChecksumIEEE(buf.Bytes())
-
-	// 4
-	slice := make([]byte, minRapidUploadFile)
-	_, err = buf.Read(slice)
+	rest, err := copyBuffered(w, r)
 	if err != nil {
 		return 0, "", "", 0, err
 	}
-	h.Reset()
-	h.Write(slice)
-	sliceMd5 = fmt.Sprintf("%x", h.Sum(nil))
 
-	return contentLen, contentMd5, sliceMd5, contentCrc32, nil
+	contentMd5 = fmt.Sprintf("%x", contentHash.Sum(nil))
+	sliceMd5 = fmt.Sprintf("%x", sliceHash.Sum(nil))
+	return head + rest, contentMd5, sliceMd5, crc.Sum32(), nil
 }
 
 type RapiduUploadOptions struct {
@@ -723,13 +986,12 @@ type RapiduUploadOptions struct {
 	// 待秒传文件CRC32
 	ContentCrc32 string `url:"content-crc32"`
 
-	// overwrite：表示覆盖同名文件；
-	// newcopy：表示生成文件副本并进行重命名，命名规则为“文件名_日期.后缀”。
-	Ondup string `url:"ondup,omitempty"`
+	// 同名文件的处理方式，见 OnDup。
+	Ondup OnDup `url:"ondup,omitempty"`
 }
 
 // 秒传一个文件。
-func (c *Client) RapidUpload(opt *RapiduUploadOptions) (*File, *http.Response, error) {
+func (c *Client) RapidUpload(ctx context.Context, opt *RapiduUploadOptions) (*File, *http.Response, error) {
 	if opt.ContentLength <= minRapidUploadFile {
 		return nil, nil, ErrMinRapidFileSize
 	}
@@ -738,13 +1000,7 @@ func (c *Client) RapidUpload(opt *RapiduUploadOptions) (*File, *http.Response, e
 		return nil, nil, err
 	}
 
-	f := new(File)
-	resp, err := c.PostForm(u, nil, f)
-	if err != nil {
-		return nil, resp, err
-	}
-
-	return f, resp, nil
+	return postForm[File](ctx, c, u, nil)
 }
 
 type AddTaskOptions struct {
@@ -754,8 +1010,20 @@ type AddTaskOptions struct {
 	// 下载后的文件保存路径
 	SavePath string `url:"save_path"`
 
-	// 源文件的URL
-	SourceURL string `url:"source_url"`
+	// 源文件的URL，Type 为 TaskTypeTorrent 时不需要
+	SourceURL string `url:"source_url,omitempty"`
+
+	// 任务类型，见 TaskTypeURL 和 TaskTypeTorrent
+	Type int `url:"type,omitempty"`
+
+	// BT种子文件在网盘中的路径，Type 为 TaskTypeTorrent 时必需
+	SourcePath string `url:"source_path,omitempty"`
+
+	// 要下载的种子内文件的序号（从1开始），以逗号分隔，如：1,3；为空时下载全部文件
+	SelectedIdx string `url:"selected_idx,omitempty"`
+
+	// 种子的 sha1，见 QueryTorrentInfo
+	Sha1 string `url:"sha1,omitempty"`
 
 	// 下载限速，默认不限速
 	RateLimit int `url:"rate_limit,omitempty"`
@@ -768,8 +1036,8 @@ type AddTaskOptions struct {
 }
 
 // 添加离线下载任务
-func (c *Client) AddOfflineDownloadTask(opt *AddTaskOptions) (int64, *http.Response, error) {
-	u, err := c.addOptions("services/cloud_dl", "add_task", opt)
+func (c *Client) AddOfflineDownloadTask(ctx context.Context, opt *AddTaskOptions) (int64, *http.Response, error) {
+	u, err := c.addOptions("../services/cloud_dl", "add_task", opt)
 	if err != nil {
 		return 0, nil, err
 	}
@@ -778,7 +1046,7 @@ func (c *Client) AddOfflineDownloadTask(opt *AddTaskOptions) (int64, *http.Respo
 		TaskId int64 `json:"task_id"`
 	}{}
 
-	resp, err := c.PostForm(u, nil, &result)
+	resp, err := c.PostForm(ctx, u, nil, &result)
 	if err != nil {
 		return 0, resp, err
 	}
@@ -798,14 +1066,14 @@ type QueryTaskOptions struct {
 }
 
 // 精确查询离线下载任务
-func (c *Client) QueryOfflineDownloadTask(opt *QueryTaskOptions) (*http.Response, error) {
-	u, err := c.addOptions("service/cloud_dl", "query_task", opt)
+func (c *Client) QueryOfflineDownloadTask(ctx context.Context, opt *QueryTaskOptions) (*http.Response, error) {
+	u, err := c.addOptions("../services/cloud_dl", "query_task", opt)
 	if err != nil {
 		return nil, err
 	}
 
 	//TODO: handle response
-	resp, err := c.PostForm(u, nil, nil)
+	resp, err := c.PostForm(idempotent(ctx), u, nil, nil)
 	if err != nil {
 		return resp, err
 	}
@@ -846,13 +1114,13 @@ type ListTaskOptions struct {
 }
 
 // 查询离线下载任务列表
-func (c *Client) ListOfflineDownloadTask(opt *ListTaskOptions) (*http.Response, error) {
-	u, err := c.addOptions("service/cloud_dl", "list_task", opt)
+func (c *Client) ListOfflineDownloadTask(ctx context.Context, opt *ListTaskOptions) (*http.Response, error) {
+	u, err := c.addOptions("../services/cloud_dl", "list_task", opt)
 	if err != nil {
 		return nil, err
 	}
 
-	resp, err := c.PostForm(u, nil, nil)
+	resp, err := c.PostForm(idempotent(ctx), u, nil, nil)
 	if err != nil {
 		return resp, err
 	}
@@ -870,13 +1138,13 @@ type CancelTaskOptions struct {
 }
 
 // 取消离线下载任务
-func (c *Client) CancelOfflineDownloadTask(opt *CancelTaskOptions) (*http.Response, error) {
-	u, err := c.addOptions("service/cloud_dl", "cancel_task", opt)
+func (c *Client) CancelOfflineDownloadTask(ctx context.Context, opt *CancelTaskOptions) (*http.Response, error) {
+	u, err := c.addOptions("../services/cloud_dl", "cancel_task", opt)
 	if err != nil {
 		return nil, err
 	}
 
-	resp, err := c.PostForm(u, nil, nil)
+	resp, err := c.PostForm(ctx, u, nil, nil)
 	if err != nil {
 		return resp, err
 	}
@@ -899,19 +1167,13 @@ type ListRecycleResponse struct {
 }
 
 // 查询回收站文件,获取回收站中的文件及目录列表
-func (c *Client) ListRecycle(opt *ListRecycleOptions) (*ListRecycleResponse, *http.Response, error) {
+func (c *Client) ListRecycle(ctx context.Context, opt *ListRecycleOptions) (*ListRecycleResponse, *http.Response, error) {
 	u, err := c.addOptions("file", "listrecycle", opt)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	v := new(ListRecycleResponse)
-	resp, err := c.Get(u, v)
-	if err != nil {
-		return nil, resp, err
-	}
-
-	return v, resp, nil
+	return get[ListRecycleResponse](ctx, c, u)
 }
 
 type RestoreResponse struct {
@@ -924,7 +1186,7 @@ type RestoreResponse struct {
 
 // 还原单个文件或目录
 // fsId: 所还原的文件或目录在PCS的临时唯一标识ID
-func (c *Client) Restore(fsId string) (*RestoreResponse, *http.Response, error) {
+func (c *Client) Restore(ctx context.Context, fsId string) (*RestoreResponse, *http.Response, error) {
 	opt := struct {
 		FsId string `url:"fs_id"`
 	}{fsId}
@@ -934,17 +1196,11 @@ func (c *Client) Restore(fsId string) (*RestoreResponse, *http.Response, error)
 		return nil, nil, err
 	}
 
-	v := new(RestoreResponse)
-	resp, err := c.PostForm(u, nil, v)
-	if err != nil {
-		return nil, resp, err
-	}
-
-	return v, resp, nil
+	return postForm[RestoreResponse](ctx, c, u, nil)
 }
 
-// 批量还原文件或目录
-func (c *Client) BatchRestore(fsIds []string) (*RestoreResponse, *http.Response, error) {
+// 批量还原文件或目录，见 BatchMove。
+func (c *Client) BatchRestore(ctx context.Context, fsIds []string) (*RestoreResponse, *http.Response, error) {
 	u, err := c.addOptions("file", "restore", nil)
 	if err != nil {
 		return nil, nil, err
@@ -966,17 +1222,11 @@ func (c *Client) BatchRestore(fsIds []string) (*RestoreResponse, *http.Response,
 	d := url.Values{}
 	d.Set("param", string(param))
 
-	v := new(RestoreResponse)
-	resp, err := c.PostForm(u, d, v)
-	if err != nil {
-		return nil, resp, err
-	}
-
-	return v, resp, nil
+	return postForm[RestoreResponse](ctx, c, u, d)
 }
 
 // 清空回收站
-func (c *Client) EmptyRecycle() (*http.Response, error) {
+func (c *Client) EmptyRecycle(ctx context.Context) (*http.Response, error) {
 	opt := struct {
 		Type string `url:"type"`
 	}{"recycle"}
@@ -986,7 +1236,7 @@ func (c *Client) EmptyRecycle() (*http.Response, error) {
 		return nil, err
 	}
 
-	resp, err := c.PostForm(u, nil, nil)
+	resp, err := c.PostForm(idempotent(ctx), u, nil, nil)
 	if err != nil {
 		return resp, err
 	}
@@ -0,0 +1,40 @@
+package pcs
+
+import (
+	"context"
+	"io"
+)
+
+// AppendFile 将 r 中的数据追加到远程文件 remotePath 的末尾。新数据作为分片上传，
+// 再以文件原有的 block_list 加上新分片调用 CreateSuperFile 重建文件，已有内容不必重新上传。
+// remotePath 不存在时创建该文件。文件的分片总数不能超过 CreateSuperFile 的上限，
+// 因此频繁的小量追加应先在本地缓冲。设置了 Cipher 时不支持追加。
+func (c *Client) AppendFile(ctx context.Context, remotePath string, r io.Reader) (*File, error) {
+	if c.cipher != nil {
+		return nil, invalid("path", "cannot append to encrypted file %q", remotePath)
+	}
+
+	var blocks []string
+	meta, _, err := c.GetMeta(ctx, remotePath)
+	switch {
+	case isNotExist(err):
+		meta = nil
+	case err != nil:
+		return nil, err
+	case meta.File == nil || meta.IsDirectory():
+		return nil, invalid("path", "%q is not a file", remotePath)
+	case meta.Size > 0:
+		if blocks, err = meta.Blocks(); err != nil {
+			return nil, err
+		}
+		if len(blocks) == 0 {
+			return nil, ErrInvalidResponse
+		}
+	}
+
+	f, err := c.uploadStream(ctx, &FileOptions{Path: remotePath, OnDup: OnDupOverwrite}, blocks, meta == nil, r)
+	if f == nil && err == nil {
+		return meta.File, nil
+	}
+	return f, err
+}
@@ -0,0 +1,56 @@
+package pcs_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/holys/baidu-pcs/v2"
+	"github.com/holys/baidu-pcs/v2/pcstest"
+)
+
+func TestAppendFile(t *testing.T) {
+	ctx := context.Background()
+	srv := pcstest.NewServer()
+	defer srv.Close()
+	c := srv.NewClient(pcs.WithBlockSize(pcs.MinBlockSize))
+	big := bytes.Repeat([]byte("0123456789abcdef"), (pcs.MinBlockSize+100)/16)
+
+	want := []byte{}
+	for _, tt := range []struct {
+		data   []byte
+		blocks int
+	}{
+		{[]byte("hello "), 1}, // 文件不存在时创建
+		{[]byte("world"), 2},
+		{big, 4}, // 超过一个分片
+		{[]byte("!"), 5},
+		{nil, 5}, // 没有数据时不修改文件
+	} {
+		f, err := c.AppendFile(ctx, "/apps/t/log", bytes.NewReader(tt.data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want = append(want, tt.data...)
+		if f.Size != uint64(len(want)) {
+			t.Errorf("after appending %d bytes: size %d, want %d", len(tt.data), f.Size, len(want))
+		}
+		if data, _ := srv.ReadFile("/apps/t/log"); !bytes.Equal(data, want) {
+			t.Fatalf("after appending %d bytes the content differs (%d bytes, want %d)", len(tt.data), len(data), len(want))
+		}
+		meta, _, err := c.GetMeta(ctx, "/apps/t/log")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if blocks, err := meta.Blocks(); err != nil || len(blocks) != tt.blocks {
+			t.Errorf("after appending %d bytes: %d blocks, %v; want %d", len(tt.data), len(blocks), err, tt.blocks)
+		}
+	}
+
+	srv.Mkdir("/apps/t/dir")
+	if _, err := c.AppendFile(ctx, "/apps/t/dir", strings.NewReader("x")); !errors.Is(err, pcs.ErrInvalidArgument) {
+		t.Errorf("AppendFile to a directory: %v", err)
+	}
+}
@@ -0,0 +1,218 @@
+package pcs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 备份目录名中的时间格式，同时用作 BackupSet.Name
+const backupNameFormat = "20060102-150405.000"
+
+// BackupEntry 是备份清单中的一个文件
+type BackupEntry struct {
+	Path    string    `json:"path"` // 相对于备份根目录的路径，以 / 分隔
+	Size    int64     `json:"size"`
+	Md5     string    `json:"md5"` // 本地文件内容的md5
+	ModTime time.Time `json:"mod_time"`
+	Remote  string    `json:"remote"` // 保存文件内容的远程路径，可能位于之前某次备份的目录中
+}
+
+// BackupSet 是一次备份的清单：备份时本地目录中的全部文件，以及每个文件的内容保存在哪里。
+// 每次备份只上传变化了的文件，未变化的文件沿用之前备份中的副本，因此任何一个清单都可以
+// 单独用于恢复，见 RestoreBackup。
+type BackupSet struct {
+	Name   string        `json:"name"`   // 备份的目录名，即备份开始的时间（UTC），与已有备份同名时加上 _2、_3 等后缀
+	Time   time.Time     `json:"time"`   // 备份开始的时间
+	Cursor string        `json:"cursor"` // 备份完成时增量更新查询接口的 cursor，见 Changes
+	Files  []BackupEntry `json:"files"`
+}
+
+// Backup 将本地目录 localRoot 增量备份到远程目录 remoteRoot：prev 为上一次备份的清单，
+// 为 nil 时进行完整备份。与 prev 相比大小、修改时间或内容变化了的文件上传到
+// remoteRoot/<Name>/ 下，其余文件沿用 prev 中的副本。之前备份的副本或其所在目录在上次
+// 备份后被修改、移动或删除时（依据 prev.Cursor 之后的增量更新）重新上传。
+//
+// 清单同时保存为 remoteRoot/<Name>.json，可通过 BackupSets 和 ReadBackupSet 取得。
+// 符号链接等非普通文件被忽略。设置了 Cipher 时不支持。
+//
+// 开始上传后出错或 ctx 被取消时，返回该错误以及包含此前已备份文件的清单，此时清单没有保存到远端。
+func (c *Client) Backup(ctx context.Context, localRoot, remoteRoot string, prev *BackupSet) (*BackupSet, error) {
+	if c.cipher != nil {
+		return nil, invalid("path", "cannot back up into encrypted directory %q", remoteRoot)
+	}
+	if err := validateRemotePath("path", remoteRoot); err != nil {
+		return nil, err
+	}
+	remoteRoot = CleanPath(remoteRoot)
+
+	// 之前备份的副本是否仍然可用
+	reusable := make(map[string]BackupEntry)
+	byMd5 := make(map[string]BackupEntry)
+	var cursor string
+	if prev != nil {
+		changes, next, reset, err := c.changesSince(ctx, prev.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		cursor = next
+		if !reset {
+			stale := make(map[string]bool)
+			for _, e := range changes {
+				stale[e.Path] = true
+			}
+			for _, e := range prev.Files {
+				if !staleRemote(stale, e.Remote) {
+					reusable[e.Path] = e
+					byMd5[e.Md5] = e
+				}
+			}
+		}
+	} else {
+		// 只需要上传前的 cursor，之后的增量就是本次备份的上传
+		var err error
+		if cursor, err = c.latestCursor(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	locals, err := syncLocalFiles(ctx, localRoot, c.hashes, SymlinkSkip)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	name, err := c.backupName(ctx, remoteRoot, now)
+	if err != nil {
+		return nil, err
+	}
+	set := &BackupSet{Name: name, Time: now, Files: []BackupEntry{}}
+	for _, f := range locals {
+		e := BackupEntry{Path: f.rel, Size: f.info.Size(), ModTime: f.info.ModTime()}
+		if old, ok := reusable[f.rel]; ok && old.Size == e.Size && old.ModTime.Equal(e.ModTime) {
+			set.Files = append(set.Files, old)
+			continue
+		}
+		if e.Md5, err = f.sum(); err != nil {
+			return set, err
+		}
+		// 内容未变（只是修改时间变了）或与其他文件相同时沿用已有副本
+		if old, ok := byMd5[e.Md5]; ok && old.Size == e.Size {
+			e.Remote = old.Remote
+		} else {
+			e.Remote = path.Join(remoteRoot, set.Name, f.rel)
+			if _, err := c.syncUpload(ctx, f, e.Remote); err != nil {
+				return set, err
+			}
+			byMd5[e.Md5] = e
+		}
+		set.Files = append(set.Files, e)
+	}
+
+	// 取得包括本次上传在内的最新 cursor，下次备份只需检查此后的变化
+	if _, set.Cursor, _, err = c.changesSince(ctx, cursor); err != nil {
+		return set, err
+	}
+
+	data, err := json.MarshalIndent(set, "", "  ")
+	if err != nil {
+		return set, err
+	}
+	manifest := path.Join(remoteRoot, set.Name+".json")
+	_, err = c.uploadStream(ctx, &FileOptions{Path: manifest, OnDup: OnDupOverwrite}, nil, true, bytes.NewReader(data))
+	if err != nil {
+		return set, err
+	}
+	return set, nil
+}
+
+// staleRemote 判断远程路径 p 或它的某个上级目录是否在 stale 中。
+// 删除或移动目录时，增量更新只包含目录本身。
+func staleRemote(stale map[string]bool, p string) bool {
+	for ; ; p = path.Dir(p) {
+		if stale[p] {
+			return true
+		}
+		if p == "/" {
+			return false
+		}
+	}
+}
+
+// backupName 返回在时间 t 开始的备份的名字。远程目录 remoteRoot 中已有同名的备份
+// 或备份目录时（例如同一毫秒内的两次备份），依次加上 _2、_3 等后缀，
+// 避免后一次备份的清单覆盖前一次的。
+func (c *Client) backupName(ctx context.Context, remoteRoot string, t time.Time) (string, error) {
+	files, err := c.listDir(ctx, remoteRoot)
+	if err != nil && !isNotExist(err) {
+		return "", err
+	}
+	taken := make(map[string]bool, len(files))
+	for _, f := range files {
+		taken[strings.TrimSuffix(path.Base(f.Path), ".json")] = true
+	}
+	base := t.Format(backupNameFormat)
+	name := base
+	for i := 2; taken[name]; i++ {
+		name = fmt.Sprintf("%s_%d", base, i)
+	}
+	return name, nil
+}
+
+// isBackupName 判断 name 是否是 backupName 返回的名字
+func isBackupName(name string) bool {
+	if i := strings.LastIndexByte(name, '_'); i >= 0 {
+		if _, err := strconv.Atoi(name[i+1:]); err != nil {
+			return false
+		}
+		name = name[:i]
+	}
+	_, err := time.Parse(backupNameFormat, name)
+	return err == nil
+}
+
+// BackupSets 返回远程目录 remoteRoot 中全部备份的名字，按时间从早到晚排列。
+func (c *Client) BackupSets(ctx context.Context, remoteRoot string) ([]string, error) {
+	files, err := c.listDir(ctx, remoteRoot)
+	if isNotExist(err) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	for _, f := range files {
+		name, ok := strings.CutSuffix(path.Base(f.Path), ".json")
+		if !f.IsDirectory() && ok && isBackupName(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// ReadBackupSet 读取远程目录 remoteRoot 中名为 name 的备份的清单。
+func (c *Client) ReadBackupSet(ctx context.Context, remoteRoot, name string) (*BackupSet, error) {
+	var buf bytes.Buffer
+	if _, err := c.DownloadTo(ctx, path.Join(remoteRoot, name+".json"), &buf); err != nil {
+		return nil, err
+	}
+	return DecodeBackupSet(&buf)
+}
+
+// DecodeBackupSet 从 r 中读取 JSON 格式的备份清单，例如保存在本地的 BackupSet。
+func DecodeBackupSet(r io.Reader) (*BackupSet, error) {
+	set := new(BackupSet)
+	if err := json.NewDecoder(r).Decode(set); err != nil {
+		return nil, err
+	}
+	return set, nil
+}
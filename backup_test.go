@@ -0,0 +1,116 @@
+package pcs_test
+
+import (
+	"context"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/holys/baidu-pcs/v2"
+	"github.com/holys/baidu-pcs/v2/pcstest"
+)
+
+// writeFiles 在 dir 中创建以相对路径为键的文件
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for rel, data := range files {
+		p := filepath.Join(dir, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func remotes(set *pcs.BackupSet) map[string]string {
+	m := make(map[string]string)
+	for _, e := range set.Files {
+		m[e.Path] = e.Remote
+	}
+	return m
+}
+
+func TestBackupReusesCopies(t *testing.T) {
+	ctx := context.Background()
+	srv := pcstest.NewServer()
+	defer srv.Close()
+	c := srv.NewClient()
+	local := t.TempDir()
+	writeFiles(t, local, map[string]string{"a": "alpha", "d/b": "bravo", "e/c": "charlie"})
+
+	s1, err := c.Backup(ctx, local, "/apps/bk", nil)
+	if err != nil || len(s1.Files) != 3 {
+		t.Fatalf("first Backup = %+v, %v", s1, err)
+	}
+	r1 := remotes(s1)
+
+	s2, err := c.Backup(ctx, local, "/apps/bk", s1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s2.Name == s1.Name {
+		t.Errorf("two backups named %s", s1.Name)
+	}
+	for rel, remote := range remotes(s2) {
+		if remote != r1[rel] {
+			t.Errorf("unchanged %s uploaded again to %s", rel, remote)
+		}
+	}
+
+	// 删除目录时增量更新里只有目录本身
+	if _, err := c.Delete(ctx, path.Dir(r1["d/b"])); err != nil {
+		t.Fatal(err)
+	}
+	srv.PutFile(r1["e/c"], []byte("tampered"))
+	s3, err := c.Backup(ctx, local, "/apps/bk", s2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r3 := remotes(s3)
+	if r3["a"] != r1["a"] || r3["d/b"] == r1["d/b"] || r3["e/c"] == r1["e/c"] {
+		t.Errorf("third backup copies %v, first %v", r3, r1)
+	}
+	if data, ok := srv.ReadFile(r3["d/b"]); !ok || string(data) != "bravo" {
+		t.Errorf("%s = %q, %v", r3["d/b"], data, ok)
+	}
+
+	names, err := c.BackupSets(ctx, "/apps/bk")
+	if err != nil || len(names) != 3 || names[2] != s3.Name {
+		t.Errorf("BackupSets = %v, %v", names, err)
+	}
+	read, err := c.ReadBackupSet(ctx, "/apps/bk", s3.Name)
+	if err != nil || read.Cursor != s3.Cursor || len(read.Files) != 3 {
+		t.Errorf("ReadBackupSet = %+v, %v", read, err)
+	}
+}
+
+func TestBackupNameCollision(t *testing.T) {
+	ctx := context.Background()
+	srv := pcstest.NewServer()
+	defer srv.Close()
+	c := srv.NewClient()
+	local := t.TempDir()
+	writeFiles(t, local, map[string]string{"a": "alpha"})
+
+	// 占用接下来几秒内的全部名字
+	start := time.Now().UTC()
+	for d := time.Duration(0); d < 5*time.Second; d += time.Millisecond {
+		srv.Mkdir("/apps/bk/" + start.Add(d).Format("20060102-150405.000"))
+	}
+	set, err := c.Backup(ctx, local, "/apps/bk", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(set.Name, "_2") {
+		t.Errorf("backup named %s, want a _2 suffix", set.Name)
+	}
+	names, err := c.BackupSets(ctx, "/apps/bk")
+	if err != nil || len(names) != 1 || names[0] != set.Name {
+		t.Errorf("BackupSets = %v, %v", names, err)
+	}
+}
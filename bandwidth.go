@@ -0,0 +1,55 @@
+package pcs
+
+import (
+	"context"
+	"io"
+
+	"golang.org/x/time/rate"
+)
+
+// 每次从限速器申请的最大字节数，同时也是令牌桶的容量。
+// 较小的粒度使并发的各个传输轮流获得带宽，而不是由某一个独占。
+const bandwidthBurst = 64 << 10
+
+// SetBandwidthLimit 设置 Client 所有上传和下载共享的总带宽（字节/秒），
+// n 为0时不限速。正在进行的传输立即按新的限制执行。
+func (c *Client) SetBandwidthLimit(n int64) {
+	if n <= 0 {
+		c.bandwidth.SetLimit(rate.Inf)
+		return
+	}
+	c.bandwidth.SetLimit(rate.Limit(n))
+}
+
+// BandwidthLimit 返回当前的总带宽限制，0表示不限速。
+func (c *Client) BandwidthLimit() int64 {
+	l := c.bandwidth.Limit()
+	if l == rate.Inf {
+		return 0
+	}
+	return int64(l)
+}
+
+func newBandwidthLimiter() *rate.Limiter {
+	return rate.NewLimiter(rate.Inf, bandwidthBurst)
+}
+
+// throttledBody 按共享的限速器读取 body，用于请求体和响应体。
+type throttledBody struct {
+	ctx context.Context
+	lim *rate.Limiter
+	io.ReadCloser
+}
+
+func (b *throttledBody) Read(p []byte) (int, error) {
+	if len(p) > bandwidthBurst {
+		p = p[:bandwidthBurst]
+	}
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 && b.lim.Limit() != rate.Inf {
+		if werr := b.lim.WaitN(b.ctx, n); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,218 @@
+package pcs
+
+import (
+	"bufio"
+	"context"
+	"crypto/md5"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"runtime"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultBlockSize 分片上传时默认的分片大小
+	DefaultBlockSize = 4 << 20
+
+	// WithBlockSize 可以设置的分片大小范围。PCS 单次上传的数据不能超过2GB；
+	// 过小的分片则需要更多请求，也更快达到 CreateSuperFile 的分片数上限。
+	MinBlockSize = 1 << 20
+	MaxBlockSize = 2 << 30
+
+	// CreateSuperFile 接受的分片数量范围
+	minSuperFileBlocks = 2
+	maxSuperFileBlocks = 1024
+)
+
+// ValidateBlockSize 检查 size 是否在 MinBlockSize 和 MaxBlockSize 之间，
+// 不在时返回的错误满足 errors.Is(err, ErrInvalidArgument)。
+func ValidateBlockSize(size int64) error {
+	if size < MinBlockSize || size > MaxBlockSize {
+		return invalid("blockSize", "must be between %s and %s, got %d bytes",
+			HumanSize(MinBlockSize), HumanSize(MaxBlockSize), size)
+	}
+	return nil
+}
+
+// Block 描述分片上传中的一个分片
+type Block struct {
+	Index  int    // 分片序号，从0开始
+	Offset int64  // 分片在文件中的起始位置
+	Size   int64  // 分片大小
+	Md5    string // 分片内容的md5
+}
+
+// SumBlocks 将本地文件按 blockSize 切分，并计算每个分片的md5，结果按分片顺序返回。
+// 各分片并发计算，并发数不超过 GOMAXPROCS，使大文件的哈希计算不会成为上传前的瓶颈。
+func SumBlocks(ctx context.Context, path string, blockSize int64) ([]*Block, error) {
+	if blockSize <= 0 {
+		return nil, ErrInvalidArgument
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	blocks := splitBlocks(stat.Size(), blockSize)
+	if err := hashBlocks(ctx, f, blocks); err != nil {
+		return nil, err
+	}
+	return blocks, nil
+}
+
+func splitBlocks(size, blockSize int64) []*Block {
+	var blocks []*Block
+	for offset := int64(0); offset < size || len(blocks) == 0; offset += blockSize {
+		n := blockSize
+		if size-offset < n {
+			n = size - offset
+		}
+		blocks = append(blocks, &Block{Index: len(blocks), Offset: offset, Size: n})
+	}
+	return blocks
+}
+
+func hashBlocks(ctx context.Context, r io.ReaderAt, blocks []*Block) error {
+	workers := runtime.GOMAXPROCS(0)
+	if workers > len(blocks) {
+		workers = len(blocks)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	jobs := make(chan *Block)
+	errc := make(chan error, workers)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			h := md5.New()
+			for b := range jobs {
+				h.Reset()
+				if _, err := copyBuffered(h, io.NewSectionReader(r, b.Offset, b.Size)); err != nil {
+					errc <- err
+					cancel()
+					return
+				}
+				b.Md5 = fmt.Sprintf("%x", h.Sum(nil))
+			}
+		}()
+	}
+
+feed:
+	for _, b := range blocks {
+		select {
+		case jobs <- b:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	select {
+	case err := <-errc:
+		return err
+	default:
+	}
+	return ctx.Err()
+}
+
+// UploadReader 将 r 中的全部数据上传为 opt.Path，用于长度无法预先知道的数据，如标准输入或管道。
+// 数据按分片大小缓冲，逐个作为临时分片上传，r 读完后用 CreateSuperFile 合并；
+// 不足一个分片时直接上传，r 为空时创建空文件。内存中只保留正在上传的分片。
+// 分片数不能超过 CreateSuperFile 的上限，即数据最多为1024个分片。
+// 通过 WithEncryption 设置了 Cipher 时上传加密后的内容。
+func (c *Client) UploadReader(ctx context.Context, r io.Reader, opt *FileOptions) (*File, error) {
+	if opt == nil {
+		return nil, invalid("path", "is required")
+	}
+	if err := validateRemotePath("path", opt.Path); err != nil {
+		return nil, err
+	}
+	if c.cipher != nil {
+		cf, err := c.cipher.newFile()
+		if err != nil {
+			return nil, err
+		}
+		r = cf.encryptReader(r)
+	}
+	return c.uploadStream(ctx, opt, nil, true, r)
+}
+
+// uploadStream 将 r 中长度未知的数据按分片上传，与已有的分片 blocks 依次合并为文件 opt.Path。
+// 没有已有分片且全部数据不足一个分片时直接上传；r 为空时，create 为 true 则创建空文件，
+// 否则不做任何修改并返回 nil, nil。
+func (c *Client) uploadStream(ctx context.Context, opt *FileOptions, blocks []string, create bool, r io.Reader) (*File, error) {
+	name := path.Base(CleanPath(opt.Path))
+	blockSize := c.blockTuner.Size()
+	br := bufio.NewReader(r)
+	uploaded := 0
+	for last := false; !last; {
+		// 读入的分片和由它拼装的请求体同时占用内存
+		reserved, err := c.memory.acquire(ctx, 2*blockSize)
+		if err != nil {
+			return nil, err
+		}
+		buf := getBytesBuffer()
+		n, err := io.CopyN(buf, br, blockSize)
+		if err == nil {
+			_, err = br.Peek(1)
+		}
+		if err == io.EOF {
+			last, err = true, nil
+		}
+		if err != nil {
+			putBytesBuffer(buf)
+			c.memory.release(reserved)
+			return nil, err
+		}
+		if n == 0 && (!create || uploaded > 0) {
+			putBytesBuffer(buf)
+			c.memory.release(reserved)
+			break
+		}
+
+		body, contentType, err := multipartBody(name, buf, n)
+		putBytesBuffer(buf)
+		if err != nil {
+			c.memory.release(reserved)
+			return nil, err
+		}
+		body.release = func() { c.memory.release(reserved) }
+		if last && len(blocks) == 0 {
+			f, _, err := c.uploadFile(ctx, body, contentType, opt)
+			return f, err
+		}
+		if len(blocks) >= maxSuperFileBlocks {
+			body.Close()
+			return nil, invalid("path", "%q would exceed %d blocks", opt.Path, maxSuperFileBlocks)
+		}
+		start := time.Now()
+		tmp, _, err := c.uploadBlock(ctx, body, contentType)
+		c.blockTuner.Observe(n, time.Since(start), err)
+		if err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, tmp.Md5)
+		uploaded++
+	}
+
+	if uploaded == 0 {
+		return nil, nil
+	}
+	f, _, err := c.CreateSuperFile(ctx, opt.Path, blocks, opt)
+	return f, err
+}
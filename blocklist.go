@@ -0,0 +1,78 @@
+package pcs
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+)
+
+// Blocks 解析 BlockList，按顺序返回文件各分片的md5。目录和没有 block_list 的响应返回 nil。
+// 通过 CreateSuperFile 合并的文件每个分片一项，直接上传的文件只有一项。
+func (m *FileMeta) Blocks() ([]string, error) {
+	if m.BlockList == "" {
+		return nil, nil
+	}
+	var blocks []string
+	if err := json.Unmarshal([]byte(m.BlockList), &blocks); err != nil {
+		return nil, ErrInvalidResponse
+	}
+	return blocks, nil
+}
+
+// BlockRanges 返回文件的各个分片及其在文件中的位置，Md5 取自 BlockList。
+// 除最后一个分片外，各分片的大小都应为上传时使用的 blockSize；只有一个分片时 blockSize 不起作用。
+// 分片数与文件大小不符时（例如用 AppendFile 多次追加的文件）返回的错误满足 errors.Is(err, ErrInvalidArgument)。
+func (m *FileMeta) BlockRanges(blockSize int64) ([]*Block, error) {
+	if m.File == nil || m.IsDirectory() {
+		return nil, invalid("path", "not a file")
+	}
+	if blockSize <= 0 {
+		return nil, invalid("blockSize", "must be positive, got %d", blockSize)
+	}
+	md5s, err := m.Blocks()
+	if err != nil {
+		return nil, err
+	}
+	if len(md5s) == 0 {
+		return nil, ErrInvalidResponse
+	}
+
+	size := int64(m.Size)
+	if len(md5s) == 1 {
+		blockSize = max(size, 1)
+	}
+	n := int64(len(md5s))
+	if size > n*blockSize || size <= (n-1)*blockSize && n > 1 {
+		return nil, invalid("blockSize", "%d blocks of %d bytes do not make up %d bytes", n, blockSize, size)
+	}
+
+	blocks := splitBlocks(size, blockSize)
+	for i, b := range blocks {
+		b.Md5 = strings.ToLower(md5s[i])
+	}
+	return blocks, nil
+}
+
+// VerifyBlocks 计算 r 中各分片的md5并与 blocks 比较，返回内容不一致的分片，全部一致时返回空。
+// blocks 通常来自 BlockRanges，r 为本地副本，从而只需重新下载或上传不一致的部分。blocks 不会被修改。
+func VerifyBlocks(ctx context.Context, r io.ReaderAt, blocks []*Block) ([]*Block, error) {
+	if len(blocks) == 0 {
+		return nil, nil
+	}
+	sums := make([]*Block, len(blocks))
+	for i, b := range blocks {
+		sums[i] = &Block{Index: b.Index, Offset: b.Offset, Size: b.Size}
+	}
+	if err := hashBlocks(ctx, r, sums); err != nil {
+		return nil, err
+	}
+
+	var bad []*Block
+	for i, b := range blocks {
+		if !strings.EqualFold(sums[i].Md5, b.Md5) {
+			bad = append(bad, b)
+		}
+	}
+	return bad, nil
+}
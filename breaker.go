@@ -0,0 +1,143 @@
+package pcs
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// BreakerState 是熔断器的状态
+type BreakerState int
+
+const (
+	BreakerClosed   BreakerState = iota // 正常发送请求
+	BreakerOpen                         // 冷却中，请求直接以 ErrCircuitOpen 失败
+	BreakerHalfOpen                     // 冷却结束，放行一个试探请求
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// breakers 为每个主机维护一个熔断器：连续 threshold 次失败（无法连接或 5xx）后打开，
+// 在 cooldown 内发往该主机的请求直接失败；冷却结束后放行一个试探请求，
+// 成功则恢复，失败则再次打开。
+type breakers struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu    sync.Mutex
+	hosts map[string]*breaker
+}
+
+type breaker struct {
+	failures int
+	openedAt time.Time // 为零值时未打开
+	probing  bool      // 半开状态下已放行试探请求
+}
+
+func newBreakers(threshold int, cooldown time.Duration) *breakers {
+	return &breakers{threshold: threshold, cooldown: cooldown, hosts: make(map[string]*breaker)}
+}
+
+func (bs *breakers) state(host string) BreakerState {
+	b, ok := bs.hosts[host]
+	switch {
+	case !ok || b.openedAt.IsZero():
+		return BreakerClosed
+	case time.Since(b.openedAt) < bs.cooldown:
+		return BreakerOpen
+	}
+	return BreakerHalfOpen
+}
+
+// allow 报告是否可以向 host 发送请求，以及该请求是否为半开状态下的试探请求
+func (bs *breakers) allow(host string) (ok, probe bool) {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	switch bs.state(host) {
+	case BreakerOpen:
+		return false, false
+	case BreakerHalfOpen:
+		b := bs.hosts[host]
+		if b.probing {
+			return false, false
+		}
+		b.probing = true
+		return true, true
+	}
+	return true, false
+}
+
+// record 记录一次发往 host 的请求的结果
+func (bs *breakers) record(host string, ok bool) {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	b := bs.hosts[host]
+	if b == nil {
+		if ok {
+			return
+		}
+		b = &breaker{}
+		bs.hosts[host] = b
+	}
+	if ok {
+		delete(bs.hosts, host)
+		return
+	}
+	b.failures++
+	b.probing = false
+	if b.failures >= bs.threshold || !b.openedAt.IsZero() {
+		b.openedAt = time.Now()
+	}
+}
+
+// abandon 结束一次没有结果的试探请求，如被调用方取消，使下一个请求可以重新试探
+func (bs *breakers) abandon(host string) {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	if b := bs.hosts[host]; b != nil {
+		b.probing = false
+	}
+}
+
+// BreakerState 返回主机 host（如 "d.pcs.baidu.com"）的熔断器状态，
+// 未通过 WithCircuitBreaker 启用熔断时总是 BreakerClosed。
+func (c *Client) BreakerState(host string) BreakerState {
+	if c.breakers == nil {
+		return BreakerClosed
+	}
+	c.breakers.mu.Lock()
+	defer c.breakers.mu.Unlock()
+	return c.breakers.state(host)
+}
+
+// send 经熔断器发送一次请求
+func (c *Client) send(req *http.Request) (*http.Response, error) {
+	if c.breakers == nil {
+		return c.client.Do(req)
+	}
+	host := req.URL.Host
+	ok, probe := c.breakers.allow(host)
+	if !ok {
+		closeBody(req)
+		return nil, ErrCircuitOpen
+	}
+	resp, err := c.client.Do(req)
+	switch {
+	case req.Context().Err() == nil:
+		c.breakers.record(host, err == nil && resp.StatusCode < 500)
+	case probe:
+		// 取消的请求不说明主机是否正常，不计入成败
+		c.breakers.abandon(host)
+	}
+	return resp, err
+}
@@ -0,0 +1,113 @@
+package pcs
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBreakerStateMachine(t *testing.T) {
+	const host = "pcs.example"
+	bs := newBreakers(2, 20*time.Millisecond)
+	state := func() BreakerState {
+		bs.mu.Lock()
+		defer bs.mu.Unlock()
+		return bs.state(host)
+	}
+
+	bs.record(host, false)
+	if s := state(); s != BreakerClosed {
+		t.Fatalf("after one failure: %v, want closed", s)
+	}
+	bs.record(host, true)
+	bs.record(host, false)
+	if s := state(); s != BreakerClosed {
+		t.Fatalf("a success must reset the failure count: %v", s)
+	}
+	bs.record(host, false)
+	if s := state(); s != BreakerOpen {
+		t.Fatalf("after two failures: %v, want open", s)
+	}
+	if ok, _ := bs.allow(host); ok {
+		t.Fatal("open breaker allowed a request")
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if s := state(); s != BreakerHalfOpen {
+		t.Fatalf("after cooldown: %v, want half-open", s)
+	}
+	if ok, probe := bs.allow(host); !ok || !probe {
+		t.Fatalf("allow = %v, %v; want the probe", ok, probe)
+	}
+	if ok, _ := bs.allow(host); ok {
+		t.Fatal("second request allowed while probing")
+	}
+	bs.record(host, false)
+	if s := state(); s != BreakerOpen {
+		t.Fatalf("after a failed probe: %v, want open", s)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if ok, probe := bs.allow(host); !ok || !probe {
+		t.Fatalf("allow = %v, %v; want the probe", ok, probe)
+	}
+	bs.record(host, true)
+	if s := state(); s != BreakerClosed {
+		t.Fatalf("after a successful probe: %v, want closed", s)
+	}
+	if ok, probe := bs.allow(host); !ok || probe {
+		t.Fatalf("allow = %v, %v; want an ordinary request", ok, probe)
+	}
+}
+
+func TestBreakerCancelledProbe(t *testing.T) {
+	var fail atomic.Bool
+	fail.Store(true)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if r.URL.Query().Get("block") != "" {
+			<-r.Context().Done()
+			return
+		}
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+	c := NewClient("", WithCircuitBreaker(1, 20*time.Millisecond))
+
+	send := func(ctx context.Context, query string) error {
+		req, _ := http.NewRequestWithContext(ctx, "GET", srv.URL+"/?"+query, nil)
+		resp, err := c.send(req)
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+	send(context.Background(), "")
+	if s := c.BreakerState(u.Host); s != BreakerOpen {
+		t.Fatalf("after a 503: %v, want open", s)
+	}
+	fail.Store(false)
+	time.Sleep(30 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := send(ctx, "block=1"); err == nil {
+		t.Fatal("blocked probe succeeded")
+	}
+	if s := c.BreakerState(u.Host); s != BreakerHalfOpen {
+		t.Fatalf("after a cancelled probe: %v, want half-open", s)
+	}
+	if err := send(context.Background(), ""); err != nil {
+		t.Fatalf("request after a cancelled probe: %v", err)
+	}
+	if s := c.BreakerState(u.Host); s != BreakerClosed {
+		t.Fatalf("after a successful probe: %v, want closed", s)
+	}
+}
@@ -0,0 +1,98 @@
+package pcs
+
+import (
+	"bytes"
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	defaultCopyBufferSize     = 32 << 10
+	defaultMaxPooledBufferCap = 8 << 20
+)
+
+var (
+	copyBufferSize     atomic.Int64
+	maxPooledBufferCap atomic.Int64
+
+	copyBufferPool  sync.Pool // *[]byte
+	bytesBufferPool = sync.Pool{
+		New: func() interface{} { return new(bytes.Buffer) },
+	}
+)
+
+func init() {
+	copyBufferSize.Store(defaultCopyBufferSize)
+	maxPooledBufferCap.Store(defaultMaxPooledBufferCap)
+}
+
+// SetCopyBufferSize 设置上传、下载和哈希计算时复制数据所用缓冲区的大小，默认为32KB。
+// 缓冲区在各个传输之间通过 sync.Pool 复用；内存受限的设备（路由器、NAS）可适当调小。
+func SetCopyBufferSize(n int) {
+	if n > 0 {
+		copyBufferSize.Store(int64(n))
+	}
+}
+
+// SetMaxPooledBufferSize 设置可归还到缓冲池的 bytes.Buffer 的最大容量，默认为8MB。
+// 超过该容量的缓冲区（例如拼装大文件上传请求时产生的）用完即丢弃，不在池中长期占用内存。
+// n 为0时不再复用任何 bytes.Buffer。
+func SetMaxPooledBufferSize(n int) {
+	if n >= 0 {
+		maxPooledBufferCap.Store(int64(n))
+	}
+}
+
+func getCopyBuffer() *[]byte {
+	size := int(copyBufferSize.Load())
+	if bp, ok := copyBufferPool.Get().(*[]byte); ok && len(*bp) == size {
+		return bp
+	}
+	b := make([]byte, size)
+	return &b
+}
+
+func putCopyBuffer(bp *[]byte) {
+	if len(*bp) == int(copyBufferSize.Load()) {
+		copyBufferPool.Put(bp)
+	}
+}
+
+// copyBuffered 与 io.Copy 相同，但使用池中的缓冲区。
+func copyBuffered(dst io.Writer, src io.Reader) (int64, error) {
+	bp := getCopyBuffer()
+	defer putCopyBuffer(bp)
+	return io.CopyBuffer(dst, src, *bp)
+}
+
+func getBytesBuffer() *bytes.Buffer {
+	buf := bytesBufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	return buf
+}
+
+func putBytesBuffer(buf *bytes.Buffer) {
+	if int64(buf.Cap()) <= maxPooledBufferCap.Load() {
+		bytesBufferPool.Put(buf)
+	}
+}
+
+// pooledBody 是使用池中缓冲区的请求体，Close 时将缓冲区归还。
+// http.Transport 保证在请求体不再使用后调用 Close。
+type pooledBody struct {
+	*bytes.Buffer
+	once    sync.Once
+	release func() // 归还预留的内存预算，见 bufferedBody
+	md5     string // 表单中文件内容的md5，见 multipartBody
+}
+
+func (b *pooledBody) Close() error {
+	b.once.Do(func() {
+		putBytesBuffer(b.Buffer)
+		if b.release != nil {
+			b.release()
+		}
+	})
+	return nil
+}
@@ -0,0 +1,216 @@
+package pcs
+
+import (
+	"container/list"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DownloadCache 是以远程文件 md5 为键的本地下载缓存，内容相同的文件只需下载一次。
+// 缓存总大小超过上限时按最近最少使用的顺序删除文件；使用时间记录在文件的修改时间中，
+// 因此重新打开同一目录后淘汰顺序保持不变。
+//
+// 通过 WithDownloadCache 设置到 Client 后，DownloadTo 和 TransferManager 的下载
+// 优先从缓存读取。启用了 WithEncryption 时缓存的是远端的密文。可被多个 goroutine 并发使用。
+type DownloadCache struct {
+	dir string
+	max int64
+
+	mu    sync.Mutex
+	size  int64
+	lru   *list.List // 最近使用的在前
+	items map[string]*list.Element
+}
+
+type cacheItem struct {
+	key  string
+	size int64
+}
+
+// NewDownloadCache 创建使用目录 dir、总大小不超过 maxSize 字节的缓存，并载入目录中已有的文件。
+func NewDownloadCache(dir string, maxSize int64) (*DownloadCache, error) {
+	if maxSize <= 0 {
+		return nil, invalid("maxSize", "must be positive")
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	type found struct {
+		key   string
+		size  int64
+		mtime time.Time
+	}
+	var files []found
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), "tmp-") {
+			// 上次未完成的写入
+			os.Remove(filepath.Join(dir, e.Name()))
+			continue
+		}
+		if !e.Type().IsRegular() || !validCacheKey(e.Name()) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, found{e.Name(), info.Size(), info.ModTime()})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].mtime.After(files[j].mtime) })
+
+	dc := &DownloadCache{dir: dir, max: maxSize, lru: list.New(), items: make(map[string]*list.Element)}
+	for _, f := range files {
+		dc.items[f.key] = dc.lru.PushBack(&cacheItem{key: f.key, size: f.size})
+		dc.size += f.size
+	}
+	dc.mu.Lock()
+	dc.evict()
+	dc.mu.Unlock()
+	return dc, nil
+}
+
+// validCacheKey 检查 key 是否为 md5 的十六进制形式，避免拼接出缓存目录之外的路径。
+func validCacheKey(key string) bool {
+	if len(key) != 32 {
+		return false
+	}
+	_, err := hex.DecodeString(key)
+	return err == nil
+}
+
+// Size 返回缓存中全部文件的总大小。
+func (dc *DownloadCache) Size() int64 {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	return dc.size
+}
+
+func (dc *DownloadCache) contains(md5 string) bool {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	_, ok := dc.items[md5]
+	return ok
+}
+
+// Open 打开 md5 对应的缓存文件，未命中时返回 nil 和 false。
+func (dc *DownloadCache) Open(md5 string) (*os.File, bool) {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	el, ok := dc.items[md5]
+	if !ok {
+		return nil, false
+	}
+	f, err := os.Open(filepath.Join(dc.dir, md5))
+	if err != nil {
+		dc.remove(el)
+		return nil, false
+	}
+	dc.lru.MoveToFront(el)
+	now := time.Now()
+	os.Chtimes(f.Name(), now, now)
+	return f, true
+}
+
+// Add 将 r 的全部内容以 md5 为键加入缓存，大于缓存上限的内容不会加入。
+func (dc *DownloadCache) Add(md5 string, r io.Reader) error {
+	w, err := dc.create(md5)
+	if err != nil {
+		return err
+	}
+	if _, err := copyBuffered(w, r); err != nil {
+		w.abort()
+		return err
+	}
+	return w.commit()
+}
+
+// create 返回写入 md5 对应缓存文件的 cacheWriter，写入的内容在 commit 后才可见。
+func (dc *DownloadCache) create(md5 string) (*cacheWriter, error) {
+	if !validCacheKey(md5) {
+		return nil, invalid("md5", "%q is not a hex md5", md5)
+	}
+	f, err := os.CreateTemp(dc.dir, "tmp-*")
+	if err != nil {
+		return nil, err
+	}
+	return &cacheWriter{dc: dc, key: md5, f: f}, nil
+}
+
+func (dc *DownloadCache) add(key string, size int64) {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	if el, ok := dc.items[key]; ok {
+		dc.size -= el.Value.(*cacheItem).size
+		dc.lru.Remove(el)
+	}
+	dc.items[key] = dc.lru.PushFront(&cacheItem{key: key, size: size})
+	dc.size += size
+	dc.evict()
+}
+
+// evict 删除最近最少使用的文件直到总大小不超过上限，调用时需持有 dc.mu。
+func (dc *DownloadCache) evict() {
+	for dc.size > dc.max && dc.lru.Len() > 0 {
+		el := dc.lru.Back()
+		os.Remove(filepath.Join(dc.dir, el.Value.(*cacheItem).key))
+		dc.remove(el)
+	}
+}
+
+func (dc *DownloadCache) remove(el *list.Element) {
+	item := el.Value.(*cacheItem)
+	dc.lru.Remove(el)
+	delete(dc.items, item.key)
+	dc.size -= item.size
+}
+
+type cacheWriter struct {
+	dc  *DownloadCache
+	key string
+	f   *os.File
+	n   int64
+	err error
+}
+
+// Write 写入缓存文件；出错后不再写入，但仍返回成功，以免缓存的错误中断下载本身。
+func (w *cacheWriter) Write(p []byte) (int, error) {
+	if w.err == nil {
+		var n int
+		n, w.err = w.f.Write(p)
+		w.n += int64(n)
+	}
+	return len(p), nil
+}
+
+func (w *cacheWriter) commit() error {
+	err := w.f.Close()
+	if w.err != nil {
+		err = w.err
+	}
+	if err != nil || w.n > w.dc.max {
+		os.Remove(w.f.Name())
+		return err
+	}
+	if err := os.Rename(w.f.Name(), filepath.Join(w.dc.dir, w.key)); err != nil {
+		os.Remove(w.f.Name())
+		return err
+	}
+	w.dc.add(w.key, w.n)
+	return nil
+}
+
+func (w *cacheWriter) abort() {
+	w.f.Close()
+	os.Remove(w.f.Name())
+}
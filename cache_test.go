@@ -0,0 +1,117 @@
+package pcs_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/holys/baidu-pcs/v2"
+	"github.com/holys/baidu-pcs/v2/pcstest"
+)
+
+func md5sum(s string) string {
+	sum := md5.Sum([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestDownloadCacheFollowsRemoteWrites(t *testing.T) {
+	ctx := context.Background()
+	srv := pcstest.NewServer()
+	defer srv.Close()
+	dir := t.TempDir()
+	dc, err := pcs.NewDownloadCache(dir, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := srv.NewClient(pcs.WithDownloadCache(dc))
+	srv.PutFile("/apps/t/a", []byte("v1"))
+
+	download := func() string {
+		t.Helper()
+		var buf bytes.Buffer
+		if _, err := c.DownloadTo(ctx, "/apps/t/a", &buf); err != nil {
+			t.Fatal(err)
+		}
+		return buf.String()
+	}
+	if got := download(); got != "v1" || dc.Size() != 2 {
+		t.Fatalf("first download = %q, cache size %d", got, dc.Size())
+	}
+
+	// 改写缓存文件，确认第二次下载读的是缓存
+	if err := os.WriteFile(filepath.Join(dir, md5sum("v1")), []byte("c1"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if got := download(); got != "c1" {
+		t.Errorf("second download = %q, want it from the cache", got)
+	}
+
+	// 远程文件被改写后 md5 改变，旧的缓存不再命中
+	srv.PutFile("/apps/t/a", []byte("v2"))
+	if got := download(); got != "v2" {
+		t.Errorf("download after the write = %q, want v2", got)
+	}
+	if f, ok := dc.Open(md5sum("v2")); !ok {
+		t.Error("new content not cached")
+	} else {
+		f.Close()
+	}
+}
+
+func TestDownloadCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	dir := t.TempDir()
+	dc, err := pcs.NewDownloadCache(dir, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	add := func(s string) {
+		t.Helper()
+		if err := dc.Add(md5sum(s), strings.NewReader(s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cached := func(s string) bool {
+		f, ok := dc.Open(md5sum(s))
+		if ok {
+			f.Close()
+		}
+		return ok
+	}
+
+	add("aaaa")
+	add("bbbb")
+	cached("aaaa") // 使 bbbb 成为最久未使用的
+	add("cccc")
+	if !cached("aaaa") || cached("bbbb") || !cached("cccc") || dc.Size() != 8 {
+		t.Errorf("after eviction: aaaa %v, bbbb %v, cccc %v, size %d",
+			cached("aaaa"), cached("bbbb"), cached("cccc"), dc.Size())
+	}
+	if _, err := os.Stat(filepath.Join(dir, md5sum("bbbb"))); !os.IsNotExist(err) {
+		t.Errorf("evicted file still on disk: %v", err)
+	}
+
+	add("this is longer than the cache")
+	if cached("this is longer than the cache") || dc.Size() != 8 {
+		t.Errorf("oversized content cached, size %d", dc.Size())
+	}
+
+	// 重新打开后按文件的使用时间恢复淘汰顺序
+	cached("aaaa")
+	dc, err = pcs.NewDownloadCache(dir, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	add("dddd")
+	if !cached("aaaa") || cached("cccc") || !cached("dddd") {
+		t.Errorf("after reopening: aaaa %v, cccc %v, dddd %v", cached("aaaa"), cached("cccc"), cached("dddd"))
+	}
+
+	if err := dc.Add("../escape", strings.NewReader("x")); err == nil {
+		t.Error("Add accepted a key that is not an md5")
+	}
+}
@@ -0,0 +1,114 @@
+package pcs
+
+import (
+	"mime"
+	"path"
+	"strings"
+)
+
+// Category 是按扩展名划分的文件类别，前四种与 ListStream 的 type 参数一致
+type Category int
+
+const (
+	CategoryOther Category = iota
+	CategoryVideo
+	CategoryAudio
+	CategoryImage
+	CategoryDoc
+	CategoryArchive
+)
+
+func (c Category) String() string {
+	switch c {
+	case CategoryVideo:
+		return "video"
+	case CategoryAudio:
+		return "audio"
+	case CategoryImage:
+		return "image"
+	case CategoryDoc:
+		return "doc"
+	case CategoryArchive:
+		return "archive"
+	}
+	return "other"
+}
+
+// mime 类型无法区分的常见扩展名
+var categoryExtensions = map[string]Category{
+	".mkv": CategoryVideo, ".rmvb": CategoryVideo, ".flv": CategoryVideo, ".ts": CategoryVideo,
+	".m4v": CategoryVideo, ".wmv": CategoryVideo, ".3gp": CategoryVideo,
+	".flac": CategoryAudio, ".ape": CategoryAudio, ".m4a": CategoryAudio, ".aac": CategoryAudio,
+	".wma": CategoryAudio, ".ogg": CategoryAudio,
+	".heic": CategoryImage, ".webp": CategoryImage, ".raw": CategoryImage, ".cr2": CategoryImage,
+	".nef": CategoryImage, ".psd": CategoryImage,
+	".pdf": CategoryDoc, ".txt": CategoryDoc, ".md": CategoryDoc, ".doc": CategoryDoc,
+	".docx": CategoryDoc, ".xls": CategoryDoc, ".xlsx": CategoryDoc, ".ppt": CategoryDoc,
+	".pptx": CategoryDoc, ".wps": CategoryDoc, ".epub": CategoryDoc, ".csv": CategoryDoc,
+	".zip": CategoryArchive, ".rar": CategoryArchive, ".7z": CategoryArchive, ".tar": CategoryArchive,
+	".gz": CategoryArchive, ".tgz": CategoryArchive, ".bz2": CategoryArchive, ".xz": CategoryArchive,
+	".zst": CategoryArchive, ".iso": CategoryArchive,
+}
+
+// CategoryOf 按扩展名判断文件名 name 的类别，未知的扩展名再按其 MIME 类型判断。
+func CategoryOf(name string) Category {
+	ext := strings.ToLower(path.Ext(name))
+	if ext == "" {
+		return CategoryOther
+	}
+	if c, ok := categoryExtensions[ext]; ok {
+		return c
+	}
+	t, _, _ := strings.Cut(mime.TypeByExtension(ext), ";")
+	switch {
+	case strings.HasPrefix(t, "video/"):
+		return CategoryVideo
+	case strings.HasPrefix(t, "audio/"):
+		return CategoryAudio
+	case strings.HasPrefix(t, "image/"):
+		return CategoryImage
+	case strings.HasPrefix(t, "text/"):
+		return CategoryDoc
+	}
+	return CategoryOther
+}
+
+// Category 返回文件的类别，目录总是 CategoryOther。
+func (f *File) Category() Category {
+	if f.IsDirectory() {
+		return CategoryOther
+	}
+	return CategoryOf(f.Path)
+}
+
+// FilterCategory 返回 files 中属于 categories 之一的文件，保持原有顺序。
+func FilterCategory(files []*File, categories ...Category) []*File {
+	var out []*File
+	for _, f := range files {
+		if !f.IsDirectory() && hasCategory(categories, f.Category()) {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// GroupByCategory 将 files 中的文件按类别分组，各组保持原有顺序，目录被忽略。
+func GroupByCategory(files []*File) map[Category][]*File {
+	groups := make(map[Category][]*File)
+	for _, f := range files {
+		if !f.IsDirectory() {
+			c := f.Category()
+			groups[c] = append(groups[c], f)
+		}
+	}
+	return groups
+}
+
+func hasCategory(categories []Category, c Category) bool {
+	for _, v := range categories {
+		if v == c {
+			return true
+		}
+	}
+	return false
+}
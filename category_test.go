@@ -0,0 +1,56 @@
+package pcs_test
+
+import (
+	"testing"
+
+	"github.com/holys/baidu-pcs/v2"
+)
+
+func TestCategoryOf(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		want pcs.Category
+	}{
+		{"movie.MKV", pcs.CategoryVideo},
+		{"song.flac", pcs.CategoryAudio},
+		{"/apps/t/IMG_0001.HEIC", pcs.CategoryImage},
+		{"report.docx", pcs.CategoryDoc},
+		{"backup.tar.gz", pcs.CategoryArchive},
+		// 不在扩展名表中时按 MIME 类型判断
+		{"photo.jpg", pcs.CategoryImage},
+		{"page.html", pcs.CategoryDoc},
+		{"Makefile", pcs.CategoryOther},
+		{"data.unknownext", pcs.CategoryOther},
+	} {
+		if got := pcs.CategoryOf(tt.name); got != tt.want {
+			t.Errorf("CategoryOf(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFilterAndGroupByCategory(t *testing.T) {
+	files := []*pcs.File{
+		{Path: "/a.m4a"},
+		{Path: "/b.png"},
+		{Path: "/photos.png", IsDir: 1},
+		{Path: "/c.flac"},
+		{Path: "/d"},
+	}
+
+	audio := pcs.FilterCategory(files, pcs.CategoryAudio)
+	if len(audio) != 2 || audio[0].Path != "/a.m4a" || audio[1].Path != "/c.flac" {
+		t.Errorf("FilterCategory(audio) = %v", audio)
+	}
+	if images := pcs.FilterCategory(files, pcs.CategoryImage, pcs.CategoryVideo); len(images) != 1 {
+		t.Errorf("FilterCategory skipped directories: %v", images)
+	}
+
+	groups := pcs.GroupByCategory(files)
+	if len(groups[pcs.CategoryAudio]) != 2 || len(groups[pcs.CategoryImage]) != 1 ||
+		len(groups[pcs.CategoryOther]) != 1 || len(groups) != 3 {
+		t.Errorf("GroupByCategory = %v", groups)
+	}
+	if s := pcs.CategoryArchive.String(); s != "archive" {
+		t.Errorf("CategoryArchive.String() = %q", s)
+	}
+}
@@ -0,0 +1,91 @@
+package pcs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// IsMagnetLink 报告 s 是否为磁力链接。磁力链接可以直接作为 AddTaskOptions.SourceURL 离线下载。
+func IsMagnetLink(s string) bool {
+	return len(s) >= len("magnet:?") && strings.EqualFold(s[:len("magnet:?")], "magnet:?")
+}
+
+// QueryOfflineTasks 查询离线下载任务 ids 的状态和进度，以任务ID为键返回。
+// 不存在的任务不在结果中。
+func (c *Client) QueryOfflineTasks(ctx context.Context, ids ...int64) (map[int64]*OfflineTask, error) {
+	opt := NewQueryTaskOptions(ids...)
+	if err := opt.Validate(); err != nil {
+		return nil, err
+	}
+	u, err := c.addOptions("../services/cloud_dl", "query_task", opt)
+	if err != nil {
+		return nil, err
+	}
+	r, _, err := postForm[struct {
+		TaskInfo map[string]json.RawMessage `json:"task_info"`
+	}](idempotent(ctx), c, u, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	tasks := make(map[int64]*OfflineTask, len(r.TaskInfo))
+	for key, raw := range r.TaskInfo {
+		id, err := strconv.ParseInt(key, 10, 64)
+		if err != nil {
+			return nil, ErrInvalidResponse
+		}
+		// 不存在的任务只有 {"result": "1"}
+		var missing struct {
+			Result string `json:"result"`
+		}
+		if json.Unmarshal(raw, &missing) == nil && missing.Result == "1" {
+			continue
+		}
+		t := new(OfflineTask)
+		if err := json.Unmarshal(raw, t); err != nil {
+			return nil, ErrInvalidResponse
+		}
+		// 查询进度时响应中可能没有 task_id
+		t.ID = id
+		tasks[id] = t
+	}
+	return tasks, nil
+}
+
+// WaitOfflineTasks 每隔 interval 查询一次离线下载任务 ids，直到它们都不再是 TaskRunning，返回最后的状态。
+// 任务是否成功需检查各自的 Status。任务不存在（例如已被取消）时返回的错误满足 errors.Is(err, ErrTaskNotFound)。
+func (c *Client) WaitOfflineTasks(ctx context.Context, interval time.Duration, ids ...int64) (map[int64]*OfflineTask, error) {
+	if interval <= 0 {
+		return nil, invalid("interval", "must be positive, got %v", interval)
+	}
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+
+		tasks, err := c.QueryOfflineTasks(ctx, ids...)
+		if err != nil {
+			return nil, err
+		}
+		running := false
+		for _, id := range ids {
+			t, ok := tasks[id]
+			if !ok {
+				return tasks, fmt.Errorf("%w: %d", ErrTaskNotFound, id)
+			}
+			running = running || t.Status == TaskRunning
+		}
+		if !running {
+			return tasks, nil
+		}
+		timer.Reset(interval)
+	}
+}
@@ -0,0 +1,350 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/holys/baidu-pcs/v2"
+	"golang.org/x/term"
+)
+
+// browseHelp 是标准输入不是终端时 browse 逐行读取的命令，全屏界面的按键见 tuiHelp
+const browseHelp = `commands:
+  cd N|DIR|..      enter directory N of the listing, DIR or the parent
+  ls               list the current directory again
+  sel N...         toggle selection of entries, N may be a range like 3-7 or * for all
+  get [LOCAL_DIR]  queue downloads of the selected entries into LOCAL_DIR (default .)
+  put LOCAL...     queue uploads of local files or directories into the current directory
+  rm               queue deletion of the selected entries
+  queue            show the queued operations
+  clear            clear the queue and the selection
+  run              run the queued operations
+  quit             leave without running the queue`
+
+// browseOp 是 browse 中排队等待执行的一个操作
+type browseOp struct {
+	kind   string // "get"、"put" 或 "rm"
+	remote string
+	local  string
+}
+
+func (op browseOp) String() string {
+	switch op.kind {
+	case "get":
+		return fmt.Sprintf("get %s -> %s", op.remote, op.local)
+	case "put":
+		return fmt.Sprintf("put %s -> %s", op.local, op.remote)
+	}
+	return "rm  " + op.remote
+}
+
+// browser 是 browse 命令的状态：当前目录的列表、跨目录保留的选择和待执行的操作。
+// 全屏界面和逐行命令共用这些状态和操作。
+type browser struct {
+	a        *app
+	dir      string
+	files    []*pcs.File
+	selected map[string]bool
+	queue    []browseOp
+}
+
+func runBrowse(ctx context.Context, a *app, args []string) error {
+	fset := flags(a, "browse")
+	a.transferFlags(fset)
+	a.cryptFlags(fset)
+	if err := a.parse(fset, args); err != nil {
+		return err
+	}
+	if fset.NArg() > 1 {
+		return errUsage("browse")
+	}
+
+	b := &browser{a: a, selected: make(map[string]bool)}
+	if err := b.cd(ctx, a.remote(fset.Arg(0))); err != nil {
+		return err
+	}
+	if in, ok := a.stdin.(*os.File); ok && term.IsTerminal(int(in.Fd())) && isTerminal(a.stdout) {
+		return runTUI(ctx, b, in, a.stdout.(*os.File))
+	}
+
+	// 不在终端中时逐行读取命令，便于脚本使用
+	b.list()
+
+	in := bufio.NewScanner(a.stdin)
+	for {
+		fmt.Fprintf(a.stdout, "%s> ", b.dir)
+		if !in.Scan() {
+			fmt.Fprintln(a.stdout)
+			return in.Err()
+		}
+		fields := strings.Fields(in.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if fields[0] == "quit" || fields[0] == "q" {
+			return nil
+		}
+		if err := b.exec(ctx, fields[0], fields[1:]); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			fmt.Fprintln(a.stdout, "error:", err)
+		}
+	}
+}
+
+func (b *browser) exec(ctx context.Context, cmd string, args []string) error {
+	switch cmd {
+	case "cd":
+		if len(args) != 1 {
+			return errors.New("usage: cd N|DIR|..")
+		}
+		dir := b.a.remote(path.Join(b.dir, args[0]))
+		if strings.HasPrefix(args[0], "/") {
+			dir = b.a.remote(args[0])
+		}
+		if n, err := strconv.Atoi(args[0]); err == nil {
+			f, err := b.entry(n)
+			if err != nil {
+				return err
+			}
+			if !f.IsDirectory() {
+				return fmt.Errorf("%s is not a directory", path.Base(f.Path))
+			}
+			dir = f.Path
+		}
+		if err := b.cd(ctx, dir); err != nil {
+			return err
+		}
+		b.list()
+	case "ls":
+		if err := b.cd(ctx, b.dir); err != nil {
+			return err
+		}
+		b.list()
+	case "sel":
+		return b.toggle(args)
+	case "get":
+		if len(args) > 1 {
+			return errors.New("usage: get [LOCAL_DIR]")
+		}
+		local := "."
+		if len(args) == 1 {
+			local = args[0]
+		}
+		if err := b.get(local); err != nil {
+			return err
+		}
+		fmt.Fprintf(b.a.stdout, "%d operations queued\n", len(b.queue))
+	case "rm":
+		if err := b.rm(); err != nil {
+			return err
+		}
+		fmt.Fprintf(b.a.stdout, "%d operations queued\n", len(b.queue))
+	case "put":
+		if len(args) == 0 {
+			return errors.New("usage: put LOCAL...")
+		}
+		for _, local := range args {
+			b.put(local)
+		}
+		fmt.Fprintf(b.a.stdout, "%d operations queued\n", len(b.queue))
+	case "queue":
+		for i, op := range b.queue {
+			fmt.Fprintf(b.a.stdout, "%3d  %s\n", i+1, op)
+		}
+	case "clear":
+		b.queue = nil
+		clear(b.selected)
+		b.list()
+	case "run":
+		if err := b.run(ctx); err != nil {
+			return err
+		}
+		if err := b.cd(ctx, b.dir); err != nil {
+			return err
+		}
+		b.list()
+	case "help", "?":
+		fmt.Fprintln(b.a.stdout, browseHelp)
+	default:
+		return fmt.Errorf("unknown command %q, try help", cmd)
+	}
+	return nil
+}
+
+// cd 列出目录 dir 并将其设为当前目录
+func (b *browser) cd(ctx context.Context, dir string) error {
+	var files []*pcs.File
+	for f, err := range b.a.client.Files(ctx, dir) {
+		if err != nil {
+			return err
+		}
+		files = append(files, f)
+	}
+	// 目录在前，各自按名字排序
+	sort.SliceStable(files, func(i, j int) bool {
+		if files[i].IsDirectory() != files[j].IsDirectory() {
+			return files[i].IsDirectory()
+		}
+		return files[i].Path < files[j].Path
+	})
+	b.dir, b.files = dir, files
+	return nil
+}
+
+func (b *browser) list() {
+	w := b.a.stdout
+	fmt.Fprintln(w, b.dir)
+	if len(b.files) == 0 {
+		fmt.Fprintln(w, "  (empty)")
+	}
+	for i, f := range b.files {
+		mark := " "
+		if b.selected[f.Path] {
+			mark = "*"
+		}
+		name, size := path.Base(f.Path), pcs.HumanSize(f.Size)
+		if f.IsDirectory() {
+			name, size = name+"/", ""
+		}
+		fmt.Fprintln(w, strings.TrimRight(fmt.Sprintf("%s%4d  %-40s %10s", mark, i+1, name, size), " "))
+	}
+	if n := len(b.selected); n > 0 {
+		fmt.Fprintf(w, "%d selected\n", n)
+	}
+}
+
+// entry 返回列表中的第 n 项，n 从1开始
+func (b *browser) entry(n int) (*pcs.File, error) {
+	if n < 1 || n > len(b.files) {
+		return nil, fmt.Errorf("no entry %d", n)
+	}
+	return b.files[n-1], nil
+}
+
+// toggle 切换 args 所指的各项的选择状态
+func (b *browser) toggle(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: sel N...")
+	}
+	var picked []*pcs.File
+	for _, arg := range args {
+		if arg == "*" {
+			picked = append(picked, b.files...)
+			continue
+		}
+		lo, hi, isRange := strings.Cut(arg, "-")
+		from, err := strconv.Atoi(lo)
+		if err != nil {
+			return fmt.Errorf("invalid entry %q", arg)
+		}
+		to := from
+		if isRange {
+			if to, err = strconv.Atoi(hi); err != nil || to < from {
+				return fmt.Errorf("invalid range %q", arg)
+			}
+		}
+		for n := from; n <= to; n++ {
+			f, err := b.entry(n)
+			if err != nil {
+				return err
+			}
+			picked = append(picked, f)
+		}
+	}
+	for _, f := range picked {
+		b.toggleFile(f)
+	}
+	b.list()
+	return nil
+}
+
+// toggleFile 切换 f 的选择状态
+func (b *browser) toggleFile(f *pcs.File) {
+	if b.selected[f.Path] {
+		delete(b.selected, f.Path)
+	} else {
+		b.selected[f.Path] = true
+	}
+}
+
+// get 将选中的各项排队下载到本地目录 local
+func (b *browser) get(local string) error {
+	return b.enqueue("get", func(p string) browseOp {
+		return browseOp{kind: "get", remote: p, local: filepath.Join(local, path.Base(p))}
+	})
+}
+
+// rm 将选中的各项排队删除
+func (b *browser) rm() error {
+	return b.enqueue("rm", func(p string) browseOp {
+		return browseOp{kind: "rm", remote: p}
+	})
+}
+
+// put 将本地文件或目录 local 排队上传到当前目录
+func (b *browser) put(local string) {
+	b.queue = append(b.queue, browseOp{kind: "put", local: local, remote: path.Join(b.dir, filepath.Base(local))})
+}
+
+// enqueue 为每个选中的路径添加 op 返回的操作，并清空选择。
+func (b *browser) enqueue(kind string, op func(p string) browseOp) error {
+	if len(b.selected) == 0 {
+		return fmt.Errorf("nothing selected for %s", kind)
+	}
+	paths := make([]string, 0, len(b.selected))
+	for p := range b.selected {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	for _, p := range paths {
+		b.queue = append(b.queue, op(p))
+	}
+	clear(b.selected)
+	return nil
+}
+
+// run 先执行队列中的上传和下载，全部成功后再执行删除，使同时排队下载和删除的文件先被下载。
+func (b *browser) run(ctx context.Context) error {
+	if len(b.queue) == 0 {
+		return errors.New("queue is empty")
+	}
+	var deletes []string
+	err := b.a.transfer(ctx, func(t *batch) error {
+		for _, op := range b.queue {
+			var err error
+			switch op.kind {
+			case "get":
+				err = b.a.addDownloads(ctx, t, op.remote, op.local)
+			case "put":
+				err = addUploads(t, op.local, op.remote, pcs.OnDupOverwrite)
+			case "rm":
+				deletes = append(deletes, op.remote)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(deletes) > 0 {
+		if _, err := b.a.client.BatchDelete(ctx, deletes); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(b.a.stdout, "%d operations done\n", len(b.queue))
+	b.queue = nil
+	return nil
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/holys/baidu-pcs/v2/pcstest"
+)
+
+func TestDecodeKeys(t *testing.T) {
+	in := "j\x1b[A\x1b[6~\r\x7f \x1b\t中\x03\x1b[1;5C"
+	want := []string{"j", "up", "pgdn", "enter", "backspace", " ", "esc", "tab", "中", "ctrl-c"}
+	if got := decodeKeys([]byte(in)); !reflect.DeepEqual(got, want) {
+		t.Errorf("decodeKeys(%q) = %q, want %q", in, got, want)
+	}
+}
+
+func TestBrowseTUI(t *testing.T) {
+	ctx := context.Background()
+	srv := pcstest.NewServer()
+	defer srv.Close()
+	srv.PutFile("/apps/t/a.txt", []byte("alpha"))
+	srv.PutFile("/apps/t/b.txt", []byte("bravo"))
+	srv.PutFile("/apps/t/d/c.txt", []byte("charlie"))
+	a := &app{client: srv.NewClient(), root: "/apps/t", stdout: new(strings.Builder), stderr: new(strings.Builder)}
+	b := &browser{a: a, selected: make(map[string]bool)}
+	if err := b.cd(ctx, "/apps/t"); err != nil {
+		t.Fatal(err)
+	}
+	ui := &tui{b: b, width: 60, height: 10}
+	press := func(keys ...string) {
+		for _, k := range keys {
+			ui.update(ctx, k)
+		}
+	}
+
+	// 目录在前：d/、a.txt、b.txt
+	press("enter")
+	if b.dir != "/apps/t/d" || !strings.Contains(ui.view(), "c.txt") {
+		t.Fatalf("after opening d: dir %s, view %q", b.dir, ui.view())
+	}
+	press("backspace")
+	if b.dir != "/apps/t" || ui.current().Path != "/apps/t/d" {
+		t.Fatalf("after going up: dir %s, cursor on %v", b.dir, ui.current())
+	}
+
+	// 选中两个文件排队下载到 out，再删除光标所在的 d
+	press("j", " ", " ", "d")
+	if ui.mode != modePrompt {
+		t.Fatalf("d did not ask for the local directory, mode %d", ui.mode)
+	}
+	press("backspace", "o", "u", "t", "enter")
+	press("g", "x")
+	want := []string{"get /apps/t/a.txt -> out/a.txt", "get /apps/t/b.txt -> out/b.txt", "rm  /apps/t/d"}
+	var got []string
+	for _, op := range b.queue {
+		got = append(got, op.String())
+	}
+	if !reflect.DeepEqual(got, want) || len(b.selected) != 0 {
+		t.Fatalf("queue %q, selected %v; want %q", got, b.selected, want)
+	}
+
+	// 在队列中去掉删除，然后确认执行
+	press("tab", "j", "j", "x", "tab")
+	if len(b.queue) != 2 {
+		t.Errorf("queue after removing the delete: %v", b.queue)
+	}
+	press("r", "n")
+	if ui.runQueue || ui.status != "cancelled" {
+		t.Errorf("answering n still runs the queue")
+	}
+	press("r", "y")
+	if !ui.runQueue {
+		t.Error("answering y does not run the queue")
+	}
+
+	// 有排队的操作时退出需要确认
+	press("q")
+	if ui.quit || ui.mode != modeConfirm {
+		t.Error("q quit without asking about the queue")
+	}
+	press("y")
+	if !ui.quit {
+		t.Error("did not quit")
+	}
+}
@@ -0,0 +1,575 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/holys/baidu-pcs/v2"
+	"golang.org/x/term"
+	"golang.org/x/text/width"
+)
+
+const tuiHelp = `keys:
+  up/down, k/j     move the cursor        pgup/pgdn, g/G   move by a page, to the top or bottom
+  enter, right, l  open the directory     left, h, bksp    go to the parent directory
+  space            select or deselect     *                select or deselect everything
+  d                queue downloads of the selection, or of the entry under the cursor
+  u                queue an upload of a local file or directory into this directory
+  x, delete        queue deletion of the selection, or of the entry under the cursor
+  tab              show the queue, where x removes an operation
+  r                run the queue          .                reload the directory
+  ?                show this help         q, ctrl-c        quit
+
+press any key to return`
+
+// 全屏界面使用的 ANSI 控制序列
+const (
+	ansiAltScreen  = "\x1b[?1049h\x1b[?25l" // 切换到备用屏幕并隐藏光标
+	ansiMainScreen = "\x1b[?25h\x1b[?1049l"
+	ansiHome       = "\x1b[H"
+	ansiClearLine  = "\x1b[K"
+	ansiClearBelow = "\x1b[J"
+	ansiReverse    = "\x1b[7m"
+	ansiBold       = "\x1b[1m"
+	ansiReset      = "\x1b[0m"
+)
+
+// 终端大小的检查间隔，大小改变时重新绘制
+const tuiResizeInterval = 250 * time.Millisecond
+
+type tuiMode int
+
+const (
+	modeList    tuiMode = iota // 当前目录的列表
+	modeQueue                  // 待执行的操作
+	modeHelp                   // 按键说明
+	modePrompt                 // 在状态行输入一行文字
+	modeConfirm                // 在状态行回答 y 或 n
+)
+
+// tui 是 browse 在终端中的全屏界面。按 Elm 架构组织：每个按键交给 update 修改状态，
+// 再由 view 按新的状态绘制整个屏幕。列表、选择和队列保存在 browser 中。
+type tui struct {
+	b             *browser
+	width, height int
+
+	mode    tuiMode
+	cursor  int // 列表中光标所在的项
+	top     int // 屏幕上显示的第一项
+	qcursor int // 队列中光标所在的操作
+	status  string
+
+	// modePrompt 和 modeConfirm 时的问题、已输入的文字和回答后执行的操作。
+	// modeConfirm 只在回答 y 时调用 answer。
+	question string
+	input    []rune
+	answer   func(ctx context.Context, s string) error
+
+	quit     bool
+	runQueue bool // 需要离开全屏界面执行队列，由 runTUI 处理
+}
+
+// runTUI 在终端 in 和 out 上运行全屏界面，直到退出或 ctx 被取消。
+func runTUI(ctx context.Context, b *browser, in, out *os.File) error {
+	fd := int(in.Fd())
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return err
+	}
+	fmt.Fprint(out, ansiAltScreen)
+	defer func() {
+		fmt.Fprint(out, ansiMainScreen)
+		term.Restore(fd, state)
+	}()
+
+	keys := make(chan string, 64)
+	go readKeys(in, keys)
+	ticker := time.NewTicker(tuiResizeInterval)
+	defer ticker.Stop()
+
+	t := &tui{b: b}
+	t.width, t.height = terminalSize(out)
+	for !t.quit {
+		fmt.Fprint(out, t.view())
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case k, ok := <-keys:
+			if !ok {
+				return nil
+			}
+			t.update(ctx, k)
+		case <-ticker.C:
+			w, h := terminalSize(out)
+			if w == t.width && h == t.height {
+				continue
+			}
+			t.width, t.height = w, h
+		}
+
+		if t.runQueue {
+			// 执行期间恢复终端，让传输的进度正常显示
+			t.runQueue = false
+			fmt.Fprint(out, ansiMainScreen)
+			term.Restore(fd, state)
+			runErr := b.run(ctx)
+			if runErr != nil {
+				fmt.Fprintln(out, "error:", runErr)
+			}
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			fmt.Fprint(out, "press any key to return")
+			if state, err = term.MakeRaw(fd); err != nil {
+				return err
+			}
+			select {
+			case <-keys:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			fmt.Fprint(out, ansiAltScreen)
+			t.reload(ctx, "")
+			t.setError(runErr)
+		}
+	}
+	return nil
+}
+
+func terminalSize(f *os.File) (int, int) {
+	w, h, err := term.GetSize(int(f.Fd()))
+	if err != nil || w <= 0 || h <= 0 {
+		return 80, 24
+	}
+	return w, h
+}
+
+// readKeys 从 r 读取按键，解码后发送到 keys，读取出错时关闭 keys。
+func readKeys(r io.Reader, keys chan<- string) {
+	defer close(keys)
+	buf := make([]byte, 256)
+	for {
+		n, err := r.Read(buf)
+		for _, k := range decodeKeys(buf[:n]) {
+			keys <- k
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// 转义序列对应的按键名
+var escapeKeys = map[string]string{
+	"[A": "up", "[B": "down", "[C": "right", "[D": "left",
+	"OA": "up", "OB": "down", "OC": "right", "OD": "left",
+	"[H": "home", "[F": "end", "OH": "home", "OF": "end",
+	"[1~": "home", "[7~": "home", "[4~": "end", "[8~": "end",
+	"[3~": "delete", "[5~": "pgup", "[6~": "pgdn",
+}
+
+// decodeKeys 将终端在原始模式下的输入解码为按键名：可打印字符为其本身，
+// 其他按键为 "up"、"enter"、"ctrl-c" 等，无法识别的控制字符和转义序列被忽略。
+func decodeKeys(p []byte) []string {
+	var keys []string
+	for len(p) > 0 {
+		switch c := p[0]; {
+		case c == 0x1b:
+			n := escapeLen(p)
+			if n == 1 {
+				keys = append(keys, "esc")
+			} else if k, ok := escapeKeys[string(p[1:n])]; ok {
+				keys = append(keys, k)
+			}
+			p = p[n:]
+			continue
+		case c == '\r' || c == '\n':
+			keys = append(keys, "enter")
+		case c == 0x7f || c == 0x08:
+			keys = append(keys, "backspace")
+		case c == '\t':
+			keys = append(keys, "tab")
+		case c == 0x03:
+			keys = append(keys, "ctrl-c")
+		case c == 0x12:
+			keys = append(keys, "ctrl-r")
+		case c < 0x20:
+		default:
+			r, n := utf8.DecodeRune(p)
+			if r != utf8.RuneError && unicode.IsPrint(r) {
+				keys = append(keys, string(r))
+			}
+			p = p[n:]
+			continue
+		}
+		p = p[1:]
+	}
+	return keys
+}
+
+// escapeLen 返回 p 开头的转义序列的长度，p[0] 为 ESC。
+func escapeLen(p []byte) int {
+	if len(p) < 2 {
+		return 1
+	}
+	switch p[1] {
+	case 'O':
+		return min(3, len(p))
+	case '[':
+		// CSI 序列以 0x40 到 0x7e 之间的字节结束
+		for i := 2; i < len(p); i++ {
+			if p[i] >= 0x40 && p[i] <= 0x7e {
+				return i + 1
+			}
+		}
+		return len(p)
+	}
+	return 1
+}
+
+// update 按键 k 修改界面的状态
+func (t *tui) update(ctx context.Context, k string) {
+	switch t.mode {
+	case modePrompt:
+		t.updatePrompt(ctx, k)
+	case modeConfirm:
+		t.mode = modeList
+		if k == "y" || k == "Y" {
+			t.setError(t.answer(ctx, "y"))
+		} else {
+			t.status = "cancelled"
+		}
+	case modeHelp:
+		t.mode = modeList
+	case modeQueue:
+		t.updateQueue(ctx, k)
+	default:
+		t.updateList(ctx, k)
+	}
+}
+
+func (t *tui) updateList(ctx context.Context, k string) {
+	b := t.b
+	t.status = ""
+	switch k {
+	case "q", "ctrl-c":
+		if len(b.queue) == 0 {
+			t.quit = true
+			return
+		}
+		t.confirm(fmt.Sprintf("quit and discard %d queued operations?", len(b.queue)), func(context.Context, string) error {
+			t.quit = true
+			return nil
+		})
+	case "up", "k":
+		t.move(-1)
+	case "down", "j":
+		t.move(1)
+	case "pgup":
+		t.move(-t.rows())
+	case "pgdn":
+		t.move(t.rows())
+	case "home", "g":
+		t.move(-len(b.files))
+	case "end", "G":
+		t.move(len(b.files))
+	case "enter", "right", "l":
+		f := t.current()
+		if f == nil || !f.IsDirectory() {
+			return
+		}
+		t.reload(ctx, f.Path)
+	case "left", "h", "backspace":
+		if b.dir == "/" {
+			return
+		}
+		from := b.dir
+		t.reload(ctx, path.Dir(b.dir))
+		for i, f := range b.files {
+			if f.Path == from {
+				t.move(i)
+				break
+			}
+		}
+	case ".", "ctrl-r":
+		t.reload(ctx, "")
+	case " ":
+		if f := t.current(); f != nil {
+			b.toggleFile(f)
+			t.move(1)
+		}
+	case "*":
+		all := len(b.files) > 0
+		for _, f := range b.files {
+			all = all && b.selected[f.Path]
+		}
+		for _, f := range b.files {
+			if all {
+				delete(b.selected, f.Path)
+			} else {
+				b.selected[f.Path] = true
+			}
+		}
+	case "d":
+		if !t.selectCurrent() {
+			return
+		}
+		t.prompt("download to local directory: ", ".", func(_ context.Context, local string) error {
+			if local == "" {
+				local = "."
+			}
+			return t.queued(b.get(local))
+		})
+	case "u":
+		t.prompt("upload local file or directory: ", "", func(_ context.Context, local string) error {
+			if local == "" {
+				return nil
+			}
+			if _, err := os.Stat(local); err != nil {
+				return err
+			}
+			b.put(local)
+			return t.queued(nil)
+		})
+	case "x", "delete":
+		if t.selectCurrent() {
+			t.setError(t.queued(b.rm()))
+		}
+	case "tab":
+		t.mode, t.qcursor = modeQueue, 0
+	case "r":
+		t.confirmRun()
+	case "?":
+		t.mode = modeHelp
+	}
+}
+
+func (t *tui) updateQueue(ctx context.Context, k string) {
+	b := t.b
+	switch k {
+	case "up", "k":
+		t.qcursor = max(t.qcursor-1, 0)
+	case "down", "j":
+		t.qcursor = min(t.qcursor+1, max(len(b.queue)-1, 0))
+	case "x", "delete":
+		if t.qcursor < len(b.queue) {
+			b.queue = append(b.queue[:t.qcursor], b.queue[t.qcursor+1:]...)
+			t.qcursor = min(t.qcursor, max(len(b.queue)-1, 0))
+		}
+	case "r":
+		t.mode = modeList
+		t.confirmRun()
+	case "tab", "esc", "q":
+		t.mode = modeList
+	}
+}
+
+func (t *tui) updatePrompt(ctx context.Context, k string) {
+	switch k {
+	case "enter":
+		t.mode = modeList
+		t.setError(t.answer(ctx, strings.TrimSpace(string(t.input))))
+	case "esc", "ctrl-c":
+		t.mode = modeList
+		t.status = "cancelled"
+	case "backspace":
+		if len(t.input) > 0 {
+			t.input = t.input[:len(t.input)-1]
+		}
+	default:
+		if utf8.RuneCountInString(k) == 1 {
+			t.input = append(t.input, []rune(k)...)
+		}
+	}
+}
+
+// prompt 在状态行询问 question，初始输入为 value，按回车后以输入调用 answer。
+func (t *tui) prompt(question, value string, answer func(ctx context.Context, s string) error) {
+	t.mode, t.question, t.input, t.answer = modePrompt, question, []rune(value), answer
+}
+
+// confirm 在状态行询问 question，回答 y 时调用 answer。
+func (t *tui) confirm(question string, answer func(ctx context.Context, s string) error) {
+	t.mode, t.question, t.answer = modeConfirm, question+" (y/n)", answer
+}
+
+func (t *tui) confirmRun() {
+	if len(t.b.queue) == 0 {
+		t.status = "queue is empty"
+		return
+	}
+	t.confirm(fmt.Sprintf("run %d queued operations?", len(t.b.queue)), func(context.Context, string) error {
+		t.runQueue = true
+		return nil
+	})
+}
+
+// selectCurrent 在没有选中任何项时选中光标所在的项，报告是否有选中的项。
+func (t *tui) selectCurrent() bool {
+	if len(t.b.selected) == 0 {
+		f := t.current()
+		if f == nil {
+			return false
+		}
+		t.b.selected[f.Path] = true
+	}
+	return true
+}
+
+// queued 在 err 为 nil 时报告队列的长度
+func (t *tui) queued(err error) error {
+	if err == nil {
+		t.status = fmt.Sprintf("%d operations queued", len(t.b.queue))
+	}
+	return err
+}
+
+func (t *tui) setError(err error) {
+	if err != nil {
+		t.status = "error: " + err.Error()
+	}
+}
+
+// reload 列出目录 dir 并将光标移到第一项，dir 为空时重新列出当前目录并尽量保持光标的位置。
+func (t *tui) reload(ctx context.Context, dir string) {
+	cursor := 0
+	if dir == "" {
+		dir, cursor = t.b.dir, t.cursor
+	}
+	if err := t.b.cd(ctx, dir); err != nil {
+		t.setError(err)
+		return
+	}
+	t.cursor, t.top = 0, 0
+	t.move(cursor)
+}
+
+func (t *tui) current() *pcs.File {
+	if t.cursor < len(t.b.files) {
+		return t.b.files[t.cursor]
+	}
+	return nil
+}
+
+// rows 返回屏幕上可以显示的列表项数：去掉标题、状态和按键提示各一行。
+func (t *tui) rows() int {
+	return max(t.height-3, 1)
+}
+
+// move 将光标移动 n 项，并滚动列表使光标可见。
+func (t *tui) move(n int) {
+	t.cursor = min(max(t.cursor+n, 0), max(len(t.b.files)-1, 0))
+	if t.cursor < t.top {
+		t.top = t.cursor
+	}
+	if rows := t.rows(); t.cursor >= t.top+rows {
+		t.top = t.cursor - rows + 1
+	}
+}
+
+// view 返回绘制整个屏幕的内容
+func (t *tui) view() string {
+	b := t.b
+	var lines []string
+	switch t.mode {
+	case modeHelp:
+		lines = strings.Split(tuiHelp, "\n")
+	case modeQueue:
+		lines = append(lines, ansiReverse+fit(fmt.Sprintf(" queue: %d operations", len(b.queue)), t.width)+ansiReset)
+		if len(b.queue) == 0 {
+			lines = append(lines, "  (empty)")
+		}
+		for i, op := range b.queue {
+			line := fit("  "+op.String(), t.width)
+			if i == t.qcursor {
+				line = ansiReverse + line + ansiReset
+			}
+			lines = append(lines, line)
+		}
+	default:
+		lines = append(lines, ansiReverse+fit(" "+b.dir, t.width)+ansiReset)
+		if len(b.files) == 0 {
+			lines = append(lines, "  (empty)")
+		}
+		for i := t.top; i < len(b.files) && i < t.top+t.rows(); i++ {
+			line := t.row(b.files[i])
+			if i == t.cursor {
+				line = ansiReverse + line + ansiReset
+			}
+			lines = append(lines, line)
+		}
+	}
+
+	// 最后两行为状态和按键提示
+	for len(lines) < t.height-2 {
+		lines = append(lines, "")
+	}
+	lines = lines[:max(t.height-2, 0)]
+	status := t.status
+	switch t.mode {
+	case modePrompt:
+		status = t.question + string(t.input) + "_"
+	case modeConfirm:
+		status = t.question
+	}
+	lines = append(lines, ansiBold+fit(status, t.width)+ansiReset)
+	hints := fmt.Sprintf("%d selected, %d queued | space select  d get  u put  x delete  tab queue  r run  ? help  q quit",
+		len(b.selected), len(b.queue))
+	lines = append(lines, fit(hints, t.width))
+
+	var sb strings.Builder
+	sb.WriteString(ansiHome)
+	for i, line := range lines {
+		if i > 0 {
+			sb.WriteString("\r\n")
+		}
+		sb.WriteString(line)
+		sb.WriteString(ansiClearLine)
+	}
+	sb.WriteString(ansiClearBelow)
+	return sb.String()
+}
+
+// row 返回列表中 f 的一行：选择标记、名字、大小和修改时间
+func (t *tui) row(f *pcs.File) string {
+	mark := "  "
+	if t.b.selected[f.Path] {
+		mark = "* "
+	}
+	name, size := path.Base(f.Path), pcs.HumanSize(f.Size)
+	if f.IsDirectory() {
+		name, size = name+"/", ""
+	}
+	mtime := time.Unix(int64(f.Mtime), 0).Format("2006-01-02 15:04")
+	rest := fmt.Sprintf(" %10s  %s", size, mtime)
+	nameWidth := t.width - len(mark) - len(rest)
+	if nameWidth < 12 {
+		rest, nameWidth = "", t.width-len(mark)
+	}
+	return mark + fit(name, nameWidth) + rest
+}
+
+// fit 将 s 截断或以空格填充到 w 列，东亚宽字符占两列。
+func fit(s string, w int) string {
+	var sb strings.Builder
+	n := 0
+	for _, r := range s {
+		rw := 1
+		if k := width.LookupRune(r).Kind(); k == width.EastAsianWide || k == width.EastAsianFullwidth {
+			rw = 2
+		}
+		if n+rw > w {
+			break
+		}
+		sb.WriteRune(r)
+		n += rw
+	}
+	return sb.String() + strings.Repeat(" ", max(w-n, 0))
+}
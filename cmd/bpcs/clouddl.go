@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/holys/baidu-pcs/v2"
+)
+
+const clouddlUsage = `usage:
+  bpcs clouddl add [-wait] [-select N,...] SOURCE SAVE_DIR
+  bpcs clouddl list [-n N]
+  bpcs clouddl status ID...
+  bpcs clouddl cancel ID...
+  bpcs clouddl wait [-interval D] ID...
+
+SOURCE is an http, https, ftp or ed2k URL, a magnet link, or the remote
+path of a .torrent file already in the drive.`
+
+func runClouddl(ctx context.Context, a *app, args []string) error {
+	if len(args) == 0 {
+		return errors.New(clouddlUsage)
+	}
+	sub, args := args[0], args[1:]
+	switch sub {
+	case "add":
+		return clouddlAdd(ctx, a, args)
+	case "list":
+		return clouddlList(ctx, a, args)
+	case "status":
+		return clouddlStatus(ctx, a, args)
+	case "cancel":
+		return clouddlCancel(ctx, a, args)
+	case "wait":
+		return clouddlWait(ctx, a, args)
+	}
+	return fmt.Errorf("unknown subcommand %q\n%s", sub, clouddlUsage)
+}
+
+func clouddlAdd(ctx context.Context, a *app, args []string) error {
+	fset := flags(a, "clouddl")
+	wait := fset.Bool("wait", false, "wait until the download finishes")
+	interval := fset.Duration("interval", 5*time.Second, "polling interval for -wait")
+	selected := fset.String("select", "", "comma separated indexes of the torrent files to download, default all")
+	if err := a.parse(fset, args); err != nil {
+		return err
+	}
+	if fset.NArg() != 2 {
+		return errors.New(clouddlUsage)
+	}
+	src, save := fset.Arg(0), a.remote(fset.Arg(1))
+
+	var (
+		id  int64
+		err error
+	)
+	switch {
+	case strings.HasSuffix(strings.ToLower(src), ".torrent") && !strings.Contains(src, "://"):
+		var idx []int
+		if *selected != "" {
+			for _, s := range strings.Split(*selected, ",") {
+				n, err := strconv.Atoi(strings.TrimSpace(s))
+				if err != nil {
+					return fmt.Errorf("invalid -select index %q", s)
+				}
+				idx = append(idx, n)
+			}
+		}
+		id, _, err = a.client.AddTorrentTask(ctx, a.remote(src), save, idx...)
+	case *selected != "":
+		return errors.New("-select only applies to .torrent files")
+	default:
+		id, _, err = a.client.AddOfflineDownloadTask(ctx, pcs.NewAddTaskOptions(src, save))
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(a.stdout, id)
+
+	if *wait {
+		return a.waitTasks(ctx, *interval, []int64{id})
+	}
+	return nil
+}
+
+func clouddlList(ctx context.Context, a *app, args []string) error {
+	fset := flags(a, "clouddl")
+	n := fset.Int("n", 0, "show at most n tasks, newest first; 0 shows all")
+	if err := a.parse(fset, args); err != nil {
+		return err
+	}
+	if fset.NArg() != 0 {
+		return errors.New(clouddlUsage)
+	}
+
+	count := 0
+	for t, err := range a.client.OfflineTasks(ctx, nil) {
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(a.stdout, t)
+		if count++; *n > 0 && count >= *n {
+			break
+		}
+	}
+	return nil
+}
+
+func clouddlStatus(ctx context.Context, a *app, args []string) error {
+	fset := flags(a, "clouddl")
+	if err := a.parse(fset, args); err != nil {
+		return err
+	}
+	ids, err := taskIDs(fset.Args())
+	if err != nil {
+		return err
+	}
+	tasks, err := a.client.QueryOfflineTasks(ctx, ids...)
+	if err != nil {
+		return err
+	}
+	return printTasks(a, ids, tasks)
+}
+
+func clouddlCancel(ctx context.Context, a *app, args []string) error {
+	fset := flags(a, "clouddl")
+	if err := a.parse(fset, args); err != nil {
+		return err
+	}
+	ids, err := taskIDs(fset.Args())
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if _, err := a.client.CancelOfflineDownloadTask(ctx, pcs.NewCancelTaskOptions(id)); err != nil {
+			return fmt.Errorf("task %d: %w", id, err)
+		}
+	}
+	return nil
+}
+
+func clouddlWait(ctx context.Context, a *app, args []string) error {
+	fset := flags(a, "clouddl")
+	interval := fset.Duration("interval", 5*time.Second, "polling interval")
+	if err := a.parse(fset, args); err != nil {
+		return err
+	}
+	ids, err := taskIDs(fset.Args())
+	if err != nil {
+		return err
+	}
+	return a.waitTasks(ctx, *interval, ids)
+}
+
+// waitTasks 等待任务 ids 结束并输出最终状态，有任务没有成功时返回错误。
+func (a *app) waitTasks(ctx context.Context, interval time.Duration, ids []int64) error {
+	tasks, err := a.client.WaitOfflineTasks(ctx, interval, ids...)
+	if err != nil {
+		return err
+	}
+	if err := printTasks(a, ids, tasks); err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if tasks[id].Status != pcs.TaskSuccess {
+			return fmt.Errorf("task %d did not succeed", id)
+		}
+	}
+	return nil
+}
+
+func printTasks(a *app, ids []int64, tasks map[int64]*pcs.OfflineTask) error {
+	var missing []string
+	for _, id := range ids {
+		t, ok := tasks[id]
+		if !ok {
+			missing = append(missing, strconv.FormatInt(id, 10))
+			continue
+		}
+		fmt.Fprintln(a.stdout, t)
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%w: %s", pcs.ErrTaskNotFound, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+func taskIDs(args []string) ([]int64, error) {
+	if len(args) == 0 {
+		return nil, errors.New(clouddlUsage)
+	}
+	ids := make([]int64, len(args))
+	for i, s := range args {
+		id, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid task id %q", s)
+		}
+		ids[i] = id
+	}
+	return ids, nil
+}
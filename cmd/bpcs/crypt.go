@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/holys/baidu-pcs/v2"
+)
+
+// cryptFlags 为子命令注册 -crypt、-crypt-names、-crypt-key 和 -crypt-pass，默认值为全局选项的值。
+func (a *app) cryptFlags(fset *flag.FlagSet) {
+	fset.BoolVar(&a.crypt, "crypt", a.crypt, "encrypt uploads and decrypt downloads with the -crypt-key or -crypt-pass key")
+	fset.BoolVar(&a.cryptNames, "crypt-names", a.cryptNames, "with -crypt, also encrypt file names below -root")
+	fset.StringVar(&a.cryptKey, "crypt-key", a.cryptKey, "hex encoded 16, 24 or 32 byte encryption `key`; default $BAIDU_PCS_CRYPT_KEY")
+	fset.StringVar(&a.cryptPass, "crypt-pass", a.cryptPass, "`passphrase` the encryption key is derived from; default $BAIDU_PCS_CRYPT_PASS")
+}
+
+// cryptOptions 返回 -crypt 对应的 ClientOption，没有设置 -crypt 时返回 nil。
+// 使用 -crypt-pass 时从 -root 下的 pcs.SaltFile 读取盐，没有时生成并保存，
+// plain 返回用于读写盐文件的不加密的 Client。
+func (a *app) cryptOptions(ctx context.Context, plain func() *pcs.Client) ([]pcs.ClientOption, error) {
+	if !a.crypt {
+		return nil, nil
+	}
+	var key []byte
+	switch {
+	case a.cryptKey != "" && a.cryptPass != "":
+		return nil, errors.New("-crypt-key and -crypt-pass are mutually exclusive")
+	case a.cryptKey != "":
+		var err error
+		if key, err = hex.DecodeString(a.cryptKey); err != nil {
+			return nil, fmt.Errorf("invalid -crypt-key: %v", err)
+		}
+	case a.cryptPass != "":
+		salt, err := plain().PassphraseSalt(ctx, a.root)
+		if err != nil {
+			return nil, fmt.Errorf("-crypt-pass: %w", err)
+		}
+		if key, err = pcs.KeyFromPassphrase(a.cryptPass, salt); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.New("-crypt needs -crypt-key or -crypt-pass")
+	}
+
+	ci, err := pcs.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid -crypt-key: %v", err)
+	}
+	opts := []pcs.ClientOption{pcs.WithEncryption(ci)}
+	if a.cryptNames {
+		nc, err := pcs.NewNameCipher(key, a.root)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, pcs.WithNameEncryption(nc))
+	}
+	return opts, nil
+}
+
+// excludes 在同步排除的模式 exclude 之后加上 -crypt-pass 的盐文件，使它不会被同步删除。
+func (a *app) excludes(exclude []string) []string {
+	if a.crypt && a.cryptPass != "" {
+		return append(exclude[:len(exclude):len(exclude)], pcs.SaltFile)
+	}
+	return exclude
+}
+
+func runCrypt(ctx context.Context, a *app, args []string) error {
+	if len(args) == 0 || args[0] != "check" {
+		return errUsage("crypt")
+	}
+	fset := flags(a, "crypt")
+	a.cryptFlags(fset)
+	limit := fset.Int("max", 0, "check at most `n` files; 0 checks all")
+	// 检查的就是 -crypt 的密钥
+	a.crypt = true
+	if err := a.parse(fset, args[1:]); err != nil {
+		return err
+	}
+	roots := fset.Args()
+	if len(roots) == 0 {
+		roots = []string{""}
+	}
+
+	var checked, failed int
+	for _, root := range roots {
+		root = a.remote(root)
+		meta, _, err := a.client.GetMeta(ctx, root)
+		if err != nil {
+			return err
+		}
+		paths := []string{root}
+		if meta.IsDirectory() {
+			files, err := a.client.Find(ctx, root, &pcs.Filter{Kind: pcs.FindFiles})
+			if err != nil {
+				return err
+			}
+			paths = paths[:0]
+			for _, f := range files {
+				paths = append(paths, f.Path)
+			}
+		}
+
+		for _, p := range paths {
+			if *limit > 0 && checked >= *limit {
+				break
+			}
+			checked++
+			if err := a.client.VerifyEncryption(ctx, p); err != nil {
+				if !errors.Is(err, pcs.ErrDecrypt) {
+					return err
+				}
+				failed++
+				fmt.Fprintf(a.stdout, "FAIL %s\n", p)
+				continue
+			}
+			fmt.Fprintf(a.stdout, "ok   %s\n", p)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d files cannot be decrypted with this key", failed, checked)
+	}
+	return nil
+}
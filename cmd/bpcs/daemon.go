@@ -0,0 +1,581 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/holys/baidu-pcs/v2"
+)
+
+const (
+	defaultDaemonAddr = "127.0.0.1:7681"
+
+	// daemonShutdownTimeout 是退出时等待传输中的分片完成的时间
+	daemonShutdownTimeout = 10 * time.Second
+
+	// daemonTokenFile 是状态目录中保存自动生成的 API token 的文件
+	daemonTokenFile = "api-token"
+)
+
+// daemonUsage 描述 daemon 的控制接口
+const daemonUsage = `The daemon runs the transfer queue and the configured syncs until it is
+interrupted. Unfinished transfers and the syncs are kept in -state and
+resume on the next start. It is controlled with JSON over HTTP:
+
+  GET    /status                  job counts by status and number of syncs
+  GET    /jobs                    all transfer jobs
+  POST   /jobs                    {"kind": "upload"|"download", "local": ABS_PATH,
+                                   "remote": PATH, "priority": N, "ondup": "overwrite"|"newcopy",
+                                   "skip_identical": BOOL}
+                                  queues a file or a whole directory; uploads already
+                                  queued, or identical remotely with skip_identical, are skipped
+  GET    /jobs/ID                 one job
+  POST   /jobs/ID/pause           pause a job
+  POST   /jobs/ID/resume          resume a paused or failed job
+  POST   /jobs/ID/priority        {"priority": N}
+  GET    /syncs                   syncs with the time and error of their last run
+  POST   /syncs                   {"local": ABS_PATH, "remote": PATH, "delete": BOOL,
+                                   "exclude": [PATTERN...], "watch": BOOL,
+                                   "interval": "1h", "debounce": "2s"}
+  DELETE /syncs/ID                stop and remove a sync
+
+Relative remote paths are resolved against -root. Every request must carry
+"Authorization: Bearer TOKEN", and requests with a body must be sent as
+"Content-Type: application/json". Without -api-token, a random token is
+generated into the api-token file in -state. On a loopback -listen address,
+the Host header must name a loopback host too; listening on any other
+address requires -api-token.`
+
+func runDaemon(ctx context.Context, a *app, args []string) error {
+	fset := flags(a, "daemon")
+	a.transferFlags(fset)
+	a.cryptFlags(fset)
+	listen := fset.String("listen", defaultDaemonAddr, "`address` of the control API; other than localhost it requires -api-token")
+	state := fset.String("state", "", "`dir` for the job queue and the syncs, default bpcs in the user config directory")
+	token := fset.String("api-token", os.Getenv("BAIDU_PCS_DAEMON_TOKEN"), "bearer `token` required on API requests; default $BAIDU_PCS_DAEMON_TOKEN, or the api-token file in -state")
+	usage := fset.Usage
+	fset.Usage = func() {
+		usage()
+		fmt.Fprintf(a.stderr, "\n%s\n", daemonUsage)
+	}
+	if a.hashes == nil {
+		a.hashes = openHashCache(a)
+	}
+	if err := a.parse(fset, args); err != nil {
+		return err
+	}
+	if fset.NArg() != 0 {
+		return errUsage("daemon")
+	}
+	loopback, err := isLoopbackAddr(*listen)
+	if err != nil {
+		return fmt.Errorf("invalid -listen: %v", err)
+	}
+	if !loopback && *token == "" {
+		return fmt.Errorf("-listen %s is reachable from other hosts; set -api-token", *listen)
+	}
+
+	dir := *state
+	if dir == "" {
+		cfg, err := os.UserConfigDir()
+		if err != nil {
+			return fmt.Errorf("no -state directory: %v", err)
+		}
+		dir = filepath.Join(cfg, "bpcs")
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	if *token == "" {
+		tokenPath := filepath.Join(dir, daemonTokenFile)
+		if *token, err = loadDaemonToken(tokenPath); err != nil {
+			return err
+		}
+		fmt.Fprintf(a.stderr, "API token in %s\n", tokenPath)
+	}
+	m, err := pcs.NewTransferManager(a.client, filepath.Join(dir, "jobs.json"), pcs.WithTransferConcurrency(a.transfers))
+	if err != nil {
+		return err
+	}
+
+	d := &daemon{a: a, m: m, path: filepath.Join(dir, "syncs.json"), token: *token, loopback: loopback,
+		ctx: ctx, syncs: make(map[string]*daemonSync), nextID: 1}
+	if err := d.load(); err != nil {
+		return err
+	}
+	ln, err := net.Listen("tcp", *listen)
+	if err != nil {
+		return err
+	}
+	srv := &http.Server{Handler: d.handler()}
+	go srv.Serve(ln)
+
+	runErr := make(chan error, 1)
+	go func() { runErr <- m.Run(ctx) }()
+	fmt.Fprintf(a.stderr, "listening on http://%s\n", ln.Addr())
+
+	select {
+	case <-ctx.Done():
+	case err = <-runErr:
+	}
+	shutdown, cancel := context.WithTimeout(context.Background(), daemonShutdownTimeout)
+	defer cancel()
+	srv.Shutdown(shutdown)
+	d.stop()
+	if serr := m.Shutdown(shutdown); serr != nil && err == nil {
+		err = serr
+	}
+	if errors.Is(err, context.Canceled) {
+		err = nil
+	}
+	return err
+}
+
+// syncSpec 是 daemon 的一个同步项，保存在状态目录的 syncs.json 中
+type syncSpec struct {
+	ID       string   `json:"id"`
+	Local    string   `json:"local"`
+	Remote   string   `json:"remote"`
+	Delete   bool     `json:"delete,omitempty"`
+	Exclude  []string `json:"exclude,omitempty"`
+	Watch    bool     `json:"watch,omitempty"`
+	Interval string   `json:"interval,omitempty"` // 定期同步的间隔，如 "1h"
+	Debounce string   `json:"debounce,omitempty"` // 见 watch -debounce，默认 2s
+}
+
+// syncStatus 是 GET /syncs 返回的一项
+type syncStatus struct {
+	syncSpec
+	LastSync time.Time `json:"last_sync,omitempty"`
+	Error    string    `json:"error,omitempty"`
+}
+
+type daemonSync struct {
+	spec   syncSpec
+	s      *syncer
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// daemon 是 bpcs daemon 的状态
+type daemon struct {
+	a        *app
+	m        *pcs.TransferManager
+	path     string          // syncs.json
+	token    string          // 请求必须携带的 bearer token，不能为空
+	loopback bool            // 只监听本机地址，此时 Host 也必须是本机
+	ctx      context.Context // 各同步项的运行期
+
+	mu     sync.Mutex
+	syncs  map[string]*daemonSync
+	nextID int
+}
+
+// load 读取并启动保存的同步项
+func (d *daemon) load() error {
+	data, err := os.ReadFile(d.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var specs []syncSpec
+	if err := json.Unmarshal(data, &specs); err != nil {
+		return fmt.Errorf("%s: %v", d.path, err)
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, spec := range specs {
+		if n, err := strconv.Atoi(spec.ID); err == nil && n >= d.nextID {
+			d.nextID = n + 1
+		}
+		if err := d.start(spec); err != nil {
+			return fmt.Errorf("%s: sync %s: %v", d.path, spec.ID, err)
+		}
+	}
+	return nil
+}
+
+// save 保存同步项，调用时需持有 d.mu
+func (d *daemon) save() error {
+	specs := make([]syncSpec, 0, len(d.syncs))
+	for _, ds := range d.sorted() {
+		specs = append(specs, ds.spec)
+	}
+	data, err := json.MarshalIndent(specs, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := d.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, d.path)
+}
+
+// sorted 按ID的数值顺序返回同步项，调用时需持有 d.mu
+func (d *daemon) sorted() []*daemonSync {
+	list := make([]*daemonSync, 0, len(d.syncs))
+	for _, ds := range d.syncs {
+		list = append(list, ds)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		a, _ := strconv.Atoi(list[i].spec.ID)
+		b, _ := strconv.Atoi(list[j].spec.ID)
+		return a < b
+	})
+	return list
+}
+
+// start 校验并开始运行同步项，调用时需持有 d.mu
+func (d *daemon) start(spec syncSpec) error {
+	if !filepath.IsAbs(spec.Local) {
+		return fmt.Errorf("%w: local path %q is not absolute", pcs.ErrInvalidArgument, spec.Local)
+	}
+	if !isDir(spec.Local) {
+		return fmt.Errorf("%w: %q is not a directory", pcs.ErrInvalidArgument, spec.Local)
+	}
+	if spec.Remote == "" {
+		return fmt.Errorf("%w: remote path is required", pcs.ErrInvalidArgument)
+	}
+	var interval time.Duration
+	debounce := 2 * time.Second
+	for _, v := range []struct {
+		s string
+		d *time.Duration
+	}{{spec.Interval, &interval}, {spec.Debounce, &debounce}} {
+		if v.s == "" {
+			continue
+		}
+		n, err := time.ParseDuration(v.s)
+		if err != nil || n <= 0 {
+			return fmt.Errorf("%w: invalid duration %q", pcs.ErrInvalidArgument, v.s)
+		}
+		*v.d = n
+	}
+	if !spec.Watch && interval == 0 {
+		return fmt.Errorf("%w: a sync needs watch or interval", pcs.ErrInvalidArgument)
+	}
+	opt := &pcs.SyncOptions{Delete: spec.Delete, Exclude: d.a.excludes(spec.Exclude)}
+	for _, p := range spec.Exclude {
+		if _, err := path.Match(p, ""); err != nil {
+			return fmt.Errorf("%w: exclude %q: %v", pcs.ErrInvalidArgument, p, err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(d.ctx)
+	ds := &daemonSync{
+		spec: spec,
+		s: &syncer{
+			client:   d.a.client,
+			hashes:   d.a.hashes,
+			log:      d.a.stderr,
+			name:     "sync " + spec.ID,
+			local:    spec.Local,
+			remote:   d.a.remote(spec.Remote),
+			opt:      opt,
+			watch:    spec.Watch,
+			debounce: debounce,
+			interval: interval,
+		},
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	d.syncs[spec.ID] = ds
+	go func() {
+		defer close(ds.done)
+		if err := ds.s.run(ctx, func(error) error { return nil }); err != nil {
+			ds.s.logf("stopped: %v", err)
+		}
+	}()
+	return nil
+}
+
+// stop 停止全部同步项并等待它们退出
+func (d *daemon) stop() {
+	d.mu.Lock()
+	list := d.sorted()
+	d.mu.Unlock()
+	for _, ds := range list {
+		ds.cancel()
+		<-ds.done
+	}
+}
+
+func (d *daemon) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /status", d.status)
+	mux.HandleFunc("GET /jobs", d.listJobs)
+	mux.HandleFunc("POST /jobs", d.addJobs)
+	mux.HandleFunc("GET /jobs/{id}", d.getJob)
+	mux.HandleFunc("POST /jobs/{id}/{action}", d.jobAction)
+	mux.HandleFunc("GET /syncs", d.listSyncs)
+	mux.HandleFunc("POST /syncs", d.addSync)
+	mux.HandleFunc("DELETE /syncs/{id}", d.deleteSync)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 网页可以向本机地址发送请求，DNS rebinding 还能让它读到响应，
+		// 因此本机监听时也检查 Host，并且总是要求 token
+		if d.loopback && !isLoopbackHost(r.Host) {
+			writeAPIError(w, http.StatusForbidden, fmt.Errorf("host %q is not a loopback address", r.Host))
+			return
+		}
+		want := "Bearer " + d.token
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte(want)) != 1 {
+			writeAPIError(w, http.StatusUnauthorized, errors.New("missing or wrong bearer token"))
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// decodeAPI 将 JSON 请求体解码到 v。请求体必须声明为 application/json，
+// 这样网页无法不经 CORS 预检就以表单或 text/plain 发送请求。
+func decodeAPI(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if mt, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || mt != "application/json" {
+		writeAPIError(w, http.StatusUnsupportedMediaType, errors.New("request body must be application/json"))
+		return false
+	}
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		writeAPIError(w, http.StatusBadRequest, err)
+		return false
+	}
+	return true
+}
+
+// loadDaemonToken 读取 path 中的 token，文件不存在时生成一个随机 token 并保存。
+func loadDaemonToken(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		if token := strings.TrimSpace(string(data)); token != "" {
+			return token, nil
+		}
+	} else if !os.IsNotExist(err) {
+		return "", err
+	}
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(b)
+	if err := os.WriteFile(path, []byte(token+"\n"), 0600); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// isLoopbackAddr 报告监听地址 addr 是否只能从本机访问
+func isLoopbackAddr(addr string) (bool, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false, err
+	}
+	return host != "" && isLoopbackHost(host), nil
+}
+
+// isLoopbackHost 报告 host（可以带端口）是否为 localhost 或本机 IP
+func isLoopbackHost(host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(strings.Trim(host, "[]"), ".")
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func (d *daemon) status(w http.ResponseWriter, r *http.Request) {
+	counts := make(map[pcs.JobStatus]int)
+	for _, j := range d.m.Jobs() {
+		counts[j.Status]++
+	}
+	d.mu.Lock()
+	n := len(d.syncs)
+	d.mu.Unlock()
+	writeAPI(w, http.StatusOK, map[string]interface{}{"jobs": counts, "syncs": n})
+}
+
+func (d *daemon) listJobs(w http.ResponseWriter, r *http.Request) {
+	writeAPI(w, http.StatusOK, d.m.Jobs())
+}
+
+func (d *daemon) getJob(w http.ResponseWriter, r *http.Request) {
+	j, err := d.m.Job(r.PathValue("id"))
+	if err != nil {
+		writeAPIError(w, http.StatusNotFound, err)
+		return
+	}
+	writeAPI(w, http.StatusOK, j)
+}
+
+func (d *daemon) addJobs(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Kind          pcs.TransferKind `json:"kind"`
+		Local         string           `json:"local"`
+		Remote        string           `json:"remote"`
+		Priority      int              `json:"priority"`
+		OnDup         pcs.OnDup        `json:"ondup"`
+		SkipIdentical bool             `json:"skip_identical"` // 远程已有相同内容的文件不上传
+	}
+	if !decodeAPI(w, r, &req) {
+		return
+	}
+	if !filepath.IsAbs(req.Local) || req.Remote == "" {
+		writeAPIError(w, http.StatusBadRequest, errors.New("an absolute local path and a remote path are required"))
+		return
+	}
+	if req.OnDup == "" {
+		req.OnDup = pcs.OnDupOverwrite
+	}
+
+	b := &batch{m: d.m, opts: []pcs.JobOption{pcs.WithPriority(req.Priority)}, expected: make(map[string]int64),
+		ctx: r.Context(), skipIdentical: req.SkipIdentical}
+	remote := d.a.remote(req.Remote)
+	var err error
+	switch req.Kind {
+	case pcs.UploadJob:
+		err = addUploads(b, req.Local, remote, req.OnDup)
+	case pcs.DownloadJob:
+		err = d.a.addDownloads(r.Context(), b, remote, req.Local)
+	default:
+		err = fmt.Errorf("%w: kind must be %q or %q", pcs.ErrInvalidArgument, pcs.UploadJob, pcs.DownloadJob)
+	}
+	// 出错前已添加的任务仍在队列中，一并返回
+	jobs := make([]pcs.Job, 0, len(b.ids))
+	for _, id := range b.ids {
+		if j, jerr := d.m.Job(id); jerr == nil {
+			jobs = append(jobs, j)
+		}
+	}
+	if err != nil {
+		writeAPIError(w, apiStatus(err), err)
+		return
+	}
+	writeAPI(w, http.StatusCreated, jobs)
+}
+
+func (d *daemon) jobAction(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	var err error
+	switch r.PathValue("action") {
+	case "pause":
+		err = d.m.Pause(id)
+	case "resume":
+		err = d.m.Resume(id)
+	case "priority":
+		var req struct {
+			Priority int `json:"priority"`
+		}
+		if !decodeAPI(w, r, &req) {
+			return
+		}
+		err = d.m.SetPriority(id, req.Priority)
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		writeAPIError(w, apiStatus(err), err)
+		return
+	}
+	d.getJob(w, r)
+}
+
+func (d *daemon) listSyncs(w http.ResponseWriter, r *http.Request) {
+	d.mu.Lock()
+	list := d.sorted()
+	d.mu.Unlock()
+	syncs := make([]syncStatus, len(list))
+	for i, ds := range list {
+		syncs[i].syncSpec = ds.spec
+		last, err := ds.s.status()
+		syncs[i].LastSync = last
+		if err != nil {
+			syncs[i].Error = err.Error()
+		}
+	}
+	writeAPI(w, http.StatusOK, syncs)
+}
+
+func (d *daemon) addSync(w http.ResponseWriter, r *http.Request) {
+	var spec syncSpec
+	if !decodeAPI(w, r, &spec) {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	spec.ID = strconv.Itoa(d.nextID)
+	if err := d.start(spec); err != nil {
+		writeAPIError(w, apiStatus(err), err)
+		return
+	}
+	d.nextID++
+	if err := d.save(); err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeAPI(w, http.StatusCreated, syncStatus{syncSpec: spec})
+}
+
+func (d *daemon) deleteSync(w http.ResponseWriter, r *http.Request) {
+	d.mu.Lock()
+	ds, ok := d.syncs[r.PathValue("id")]
+	if ok {
+		delete(d.syncs, ds.spec.ID)
+	}
+	var err error
+	if ok {
+		err = d.save()
+	}
+	d.mu.Unlock()
+	if !ok {
+		writeAPIError(w, http.StatusNotFound, errors.New("sync not found"))
+		return
+	}
+	ds.cancel()
+	<-ds.done
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// apiStatus 返回 err 对应的 HTTP 状态码
+func apiStatus(err error) int {
+	switch {
+	case errors.Is(err, pcs.ErrJobNotFound), errors.Is(err, pcs.ErrFileNotExist), os.IsNotExist(err):
+		return http.StatusNotFound
+	case errors.Is(err, pcs.ErrInvalidArgument):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+func writeAPI(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeAPIError(w http.ResponseWriter, status int, err error) {
+	writeAPI(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/holys/baidu-pcs/v2"
+	"github.com/holys/baidu-pcs/v2/pcstest"
+)
+
+func newTestDaemon(t *testing.T) *daemon {
+	srv := pcstest.NewServer()
+	t.Cleanup(srv.Close)
+	a := &app{client: srv.NewClient(), root: "/apps/t", stdout: new(strings.Builder), stderr: new(strings.Builder)}
+	m, err := pcs.NewTransferManager(a.client, filepath.Join(t.TempDir(), "jobs.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &daemon{a: a, m: m, path: filepath.Join(t.TempDir(), "syncs.json"), token: "tok", loopback: true,
+		ctx: context.Background(), syncs: make(map[string]*daemonSync), nextID: 1}
+}
+
+func TestDaemonAPIRequiresTokenHostAndJSON(t *testing.T) {
+	h := newTestDaemon(t).handler()
+	body := `{"priority": 1}`
+	tests := []struct {
+		name        string
+		host        string
+		auth        string
+		contentType string
+		want        int
+	}{
+		{"ok", "127.0.0.1:7681", "Bearer tok", "application/json", http.StatusNotFound},
+		{"localhost", "localhost:7681", "Bearer tok", "application/json; charset=utf-8", http.StatusNotFound},
+		{"no token", "127.0.0.1:7681", "", "application/json", http.StatusUnauthorized},
+		{"wrong token", "127.0.0.1:7681", "Bearer nope", "application/json", http.StatusUnauthorized},
+		{"rebound host", "evil.example:7681", "Bearer tok", "application/json", http.StatusForbidden},
+		{"text/plain", "127.0.0.1:7681", "Bearer tok", "text/plain", http.StatusUnsupportedMediaType},
+		{"form", "[::1]:7681", "Bearer tok", "application/x-www-form-urlencoded", http.StatusUnsupportedMediaType},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("POST", "/jobs/1/priority", strings.NewReader(body))
+		r.Host = tt.host
+		if tt.auth != "" {
+			r.Header.Set("Authorization", tt.auth)
+		}
+		r.Header.Set("Content-Type", tt.contentType)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		// 通过检查的请求因任务不存在返回404
+		if w.Code != tt.want {
+			t.Errorf("%s: status %d, want %d: %s", tt.name, w.Code, tt.want, w.Body)
+		}
+	}
+}
+
+func TestDaemonNonLoopbackAllowsAnyHost(t *testing.T) {
+	d := newTestDaemon(t)
+	d.loopback = false
+	r := httptest.NewRequest("GET", "/status", nil)
+	r.Host = "nas.lan:7681"
+	r.Header.Set("Authorization", "Bearer tok")
+	w := httptest.NewRecorder()
+	d.handler().ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("status %d: %s", w.Code, w.Body)
+	}
+}
+
+func TestLoadDaemonToken(t *testing.T) {
+	p := filepath.Join(t.TempDir(), daemonTokenFile)
+	token, err := loadDaemonToken(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(token) != 32 {
+		t.Errorf("token %q, want 32 hex digits", token)
+	}
+	if fi, err := os.Stat(p); err != nil || fi.Mode().Perm() != 0600 {
+		t.Errorf("token file: %v %v", fi.Mode(), err)
+	}
+	again, err := loadDaemonToken(p)
+	if err != nil || again != token {
+		t.Errorf("second load = %q, %v; want %q", again, err, token)
+	}
+}
+
+func TestIsLoopbackAddr(t *testing.T) {
+	for addr, want := range map[string]bool{
+		"127.0.0.1:7681": true,
+		"[::1]:7681":     true,
+		"localhost:0":    true,
+		":7681":          false,
+		"0.0.0.0:7681":   false,
+		"10.0.0.2:7681":  false,
+		"nas.lan:7681":   false,
+	} {
+		if got, err := isLoopbackAddr(addr); err != nil || got != want {
+			t.Errorf("isLoopbackAddr(%q) = %v, %v; want %v", addr, got, err, want)
+		}
+	}
+}
+
+func TestRunDaemonRefusesPublicListenWithoutToken(t *testing.T) {
+	h, err := pcs.NewHashCache(filepath.Join(t.TempDir(), "hashes.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := &app{client: pcs.NewClient(""), hashes: h, stderr: new(strings.Builder)}
+	t.Setenv("BAIDU_PCS_DAEMON_TOKEN", "")
+	err = runDaemon(context.Background(), a, []string{"-listen", "0.0.0.0:0", "-state", t.TempDir()})
+	if err == nil || !strings.Contains(err.Error(), "-api-token") {
+		t.Fatalf("runDaemon = %v, want an error asking for -api-token", err)
+	}
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/holys/baidu-pcs/v2"
+)
+
+func runLs(ctx context.Context, a *app, args []string) error {
+	fset := flags(a, "ls")
+	long := fset.Bool("l", false, "show type, size and modification time")
+	if err := a.parse(fset, args); err != nil {
+		return err
+	}
+	if fset.NArg() > 1 {
+		return errUsage("ls")
+	}
+	dir := a.remote(fset.Arg(0))
+
+	for f, err := range a.client.Files(ctx, dir) {
+		if err != nil {
+			return err
+		}
+		switch {
+		case *long:
+			fmt.Fprintln(a.stdout, f)
+		case f.IsDirectory():
+			fmt.Fprintln(a.stdout, path.Base(f.Path)+"/")
+		default:
+			fmt.Fprintln(a.stdout, path.Base(f.Path))
+		}
+	}
+	return nil
+}
+
+func runGet(ctx context.Context, a *app, args []string) error {
+	fset := flags(a, "get")
+	a.transferFlags(fset)
+	a.cryptFlags(fset)
+	if err := a.parse(fset, args); err != nil {
+		return err
+	}
+	if fset.NArg() < 2 {
+		return errUsage("get")
+	}
+	srcs, local := fset.Args()[:fset.NArg()-1], fset.Arg(fset.NArg()-1)
+
+	// 只有一个源且 local 不是已有的目录时，local 是下载的目标路径；否则下载到 local 目录中。
+	into := len(srcs) > 1 || isDir(local)
+	return a.transfer(ctx, func(b *batch) error {
+		for _, src := range srcs {
+			remote, dst := a.remote(src), local
+			if into {
+				dst = filepath.Join(local, path.Base(remote))
+			}
+			if err := a.addDownloads(ctx, b, remote, dst); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// addDownloads 添加下载远程文件 remote 的任务；remote 是目录时下载其中的全部文件。
+func (a *app) addDownloads(ctx context.Context, b *batch, remote, local string) error {
+	meta, _, err := a.client.GetMeta(ctx, remote)
+	if err != nil {
+		return err
+	}
+	if !meta.IsDirectory() {
+		return b.download(remote, local, meta.Size)
+	}
+
+	// Find 按 -checkers 并发列出各级目录
+	files, err := a.client.Find(ctx, remote, nil)
+	if err != nil {
+		return err
+	}
+	prefix := strings.TrimSuffix(remote, "/") + "/"
+	for _, f := range files {
+		dst := filepath.Join(local, filepath.FromSlash(strings.TrimPrefix(f.Path, prefix)))
+		if f.IsDirectory() {
+			if err := os.MkdirAll(dst, 0755); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := b.download(f.Path, dst, f.Size); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func runPut(ctx context.Context, a *app, args []string) error {
+	fset := flags(a, "put")
+	a.transferFlags(fset)
+	a.cryptFlags(fset)
+	newCopy := fset.Bool("newcopy", false, "keep existing remote files and upload under a new name")
+	skip := fset.Bool("skip-identical", false, "skip files whose remote copy already has the same size and md5")
+	if err := a.parse(fset, args); err != nil {
+		return err
+	}
+	if fset.NArg() < 2 {
+		return errUsage("put")
+	}
+	srcs, dir := fset.Args()[:fset.NArg()-1], a.remote(fset.Arg(fset.NArg()-1))
+	ondup := pcs.OnDupOverwrite
+	if *newCopy {
+		ondup = pcs.OnDupNewCopy
+	}
+	if srcs[0] == "-" {
+		// 标准输入上传为文件，最后一个参数是文件路径而不是目录
+		if len(srcs) != 1 {
+			return errUsage("put")
+		}
+		return a.putStdin(ctx, pcs.NewFileOptions(dir, ondup))
+	}
+
+	return a.transfer(ctx, func(b *batch) error {
+		b.skipIdentical = *skip
+		for _, src := range srcs {
+			if err := addUploads(b, src, path.Join(dir, filepath.Base(src)), ondup); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// putStdin 将标准输入中的全部数据上传为 opt.Path，数据长度不需要预先知道。
+func (a *app) putStdin(ctx context.Context, opt *pcs.FileOptions) error {
+	r := &countingReader{r: a.stdin}
+	p := newProgress(a.stderr)
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(progressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				n := r.n.Load()
+				p.counts(0, 1, n, n)
+			}
+		}
+	}()
+	_, err := a.client.UploadReader(ctx, r, opt)
+	close(stop)
+	<-done
+	if err != nil {
+		return err
+	}
+	p.finish(1, r.n.Load())
+	return nil
+}
+
+// countingReader 记录从 r 读出的字节数，可以在读取的同时从其他 goroutine 获取
+type countingReader struct {
+	r io.Reader
+	n atomic.Int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n.Add(int64(n))
+	return n, err
+}
+
+// addUploads 添加将本地文件 local 上传为 remote 的任务；local 是目录时上传其中的全部文件。
+func addUploads(b *batch, local, remote string, ondup pcs.OnDup) error {
+	return filepath.WalkDir(local, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(local, p)
+		if err != nil {
+			return err
+		}
+		return b.upload(p, pcs.NewFileOptions(path.Join(remote, filepath.ToSlash(rel)), ondup))
+	})
+}
+
+func runRm(ctx context.Context, a *app, args []string) error {
+	fset := flags(a, "rm")
+	if err := a.parse(fset, args); err != nil {
+		return err
+	}
+	if fset.NArg() == 0 {
+		return errUsage("rm")
+	}
+	paths := make([]string, fset.NArg())
+	for i, p := range fset.Args() {
+		paths[i] = a.remote(p)
+	}
+	_, err := a.client.BatchDelete(ctx, paths)
+	return err
+}
+
+func runMkdir(ctx context.Context, a *app, args []string) error {
+	fset := flags(a, "mkdir")
+	if err := a.parse(fset, args); err != nil {
+		return err
+	}
+	if fset.NArg() == 0 {
+		return errUsage("mkdir")
+	}
+	for _, p := range fset.Args() {
+		if _, _, err := a.client.Mkdir(ctx, a.remote(p)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func runQuota(ctx context.Context, a *app, args []string) error {
+	fset := flags(a, "quota")
+	if err := a.parse(fset, args); err != nil {
+		return err
+	}
+	if fset.NArg() != 0 {
+		return errUsage("quota")
+	}
+	q, _, err := a.client.GetQuota(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(a.stdout, q)
+	return nil
+}
+
+func isDir(p string) bool {
+	fi, err := os.Stat(p)
+	return err == nil && fi.IsDir()
+}
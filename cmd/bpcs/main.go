@@ -0,0 +1,278 @@
+// bpcs 是基于 baidu-pcs 的命令行客户端。
+//
+// 用法：
+//
+//	bpcs [全局选项] 命令 [参数]
+//
+// access token 由 -token 或环境变量 BAIDU_PCS_TOKEN 指定。不以 / 开头的远程路径相对于
+// -root（默认为环境变量 BAIDU_PCS_ROOT），PCS 只允许访问 /apps/应用名 下的文件，
+// 因此通常将 -root 设为该目录。
+//
+// -crypt 在上传前加密、下载后解密文件内容，密钥由 -crypt-key（十六进制，默认为环境变量
+// BAIDU_PCS_CRYPT_KEY）或 -crypt-pass（口令，默认为环境变量 BAIDU_PCS_CRYPT_PASS）指定，
+// -crypt-names 同时加密 -root 之下的文件名。口令与保存在 -root 下 baidu-pcs.salt 中的
+// 随机盐一起导出密钥，第一次使用时生成该文件；同步时不会删除它。
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/holys/baidu-pcs/v2"
+)
+
+// command 是一个子命令
+type command struct {
+	name  string
+	args  string // 用法中的参数说明
+	short string // 一行说明
+	run   func(ctx context.Context, a *app, args []string) error
+}
+
+const (
+	defaultTransfers = 4
+	defaultCheckers  = 8
+	defaultRetries   = 3
+)
+
+// commands 在 init 中赋值，因为各命令通过 lookup 引用了它
+var commands []*command
+
+func init() {
+	commands = []*command{
+		{"ls", "[-l] [DIR]", "list a remote directory", runLs},
+		{"get", "REMOTE... LOCAL", "download remote files or directories", runGet},
+		{"put", "[-skip-identical] LOCAL... REMOTE_DIR | - REMOTE_FILE", "upload local files or directories, or standard input", runPut},
+		{"rm", "REMOTE...", "delete remote files or directories", runRm},
+		{"mkdir", "DIR...", "create remote directories", runMkdir},
+		{"quota", "", "show used and total space", runQuota},
+		{"clouddl", "add|list|status|cancel|wait [args]", "manage offline downloads into the drive", runClouddl},
+		{"share", "create|list|cancel|save [args]", "manage share links and save shared files", runShare},
+		{"crypt", "check [-max N] [REMOTE...]", "verify the -crypt key against remote files", runCrypt},
+		{"sync", "[-delete] [-dry-run] [-exclude PATTERN] LOCAL_DIR REMOTE_DIR", "make a remote directory match a local one", runSync},
+		{"watch", "[-delete] [-debounce D] [-exclude PATTERN] LOCAL_DIR REMOTE_DIR", "keep a remote directory in sync with a local one", runWatch},
+		{"browse", "[DIR]", "browse remote directories in a full-screen terminal UI and queue transfers", runBrowse},
+		{"daemon", "[-listen ADDR] [-state DIR] [-api-token TOKEN]", "run transfers and syncs in the background, controlled over HTTP", runDaemon},
+		{"open", "[-transcode TYPE] [-direct] [-player CMD] REMOTE", "play a remote video through a local URL", runOpen},
+	}
+}
+
+// app 是各子命令共用的状态
+type app struct {
+	client *pcs.Client // 在 parse 中创建
+	token  string
+	root   string // 相对远程路径的基准目录
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+
+	// 全局选项，传输类的命令可以覆盖，见 transferFlags
+	bwlimit   sizeFlag
+	blockSize sizeFlag
+	transfers int
+	checkers  int
+	retries   int
+
+	// 加密选项，见 cryptFlags
+	crypt      bool
+	cryptNames bool
+	cryptKey   string
+	cryptPass  string
+
+	// newClient 创建 Client，为 nil 时使用 pcs.NewClient 和 token
+	newClient func(opts ...pcs.ClientOption) *pcs.Client
+
+	// hashes 不为 nil 时用于 Client 的 WithHashCache
+	hashes *pcs.HashCache
+}
+
+// parse 解析子命令的参数，然后按最终的选项创建 Client。
+func (a *app) parse(fset *flag.FlagSet, args []string) error {
+	if err := fset.Parse(args); err != nil {
+		return err
+	}
+	if a.client == nil {
+		opts := []pcs.ClientOption{pcs.WithListConcurrency(a.checkers), pcs.WithRetry(a.retries, 0)}
+		if a.blockSize > 0 {
+			if err := pcs.ValidateBlockSize(int64(a.blockSize)); err != nil {
+				return fmt.Errorf("invalid -block-size: %v", err)
+			}
+			opts = append(opts, pcs.WithBlockSize(int64(a.blockSize)))
+		}
+		if a.hashes != nil {
+			opts = append(opts, pcs.WithHashCache(a.hashes))
+		}
+		newClient := a.newClient
+		if newClient == nil {
+			newClient = func(opts ...pcs.ClientOption) *pcs.Client {
+				return pcs.NewClient(a.token, opts...)
+			}
+		}
+		crypt, err := a.cryptOptions(context.Background(), func() *pcs.Client { return newClient(opts...) })
+		if err != nil {
+			return err
+		}
+		a.client = newClient(append(crypt, opts...)...)
+	}
+	a.client.SetBandwidthLimit(int64(a.bwlimit))
+	return nil
+}
+
+// transferFlags 为子命令注册 -bwlimit、-block-size、-transfers、-checkers 和 -retries，默认值为全局选项的值。
+func (a *app) transferFlags(fset *flag.FlagSet) {
+	fset.Var(&a.bwlimit, "bwlimit", "bandwidth limit per second as a `size` such as 512K or 10M; 0 means unlimited")
+	fset.Var(&a.blockSize, "block-size", "upload large files in blocks of this `size`, 1M to 2G; 0 adjusts it to the connection")
+	fset.IntVar(&a.transfers, "transfers", a.transfers, "number of files to transfer in parallel")
+	fset.IntVar(&a.checkers, "checkers", a.checkers, "number of remote directories to list in parallel")
+	fset.IntVar(&a.retries, "retries", a.retries, "attempts per request, including each block of a large upload, while it fails temporarily")
+}
+
+// remote 将命令行中的远程路径转换为绝对路径
+func (a *app) remote(p string) string {
+	if strings.HasPrefix(p, "/") {
+		return pcs.CleanPath(p)
+	}
+	return pcs.CleanPath(path.Join(a.root, p))
+}
+
+func main() {
+	a := &app{
+		stdin:     os.Stdin,
+		stdout:    os.Stdout,
+		stderr:    os.Stderr,
+		transfers: defaultTransfers,
+		checkers:  defaultCheckers,
+		retries:   defaultRetries,
+		cryptKey:  os.Getenv("BAIDU_PCS_CRYPT_KEY"),
+		cryptPass: os.Getenv("BAIDU_PCS_CRYPT_PASS"),
+	}
+	flag.StringVar(&a.token, "token", os.Getenv("BAIDU_PCS_TOKEN"), "access token")
+	root := flag.String("root", os.Getenv("BAIDU_PCS_ROOT"), "remote directory relative paths are resolved against")
+	a.transferFlags(flag.CommandLine)
+	a.cryptFlags(flag.CommandLine)
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+	cmd := lookup(flag.Arg(0))
+	if cmd == nil {
+		fmt.Fprintf(os.Stderr, "bpcs: unknown command %q\n", flag.Arg(0))
+		usage()
+		os.Exit(2)
+	}
+	if a.token == "" {
+		fmt.Fprintln(os.Stderr, "bpcs: access token not set, use -token or BAIDU_PCS_TOKEN")
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	a.root = pcs.CleanPath(*root)
+	err := cmd.run(ctx, a, flag.Args()[1:])
+	if a.client != nil {
+		a.client.Close(context.Background())
+	}
+	if err != nil {
+		if !errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintf(os.Stderr, "bpcs %s: %v\n", cmd.name, err)
+		}
+		os.Exit(1)
+	}
+}
+
+func lookup(name string) *command {
+	for _, c := range commands {
+		if c.name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+func usage() {
+	w := flag.CommandLine.Output()
+	fmt.Fprintln(w, "usage: bpcs [flags] command [args]")
+	fmt.Fprintln(w, "\ncommands:")
+	cmds := append([]*command(nil), commands...)
+	sort.Slice(cmds, func(i, j int) bool { return cmds[i].name < cmds[j].name })
+	for _, c := range cmds {
+		fmt.Fprintf(w, "  %-8s %s\n", c.name, c.short)
+	}
+	fmt.Fprintln(w, "\nflags:")
+	flag.PrintDefaults()
+}
+
+// flags 返回子命令 cmd 的 FlagSet，出错时返回错误而不是退出。
+func flags(a *app, cmd string) *flag.FlagSet {
+	fs := flag.NewFlagSet(cmd, flag.ContinueOnError)
+	fs.SetOutput(a.stderr)
+	fs.Usage = func() {
+		c := lookup(cmd)
+		fmt.Fprintf(a.stderr, "usage: bpcs %s %s\n\n%s\n", c.name, c.args, c.short)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// errUsage 报告子命令的参数个数错误
+func errUsage(cmd string) error {
+	c := lookup(cmd)
+	return fmt.Errorf("usage: bpcs %s %s", c.name, c.args)
+}
+
+// sizeFlag 是以字节为单位的大小，可以带 K、M、G、T 后缀（1024进制），如 512K、1.5M。
+type sizeFlag int64
+
+func (s *sizeFlag) String() string {
+	return strconv.FormatInt(int64(*s), 10)
+}
+
+func (s *sizeFlag) Set(v string) error {
+	n, err := parseSize(v)
+	if err != nil {
+		return err
+	}
+	*s = sizeFlag(n)
+	return nil
+}
+
+// listFlag 是可以重复指定的字符串选项
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *listFlag) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+func parseSize(s string) (int64, error) {
+	t := strings.TrimSuffix(strings.TrimSuffix(strings.ToUpper(strings.TrimSpace(s)), "B"), "I")
+	mult := 1.0
+	if n := len(t); n > 0 {
+		if i := strings.IndexByte("KMGT", t[n-1]); i >= 0 {
+			mult = float64(int64(1) << (10 * (i + 1)))
+			t = t[:n-1]
+		}
+	}
+	f, err := strconv.ParseFloat(t, 64)
+	if err != nil || f < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return int64(f * mult), nil
+}
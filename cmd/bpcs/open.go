@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"path"
+	"strings"
+	"time"
+)
+
+func runOpen(ctx context.Context, a *app, args []string) error {
+	fset := flags(a, "open")
+	transcode := fset.String("transcode", "", "play a transcoded `type` such as M3U8_854_480 instead of the original file")
+	direct := fset.Bool("direct", false, "print a signed download URL instead of starting the local proxy")
+	player := fset.String("player", "", "launch `cmd` (for example mpv or vlc) with the URL and exit when it does")
+	addr := fset.String("addr", "127.0.0.1:0", "listen `address` of the local proxy")
+	if err := a.parse(fset, args); err != nil {
+		return err
+	}
+	if fset.NArg() != 1 {
+		return errUsage("open")
+	}
+	remote := a.remote(fset.Arg(0))
+	if a.crypt && (*direct || *transcode != "") {
+		return errors.New("-direct and -transcode cannot decrypt -crypt files")
+	}
+
+	if *direct {
+		if *transcode != "" {
+			return errors.New("-direct and -transcode are mutually exclusive")
+		}
+		mirrors, err := a.client.LocateDownloadMirrors(ctx, remote)
+		if err != nil {
+			return err
+		}
+		return a.play(ctx, *player, mirrors.Next())
+	}
+
+	// 代理在返回时停止
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	h, name, err := a.streamHandler(ctx, remote, *transcode)
+	if err != nil {
+		return err
+	}
+	ln, err := net.Listen("tcp", *addr)
+	if err != nil {
+		return err
+	}
+	// 只代理一个文件，URL 中的名字仅供播放器识别格式
+	srv := &http.Server{Handler: h}
+	go srv.Serve(ln)
+	defer srv.Close()
+
+	u := (&url.URL{Scheme: "http", Host: ln.Addr().String(), Path: "/" + name}).String()
+	if err := a.play(ctx, *player, u); err != nil {
+		return err
+	}
+	if *player != "" {
+		return nil
+	}
+	fmt.Fprintln(a.stderr, "serving until interrupted")
+	<-ctx.Done()
+	return nil
+}
+
+// play 输出 u，player 不为空时用它播放 u 并等待其退出。
+func (a *app) play(ctx context.Context, player, u string) error {
+	fmt.Fprintln(a.stdout, u)
+	if player == "" {
+		return nil
+	}
+	fields := strings.Fields(player)
+	cmd := exec.CommandContext(ctx, fields[0], append(fields[1:], u)...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = a.stdin, a.stdout, a.stderr
+	return cmd.Run()
+}
+
+// streamHandler 返回本地代理提供 remote 内容的 Handler 和它在 URL 中的名字：
+// 指定 typ 时提供转码后的播放列表；否则按 Range 请求读取原文件，使播放器可以跳转。
+// 设置了 -crypt 时只能按顺序解密，不支持跳转。
+func (a *app) streamHandler(ctx context.Context, remote, typ string) (http.Handler, string, error) {
+	name := path.Base(remote)
+	if typ != "" {
+		// 先请求一次，尽早报告不支持的格式或文件
+		if _, err := a.client.StreamingPlaylist(ctx, remote, typ); err != nil {
+			return nil, "", err
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// 每次重新请求，分段地址中的签名会过期
+			list, err := a.client.StreamingPlaylist(r.Context(), remote, typ)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadGateway)
+				return
+			}
+			w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+			w.Write(list)
+		}), strings.TrimSuffix(name, path.Ext(name)) + ".m3u8", nil
+	}
+
+	if a.crypt {
+		if _, _, err := a.client.GetMeta(ctx, remote); err != nil {
+			return nil, "", err
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if ct := mime.TypeByExtension(path.Ext(name)); ct != "" {
+				w.Header().Set("Content-Type", ct)
+			}
+			if _, err := a.client.DownloadTo(r.Context(), remote, w); err != nil && r.Context().Err() == nil {
+				fmt.Fprintf(a.stderr, "%s: %v\n", remote, err)
+			}
+		}), name, nil
+	}
+
+	// 在 ctx 结束前一直打开，各请求共用预读的分块。ReadAt 可以并发调用
+	f, err := a.client.Open(ctx, remote)
+	if err != nil {
+		return nil, "", err
+	}
+	context.AfterFunc(ctx, func() { f.Close() })
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, name, time.Time{}, io.NewSectionReader(f, 0, f.Size()))
+	}), name, nil
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/holys/baidu-pcs/v2"
+)
+
+const (
+	progressInterval = 500 * time.Millisecond
+	progressBarWidth = 20
+
+	// 计算速度时最近一次采样的权重
+	speedSmoothing = 0.3
+)
+
+// progress 显示传输进度。输出到终端时原地刷新每个执行中任务的进度条和总体的速度及剩余时间；
+// 否则只在每个任务结束时输出一行，不干扰日志或管道。
+type progress struct {
+	w     io.Writer
+	live  bool
+	start time.Time
+	lines int // 上次刷新输出的行数
+
+	lastBytes int64
+	lastAt    time.Time
+	speed     float64 // 字节/秒
+
+	reported map[string]bool // 非终端时已输出的任务
+}
+
+func newProgress(w io.Writer) *progress {
+	now := time.Now()
+	return &progress{w: w, live: isTerminal(w), start: now, lastAt: now, reported: make(map[string]bool)}
+}
+
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// jobs 按 TransferManager 中任务的快照刷新进度。expected 是任务开始执行、确定 Job.Size 之前预计的大小。
+func (p *progress) jobs(jobs []pcs.Job, expected map[string]int64) {
+	var (
+		done, bytes, total int64
+		running            []pcs.Job
+	)
+	for _, j := range jobs {
+		size := j.Size
+		if size == 0 {
+			size = expected[j.ID]
+		}
+		total += size
+		switch j.Status {
+		case pcs.JobDone:
+			done++
+			bytes += size
+		case pcs.JobFailed:
+			done++
+			bytes += j.Transferred
+		case pcs.JobRunning:
+			running = append(running, j)
+			bytes += j.Transferred
+		default:
+			bytes += j.Transferred
+		}
+
+		if !p.live && (j.Status == pcs.JobDone || j.Status == pcs.JobFailed) && !p.reported[j.ID] {
+			p.reported[j.ID] = true
+			fmt.Fprintf(p.w, "%s %s %s (%s)\n", j.Status, j.Kind, jobName(j), pcs.HumanSize(uint64(size)))
+		}
+	}
+
+	lines := make([]string, 0, len(running)+1)
+	for _, j := range running {
+		lines = append(lines, jobBar(j))
+	}
+	lines = append(lines, p.summary(done, int64(len(jobs)), bytes, total))
+	p.draw(lines)
+}
+
+// counts 按已完成的项数和字节数刷新进度，用于不经过 TransferManager 的操作，如 sync。
+func (p *progress) counts(done, count, bytes, total int64) {
+	p.draw([]string{p.summary(done, count, bytes, total)})
+}
+
+func (p *progress) summary(done, count, bytes, total int64) string {
+	now := time.Now()
+	if dt := now.Sub(p.lastAt).Seconds(); dt > 0 {
+		inst := float64(bytes-p.lastBytes) / dt
+		if p.lastBytes == 0 && p.speed == 0 {
+			p.speed = inst
+		} else {
+			p.speed = speedSmoothing*inst + (1-speedSmoothing)*p.speed
+		}
+		p.lastBytes, p.lastAt = bytes, now
+	}
+
+	s := fmt.Sprintf("%d/%d files  %s/%s  %s/s", done, count,
+		pcs.HumanSize(uint64(bytes)), pcs.HumanSize(uint64(total)), pcs.HumanSize(uint64(p.speed)))
+	if p.speed >= 1 && total > bytes {
+		eta := time.Duration(float64(total-bytes) / p.speed * float64(time.Second))
+		s += "  ETA " + eta.Round(time.Second).String()
+	}
+	return s
+}
+
+// draw 在终端上用 lines 替换上次输出的各行
+func (p *progress) draw(lines []string) {
+	if !p.live {
+		return
+	}
+	var b strings.Builder
+	if p.lines > 0 {
+		fmt.Fprintf(&b, "\x1b[%dA", p.lines)
+	}
+	for _, l := range lines {
+		b.WriteString("\r\x1b[K" + l + "\n")
+	}
+	// 上次多出的行
+	for i := len(lines); i < p.lines; i++ {
+		b.WriteString("\r\x1b[K\n")
+	}
+	if extra := p.lines - len(lines); extra > 0 {
+		fmt.Fprintf(&b, "\x1b[%dA", extra)
+	}
+	p.lines = len(lines)
+	io.WriteString(p.w, b.String())
+}
+
+// finish 输出总的传输量、用时和平均速度
+func (p *progress) finish(count, bytes int64) {
+	elapsed := time.Since(p.start)
+	rate := float64(bytes) / max(elapsed.Seconds(), 0.001)
+	fmt.Fprintf(p.w, "%d files, %s in %s (%s/s)\n", count, pcs.HumanSize(uint64(bytes)),
+		elapsed.Round(time.Millisecond), pcs.HumanSize(uint64(rate)))
+}
+
+func jobName(j pcs.Job) string {
+	if j.Kind == pcs.UploadJob {
+		return j.LocalPath
+	}
+	return j.RemotePath
+}
+
+// jobBar 返回执行中任务的进度条，如 "[=========>          ]  45%  1.2 MiB/2.7 MiB  a.mp4"
+func jobBar(j pcs.Job) string {
+	frac := 0.0
+	if j.Size > 0 {
+		frac = min(float64(j.Transferred)/float64(j.Size), 1)
+	}
+	n := int(frac * progressBarWidth)
+	bar := strings.Repeat("=", n)
+	if n < progressBarWidth {
+		bar += ">" + strings.Repeat(" ", progressBarWidth-n-1)
+	}
+	return fmt.Sprintf("[%s] %3.0f%%  %s/%s  %s", bar, frac*100,
+		pcs.HumanSize(uint64(j.Transferred)), pcs.HumanSize(uint64(j.Size)), path.Base(j.RemotePath))
+}
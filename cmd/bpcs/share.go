@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/holys/baidu-pcs/v2"
+)
+
+const shareUsage = `usage:
+  bpcs share create [-pwd CODE] [-period DAYS] REMOTE...
+  bpcs share list
+  bpcs share cancel ID...
+  bpcs share save URL PWD DEST
+
+PWD may be empty ("") when the URL carries ?pwd=.`
+
+// sharePwdChars 是随机生成提取码使用的字符
+const sharePwdChars = "abcdefghijkmnpqrstuvwxyz23456789"
+
+func runShare(ctx context.Context, a *app, args []string) error {
+	if len(args) == 0 {
+		return errors.New(shareUsage)
+	}
+	sub, args := args[0], args[1:]
+	switch sub {
+	case "create":
+		return shareCreate(ctx, a, args)
+	case "list":
+		return shareList(ctx, a, args)
+	case "cancel":
+		return shareCancel(ctx, a, args)
+	case "save":
+		return shareSave(ctx, a, args)
+	}
+	return fmt.Errorf("unknown subcommand %q\n%s", sub, shareUsage)
+}
+
+func shareCreate(ctx context.Context, a *app, args []string) error {
+	fset := flags(a, "share")
+	pwd := fset.String("pwd", "", "4 character extraction code, random by default")
+	period := fset.Int("period", pcs.ShareOneWeek, "days the link stays valid: 1, 7, 30, or 0 for ever")
+	if err := a.parse(fset, args); err != nil {
+		return err
+	}
+	if fset.NArg() == 0 {
+		return errors.New(shareUsage)
+	}
+	if *pwd == "" {
+		*pwd = randomPwd()
+	}
+	paths := make([]string, fset.NArg())
+	for i, p := range fset.Args() {
+		paths[i] = a.remote(p)
+	}
+
+	link, _, err := a.client.CreateShare(ctx, *pwd, *period, paths...)
+	if err != nil {
+		return err
+	}
+	printShareLink(a, link)
+	return nil
+}
+
+func shareList(ctx context.Context, a *app, args []string) error {
+	fset := flags(a, "share")
+	if err := a.parse(fset, args); err != nil {
+		return err
+	}
+	if fset.NArg() != 0 {
+		return errors.New(shareUsage)
+	}
+	links, _, err := a.client.ListShareLinks(ctx)
+	if err != nil {
+		return err
+	}
+	for _, l := range links {
+		printShareLink(a, l)
+	}
+	return nil
+}
+
+func shareCancel(ctx context.Context, a *app, args []string) error {
+	fset := flags(a, "share")
+	if err := a.parse(fset, args); err != nil {
+		return err
+	}
+	if fset.NArg() == 0 {
+		return errors.New(shareUsage)
+	}
+	ids := make([]uint64, fset.NArg())
+	for i, s := range fset.Args() {
+		id, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid share id %q", s)
+		}
+		ids[i] = id
+	}
+	_, err := a.client.CancelShares(ctx, ids...)
+	return err
+}
+
+func shareSave(ctx context.Context, a *app, args []string) error {
+	fset := flags(a, "share")
+	if err := a.parse(fset, args); err != nil {
+		return err
+	}
+	if fset.NArg() != 3 {
+		return errors.New(shareUsage)
+	}
+	link, pwd, dest := fset.Arg(0), fset.Arg(1), a.remote(fset.Arg(2))
+
+	saved, err := a.client.SaveShareLink(ctx, link, pwd, dest, nil)
+	for _, f := range saved {
+		name := f.Name
+		if f.IsDirectory() {
+			name += "/"
+		}
+		fmt.Fprintln(a.stdout, name)
+	}
+	return err
+}
+
+// printShareLink 输出一行：分享ID、带提取码的链接、过期时间和分享的第一个路径
+func printShareLink(a *app, l *pcs.ShareLink) {
+	expire := "never"
+	if l.Expire > 0 {
+		expire = time.Unix(l.Expire, 0).Format("2006-01-02 15:04")
+	}
+	fmt.Fprintf(a.stdout, "%d\t%s?pwd=%s\t%s\t%s\n", l.ShareID, l.Link, l.Pwd, expire, l.Path)
+}
+
+// randomPwd 生成随机的 4 位提取码，不含容易混淆的 0、o、1、l
+func randomPwd() string {
+	b := make([]byte, 4)
+	rand.Read(b)
+	for i := range b {
+		b[i] = sharePwdChars[int(b[i])%len(sharePwdChars)]
+	}
+	return string(b)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/holys/baidu-pcs/v2"
+)
+
+func runSync(ctx context.Context, a *app, args []string) error {
+	fset := flags(a, "sync")
+	a.transferFlags(fset)
+	a.cryptFlags(fset)
+	del := fset.Bool("delete", false, "delete remote files that no longer exist locally")
+	dryRun := fset.Bool("dry-run", false, "only print the actions that would be taken")
+	var exclude listFlag
+	fset.Var(&exclude, "exclude", "skip files matching `pattern`, such as *.tmp or .git; may be repeated")
+	if err := a.parse(fset, args); err != nil {
+		return err
+	}
+	if fset.NArg() != 2 {
+		return errUsage("sync")
+	}
+	local, remote := fset.Arg(0), a.remote(fset.Arg(1))
+
+	// 先取得计划，用于显示总量
+	plan, err := a.client.Sync(ctx, local, remote, &pcs.SyncOptions{Delete: *del, DryRun: true, Exclude: a.excludes(exclude)})
+	if err != nil {
+		return err
+	}
+	if *dryRun {
+		for _, act := range plan {
+			fmt.Fprintln(a.stdout, syncActionString(act))
+		}
+		return nil
+	}
+
+	var total int64
+	for _, act := range plan {
+		if act.Op == pcs.SyncUpload {
+			total += act.Size
+		}
+	}
+
+	p := newProgress(a.stderr)
+	var (
+		done, bytes int64
+		drawn       time.Time
+	)
+	opt := &pcs.SyncOptions{
+		Delete:  *del,
+		Exclude: a.excludes(exclude),
+		Progress: func(act pcs.SyncAction) {
+			done++
+			if act.Op == pcs.SyncUpload {
+				bytes += act.Size
+			}
+			if !p.live {
+				fmt.Fprintln(a.stderr, syncActionString(act))
+			} else if time.Since(drawn) >= progressInterval {
+				p.counts(done, int64(len(plan)), bytes, total)
+				drawn = time.Now()
+			}
+		},
+	}
+	p.counts(0, int64(len(plan)), 0, total)
+	_, err = a.client.Sync(ctx, local, remote, opt)
+	p.counts(done, int64(len(plan)), bytes, total)
+	if err != nil {
+		return err
+	}
+	p.finish(done, bytes)
+	return nil
+}
+
+func syncActionString(act pcs.SyncAction) string {
+	switch act.Op {
+	case pcs.SyncMove, pcs.SyncCopy:
+		return fmt.Sprintf("%-6s %s -> %s", act.Op, act.From, act.Path)
+	case pcs.SyncUpload:
+		return fmt.Sprintf("%-6s %s (%s)", act.Op, act.Path, pcs.HumanSize(uint64(act.Size)))
+	}
+	return fmt.Sprintf("%-6s %s", act.Op, act.Path)
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/holys/baidu-pcs/v2"
+)
+
+// batch 是一次命令添加到 TransferManager 的任务，并记录各任务预计的大小用于显示进度。
+type batch struct {
+	m        *pcs.TransferManager
+	opts     []pcs.JobOption // 添加任务时使用
+	ids      []string        // 已添加的任务
+	expected map[string]int64
+
+	// skipIdentical 为 true 时，远程已有相同内容的文件不上传，计入 identical，
+	// 此时用 ctx 查询远程文件
+	ctx           context.Context
+	skipIdentical bool
+	identical     int
+}
+
+func (b *batch) download(remote, local string, size uint64) error {
+	id, err := b.m.AddDownload(remote, local, b.opts...)
+	if err != nil {
+		return err
+	}
+	b.ids = append(b.ids, id)
+	b.expected[id] = int64(size)
+	return nil
+}
+
+func (b *batch) upload(local string, opt *pcs.FileOptions) error {
+	var id string
+	var err error
+	if b.skipIdentical {
+		id, err = b.m.AddUploadIfChanged(b.ctx, local, opt, b.opts...)
+	} else {
+		id, err = b.m.AddUpload(local, opt, b.opts...)
+	}
+	if err != nil {
+		return err
+	}
+	if id == "" {
+		b.identical++
+		return nil
+	}
+	b.ids = append(b.ids, id)
+	if fi, err := os.Stat(local); err == nil {
+		b.expected[id] = fi.Size()
+	}
+	return nil
+}
+
+// transfer 用一个临时的 TransferManager 执行 add 添加的任务，显示进度并等待全部任务结束，
+// 有任务失败时逐个报告并返回错误。中断时未完成的任务不会保留。
+func (a *app) transfer(ctx context.Context, add func(b *batch) error) error {
+	dir, err := os.MkdirTemp("", "bpcs-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	m, err := pcs.NewTransferManager(a.client, filepath.Join(dir, "jobs.json"), pcs.WithTransferConcurrency(a.transfers))
+	if err != nil {
+		return err
+	}
+	b := &batch{m: m, expected: make(map[string]int64), ctx: ctx}
+	if err := add(b); err != nil {
+		return err
+	}
+	if b.identical > 0 {
+		fmt.Fprintf(a.stderr, "%d files already up to date\n", b.identical)
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+	done := make(chan error, 1)
+	go func() { done <- m.Run(runCtx) }()
+
+	p := newProgress(a.stderr)
+	waited := make(chan error, 1)
+	go func() { waited <- m.Wait(ctx) }()
+	ticker := time.NewTicker(progressInterval)
+	defer ticker.Stop()
+wait:
+	for {
+		p.jobs(m.Jobs(), b.expected)
+		select {
+		case err = <-waited:
+			break wait
+		case <-ticker.C:
+		}
+	}
+	cancel()
+	<-done
+
+	jobs := m.Jobs()
+	p.jobs(jobs, b.expected)
+	if err != nil {
+		return err
+	}
+
+	var failed, bytes int64
+	for _, j := range jobs {
+		if j.Status == pcs.JobFailed {
+			fmt.Fprintf(a.stderr, "%s %s: %s\n", j.Kind, j.RemotePath, j.Err)
+			failed++
+		} else {
+			bytes += j.Size
+		}
+	}
+	p.finish(int64(len(jobs))-failed, bytes)
+	if failed > 0 {
+		return fmt.Errorf("%d of %d transfers failed", failed, len(jobs))
+	}
+	return nil
+}
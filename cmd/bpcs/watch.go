@@ -0,0 +1,233 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/holys/baidu-pcs/v2"
+)
+
+// watchRetry 是同步失败后重试的间隔
+const watchRetry = time.Minute
+
+func runWatch(ctx context.Context, a *app, args []string) error {
+	fset := flags(a, "watch")
+	a.transferFlags(fset)
+	a.cryptFlags(fset)
+	del := fset.Bool("delete", false, "delete remote files that no longer exist locally")
+	debounce := fset.Duration("debounce", 2*time.Second, "upload once no change has been seen for this long")
+	var exclude listFlag
+	fset.Var(&exclude, "exclude", "skip files matching `pattern`, such as *.tmp or .git; may be repeated")
+	if a.hashes == nil {
+		a.hashes = openHashCache(a)
+	}
+	if err := a.parse(fset, args); err != nil {
+		return err
+	}
+	if fset.NArg() != 2 {
+		return errUsage("watch")
+	}
+
+	s := &syncer{
+		client:   a.client,
+		hashes:   a.hashes,
+		log:      a.stderr,
+		local:    fset.Arg(0),
+		remote:   a.remote(fset.Arg(1)),
+		opt:      &pcs.SyncOptions{Delete: *del, Exclude: a.excludes(exclude)},
+		watch:    true,
+		debounce: *debounce,
+	}
+	return s.run(ctx, func(err error) error {
+		// 第一次同步出错（例如参数错误）时直接返回
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(a.stderr, "watching %s\n", s.local)
+		return nil
+	})
+}
+
+// syncer 使远程目录持续与本地目录保持一致：watch 为 true 时在本地目录变化后同步，
+// interval 大于0时定期同步。用于 watch 和 daemon。
+type syncer struct {
+	client *pcs.Client
+	hashes *pcs.HashCache // 可以为 nil
+	log    io.Writer
+	name   string // 非空时作为日志的前缀
+
+	local    string
+	remote   string
+	opt      *pcs.SyncOptions
+	watch    bool
+	debounce time.Duration
+	interval time.Duration
+
+	mu      sync.Mutex
+	last    time.Time // 上次同步结束的时间
+	lastErr error
+}
+
+// run 先同步一次并以其结果调用 ready，ready 返回错误时 run 返回该错误；
+// 之后按 watch 和 interval 继续同步，直到 ctx 结束。同步失败时在 watchRetry 后重试。
+func (s *syncer) run(ctx context.Context, ready func(err error) error) error {
+	var (
+		w      *fsnotify.Watcher
+		events <-chan fsnotify.Event
+		errs   <-chan error
+	)
+	if s.watch {
+		// 在第一次同步之前开始监视，不遗漏同步期间的变化
+		var err error
+		if w, err = fsnotify.NewWatcher(); err != nil {
+			return err
+		}
+		defer w.Close()
+		if err := watchTree(w, s.local, s.local, s.opt); err != nil {
+			return err
+		}
+		events, errs = w.Events, w.Errors
+	}
+
+	timer := time.NewTimer(time.Hour)
+	timer.Stop()
+	retry := func(err error) {
+		s.logf("sync: %v, retrying in %v", err, watchRetry)
+		timer.Reset(watchRetry)
+	}
+	err := s.sync(ctx)
+	if err := ready(err); err != nil {
+		return err
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil
+		}
+		retry(err)
+	}
+	var tick <-chan time.Time
+	if s.interval > 0 {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case ev, ok := <-events:
+			if !ok {
+				return nil
+			}
+			rel, err := filepath.Rel(s.local, ev.Name)
+			if err != nil || s.opt.Excludes(filepath.ToSlash(rel)) {
+				continue
+			}
+			if ev.Has(fsnotify.Create) {
+				if fi, err := os.Stat(ev.Name); err == nil && fi.IsDir() {
+					if err := watchTree(w, s.local, ev.Name, s.opt); err != nil {
+						s.logf("watching %s: %v", ev.Name, err)
+					}
+				}
+			}
+			timer.Reset(s.debounce)
+		case err, ok := <-errs:
+			if !ok {
+				return nil
+			}
+			s.logf("watch: %v", err)
+		case <-tick:
+			timer.Reset(0)
+		case <-timer.C:
+			if err := s.sync(ctx); err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				retry(err)
+			}
+		}
+	}
+}
+
+// sync 执行一次同步，记录并输出结果。
+func (s *syncer) sync(ctx context.Context) error {
+	opt := *s.opt
+	opt.Progress = func(act pcs.SyncAction) {
+		s.logf("%s", syncActionString(act))
+	}
+	_, err := s.client.Sync(ctx, s.local, s.remote, &opt)
+	if s.hashes != nil {
+		if err := s.hashes.Save(); err != nil {
+			s.logf("saving hash cache: %v", err)
+		}
+	}
+
+	s.mu.Lock()
+	s.last, s.lastErr = time.Now(), err
+	s.mu.Unlock()
+	return err
+}
+
+// logf 输出一行带时间的日志
+func (s *syncer) logf(format string, args ...any) {
+	prefix := time.Now().Format("15:04:05") + " "
+	if s.name != "" {
+		prefix += "[" + s.name + "] "
+	}
+	fmt.Fprintf(s.log, prefix+format+"\n", args...)
+}
+
+// status 返回上次同步结束的时间和错误
+func (s *syncer) status() (time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.last, s.lastErr
+}
+
+// watchTree 监视 dir 及其下未被排除的全部目录。fsnotify 只监视目录中的直接变化，
+// 因此每个目录都要单独添加。
+func watchTree(w *fsnotify.Watcher, root, dir string, opt *pcs.SyncOptions) error {
+	return filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// 遍历期间被删除的目录
+			if os.IsNotExist(err) && p != dir {
+				return nil
+			}
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if rel, err := filepath.Rel(root, p); err == nil && rel != "." && opt.Excludes(filepath.ToSlash(rel)) {
+			return filepath.SkipDir
+		}
+		return w.Add(p)
+	})
+}
+
+// openHashCache 打开用户缓存目录中的 HashCache，使重新启动后不必再次计算未修改文件的校验值。
+// 无法打开时返回 nil，只是每次启动都重新计算。
+func openHashCache(a *app) *pcs.HashCache {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return nil
+	}
+	dir = filepath.Join(dir, "bpcs")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil
+	}
+	h, err := pcs.NewHashCache(filepath.Join(dir, "hashes.json"))
+	if err != nil {
+		fmt.Fprintf(a.stderr, "ignoring hash cache: %v\n", err)
+		return nil
+	}
+	return h
+}
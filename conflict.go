@@ -0,0 +1,80 @@
+package pcs
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ConflictPolicy 决定同一个文件在本地和远程都被修改过时的处理方式，见 SyncOptions
+// 和 WithConflictPolicy。零值表示使用各自的默认方式。
+type ConflictPolicy string
+
+const (
+	// 保留两者：将要被覆盖的一方改用 ConflictNamer 生成的名字保存，再照常传输
+	ConflictKeepBoth ConflictPolicy = "keep-both"
+	// 以本地文件为准：Sync 时覆盖远程文件，下载时跳过
+	ConflictPreferLocal ConflictPolicy = "prefer-local"
+	// 以远程文件为准：Sync 时跳过上传，下载时覆盖本地文件
+	ConflictPreferRemote ConflictPolicy = "prefer-remote"
+)
+
+// ConflictNamer 返回冲突时被保留的文件 name 的新名字，t 为发现冲突的时间。
+// name 是不含目录的文件名。
+type ConflictNamer func(name string, t time.Time) string
+
+// ConflictSuffix 是默认的 ConflictNamer，在扩展名之前加上 ".conflict-<时间>"，
+// 如 report.pdf 改为 report.conflict-20240102-150405.pdf。
+func ConflictSuffix(name string, t time.Time) string {
+	ext := path.Ext(name)
+	if ext == name {
+		// 以 . 开头且没有其他扩展名的文件，如 .bashrc
+		ext = ""
+	}
+	return strings.TrimSuffix(name, ext) + ".conflict-" + t.Format("20060102-150405") + ext
+}
+
+// conflictPath 返回 p 所在目录下按 namer 改名后的路径，namer 为 nil 时使用 ConflictSuffix。
+// p 以 / 分隔。
+func conflictPath(p string, namer ConflictNamer) string {
+	if namer == nil {
+		namer = ConflictSuffix
+	}
+	dir, name := path.Split(p)
+	return dir + namer(name, time.Now())
+}
+
+// localConflictPath 返回本地文件 p 按 namer 改名后的路径
+func localConflictPath(p string, namer ConflictNamer) string {
+	if namer == nil {
+		namer = ConflictSuffix
+	}
+	return filepath.Join(filepath.Dir(p), namer(filepath.Base(p), time.Now()))
+}
+
+// localDiffers 报告本地文件 p 是否存在且内容与远程文件 r 不同。encrypted 为 true 时
+// r.Md5 是密文的md5，只能依据 p 的 FileStamp 判断。
+func localDiffers(p string, r *File, encrypted bool) (bool, error) {
+	fi, err := os.Stat(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if s, err := ReadStamp(p); err == nil && s.Matches(fi) && (s.FsId == r.FsId || s.Md5 == r.Md5) {
+		return false, nil
+	}
+	if encrypted || fi.Size() != int64(r.Size) {
+		return true, nil
+	}
+	e, err := sumManifestEntry(p)
+	if err != nil {
+		return false, err
+	}
+	return !strings.EqualFold(e.Md5, r.Md5), nil
+}
@@ -0,0 +1,90 @@
+package pcs
+
+import (
+	"context"
+	"io"
+	"path"
+	"strings"
+	"time"
+)
+
+// CopyBetween 将 src 账号中的文件 srcPath 复制到 dst 账号的 dstPath（已存在时失败）。
+// 内容按分片从 src 下载后直接上传到 dst，不在本地保存副本，内存中只保留正在上传的分片。
+// 复制的是文件在网盘中存储的原始内容，加密的文件在 dst 中仍以原来的 Cipher 解密。
+func CopyBetween(ctx context.Context, src, dst *Client, srcPath, dstPath string) (*File, error) {
+	meta, _, err := src.GetMeta(ctx, srcPath)
+	if err != nil {
+		return nil, err
+	}
+	if meta.File == nil || meta.IsDirectory() {
+		return nil, invalid("path", "%q is not a file", srcPath)
+	}
+	size := int64(meta.Size)
+	if err := dst.checkQuota(ctx, size); err != nil {
+		return nil, err
+	}
+
+	opt := &FileOptions{Path: dstPath}
+	name := path.Base(CleanPath(dstPath))
+	blockSize := uploadBlockSize(size, dst.blockTuner.Size())
+	if size <= blockSize {
+		body, contentType, err := src.rangeBody(ctx, srcPath, meta.Md5, name, 0, size)
+		if err != nil {
+			return nil, err
+		}
+		f, _, err := dst.uploadFile(ctx, body, contentType, opt)
+		return f, err
+	}
+
+	var blocks []string
+	for _, b := range splitBlocks(size, blockSize) {
+		start := time.Now()
+		body, contentType, err := src.rangeBody(ctx, srcPath, meta.Md5, name, b.Offset, b.Size)
+		if err != nil {
+			return nil, err
+		}
+		tmp, _, err := dst.uploadBlock(ctx, body, contentType)
+		dst.blockTuner.Observe(b.Size, time.Since(start), err)
+		if err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, tmp.Md5)
+	}
+	f, _, err := dst.CreateSuperFile(ctx, dstPath, blocks, opt)
+	return f, err
+}
+
+// rangeBody 下载文件 path 中 [offset, offset+size) 的原始内容，封装为名为 name 的 multipart 请求体。
+func (c *Client) rangeBody(ctx context.Context, path, md5, name string, offset, size int64) (*pooledBody, string, error) {
+	if size == 0 {
+		return multipartBody(name, strings.NewReader(""), 0)
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		_, err := c.downloadRange(ctx, path, md5, offset, offset+size-1, pw)
+		pw.CloseWithError(err)
+	}()
+	body, contentType, err := c.bufferedBody(ctx, name, pr, size)
+	pr.Close()
+	return body, contentType, err
+}
+
+// ImportFromAccount 获取 src 账号中文件 srcPath 的临时下载地址，在 dst 账号中添加以该地址为源、
+// 保存到 savePath 的离线下载任务，数据完全在百度一侧传输，返回离线下载任务的ID。
+// 下载地址的有效期很短（见 DownloadLocations.Expire），可通过 QueryOfflineDownloadTask 查看进度。
+func ImportFromAccount(ctx context.Context, src, dst *Client, srcPath, savePath string) (int64, error) {
+	locs, _, err := src.LocateDownload(ctx, srcPath)
+	if err != nil {
+		return 0, err
+	}
+	if len(locs.URLs) == 0 || locs.URLs[0].URL == "" {
+		return 0, ErrNoDownloadServer
+	}
+
+	id, _, err := dst.AddOfflineDownloadTask(ctx, &AddTaskOptions{
+		SourceURL: locs.URLs[0].URL,
+		SavePath:  savePath,
+	})
+	return id, err
+}
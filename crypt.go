@@ -0,0 +1,458 @@
+package pcs
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// 加密文件格式：
+//
+//	header: magic(8) | 明文分块大小(4, 大端) | nonce 前缀(8, 随机)
+//	chunk i: AES-GCM(nonce = 前缀 | i(4, 大端), 明文分块, aad = header | 是否最后一块(1))
+//
+// 除最后一块外每块明文均为分块大小，空文件也有一个空的最后一块。
+// 各分块可独立加解密，因此加密后的文件仍然可以分片上传、断点续传和分段下载；
+// 附加数据绑定了文件头和最后一块的标记，分块被替换、重排或截断都会导致解密失败。
+const (
+	cryptMagic            = "BPCSGCM1"
+	cryptHeaderSize       = 20
+	defaultCryptChunkSize = 64 << 10
+
+	// 文件头中允许的最大分块大小。解密时按分块分配缓冲区，超过此值的文件头视为损坏，
+	// 以免在认证之前按被篡改的文件头分配过多内存
+	maxCryptChunkSize = 16 << 20
+)
+
+var ErrDecrypt = errors.New("baidu-pcs: message authentication failed, wrong key or corrupted file")
+
+// Cipher 使用用户提供的密钥对上传内容进行 AES-GCM 加密，对下载内容解密。
+// 通过 WithEncryption 设置到 Client 后，Upload、DownloadTo 以及 TransferManager
+// 的上传下载都会透明地加解密。可被多个 goroutine 并发使用。
+type Cipher struct {
+	aead      cipher.AEAD
+	chunkSize int
+}
+
+// NewCipher 以 key 创建 Cipher，key 的长度为16、24或32字节，分别对应 AES-128、AES-192 和 AES-256。
+// 密钥丢失后加密的文件无法恢复。
+func NewCipher(key []byte) (*Cipher, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Cipher{aead: aead, chunkSize: defaultCryptChunkSize}, nil
+}
+
+// EncryptedSize 返回 size 字节的明文加密后的大小。
+func (c *Cipher) EncryptedSize(size int64) int64 {
+	return encryptedSize(size, int64(c.chunkSize), int64(c.aead.Overhead()))
+}
+
+func encryptedSize(size, chunk, overhead int64) int64 {
+	chunks := (size + chunk - 1) / chunk
+	if chunks == 0 {
+		chunks = 1
+	}
+	return cryptHeaderSize + size + chunks*overhead
+}
+
+// newHeader 生成一个使用随机 nonce 前缀的文件头。
+func (c *Cipher) newHeader() ([]byte, error) {
+	h := make([]byte, cryptHeaderSize)
+	copy(h, cryptMagic)
+	binary.BigEndian.PutUint32(h[8:], uint32(c.chunkSize))
+	if _, err := rand.Read(h[12:]); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// cryptFile 描述一个加密文件的分块布局。
+type cryptFile struct {
+	c      *Cipher
+	header []byte
+	chunk  int64 // 明文分块大小
+}
+
+// newFile 以新的文件头创建 cryptFile，用于加密一个新文件。
+func (c *Cipher) newFile() (*cryptFile, error) {
+	h, err := c.newHeader()
+	if err != nil {
+		return nil, err
+	}
+	return c.parseHeader(h)
+}
+
+func (c *Cipher) parseHeader(h []byte) (*cryptFile, error) {
+	if len(h) < cryptHeaderSize || string(h[:8]) != cryptMagic {
+		return nil, ErrDecrypt
+	}
+	chunk := int64(binary.BigEndian.Uint32(h[8:]))
+	if chunk == 0 || chunk > maxCryptChunkSize {
+		return nil, ErrDecrypt
+	}
+	return &cryptFile{c: c, header: append([]byte(nil), h[:cryptHeaderSize]...), chunk: chunk}, nil
+}
+
+func (f *cryptFile) overhead() int64 { return int64(f.c.aead.Overhead()) }
+
+// encChunk 返回每个密文分块的大小。
+func (f *cryptFile) encChunk() int64 { return f.chunk + f.overhead() }
+
+// plainSize 返回 size 字节的密文（含文件头）解密后的大小。
+func (f *cryptFile) plainSize(size int64) (int64, error) {
+	rem := size - cryptHeaderSize
+	if rem < f.overhead() {
+		return 0, ErrDecrypt
+	}
+	full, tail := rem/f.encChunk(), rem%f.encChunk()
+	if tail == 0 {
+		return full * f.chunk, nil
+	}
+	if tail < f.overhead() {
+		return 0, ErrDecrypt
+	}
+	return full*f.chunk + tail - f.overhead(), nil
+}
+
+func (f *cryptFile) nonce(i int64) []byte {
+	n := make([]byte, 12)
+	copy(n, f.header[12:20])
+	binary.BigEndian.PutUint32(n[8:], uint32(i))
+	return n
+}
+
+func (f *cryptFile) aad(final bool) []byte {
+	aad := append(append([]byte(nil), f.header...), 0)
+	if final {
+		aad[len(aad)-1] = 1
+	}
+	return aad
+}
+
+func (f *cryptFile) seal(dst, plain []byte, i int64, final bool) []byte {
+	return f.c.aead.Seal(dst, f.nonce(i), plain, f.aad(final))
+}
+
+func (f *cryptFile) open(dst, enc []byte, i int64, final bool) ([]byte, error) {
+	out, err := f.c.aead.Open(dst, f.nonce(i), enc, f.aad(final))
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return out, nil
+}
+
+// encryptedReaderAt 以 io.ReaderAt 的形式提供明文 src 加密后的内容，
+// 每个分块按需加密，因此可以按任意偏移读取，用于分片上传和断点续传。
+type encryptedReaderAt struct {
+	f     *cryptFile
+	src   io.ReaderAt
+	plain int64 // 明文大小
+	size  int64 // 密文大小
+
+	mu     sync.Mutex
+	cached int64 // 缓存的分块序号
+	buf    []byte
+}
+
+func (f *cryptFile) encryptReaderAt(src io.ReaderAt, plain int64) *encryptedReaderAt {
+	return &encryptedReaderAt{
+		f:      f,
+		src:    src,
+		plain:  plain,
+		size:   encryptedSize(plain, f.chunk, f.overhead()),
+		cached: -1,
+	}
+}
+
+func (r *encryptedReaderAt) Size() int64 { return r.size }
+
+func (r *encryptedReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	var n int
+	for len(p) > 0 {
+		if off >= r.size {
+			return n, io.EOF
+		}
+		if off < cryptHeaderSize {
+			m := copy(p, r.f.header[off:])
+			n, off, p = n+m, off+int64(m), p[m:]
+			continue
+		}
+
+		i := (off - cryptHeaderSize) / r.f.encChunk()
+		chunk, err := r.chunkAt(i)
+		if err != nil {
+			return n, err
+		}
+		m := copy(p, chunk[(off-cryptHeaderSize)%r.f.encChunk():])
+		n, off, p = n+m, off+int64(m), p[m:]
+	}
+	return n, nil
+}
+
+func (r *encryptedReaderAt) chunkAt(i int64) ([]byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.cached == i {
+		return r.buf, nil
+	}
+
+	start := i * r.f.chunk
+	n := r.plain - start
+	if n > r.f.chunk {
+		n = r.f.chunk
+	}
+	plain := make([]byte, n)
+	if k, err := r.src.ReadAt(plain, start); int64(k) < n {
+		if err == nil || err == io.EOF {
+			err = ErrIncompleteFile
+		}
+		return nil, err
+	}
+	final := start+n >= r.plain
+	r.buf = r.f.seal(r.buf[:0], plain, i, final)
+	r.cached = i
+	return r.buf, nil
+}
+
+// encryptedReader 加密长度未知的明文流，先输出文件头，再逐块输出密文。
+// 每个分块读满后预读一个字节，以判断它是否是最后一块。
+type encryptedReader struct {
+	f     *cryptFile
+	src   *bufio.Reader
+	index int64
+	plain []byte
+	enc   []byte // 加密分块的缓冲区
+	buf   []byte // 尚未读出的密文
+	done  bool   // 已加密最后一块
+}
+
+func (f *cryptFile) encryptReader(r io.Reader) *encryptedReader {
+	return &encryptedReader{
+		f:     f,
+		src:   bufio.NewReader(r),
+		plain: make([]byte, f.chunk),
+		buf:   f.header,
+	}
+}
+
+func (r *encryptedReader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+		n, err := io.ReadFull(r.src, r.plain)
+		switch err {
+		case nil:
+			if _, err := r.src.Peek(1); err == io.EOF {
+				r.done = true
+			} else if err != nil {
+				return 0, err
+			}
+		case io.EOF, io.ErrUnexpectedEOF:
+			r.done = true
+		default:
+			return 0, err
+		}
+		r.enc = r.f.seal(r.enc[:0], r.plain[:n], r.index, r.done)
+		r.buf = r.enc
+		r.index++
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+// decryptWriter 将从第 index 个分块开始的密文解密后写入 w。
+// 除最后一块外，分块只有在之后还有数据时才解密，最后剩余的部分由 finish 处理。
+type decryptWriter struct {
+	f     *cryptFile
+	w     io.Writer
+	index int64
+	buf   bytes.Buffer
+	plain []byte
+}
+
+func (f *cryptFile) decryptWriter(w io.Writer, index int64) *decryptWriter {
+	return &decryptWriter{f: f, w: w, index: index}
+}
+
+func (d *decryptWriter) Write(p []byte) (int, error) {
+	d.buf.Write(p)
+	for int64(d.buf.Len()) > d.f.encChunk() {
+		if err := d.flushChunk(d.buf.Next(int(d.f.encChunk())), false); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// finish 解密剩余的一个分块，final 表示它是否为文件的最后一块。
+func (d *decryptWriter) finish(final bool) error {
+	if d.buf.Len() == 0 && !final {
+		return nil
+	}
+	return d.flushChunk(d.buf.Next(d.buf.Len()), final)
+}
+
+func (d *decryptWriter) flushChunk(enc []byte, final bool) error {
+	plain, err := d.f.open(d.plain[:0], enc, d.index, final)
+	if err != nil {
+		return err
+	}
+	d.plain = plain
+	d.index++
+	_, err = d.w.Write(plain)
+	return err
+}
+
+// Decrypt 从 src 读取完整的加密文件，将解密后的内容写入 dst。
+func (c *Cipher) Decrypt(dst io.Writer, src io.Reader) error {
+	h := make([]byte, cryptHeaderSize)
+	if _, err := io.ReadFull(src, h); err != nil {
+		return ErrDecrypt
+	}
+	f, err := c.parseHeader(h)
+	if err != nil {
+		return err
+	}
+	d := f.decryptWriter(dst, 0)
+	if _, err := copyBuffered(d, src); err != nil {
+		return err
+	}
+	return d.finish(true)
+}
+
+// Encrypt 将 size 字节的明文 src 加密后写入 dst。
+func (c *Cipher) Encrypt(dst io.Writer, src io.ReaderAt, size int64) error {
+	f, err := c.newFile()
+	if err != nil {
+		return err
+	}
+	r := f.encryptReaderAt(src, size)
+	_, err = copyBuffered(dst, io.NewSectionReader(r, 0, r.Size()))
+	return err
+}
+
+// KeyFromPassphrase 使用的 Argon2id 参数，即 RFC 9106 推荐的第二组参数。
+const (
+	argon2Time    = 3
+	argon2Memory  = 64 << 10 // KiB
+	argon2Threads = 4
+)
+
+// PassphraseSaltSize 是 NewPassphraseSalt 生成的盐的长度，KeyFromPassphrase 要求盐至少有这么长。
+const PassphraseSaltSize = 16
+
+// SaltFile 是 PassphraseSalt 在远程目录中保存盐的文件名。
+const SaltFile = "baidu-pcs.salt"
+
+// KeyFromPassphrase 用 Argon2id 从口令和盐导出 32 字节的密钥，可用于 NewCipher 和 NewNameCipher。
+// 盐应当随机生成（见 NewPassphraseSalt）并与加密的数据一起保存，同一口令和盐总是得到同一密钥。
+// 盐短于 PassphraseSaltSize 时返回 ErrInvalidArgument。
+func KeyFromPassphrase(passphrase string, salt []byte) ([]byte, error) {
+	if len(salt) < PassphraseSaltSize {
+		return nil, invalid("salt", "must be at least %d bytes, got %d", PassphraseSaltSize, len(salt))
+	}
+	return argon2.IDKey([]byte(passphrase), salt, argon2Time, argon2Memory, argon2Threads, 32), nil
+}
+
+// NewPassphraseSalt 生成 PassphraseSaltSize 字节的随机盐。
+func NewPassphraseSalt() ([]byte, error) {
+	salt := make([]byte, PassphraseSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	return salt, nil
+}
+
+// PassphraseSalt 返回保存在远程目录 dir 下 SaltFile 中的盐，文件不存在时生成新的盐并保存，
+// 使 dir 下的数据总是用同一个盐导出密钥。盐不是秘密，但丢失后无法再由口令得到原来的密钥。
+// 盐以明文保存，因此 c 不能通过 WithEncryption 或 WithNameEncryption 启用加密。
+func (c *Client) PassphraseSalt(ctx context.Context, dir string) ([]byte, error) {
+	if c.cipher != nil || c.names != nil {
+		return nil, invalid("dir", "the salt of %q must be read without encryption", dir)
+	}
+	p := path.Join(CleanPath(dir), SaltFile)
+	for attempt := 1; ; attempt++ {
+		var buf bytes.Buffer
+		_, err := c.DownloadTo(ctx, p, &buf)
+		if err == nil {
+			salt, err := hex.DecodeString(strings.TrimSpace(buf.String()))
+			if err != nil || len(salt) < PassphraseSaltSize {
+				return nil, fmt.Errorf("baidu-pcs: bad salt in %s", p)
+			}
+			return salt, nil
+		}
+		if !errors.Is(err, ErrFileNotExist) {
+			return nil, err
+		}
+
+		salt, err := NewPassphraseSalt()
+		if err != nil {
+			return nil, err
+		}
+		data := hex.EncodeToString(salt) + "\n"
+		_, err = c.uploadStream(ctx, &FileOptions{Path: p}, nil, true, strings.NewReader(data))
+		if err == nil {
+			return salt, nil
+		}
+		// 另一个进程同时创建了盐，改用它的
+		if !errors.Is(err, ErrAlreadyExists) || attempt == 2 {
+			return nil, err
+		}
+	}
+}
+
+// VerifyEncryption 读取远程文件 path 的文件头和第一个分块，检查它能否用 WithEncryption
+// 设置的 Cipher 解密。密钥不符、文件不是加密文件或已损坏时返回 ErrDecrypt。
+func (c *Client) VerifyEncryption(ctx context.Context, path string) error {
+	if c.cipher == nil {
+		return invalid("path", "no cipher configured to verify %q", path)
+	}
+	rf, err := c.Open(ctx, path)
+	if err != nil {
+		return err
+	}
+	defer rf.Close()
+
+	h := make([]byte, cryptHeaderSize)
+	if _, err := rf.ReadAt(h, 0); err != nil {
+		if err == io.EOF {
+			return ErrDecrypt
+		}
+		return err
+	}
+	f, err := c.cipher.parseHeader(h)
+	if err != nil {
+		return err
+	}
+	if _, err := f.plainSize(rf.Size()); err != nil {
+		return err
+	}
+	n := min(rf.Size()-cryptHeaderSize, f.encChunk())
+	enc := make([]byte, n)
+	if _, err := rf.ReadAt(enc, cryptHeaderSize); err != nil && err != io.EOF {
+		return err
+	}
+	_, err = f.open(nil, enc, 0, cryptHeaderSize+n == rf.Size())
+	return err
+}
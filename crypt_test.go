@@ -0,0 +1,164 @@
+package pcs_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/holys/baidu-pcs/v2"
+	"github.com/holys/baidu-pcs/v2/pcstest"
+)
+
+func TestKeyFromPassphrase(t *testing.T) {
+	salt := bytes.Repeat([]byte{1}, pcs.PassphraseSaltSize)
+	k1, err := pcs.KeyFromPassphrase("secret", salt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(k1) != 32 {
+		t.Fatalf("key is %d bytes, want 32", len(k1))
+	}
+	if k2, _ := pcs.KeyFromPassphrase("secret", salt); !bytes.Equal(k1, k2) {
+		t.Error("the same passphrase and salt derived different keys")
+	}
+	other := bytes.Repeat([]byte{2}, pcs.PassphraseSaltSize)
+	if k2, _ := pcs.KeyFromPassphrase("secret", other); bytes.Equal(k1, k2) {
+		t.Error("different salts derived the same key")
+	}
+	if _, err := pcs.KeyFromPassphrase("secret", salt[:8]); !errors.Is(err, pcs.ErrInvalidArgument) {
+		t.Errorf("short salt: %v, want ErrInvalidArgument", err)
+	}
+}
+
+func TestPassphraseSalt(t *testing.T) {
+	ctx := context.Background()
+	srv := pcstest.NewServer()
+	defer srv.Close()
+	c := srv.NewClient()
+
+	salt, err := c.PassphraseSalt(ctx, "/apps/t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(salt) != pcs.PassphraseSaltSize {
+		t.Fatalf("salt is %d bytes", len(salt))
+	}
+	if data, ok := srv.ReadFile("/apps/t/" + pcs.SaltFile); !ok || strings.TrimSpace(string(data)) == "" {
+		t.Fatalf("salt file = %q, %v", data, ok)
+	}
+	again, err := c.PassphraseSalt(ctx, "/apps/t")
+	if err != nil || !bytes.Equal(again, salt) {
+		t.Errorf("second call = %x, %v; want the stored salt %x", again, err, salt)
+	}
+	if other, _ := c.PassphraseSalt(ctx, "/apps/u"); bytes.Equal(other, salt) {
+		t.Error("two directories got the same salt")
+	}
+
+	ci, _ := pcs.NewCipher(bytes.Repeat([]byte("k"), 32))
+	if _, err := srv.NewClient(pcs.WithEncryption(ci)).PassphraseSalt(ctx, "/apps/t"); !errors.Is(err, pcs.ErrInvalidArgument) {
+		t.Errorf("encrypting client: %v, want ErrInvalidArgument", err)
+	}
+}
+
+func TestCipherRoundTrip(t *testing.T) {
+	ci, _ := pcs.NewCipher(bytes.Repeat([]byte("k"), 32))
+	for _, size := range []int{0, 1, 64 << 10, 200000} {
+		data := make([]byte, size)
+		rand.Read(data)
+		var enc bytes.Buffer
+		if err := ci.Encrypt(&enc, bytes.NewReader(data), int64(size)); err != nil {
+			t.Fatal(err)
+		}
+		if int64(enc.Len()) != ci.EncryptedSize(int64(size)) {
+			t.Errorf("size %d: encrypted to %d bytes, EncryptedSize says %d", size, enc.Len(), ci.EncryptedSize(int64(size)))
+		}
+		var dec bytes.Buffer
+		if err := ci.Decrypt(&dec, bytes.NewReader(enc.Bytes())); err != nil || !bytes.Equal(dec.Bytes(), data) {
+			t.Errorf("size %d: decrypted %d bytes, %v", size, dec.Len(), err)
+		}
+
+		damaged := bytes.Clone(enc.Bytes())
+		damaged[len(damaged)-1] ^= 1
+		if err := ci.Decrypt(io.Discard, bytes.NewReader(damaged)); !errors.Is(err, pcs.ErrDecrypt) {
+			t.Errorf("size %d: damaged file: %v, want ErrDecrypt", size, err)
+		}
+		// 在分块边界处截断也要被发现，分块为 64KiB
+		if size > 64<<10 {
+			truncated := enc.Bytes()[:ci.EncryptedSize(64<<10)]
+			if err := ci.Decrypt(io.Discard, bytes.NewReader(truncated)); !errors.Is(err, pcs.ErrDecrypt) {
+				t.Errorf("size %d: truncated file: %v, want ErrDecrypt", size, err)
+			}
+		}
+	}
+
+	other, _ := pcs.NewCipher(bytes.Repeat([]byte("o"), 32))
+	var enc bytes.Buffer
+	ci.Encrypt(&enc, strings.NewReader("secret"), 6)
+	if err := other.Decrypt(io.Discard, bytes.NewReader(enc.Bytes())); !errors.Is(err, pcs.ErrDecrypt) {
+		t.Errorf("wrong key: %v, want ErrDecrypt", err)
+	}
+
+	// 文件头中的分块大小位于第8到12字节，超出范围时不按其分配缓冲区
+	for _, chunk := range []uint32{0, 16<<20 + 1, 1<<32 - 1} {
+		bad := bytes.Clone(enc.Bytes())
+		binary.BigEndian.PutUint32(bad[8:], chunk)
+		if err := ci.Decrypt(io.Discard, bytes.NewReader(bad)); !errors.Is(err, pcs.ErrDecrypt) {
+			t.Errorf("chunk size %d in the header: %v, want ErrDecrypt", chunk, err)
+		}
+	}
+}
+
+func TestEncryptedTransfers(t *testing.T) {
+	ctx := context.Background()
+	srv := pcstest.NewServer()
+	defer srv.Close()
+	ci, _ := pcs.NewCipher(bytes.Repeat([]byte("k"), 32))
+	c := srv.NewClient(pcs.WithEncryption(ci), pcs.WithChunkTuners(pcs.FixedChunkTuner(50000), pcs.FixedChunkTuner(100000)))
+	dir := t.TempDir()
+
+	for _, size := range []int{0, 1, 200000} {
+		data := make([]byte, size)
+		rand.Read(data)
+		local := filepath.Join(dir, fmt.Sprint(size))
+		if err := os.WriteFile(local, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		remote := fmt.Sprintf("/apps/t/%d", size)
+		if _, _, err := c.Upload(ctx, local, pcs.NewFileOptions(remote, pcs.OnDupOverwrite)); err != nil {
+			t.Fatal(err)
+		}
+		stored, _ := srv.ReadFile(remote)
+		if int64(len(stored)) != ci.EncryptedSize(int64(size)) || size > 16 && bytes.Contains(stored, data) {
+			t.Errorf("size %d: stored %d bytes of ciphertext", size, len(stored))
+		}
+		var out bytes.Buffer
+		if _, err := c.DownloadTo(ctx, remote, &out); err != nil || !bytes.Equal(out.Bytes(), data) {
+			t.Errorf("size %d: Upload then DownloadTo returned %d bytes, %v", size, out.Len(), err)
+		}
+		if err := c.VerifyEncryption(ctx, remote); err != nil {
+			t.Errorf("size %d: VerifyEncryption: %v", size, err)
+		}
+
+		// 分片上传
+		if _, err := c.UploadReader(ctx, bytes.NewReader(data), pcs.NewFileOptions(remote+".r", pcs.OnDupOverwrite)); err != nil {
+			t.Fatal(err)
+		}
+		out.Reset()
+		if _, err := c.DownloadTo(ctx, remote+".r", &out); err != nil || !bytes.Equal(out.Bytes(), data) {
+			t.Errorf("size %d: UploadReader then DownloadTo returned %d bytes, %v", size, out.Len(), err)
+		}
+	}
+
+	srv.PutFile("/apps/t/plain", []byte("not encrypted"))
+	if err := c.VerifyEncryption(ctx, "/apps/t/plain"); !errors.Is(err, pcs.ErrDecrypt) {
+		t.Errorf("VerifyEncryption of a plain file: %v, want ErrDecrypt", err)
+	}
+}
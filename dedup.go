@@ -0,0 +1,135 @@
+package pcs
+
+import (
+	"context"
+	"sort"
+)
+
+// Deduplicate 每次批量删除的文件数
+const dedupBatchSize = 100
+
+// DuplicateGroup 是一组内容相同（md5和大小一致）的远程文件
+type DuplicateGroup struct {
+	Md5   string
+	Size  uint64
+	Files []*File // 至少两个，按创建时间从早到晚排列
+}
+
+// KeepPolicy 决定 Deduplicate 在每组重复文件中保留哪个副本
+type KeepPolicy int
+
+const (
+	KeepOldest       KeepPolicy = iota // 创建时间最早的
+	KeepNewest                         // 创建时间最晚的
+	KeepShortestPath                   // 路径最短的，常用于保留不在多层副本目录中的那个
+)
+
+// DedupOptions 是 Deduplicate 的选项
+type DedupOptions struct {
+	Keep   KeepPolicy
+	DryRun bool // 只返回将要删除的路径，不做任何修改
+}
+
+// keep 返回 g 中按 policy 保留的文件的下标。时间或长度相同时保留路径在字典序中靠前的。
+func (g DuplicateGroup) keep(policy KeepPolicy) int {
+	k := 0
+	for i := 1; i < len(g.Files); i++ {
+		f, kf := g.Files[i], g.Files[k]
+		var better bool
+		switch policy {
+		case KeepNewest:
+			better = f.Ctime > kf.Ctime || f.Ctime == kf.Ctime && f.Path < kf.Path
+		case KeepShortestPath:
+			better = len(f.Path) < len(kf.Path) || len(f.Path) == len(kf.Path) && f.Path < kf.Path
+		default:
+			better = f.Ctime < kf.Ctime || f.Ctime == kf.Ctime && f.Path < kf.Path
+		}
+		if better {
+			k = i
+		}
+	}
+	return k
+}
+
+// Wasted 返回删除多余副本后可以释放的空间
+func (g DuplicateGroup) Wasted() uint64 {
+	return g.Size * uint64(len(g.Files)-1)
+}
+
+// FindDuplicates 遍历远程目录 root，按md5和大小将文件分组，返回包含多个文件的组，
+// 按可释放的空间从大到小排列。组内的文件按创建时间从早到晚排列，创建时间相同时按路径排列。
+// 空文件不参与比较。
+// 出错或 ctx 被取消时返回该错误以及此前已找到的重复文件。
+func (c *Client) FindDuplicates(ctx context.Context, root string) ([]DuplicateGroup, error) {
+	files, err := c.Find(ctx, root, &Filter{Kind: FindFiles, MinSize: 1})
+
+	type key struct {
+		md5  string
+		size uint64
+	}
+	byKey := make(map[key][]*File)
+	for _, f := range files {
+		k := key{f.Md5, f.Size}
+		byKey[k] = append(byKey[k], f)
+	}
+
+	groups := []DuplicateGroup{}
+	for k, fs := range byKey {
+		if len(fs) < 2 {
+			continue
+		}
+		sort.Slice(fs, func(i, j int) bool {
+			if fs[i].Ctime != fs[j].Ctime {
+				return fs[i].Ctime < fs[j].Ctime
+			}
+			return fs[i].Path < fs[j].Path
+		})
+		groups = append(groups, DuplicateGroup{Md5: k.md5, Size: k.size, Files: fs})
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if wi, wj := groups[i].Wasted(), groups[j].Wasted(); wi != wj {
+			return wi > wj
+		}
+		return groups[i].Files[0].Path < groups[j].Files[0].Path
+	})
+	return groups, err
+}
+
+// Deduplicate 在 groups 的每组中按 opt.Keep 保留一个副本，删除其余的副本以释放空间配额，
+// 返回已删除的路径；opt.DryRun 时返回将要删除的路径。opt 为 nil 时保留最早创建的副本。
+// PCS 不支持链接，被删除的路径上不再有文件，引用这些路径的程序需要改用保留的副本。
+// 出错或 ctx 被取消时也返回出错前已删除的路径。
+func (c *Client) Deduplicate(ctx context.Context, groups []DuplicateGroup, opt *DedupOptions) ([]string, error) {
+	if opt == nil {
+		opt = &DedupOptions{}
+	}
+	var paths []string
+	for _, g := range groups {
+		if len(g.Files) < 2 {
+			continue
+		}
+		k := g.keep(opt.Keep)
+		for i, f := range g.Files {
+			if i != k {
+				paths = append(paths, f.Path)
+			}
+		}
+	}
+	if opt.DryRun {
+		if paths == nil {
+			paths = []string{}
+		}
+		return paths, nil
+	}
+
+	deleted := []string{}
+	for len(paths) > 0 {
+		n := min(len(paths), dedupBatchSize)
+		if _, err := c.BatchDelete(ctx, paths[:n]); err != nil {
+			return deleted, err
+		}
+		deleted = append(deleted, paths[:n]...)
+		paths = paths[n:]
+	}
+	return deleted, nil
+}
@@ -0,0 +1,88 @@
+package pcs_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/holys/baidu-pcs/v2"
+	"github.com/holys/baidu-pcs/v2/pcstest"
+)
+
+// newDuplicates 在 /apps/t 下放置三个内容相同、创建时间不同的文件和一个不重复的文件
+func newDuplicates(t *testing.T) (*pcstest.Server, []pcs.DuplicateGroup) {
+	t.Helper()
+	srv := pcstest.NewServer()
+	t.Cleanup(srv.Close)
+	for i, p := range []string{"/apps/t/photos/backup/old/a.jpg", "/apps/t/a.jpg", "/apps/t/photos/a.jpg"} {
+		srv.PutFile(p, []byte("same")).Ctime = uint64(1000 + i)
+	}
+	srv.PutFile("/apps/t/b.jpg", []byte("other"))
+
+	groups, err := srv.NewClient().FindDuplicates(context.Background(), "/apps/t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 1 || len(groups[0].Files) != 3 || groups[0].Wasted() != 8 {
+		t.Fatalf("FindDuplicates = %+v", groups)
+	}
+	return srv, groups
+}
+
+func TestDeduplicateKeep(t *testing.T) {
+	for _, tt := range []struct {
+		keep pcs.KeepPolicy
+		kept string
+	}{
+		{pcs.KeepOldest, "/apps/t/photos/backup/old/a.jpg"},
+		{pcs.KeepNewest, "/apps/t/photos/a.jpg"},
+		{pcs.KeepShortestPath, "/apps/t/a.jpg"},
+	} {
+		srv, groups := newDuplicates(t)
+		deleted, err := srv.NewClient().Deduplicate(context.Background(), groups, &pcs.DedupOptions{Keep: tt.keep})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(deleted) != 2 {
+			t.Errorf("keep %d: deleted %v", tt.keep, deleted)
+		}
+		for _, f := range groups[0].Files {
+			if want := f.Path == tt.kept; srv.Exists(f.Path) != want {
+				t.Errorf("keep %d: %s exists = %v, want %v", tt.keep, f.Path, !want, want)
+			}
+		}
+		if !srv.Exists("/apps/t/b.jpg") {
+			t.Errorf("keep %d: the unique file was deleted", tt.keep)
+		}
+	}
+}
+
+func TestDeduplicateDryRun(t *testing.T) {
+	srv, groups := newDuplicates(t)
+	paths, err := srv.NewClient().Deduplicate(context.Background(), groups, &pcs.DedupOptions{DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"/apps/t/a.jpg", "/apps/t/photos/a.jpg"}
+	if !reflect.DeepEqual(paths, want) {
+		t.Errorf("DryRun = %v, want %v", paths, want)
+	}
+	for _, f := range groups[0].Files {
+		if !srv.Exists(f.Path) {
+			t.Errorf("DryRun deleted %s", f.Path)
+		}
+	}
+}
+
+func TestFindDuplicatesComparesSize(t *testing.T) {
+	srv := pcstest.NewServer()
+	defer srv.Close()
+	// 服务端报告的 md5 相同但大小不同，不是同一内容
+	a := srv.PutFile("/apps/t/a.bin", []byte("short"))
+	srv.PutFile("/apps/t/b.bin", []byte("much longer")).Md5 = a.Md5
+
+	groups, err := srv.NewClient().FindDuplicates(context.Background(), "/apps/t")
+	if err != nil || len(groups) != 0 {
+		t.Errorf("FindDuplicates = %+v, %v; want no groups", groups, err)
+	}
+}
@@ -0,0 +1,68 @@
+package pcs
+
+import (
+	"strconv"
+	"strings"
+)
+
+// 以下构造函数填写各个选项中必需的字段，其余字段为服务端的默认值，
+// 避免零值的必需字段被发送给服务端后得到难以理解的错误。返回的选项可以继续修改。
+
+// NewFileOptions 返回上传到 path 的选项，ondup 为空时同名文件已存在则上传失败。
+func NewFileOptions(path string, ondup OnDup) *FileOptions {
+	return &FileOptions{Path: path, OnDup: ondup}
+}
+
+// NewListFilesOptions 返回列出目录 path 的选项
+func NewListFilesOptions(path string) *ListFilesOptions {
+	return &ListFilesOptions{Path: path}
+}
+
+// NewSearchOptions 返回在目录 path 中按关键词 word 搜索的选项，recursive 为 true 时包括子目录。
+func NewSearchOptions(path, word string, recursive bool) *SearchOptions {
+	opt := &SearchOptions{Path: path, Word: word, Re: "0"}
+	if recursive {
+		opt.Re = "1"
+	}
+	return opt
+}
+
+// NewThumbnailOptions 返回以最高质量生成图片 path 的 width×height 缩略图的选项
+func NewThumbnailOptions(path string, width, height int) *ThumbnailOptions {
+	return &ThumbnailOptions{Path: path, Quality: 100, Width: width, Height: height}
+}
+
+// NewListStreamOptions 返回列出 typ 类型（video、audio、image 或 doc）文件的选项
+func NewListStreamOptions(typ string) *ListStreamOptions {
+	return &ListStreamOptions{Type: typ}
+}
+
+// NewAddTaskOptions 返回将 sourceURL 离线下载到 savePath 的选项，下载网盘中的BT种子见 AddTorrentTask。
+func NewAddTaskOptions(sourceURL, savePath string) *AddTaskOptions {
+	return &AddTaskOptions{SourceURL: sourceURL, SavePath: savePath, Type: TaskTypeURL}
+}
+
+// NewQueryTaskOptions 返回查询离线下载任务 ids 的进度的选项。OpType 的零值表示查询任务信息，
+// 与文档中的默认值不同，因此这里明确设为1。
+func NewQueryTaskOptions(ids ...int64) *QueryTaskOptions {
+	s := make([]string, len(ids))
+	for i, id := range ids {
+		s[i] = strconv.FormatInt(id, 10)
+	}
+	return &QueryTaskOptions{TaskIds: strings.Join(s, ","), OpType: 1}
+}
+
+// NewListTaskOptions 返回按创建时间从新到旧列出离线下载任务的选项，每次最多 limit 个。
+func NewListTaskOptions(limit int) *ListTaskOptions {
+	return &ListTaskOptions{Limit: limit, NeedTaskInfo: 1}
+}
+
+// NewCancelTaskOptions 返回取消离线下载任务 id 的选项
+func NewCancelTaskOptions(id int64) *CancelTaskOptions {
+	return &CancelTaskOptions{TaskId: strconv.FormatInt(id, 10)}
+}
+
+// NewListRecycleOptions 返回从第 start 个开始列出回收站中 limit 个条目的选项
+func NewListRecycleOptions(start, limit int) *ListRecycleOptions {
+	return &ListRecycleOptions{Start: start, Limit: limit}
+}
@@ -1,10 +1,11 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
 
-	"github.com/holys/baidu-pcs"
+	"github.com/holys/baidu-pcs/v2"
 )
 
 func main() {
@@ -13,7 +14,7 @@ func main() {
 		panic("token not found")
 	}
 	client := pcs.NewClient(token)
-	quota, _, err := client.GetQuota()
+	quota, _, err := client.GetQuota(context.Background())
 	if err != nil {
 		return
 	}
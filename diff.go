@@ -0,0 +1,79 @@
+package pcs
+
+import (
+	"context"
+	"net/http"
+)
+
+// DiffEntry 是 Changes 返回的一个文件或目录的变化
+type DiffEntry struct {
+	*File
+	IsDelete int `json:"isdelete"` // 非0表示已被删除
+}
+
+// DiffResult 是 Changes 的结果
+type DiffResult struct {
+	Entries map[string]*DiffEntry `json:"entries"` // 以路径为键
+	HasMore bool                  `json:"has_more"`
+	Reset   bool                  `json:"reset"`  // 为 true 时 Entries 是完整的文件列表而不是增量，应丢弃本地缓存的状态
+	Cursor  string                `json:"cursor"` // 下次调用时使用
+}
+
+// Changes 调用增量更新查询接口，返回 cursor 之后的文件变化。cursor 为空表示首次调用，
+// 此时返回全部文件。HasMore 为 true 时应以返回的 Cursor 继续调用。
+func (c *Client) Changes(ctx context.Context, cursor string) (*DiffResult, *http.Response, error) {
+	if cursor == "" {
+		cursor = "null"
+	}
+	opt := struct {
+		Cursor string `url:"cursor"`
+	}{
+		Cursor: cursor,
+	}
+
+	u, err := c.addOptions("file", "diff", &opt)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return get[DiffResult](ctx, c, u)
+}
+
+// changesSince 取得 cursor 之后的全部变化，返回最新的 cursor。reset 为 true 时
+// 服务端无法提供增量，entries 为完整的文件列表。
+func (c *Client) changesSince(ctx context.Context, cursor string) (entries []*DiffEntry, next string, reset bool, err error) {
+	for {
+		res, _, err := c.Changes(ctx, cursor)
+		if err != nil {
+			return nil, "", false, err
+		}
+		if res.Reset {
+			entries, reset = nil, true
+		}
+		for _, e := range res.Entries {
+			if e.File != nil {
+				entries = append(entries, e)
+			}
+		}
+		cursor = res.Cursor
+		if !res.HasMore {
+			return entries, cursor, reset, nil
+		}
+	}
+}
+
+// latestCursor 返回最新的 cursor。PCS 没有单独取得最新 cursor 的接口，只能从头
+// 翻完全部文件列表，但不保留其中的文件。
+func (c *Client) latestCursor(ctx context.Context) (string, error) {
+	cursor := ""
+	for {
+		res, _, err := c.Changes(ctx, cursor)
+		if err != nil {
+			return "", err
+		}
+		cursor = res.Cursor
+		if !res.HasMore {
+			return cursor, nil
+		}
+	}
+}
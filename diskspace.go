@@ -0,0 +1,54 @@
+package pcs
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// DiskSpaceError 表示本地文件系统的可用空间不足以保存下载的文件。
+// errors.Is(err, ErrInsufficientDiskSpace) 对其成立。
+type DiskSpaceError struct {
+	Path      string // 下载的目标目录
+	Need      int64  // 还需要写入的字节数
+	Available int64  // 文件系统的可用字节数
+}
+
+func (e *DiskSpaceError) Error() string {
+	return fmt.Sprintf("baidu-pcs: not enough disk space in %s: need %d bytes, %d available", e.Path, e.Need, e.Available)
+}
+
+func (e *DiskSpaceError) Is(target error) bool {
+	return target == ErrInsufficientDiskSpace
+}
+
+// CheckDiskSpace 检查本地目录 dir 所在文件系统的可用空间是否足以写入 size 字节，
+// 不足时返回 *DiskSpaceError。dir 尚不存在时检查其最近的已存在的上级目录。
+// 下载多个文件时可先以总大小调用一次。无法获取可用空间的平台上总是返回 nil。
+func CheckDiskSpace(dir string, size int64) error {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	for {
+		_, err := os.Stat(dir)
+		if err == nil {
+			break
+		}
+		parent := filepath.Dir(dir)
+		if !errors.Is(err, os.ErrNotExist) || parent == dir {
+			return err
+		}
+		dir = parent
+	}
+
+	avail, ok, err := diskFree(dir)
+	if err != nil || !ok {
+		return err
+	}
+	if size > avail {
+		return &DiskSpaceError{Path: dir, Need: size, Available: avail}
+	}
+	return nil
+}
@@ -0,0 +1,8 @@
+//go:build !(linux || darwin || freebsd)
+
+package pcs
+
+// diskFree 在不支持的平台上报告无法获取可用空间。
+func diskFree(dir string) (int64, bool, error) {
+	return 0, false, nil
+}
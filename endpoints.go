@@ -0,0 +1,69 @@
+package pcs
+
+import "net/url"
+
+// EndpointSet names the hosts a Client talks to. Requests are resolved
+// against Base, except uploads (Upload), downloads (Download) and netdisk
+// platform calls such as GetUserInfo (Pan).
+//
+// When the upload or download host cannot be reached, the Client switches
+// to the next of its fallbacks and keeps using it for the rest of the
+// session. The failed request is retried on the new host if its body can
+// be replayed.
+type EndpointSet struct {
+	Name     string
+	Base     *url.URL
+	Upload   *url.URL
+	Download *url.URL
+	Pan      *url.URL
+
+	UploadFallbacks   []*url.URL
+	DownloadFallbacks []*url.URL
+}
+
+// DefaultEndpoints returns the public Baidu hosts used by NewClient.
+func DefaultEndpoints() EndpointSet {
+	base, _ := url.Parse(defaultBaseURL)
+	upload, _ := url.Parse(uploadBaseURL)
+	download, _ := url.Parse(downloadBaseURL)
+	pan, _ := url.Parse(panBaseURL)
+	return EndpointSet{Name: "baidu", Base: base, Upload: upload, Download: download, Pan: pan}
+}
+
+// Endpoints returns the hosts currently used by the Client.
+func (c *Client) Endpoints() EndpointSet {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return EndpointSet{
+		Name:     c.endpointsName,
+		Base:     c.BaseURL,
+		Upload:   c.UploadURL,
+		Download: c.DownloadURL,
+		Pan:      c.PanURL,
+
+		UploadFallbacks:   c.uploadHosts.fallbacks(),
+		DownloadFallbacks: c.downloadHosts.fallbacks(),
+	}
+}
+
+// SetEndpoints replaces the hosts used by subsequent requests. Nil URLs in
+// e leave the corresponding host unchanged.
+func (c *Client) SetEndpoints(e EndpointSet) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.endpointsName = e.Name
+	if e.Base != nil {
+		c.BaseURL = e.Base
+	}
+	if e.Upload != nil {
+		c.UploadURL = e.Upload
+	}
+	if e.Download != nil {
+		c.DownloadURL = e.Download
+	}
+	if e.Pan != nil {
+		c.PanURL = e.Pan
+	}
+	c.uploadHosts = newHostGroup(c.UploadURL, e.UploadFallbacks)
+	c.downloadHosts = newHostGroup(c.DownloadURL, e.DownloadFallbacks)
+}
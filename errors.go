@@ -0,0 +1,206 @@
+package pcs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+)
+
+// Baidu error codes that indicate a transient condition, such as request
+// throttling, rather than a problem with the request itself.
+var temporaryCodes = map[int]bool{
+	31034: true, // hit frequency limit
+}
+
+// codeFileNotExist is the error_code PCS answers with for a missing path.
+const codeFileNotExist = 31066
+
+// codeErrors maps the error codes of PCS, and the errno values of the
+// pan.baidu.com endpoints, to the sentinel errors an *APIError unwraps to.
+var codeErrors = map[int]error{
+	110:   ErrTokenExpired, // access token invalid or no longer valid
+	111:   ErrTokenExpired, // access token expired
+	31034: ErrRateLimited,
+	31061: ErrAlreadyExists,
+	31063: ErrFileNotExist, // parent directory does not exist
+	31064: ErrPermissionDenied,
+	31066: ErrFileNotExist,
+	31070: ErrDeleteFailed,
+	31112: ErrInsufficientQuota,
+	36016: ErrTaskNotFound,
+
+	-6: ErrTokenExpired,
+	-7: ErrPermissionDenied,
+	-8: ErrAlreadyExists,
+	-9: ErrFileNotExist,
+}
+
+// isNotExist reports whether err is a PCS answer for a missing path.
+func isNotExist(err error) bool {
+	return hasCode(err, codeFileNotExist)
+}
+
+// hasCode reports whether err is a PCS answer with the given error_code.
+func hasCode(err error, code int) bool {
+	var ae *APIError
+	return errors.As(err, &ae) && ae.Code == code
+}
+
+// RequestError reports a request that did not produce a response: a
+// network failure, a transport timeout, or a cancelled or expired context.
+// Err is the underlying cause, so errors.Is(err, context.DeadlineExceeded)
+// works as expected.
+type RequestError struct {
+	Method string
+	URL    string
+	Err    error
+}
+
+func (e *RequestError) Error() string {
+	return fmt.Sprintf("[%v] - %v - %v", e.Method, e.URL, e.Err)
+}
+
+func (e *RequestError) Unwrap() error { return e.Err }
+
+// Timeout reports whether the request failed because a deadline expired.
+func (e *RequestError) Timeout() bool {
+	var ne net.Error
+	return errors.As(e.Err, &ne) && ne.Timeout()
+}
+
+// Temporary reports whether repeating the request may succeed. Requests
+// cancelled by the caller are never temporary.
+func (e *RequestError) Temporary() bool {
+	if errors.Is(e.Err, context.Canceled) {
+		return false
+	}
+	var ne net.Error
+	return e.Timeout() || (errors.As(e.Err, &ne) && ne.Temporary())
+}
+
+var _ net.Error = (*RequestError)(nil)
+
+// APIError is returned when PCS answers with an error_code, either with a
+// non-2xx status or inside an otherwise successful response.
+type APIError struct {
+	Response *http.Response // HTTP response that caused this error
+	Message  string         `json:"error_msg"`  // error message
+	Code     int            `json:"error_code"` // error code
+}
+
+// ErrorResponse is the former name of APIError.
+//
+// Deprecated: use APIError.
+type ErrorResponse = APIError
+
+func (r *APIError) Error() string {
+	return fmt.Sprintf("[%v] - %v - %d - %v - %d",
+		r.Response.Request.Method, r.Response.Request.URL,
+		r.Response.StatusCode, r.Message, r.Code)
+}
+
+// Unwrap returns the sentinel error for the error code, such as
+// ErrFileNotExist, or nil for codes without one.
+func (r *APIError) Unwrap() error { return codeErrors[r.Code] }
+
+// Timeout reports whether the server gave up waiting for the request.
+func (r *APIError) Timeout() bool {
+	switch r.Response.StatusCode {
+	case http.StatusRequestTimeout, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// Temporary reports whether repeating the request may succeed: server
+// errors, throttling and timeouts are temporary, rejected arguments or
+// missing files are not.
+func (r *APIError) Temporary() bool {
+	if r.Timeout() || temporaryCodes[r.Code] {
+		return true
+	}
+	c := r.Response.StatusCode
+	return c == http.StatusTooManyRequests || (500 <= c && c <= 599)
+}
+
+var _ net.Error = (*APIError)(nil)
+
+// panStatus is embedded in the results of the pan.baidu.com endpoints,
+// which report failures with errno rather than error_code.
+type panStatus struct {
+	Errno  int    `json:"errno"`
+	Errmsg string `json:"errmsg"`
+}
+
+func (s panStatus) err(resp *http.Response) error {
+	if s.Errno == 0 {
+		return nil
+	}
+	return &APIError{Response: resp, Code: s.Errno, Message: s.Errmsg}
+}
+
+// DecodeError is returned when a successful response could not be decoded
+// into the expected type.
+type DecodeError struct {
+	Response *http.Response
+	Body     []byte // raw response body
+	Err      error  // error returned by encoding/json
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("[%v] - %v - %d - decoding response: %v",
+		e.Response.Request.Method, e.Response.Request.URL,
+		e.Response.StatusCode, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error { return e.Err }
+
+// RedirectError is returned for 3xx responses that were not followed, either
+// because the Client was created WithoutRedirects or because the server did
+// not send a usable Location.
+type RedirectError struct {
+	Response *http.Response // HTTP response that caused this error
+	Location string         // value of the Location header, may be empty
+}
+
+func (r *RedirectError) Error() string {
+	return fmt.Sprintf("[%v] - %v - %d - redirect to %q",
+		r.Response.Request.Method, r.Response.Request.URL,
+		r.Response.StatusCode, r.Location)
+}
+
+func (r *RedirectError) Timeout() bool   { return false }
+func (r *RedirectError) Temporary() bool { return false }
+
+var _ net.Error = (*RedirectError)(nil)
+
+func CheckResponse(r *http.Response) error {
+	if c := r.StatusCode; 200 <= c && c <= 299 {
+		return nil
+	}
+	if c := r.StatusCode; 300 <= c && c <= 399 {
+		return &RedirectError{Response: r, Location: r.Header.Get("Location")}
+	}
+	apiError := &APIError{Response: r}
+	data, err := ioutil.ReadAll(r.Body)
+	if err == nil && data != nil {
+		json.Unmarshal(data, apiError)
+	}
+	return apiError
+}
+
+func checkErrorBody(resp *http.Response, data []byte) error {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil
+	}
+	apiError := &APIError{Response: resp}
+	if json.Unmarshal(data, apiError) != nil || apiError.Code == 0 {
+		return nil
+	}
+	return apiError
+}
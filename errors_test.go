@@ -0,0 +1,55 @@
+package pcs_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/holys/baidu-pcs/v2"
+	"github.com/holys/baidu-pcs/v2/pcstest"
+)
+
+func TestAPIErrorSentinels(t *testing.T) {
+	ctx := context.Background()
+	srv := pcstest.NewServer()
+	defer srv.Close()
+	c := srv.NewClient()
+	srv.PutFile("/apps/t/a.txt", []byte("a"))
+
+	for code, want := range map[int]error{
+		31061: pcs.ErrAlreadyExists,
+		31064: pcs.ErrPermissionDenied,
+		31066: pcs.ErrFileNotExist,
+		31070: pcs.ErrDeleteFailed,
+		31112: pcs.ErrInsufficientQuota,
+		110:   pcs.ErrTokenExpired,
+		-9:    pcs.ErrFileNotExist,
+	} {
+		srv.FailNext("file", "delete", http.StatusBadRequest, code)
+		_, err := c.Delete(ctx, "/apps/t/a.txt")
+		if !errors.Is(err, want) {
+			t.Errorf("code %d: %v, want %v", code, err, want)
+		}
+		var ae *pcs.APIError
+		if !errors.As(err, &ae) || ae.Code != code {
+			t.Errorf("code %d: %v is not an *APIError with that code", code, err)
+		}
+		var er *pcs.ErrorResponse
+		if !errors.As(err, &er) || !errors.Is(er, want) {
+			t.Errorf("code %d: %v does not match through *ErrorResponse", code, err)
+		}
+	}
+
+	// 31070 表示删除失败，原因不一定是目录非空
+	srv.FailNext("file", "delete", http.StatusBadRequest, 31070)
+	if _, err := c.Delete(ctx, "/apps/t/a.txt"); errors.Is(err, pcs.ErrDirNotEmpty) {
+		t.Errorf("code 31070 matches ErrDirNotEmpty: %v", err)
+	}
+
+	srv.FailNext("file", "delete", http.StatusBadRequest, 31023)
+	_, err := c.Delete(ctx, "/apps/t/a.txt")
+	if err == nil || errors.Is(err, pcs.ErrDeleteFailed) || errors.Is(err, pcs.ErrFileNotExist) {
+		t.Errorf("code without a sentinel: %v", err)
+	}
+}
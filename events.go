@@ -0,0 +1,153 @@
+package pcs
+
+import (
+	"sync"
+)
+
+// 默认在已用空间达到配额的95%时发布 QuotaLow
+const defaultQuotaLowRatio = 0.95
+
+// Event 是通过 Client.Subscribe 发布的事件，具体类型为以下结构体之一，
+// 订阅者可使用类型断言或 type switch 区分。
+type Event interface {
+	event()
+}
+
+// UploadStarted 在 TransferManager 开始（或从断点继续）执行上传任务时发布。
+type UploadStarted struct {
+	Job Job
+}
+
+// UploadCompleted 在上传任务完成时发布。
+type UploadCompleted struct {
+	Job Job
+}
+
+// UploadFailed 在上传任务失败时发布，暂停或关闭导致的中断不算失败。
+type UploadFailed struct {
+	Job Job
+	Err error
+}
+
+// DownloadStarted 在 TransferManager 开始（或从断点继续）执行下载任务时发布。
+type DownloadStarted struct {
+	Job Job
+}
+
+// DownloadCompleted 在下载任务完成时发布。
+type DownloadCompleted struct {
+	Job Job
+}
+
+// DownloadFailed 在下载任务失败时发布。
+type DownloadFailed struct {
+	Job Job
+	Err error
+}
+
+// FileDeleted 在 Delete 或 BatchDelete 成功后发布。
+type FileDeleted struct {
+	Paths []string
+}
+
+// QuotaLow 在 GetQuota 发现已用空间达到配额的一定比例时发布，见 WithQuotaLowRatio。
+type QuotaLow struct {
+	Quota Quota
+}
+
+func (UploadStarted) event()     {}
+func (UploadCompleted) event()   {}
+func (UploadFailed) event()      {}
+func (DownloadStarted) event()   {}
+func (DownloadCompleted) event() {}
+func (DownloadFailed) event()    {}
+func (FileDeleted) event()       {}
+func (QuotaLow) event()          {}
+
+// Subscribe 注册 fn 接收此后发布的全部事件，返回取消订阅的函数。
+// 每个订阅者在各自的 goroutine 中按发布顺序收到事件，处理缓慢的订阅者
+// 不会阻塞传输或其他订阅者；取消订阅时尚未处理的事件被丢弃。
+func (c *Client) Subscribe(fn func(Event)) (unsubscribe func()) {
+	return c.events.subscribe(fn)
+}
+
+func (c *Client) publish(e Event) {
+	c.events.publish(e)
+}
+
+type eventBus struct {
+	mu   sync.Mutex
+	subs map[*subscriber]struct{}
+}
+
+type subscriber struct {
+	fn    func(Event)
+	mu    sync.Mutex
+	queue []Event
+	wake  chan struct{}
+	done  chan struct{}
+}
+
+func (b *eventBus) subscribe(fn func(Event)) func() {
+	s := &subscriber{
+		fn:   fn,
+		wake: make(chan struct{}, 1),
+		done: make(chan struct{}),
+	}
+	b.mu.Lock()
+	if b.subs == nil {
+		b.subs = make(map[*subscriber]struct{})
+	}
+	b.subs[s] = struct{}{}
+	b.mu.Unlock()
+
+	go s.loop()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subs, s)
+			b.mu.Unlock()
+			close(s.done)
+		})
+	}
+}
+
+func (b *eventBus) publish(e Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for s := range b.subs {
+		s.mu.Lock()
+		s.queue = append(s.queue, e)
+		s.mu.Unlock()
+		select {
+		case s.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (s *subscriber) loop() {
+	for {
+		select {
+		case <-s.wake:
+		case <-s.done:
+			return
+		}
+
+		s.mu.Lock()
+		queue := s.queue
+		s.queue = nil
+		s.mu.Unlock()
+
+		for _, e := range queue {
+			select {
+			case <-s.done:
+				return
+			default:
+			}
+			s.fn(e)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package pcs
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+)
+
+// ExportFormat 是清单和报表导出的格式
+type ExportFormat int
+
+const (
+	FormatJSON   ExportFormat = iota // 缩进的 JSON 数组
+	FormatCSV                        // 首行为表头的 CSV
+	FormatNDJSON                     // 每行一个 JSON 对象
+)
+
+func writeJSON(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func writeNDJSON[T any](w io.Writer, items []T) error {
+	enc := json.NewEncoder(w)
+	for _, v := range items {
+		if err := enc.Encode(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeCSV(w io.Writer, header []string, rows [][]string) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	if err := cw.WriteAll(rows); err != nil {
+		return err
+	}
+	return cw.Error()
+}
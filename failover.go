@@ -0,0 +1,83 @@
+package pcs
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+)
+
+// hostGroup 是可以互相替代的一组主机，current 为当前使用的主机。
+type hostGroup struct {
+	hosts   []*url.URL
+	current int
+}
+
+func newHostGroup(primary *url.URL, fallbacks []*url.URL) *hostGroup {
+	if len(fallbacks) == 0 {
+		return nil
+	}
+	return &hostGroup{hosts: append([]*url.URL{primary}, fallbacks...)}
+}
+
+func (g *hostGroup) fallbacks() []*url.URL {
+	if g == nil {
+		return nil
+	}
+	return append([]*url.URL(nil), g.hosts[1:]...)
+}
+
+// isConnError 判断 err 是否为无法连接主机的错误，例如域名解析失败、连接被拒绝或熔断器打开。
+// 这类错误发生时请求尚未发出，换一个主机重试是安全的。
+func isConnError(err error) bool {
+	if errors.Is(err, ErrCircuitOpen) {
+		return true
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return true
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// failover 在 req 的主机无法连接时将对应的上传或下载地址切换到下一个备用主机，
+// 之后的请求都使用新主机。req 可以重放时返回发往新主机的副本，否则返回 nil。
+func (c *Client) failover(req *http.Request, err error) *http.Request {
+	if !isConnError(err) {
+		return nil
+	}
+
+	c.mu.Lock()
+	var next *url.URL
+	for _, g := range []struct {
+		group *hostGroup
+		url   **url.URL
+	}{{c.uploadHosts, &c.UploadURL}, {c.downloadHosts, &c.DownloadURL}} {
+		if g.group == nil || (*g.url).Host != req.URL.Host {
+			continue
+		}
+		g.group.current = (g.group.current + 1) % len(g.group.hosts)
+		next = g.group.hosts[g.group.current]
+		*g.url = next
+		break
+	}
+	c.mu.Unlock()
+	if next == nil {
+		return nil
+	}
+
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return nil
+	}
+	retry := req.Clone(req.Context())
+	retry.URL.Scheme, retry.URL.Host, retry.Host = next.Scheme, next.Host, ""
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil
+		}
+		retry.Body = body
+	}
+	return retry
+}
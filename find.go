@@ -0,0 +1,144 @@
+package pcs
+
+import (
+	"context"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Find 默认并发列出目录的数量上限，见 WithListConcurrency
+const defaultListConcurrency = 8
+
+// FindKind 限定 Find 返回的条目类型
+type FindKind int
+
+const (
+	FindAll   FindKind = iota // 文件和目录
+	FindFiles                 // 只返回文件
+	FindDirs                  // 只返回目录
+)
+
+// Filter 是 Find 的筛选条件，零值的字段不参与筛选
+type Filter struct {
+	Kind FindKind
+
+	MinSize uint64 // 文件大小下限（含），不适用于目录
+	MaxSize uint64 // 文件大小上限（含），为0时不限，不适用于目录
+
+	ModifiedAfter  time.Time // 修改时间不早于该时间
+	ModifiedBefore time.Time // 修改时间早于该时间
+
+	Extensions []string       // 文件扩展名，如 ".mp4" 或 "mp4"，不区分大小写，不适用于目录
+	Categories []Category     // 文件类别，见 CategoryOf，不适用于目录
+	Name       *regexp.Regexp // 匹配文件名或目录名（不含路径）
+
+	MaxDepth int // 最大深度，root 下的直接子项深度为1，为0时不限
+}
+
+// Match 报告深度为 depth 的条目 f 是否满足筛选条件
+func (ft *Filter) Match(f *File, depth int) bool {
+	isDir := f.IsDirectory()
+	switch {
+	case ft.Kind == FindFiles && isDir,
+		ft.Kind == FindDirs && !isDir,
+		ft.MaxDepth > 0 && depth > ft.MaxDepth:
+		return false
+	}
+
+	mtime := time.Unix(int64(f.Mtime), 0)
+	if !ft.ModifiedAfter.IsZero() && mtime.Before(ft.ModifiedAfter) {
+		return false
+	}
+	if !ft.ModifiedBefore.IsZero() && !mtime.Before(ft.ModifiedBefore) {
+		return false
+	}
+
+	name := path.Base(f.Path)
+	if ft.Name != nil && !ft.Name.MatchString(name) {
+		return false
+	}
+	if isDir {
+		return true
+	}
+
+	if f.Size < ft.MinSize || (ft.MaxSize > 0 && f.Size > ft.MaxSize) {
+		return false
+	}
+	if len(ft.Categories) > 0 && !hasCategory(ft.Categories, CategoryOf(name)) {
+		return false
+	}
+	if len(ft.Extensions) > 0 {
+		ext := strings.TrimPrefix(path.Ext(name), ".")
+		for _, e := range ft.Extensions {
+			if strings.EqualFold(ext, strings.TrimPrefix(e, ".")) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// Find 遍历远程目录 root，返回满足 filter 的全部文件和目录，按路径排序。
+// 服务端的 Search 只能按文件名查找且结果常常滞后，Find 则并发列出各级目录后在本地筛选。
+// filter 为 nil 时返回全部条目。任一目录列出失败或 ctx 被取消时，立即中断其余的请求，
+// 返回该错误以及此前已找到的条目。
+func (c *Client) Find(ctx context.Context, root string, filter *Filter) ([]*File, error) {
+	if filter == nil {
+		filter = &Filter{}
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu      sync.Mutex
+		found   []*File
+		findErr error
+		wg      sync.WaitGroup
+	)
+	sem := make(chan struct{}, c.listConcurrency)
+
+	var visit func(dir string, depth int)
+	visit = func(dir string, depth int) {
+		defer wg.Done()
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+		files, err := c.listDir(ctx, dir)
+		<-sem
+
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			if findErr == nil {
+				findErr = err
+				cancel()
+			}
+			return
+		}
+		for _, f := range files {
+			if filter.Match(f, depth) {
+				found = append(found, f)
+			}
+			if f.IsDirectory() && (filter.MaxDepth == 0 || depth < filter.MaxDepth) {
+				wg.Add(1)
+				go visit(f.Path, depth+1)
+			}
+		}
+	}
+	wg.Add(1)
+	visit(CleanPath(root), 1)
+	wg.Wait()
+
+	sort.Slice(found, func(i, j int) bool { return found[i].Path < found[j].Path })
+	if findErr != nil {
+		return found, findErr
+	}
+	return found, ctx.Err()
+}
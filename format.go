@@ -0,0 +1,96 @@
+package pcs
+
+import (
+	"fmt"
+	"time"
+)
+
+var sizeUnits = []string{"B", "KiB", "MiB", "GiB", "TiB", "PiB", "EiB"}
+
+// HumanSize 以1024为进制返回便于阅读的大小，如 "512 B"、"1.5 MiB"
+func HumanSize(n uint64) string {
+	if n < 1024 {
+		return fmt.Sprintf("%d B", n)
+	}
+	f, i := float64(n), 0
+	for f >= 1024 && i < len(sizeUnits)-1 {
+		f /= 1024
+		i++
+	}
+	return fmt.Sprintf("%.1f %s", f, sizeUnits[i])
+}
+
+// String 返回已用空间、总空间和使用比例，如 "1.5 GiB / 2.0 TiB (0.1%)"
+func (q Quota) String() string {
+	s := HumanSize(q.Used) + " / " + HumanSize(q.Quota)
+	if q.Quota > 0 {
+		s += fmt.Sprintf(" (%.1f%%)", float64(q.Used)/float64(q.Quota)*100)
+	}
+	return s
+}
+
+// String 返回类似 ls -l 的一行：类型、大小、修改时间和路径，目录的路径以 / 结尾
+func (f *File) String() string {
+	kind, size, p := "-", HumanSize(f.Size), f.Path
+	if f.IsDirectory() {
+		kind, size, p = "d", "-", f.Path+"/"
+	}
+	mtime := time.Unix(int64(f.Mtime), 0).Format("2006-01-02 15:04")
+	return fmt.Sprintf("%s %10s %s %s", kind, size, mtime, p)
+}
+
+// 离线下载任务的状态，见 OfflineTask
+const (
+	TaskSuccess      = 0 // 下载成功
+	TaskRunning      = 1 // 下载进行中
+	TaskSystemError  = 2 // 系统错误
+	TaskNotFound     = 3 // 资源不存在
+	TaskTimeout      = 4 // 下载超时
+	TaskFailed       = 5 // 资源存在但下载失败
+	TaskNoSpace      = 6 // 存储空间不足
+	TaskTargetExists = 7 // 目标地址数据已存在
+	TaskCancelled    = 8 // 任务取消
+)
+
+var taskStatusNames = map[int]string{
+	TaskSuccess:      "success",
+	TaskRunning:      "running",
+	TaskSystemError:  "system error",
+	TaskNotFound:     "not found",
+	TaskTimeout:      "timed out",
+	TaskFailed:       "failed",
+	TaskNoSpace:      "insufficient space",
+	TaskTargetExists: "already exists",
+	TaskCancelled:    "cancelled",
+}
+
+// OfflineTask 是 QueryOfflineDownloadTask 和 ListOfflineDownloadTask 的响应中 task_info 的一项。
+// cloud_dl 接口以字符串返回数值，解码时由 json 的 string 选项转换。
+type OfflineTask struct {
+	ID           int64  `json:"task_id,string"`
+	TaskName     string `json:"task_name"`
+	SourceURL    string `json:"source_url"`
+	SavePath     string `json:"save_path"`
+	Status       int    `json:"status,string"` // 见 TaskSuccess 等常量
+	FileSize     uint64 `json:"file_size,string"`
+	FinishedSize uint64 `json:"finished_size,string"`
+	CreateTime   int64  `json:"create_time,string"`
+}
+
+// String 返回任务的ID、状态和进度，如 "#12 running 45.0% (1.2 MiB / 2.7 MiB) http://… -> /apps/dl"
+func (t *OfflineTask) String() string {
+	status, ok := taskStatusNames[t.Status]
+	if !ok {
+		status = fmt.Sprintf("status %d", t.Status)
+	}
+	s := fmt.Sprintf("#%d %s", t.ID, status)
+	if t.FileSize > 0 {
+		s += fmt.Sprintf(" %.1f%% (%s / %s)", float64(t.FinishedSize)/float64(t.FileSize)*100,
+			HumanSize(t.FinishedSize), HumanSize(t.FileSize))
+	}
+	src := t.SourceURL
+	if src == "" {
+		src = t.TaskName
+	}
+	return fmt.Sprintf("%s %s -> %s", s, src, t.SavePath)
+}
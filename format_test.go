@@ -0,0 +1,61 @@
+package pcs_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/holys/baidu-pcs/v2"
+)
+
+func TestHumanSize(t *testing.T) {
+	for _, tt := range []struct {
+		n    uint64
+		want string
+	}{
+		{0, "0 B"},
+		{1023, "1023 B"},
+		{1024, "1.0 KiB"},
+		{1536 * 1024, "1.5 MiB"},
+		{5 << 40, "5.0 TiB"},
+		{1<<64 - 1, "16.0 EiB"},
+	} {
+		if got := pcs.HumanSize(tt.n); got != tt.want {
+			t.Errorf("HumanSize(%d) = %q, want %q", tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestFormatStrings(t *testing.T) {
+	if s := (pcs.Quota{Quota: 2 << 40, Used: 1536 << 20}).String(); s != "1.5 GiB / 2.0 TiB (0.1%)" {
+		t.Errorf("Quota.String() = %q", s)
+	}
+	if s := (pcs.Quota{}).String(); s != "0 B / 0 B" {
+		t.Errorf("empty Quota.String() = %q", s)
+	}
+
+	mtime := time.Date(2024, 3, 4, 5, 6, 0, 0, time.Local)
+	f := &pcs.File{Path: "/apps/t/a.txt", Size: 2048, Mtime: uint64(mtime.Unix())}
+	if s, want := f.String(), "-    2.0 KiB 2024-03-04 05:06 /apps/t/a.txt"; s != want {
+		t.Errorf("File.String() = %q, want %q", s, want)
+	}
+	f = &pcs.File{Path: "/apps/t/d", IsDir: 1, Mtime: uint64(mtime.Unix())}
+	if s, want := f.String(), "d          - 2024-03-04 05:06 /apps/t/d/"; s != want {
+		t.Errorf("directory File.String() = %q, want %q", s, want)
+	}
+
+	// cloud_dl 以字符串返回数值
+	var task pcs.OfflineTask
+	data := `{"task_id":"12","status":"1","file_size":"2048","finished_size":"1024",
+		"source_url":"http://example.com/a.iso","save_path":"/apps/dl"}`
+	if err := json.Unmarshal([]byte(data), &task); err != nil {
+		t.Fatal(err)
+	}
+	if s, want := task.String(), "#12 running 50.0% (1.0 KiB / 2.0 KiB) http://example.com/a.iso -> /apps/dl"; s != want {
+		t.Errorf("OfflineTask.String() = %q, want %q", s, want)
+	}
+	task = pcs.OfflineTask{ID: 3, Status: 42, TaskName: "a.iso", SavePath: "/apps/dl"}
+	if s, want := task.String(), "#3 status 42 a.iso -> /apps/dl"; s != want {
+		t.Errorf("OfflineTask.String() = %q, want %q", s, want)
+	}
+}
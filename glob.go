@@ -0,0 +1,116 @@
+package pcs
+
+import (
+	"context"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Glob 返回与 pattern 匹配的全部远程文件和目录，按路径排序。pattern 为绝对路径，
+// 各级名称中可使用 path.Match 的 *、? 和 [...]，单独的一级 ** 匹配零或多级目录，
+// 如 /backups/2024-*/**/*.tar.gz。一次调用中每个目录最多列出一次。没有匹配时返回空切片。
+// 出错或 ctx 被取消时返回该错误以及此前已匹配的条目。
+func (c *Client) Glob(ctx context.Context, pattern string) ([]*File, error) {
+	pattern = CleanPath(pattern)
+	if !strings.HasPrefix(pattern, "/") {
+		return nil, invalid("pattern", "pattern %q must be absolute", pattern)
+	}
+
+	segs := strings.Split(strings.TrimPrefix(pattern, "/"), "/")
+	for _, s := range segs {
+		if _, err := path.Match(s, ""); err != nil {
+			return nil, invalid("pattern", "%v in %q", err, pattern)
+		}
+	}
+
+	// 不含通配符的前缀直接作为起始目录
+	i := 0
+	for i < len(segs) && !hasMeta(segs[i]) {
+		i++
+	}
+	if i == len(segs) {
+		meta, _, err := c.GetMeta(ctx, pattern)
+		if isNotExist(err) {
+			return []*File{}, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return []*File{meta.File}, nil
+	}
+
+	g := &globber{c: c, listed: make(map[string][]*File), found: make(map[string]*File)}
+	err := g.match(ctx, "/"+path.Join(segs[:i]...), segs[i:])
+
+	files := make([]*File, 0, len(g.found))
+	for _, f := range g.found {
+		files = append(files, f)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files, err
+}
+
+func hasMeta(s string) bool {
+	return strings.ContainsAny(s, `*?[\`)
+}
+
+type globber struct {
+	c      *Client
+	listed map[string][]*File // 已列出的目录
+	found  map[string]*File
+}
+
+func (g *globber) list(ctx context.Context, dir string) ([]*File, error) {
+	if files, ok := g.listed[dir]; ok {
+		return files, nil
+	}
+	files, err := g.c.listDir(ctx, dir)
+	if isNotExist(err) {
+		files, err = nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	g.listed[dir] = files
+	return files, nil
+}
+
+// match 在目录 dir 下查找与 segs 匹配的条目
+func (g *globber) match(ctx context.Context, dir string, segs []string) error {
+	if segs[0] == "**" && len(segs) > 1 {
+		if err := g.match(ctx, dir, segs[1:]); err != nil {
+			return err
+		}
+	}
+
+	files, err := g.list(ctx, dir)
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		if segs[0] == "**" {
+			// 末尾的 ** 匹配 dir 下的全部条目
+			if len(segs) == 1 {
+				g.found[f.Path] = f
+			}
+			if f.IsDirectory() {
+				if err := g.match(ctx, f.Path, segs); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if ok, _ := path.Match(segs[0], path.Base(f.Path)); !ok {
+			continue
+		}
+		if len(segs) == 1 {
+			g.found[f.Path] = f
+		} else if f.IsDirectory() {
+			if err := g.match(ctx, f.Path, segs[1:]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
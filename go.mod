@@ -0,0 +1,14 @@
+module github.com/holys/baidu-pcs/v2
+
+go 1.23.0
+
+require (
+	github.com/fsnotify/fsnotify v1.9.0
+	github.com/google/go-querystring v1.2.0
+	golang.org/x/crypto v0.41.0
+	golang.org/x/term v0.34.0
+	golang.org/x/text v0.28.0
+	golang.org/x/time v0.12.0
+)
+
+require golang.org/x/sys v0.35.0 // indirect
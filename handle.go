@@ -0,0 +1,114 @@
+package pcs
+
+import (
+	"context"
+	"io"
+	"path"
+)
+
+// FileHandle 指向一个远程文件，以方法的形式提供常用的操作，例如
+//
+//	c.File("/apps/app/docs/a.pdf").Download(ctx, w)
+//
+// 创建 FileHandle 不发送请求，文件不必已经存在。FileHandle 不可修改，可被多个 goroutine 并发使用。
+type FileHandle struct {
+	c    *Client
+	path string
+}
+
+// File 返回远程文件 p 的 FileHandle
+func (c *Client) File(p string) *FileHandle {
+	return &FileHandle{c: c, path: CleanPath(p)}
+}
+
+// Path 返回文件的远程路径
+func (f *FileHandle) Path() string {
+	return f.path
+}
+
+// Meta 返回文件的元信息，见 GetMeta。
+func (f *FileHandle) Meta(ctx context.Context) (*FileMeta, error) {
+	meta, _, err := f.c.GetMeta(ctx, f.path)
+	return meta, err
+}
+
+// Download 下载文件并将内容写入 w，见 DownloadTo。
+func (f *FileHandle) Download(ctx context.Context, w io.Writer) error {
+	_, err := f.c.DownloadTo(ctx, f.path, w)
+	return err
+}
+
+// Open 打开文件用于随机读取，见 Client.Open。
+func (f *FileHandle) Open(ctx context.Context) (*RemoteFile, error) {
+	return f.c.Open(ctx, f.path)
+}
+
+// Upload 将 r 中的全部数据上传为该文件，已存在时覆盖。数据按分片上传，不需要预先知道长度，
+// 见 Client.UploadReader。
+func (f *FileHandle) Upload(ctx context.Context, r io.Reader) (*File, error) {
+	return f.c.UploadReader(ctx, r, &FileOptions{Path: f.path, OnDup: OnDupOverwrite})
+}
+
+// Delete 删除文件
+func (f *FileHandle) Delete(ctx context.Context) error {
+	_, err := f.c.Delete(ctx, f.path)
+	return err
+}
+
+// MoveTo 将文件移动到 to，返回指向 to 的 FileHandle。
+func (f *FileHandle) MoveTo(ctx context.Context, to string) (*FileHandle, error) {
+	if _, _, err := f.c.Move(ctx, f.path, to); err != nil {
+		return nil, err
+	}
+	return f.c.File(to), nil
+}
+
+// CopyTo 将文件复制到 to，返回指向 to 的 FileHandle。
+func (f *FileHandle) CopyTo(ctx context.Context, to string) (*FileHandle, error) {
+	if _, _, err := f.c.Copy(ctx, f.path, to); err != nil {
+		return nil, err
+	}
+	return f.c.File(to), nil
+}
+
+// DirHandle 指向一个远程目录，见 FileHandle。
+type DirHandle struct {
+	c    *Client
+	path string
+}
+
+// Dir 返回远程目录 p 的 DirHandle
+func (c *Client) Dir(p string) *DirHandle {
+	return &DirHandle{c: c, path: CleanPath(p)}
+}
+
+// Path 返回目录的远程路径
+func (d *DirHandle) Path() string {
+	return d.path
+}
+
+// File 返回目录中名为 name 的文件的 FileHandle，name 可以包含子目录。
+func (d *DirHandle) File(name string) *FileHandle {
+	return d.c.File(path.Join(d.path, name))
+}
+
+// Dir 返回目录中名为 name 的子目录的 DirHandle
+func (d *DirHandle) Dir(name string) *DirHandle {
+	return d.c.Dir(path.Join(d.path, name))
+}
+
+// List 返回目录的全部直接子项，超过 1000 项时按页列出，见 Files。
+func (d *DirHandle) List(ctx context.Context) ([]*File, error) {
+	return d.c.listDir(ctx, d.path)
+}
+
+// Walk 以广度优先的顺序遍历目录下的全部文件和目录，对每一项调用 fn。
+// fn 返回错误时停止遍历并返回该错误。
+func (d *DirHandle) Walk(ctx context.Context, fn func(*File) error) error {
+	return d.c.walk(ctx, d.path, fn)
+}
+
+// Upload 将 r 中的全部数据上传为目录中名为 name 的文件，见 FileHandle.Upload。
+func (d *DirHandle) Upload(ctx context.Context, name string, r io.Reader) (*File, error) {
+	return d.File(name).Upload(ctx, r)
+}
@@ -0,0 +1,135 @@
+package pcs
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// 修改时间距计算时不足该时长的文件不加入缓存：文件系统的时间精度有限，
+// 紧接着的修改可能不改变修改时间，缓存的校验值就会过期而无法发现
+const hashCacheRacyWindow = 2 * time.Second
+
+type hashCacheEntry struct {
+	Size     int64  `json:"size"`
+	ModTime  int64  `json:"mtime"` // UnixNano
+	Md5      string `json:"md5"`
+	SliceMd5 string `json:"slice_md5"`
+	Crc32    uint32 `json:"crc32"`
+}
+
+// HashCache 在本地文件中缓存文件的校验值，以文件的绝对路径、大小和修改时间为键，
+// 重复秒传或同步大量未修改的文件时不必每次重新读取全部内容。
+// 通过 WithHashCache 设置后，SumFile、Sync 和 Backup 优先使用缓存的值。
+// 新计算的值只保存在内存中，需要调用 Save 写入文件。可被多个 goroutine 并发使用。
+type HashCache struct {
+	path string
+
+	mu      sync.Mutex
+	entries map[string]hashCacheEntry
+	dirty   bool
+}
+
+// NewHashCache 创建保存在 path 的 HashCache，并加载已有的记录。
+func NewHashCache(path string) (*HashCache, error) {
+	h := &HashCache{path: path, entries: make(map[string]hashCacheEntry)}
+
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &h.entries); err != nil {
+			return nil, err
+		}
+	}
+	return h, nil
+}
+
+// Sum 返回本地文件 p 的校验值，文件的大小和修改时间与缓存的记录一致时不读取文件。
+// 返回值的 Path 为空。h 为 nil 时总是读取文件。
+func (h *HashCache) Sum(p string) (ManifestEntry, error) {
+	if h == nil {
+		return sumManifestEntry(p)
+	}
+	abs, err := filepath.Abs(p)
+	if err != nil {
+		return ManifestEntry{}, err
+	}
+	fi, err := os.Stat(abs)
+	if err != nil {
+		return ManifestEntry{}, err
+	}
+
+	h.mu.Lock()
+	e, ok := h.entries[abs]
+	h.mu.Unlock()
+	if ok && e.Size == fi.Size() && e.ModTime == fi.ModTime().UnixNano() {
+		return ManifestEntry{Size: e.Size, Md5: e.Md5, SliceMd5: e.SliceMd5, Crc32: e.Crc32}, nil
+	}
+
+	start := time.Now()
+	m, err := sumManifestEntry(abs)
+	if err != nil {
+		return ManifestEntry{}, err
+	}
+	// 计算期间被修改过的文件同样不加入缓存
+	if after, err := os.Stat(abs); err != nil || after.Size() != fi.Size() || !after.ModTime().Equal(fi.ModTime()) ||
+		start.Sub(fi.ModTime()) < hashCacheRacyWindow {
+		return m, nil
+	}
+
+	h.mu.Lock()
+	h.entries[abs] = hashCacheEntry{
+		Size:     m.Size,
+		ModTime:  fi.ModTime().UnixNano(),
+		Md5:      m.Md5,
+		SliceMd5: m.SliceMd5,
+		Crc32:    m.Crc32,
+	}
+	h.dirty = true
+	h.mu.Unlock()
+	return m, nil
+}
+
+// Prune 删除文件已不存在或已被修改的记录，返回删除的记录数。
+func (h *HashCache) Prune() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	n := 0
+	for p, e := range h.entries {
+		if fi, err := os.Stat(p); err != nil || fi.Size() != e.Size || fi.ModTime().UnixNano() != e.ModTime {
+			delete(h.entries, p)
+			n++
+		}
+	}
+	if n > 0 {
+		h.dirty = true
+	}
+	return n
+}
+
+// Save 将记录写入文件，没有变化时什么也不做。
+func (h *HashCache) Save() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !h.dirty {
+		return nil
+	}
+
+	data, err := json.Marshal(h.entries)
+	if err != nil {
+		return err
+	}
+	tmp := h.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, h.path); err != nil {
+		return err
+	}
+	h.dirty = false
+	return nil
+}
@@ -0,0 +1,26 @@
+package pcs
+
+import (
+	"context"
+	"net/http"
+)
+
+type headerKey struct{}
+
+// WithRequestHeader returns a copy of ctx that makes every request sent with
+// it carry the header key: value, e.g. an X-Request-Id for tracing one
+// operation or a per-application User-Agent. Request headers take precedence
+// over the Client's own headers, including User-Agent.
+func WithRequestHeader(ctx context.Context, key, value string) context.Context {
+	h := requestHeader(ctx).Clone()
+	if h == nil {
+		h = make(http.Header)
+	}
+	h.Set(key, value)
+	return context.WithValue(ctx, headerKey{}, h)
+}
+
+func requestHeader(ctx context.Context) http.Header {
+	h, _ := ctx.Value(headerKey{}).(http.Header)
+	return h
+}
@@ -0,0 +1,79 @@
+package pcs
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const (
+	defaultHookAttempts = 3
+	defaultHookBackoff  = time.Second
+)
+
+// TransferHook 在上传或下载成功后由 TransferManager 调用，可用于设置扩展属性、
+// 通知 webhook、移动本地文件等。job 为完成传输时的任务快照，可通过 job.Kind 区分方向。
+// 返回错误时按 WithHookRetry 的设置重试，因此 hook 应当可以重复执行。
+type TransferHook func(ctx context.Context, job Job) error
+
+// WithTransferHook 注册传输成功后依次执行的 hook。
+// 已完成的 hook 记录在任务的断点中，重启后只执行尚未完成的，
+// 因此各次运行注册 hook 的顺序应保持一致。
+func WithTransferHook(h TransferHook) TransferOption {
+	return func(m *TransferManager) {
+		m.hooks = append(m.hooks, h)
+	}
+}
+
+// WithHookRetry 设置每个 hook 最多尝试 attempts 次，两次尝试之间等待 backoff 并逐次加倍。
+// 默认为3次，间隔1秒。全部失败时任务标记为 JobFailed，Resume 后只重新执行未完成的 hook。
+func WithHookRetry(attempts int, backoff time.Duration) TransferOption {
+	return func(m *TransferManager) {
+		if attempts > 0 {
+			m.hookAttempts = attempts
+		}
+		if backoff >= 0 {
+			m.hookBackoff = backoff
+		}
+	}
+}
+
+// runHooks 从 j.HooksDone 开始执行 hook，每完成一个保存一次断点。
+func (m *TransferManager) runHooks(ctx context.Context, j *Job) error {
+	m.mu.Lock()
+	done := j.HooksDone
+	job := j.clone()
+	m.mu.Unlock()
+
+	for i := done; i < len(m.hooks); i++ {
+		if err := m.runHook(ctx, i, job); err != nil {
+			return err
+		}
+		if err := m.update(func() { j.HooksDone = i + 1 }); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *TransferManager) runHook(ctx context.Context, i int, job Job) error {
+	backoff := m.hookBackoff
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = m.hooks[i](ctx, job); err == nil {
+			return nil
+		}
+		if attempt >= m.hookAttempts {
+			return fmt.Errorf("baidu-pcs: hook %d failed after %d attempts: %w", i, attempt, err)
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+		backoff *= 2
+	}
+}
@@ -0,0 +1,77 @@
+package pcs
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+)
+
+// codeFileExists is the error_code PCS answers with when the target path
+// is already taken.
+const codeFileExists = 31061
+
+type retriedKey struct{}
+
+// trackRetries returns a copy of ctx in which Do records whether it
+// retried a request, so that mutating operations can tell an error caused
+// by their own earlier, successful attempt from a genuine one.
+func trackRetries(ctx context.Context) (context.Context, *atomic.Bool) {
+	retried := new(atomic.Bool)
+	return context.WithValue(ctx, retriedKey{}, retried), retried
+}
+
+func markRetried(ctx context.Context) {
+	if retried, ok := ctx.Value(retriedKey{}).(*atomic.Bool); ok {
+		retried.Store(true)
+	}
+}
+
+// mkdirDone 在重试后收到“已存在”时确认 path 是否为目录：此时目录多半是之前超时的那次请求创建的。
+func (c *Client) mkdirDone(ctx context.Context, path string, retried bool, err error) (*File, bool) {
+	if !retried || !hasCode(err, codeFileExists) {
+		return nil, false
+	}
+	meta, _, merr := c.GetMeta(ctx, path)
+	if merr != nil || meta.File == nil || !meta.IsDirectory() {
+		return nil, false
+	}
+	return meta.File, true
+}
+
+// moveCopyDone 在重试后收到错误时确认移动或复制是否已经完成：
+// 移动时 from 已不存在且 to 存在，复制时 to 已存在。
+func (c *Client) moveCopyDone(ctx context.Context, move bool, from, to string, retried bool, err error) (*MoveCopyResponse, bool) {
+	if !retried {
+		return nil, false
+	}
+	if move && !isNotExist(err) || !move && !hasCode(err, codeFileExists) {
+		return nil, false
+	}
+	if _, _, merr := c.GetMeta(ctx, to); merr != nil {
+		return nil, false
+	}
+	if move {
+		if _, _, merr := c.GetMeta(ctx, from); !isNotExist(merr) {
+			return nil, false
+		}
+	}
+	m := new(MoveCopyResponse)
+	m.Extra.List = append(m.Extra.List, struct {
+		To   string `json:"to"`
+		From string `json:"from"`
+	}{CleanPath(to), CleanPath(from)})
+	return m, true
+}
+
+// deleteDone 报告重试后收到的“不存在”是否说明之前的请求已经删除了文件
+func deleteDone(retried bool, err error) bool {
+	return retried && isNotExist(err)
+}
+
+// postIdempotent 发送一个修改操作，失败时按 WithRetry 重试，返回 Do 是否重试过。
+// 调用方须能识别重试时由之前成功的尝试造成的错误，见 mkdirDone 等。
+func (c *Client) postIdempotent(ctx context.Context, u string, v interface{}) (*http.Response, bool, error) {
+	ctx, retried := trackRetries(ctx)
+	resp, err := c.PostForm(idempotent(ctx), u, nil, v)
+	return resp, retried.Load(), err
+}
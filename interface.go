@@ -0,0 +1,86 @@
+package pcs
+
+import (
+	"context"
+	"io"
+	"net/http"
+)
+
+// QuotaService 空间配额相关接口
+type QuotaService interface {
+	GetQuota(ctx context.Context) (*Quota, *http.Response, error)
+}
+
+// AccountService 账号信息相关接口
+type AccountService interface {
+	GetUserInfo(ctx context.Context) (*UserInfo, *http.Response, error)
+}
+
+// FileService 文件操作相关接口
+type FileService interface {
+	Upload(ctx context.Context, srcPath string, opt *FileOptions) (*File, *http.Response, error)
+	BlockUpload(ctx context.Context, srcPath string) (*File, *http.Response, error)
+	BlockUploadReader(ctx context.Context, r io.Reader, size int64) (*File, *http.Response, error)
+	CreateSuperFile(ctx context.Context, targetPath string, md5 []string, opt *FileOptions) (*File, *http.Response, error)
+	RapidUpload(ctx context.Context, opt *RapiduUploadOptions) (*File, *http.Response, error)
+	LocateUpload(ctx context.Context) (*UploadServers, *http.Response, error)
+	Download(ctx context.Context, path string) (*http.Response, error)
+	DownloadTo(ctx context.Context, path string, w io.Writer) (*http.Response, error)
+	LocateDownload(ctx context.Context, path string) (*DownloadLocations, *http.Response, error)
+	PartialDownload(ctx context.Context, path string, start, end int64) (*http.Response, error)
+	Mkdir(ctx context.Context, path string) (*File, *http.Response, error)
+	GetMeta(ctx context.Context, path string) (*FileMeta, *http.Response, error)
+	BatchGetMeta(ctx context.Context, paths []string) ([]*FileMeta, *http.Response, error)
+	ListFiles(ctx context.Context, opt *ListFilesOptions) ([]*File, *http.Response, error)
+	Move(ctx context.Context, from, to string) (*MoveCopyResponse, *http.Response, error)
+	Copy(ctx context.Context, from, to string) (*MoveCopyResponse, *http.Response, error)
+	Delete(ctx context.Context, path string) (*http.Response, error)
+	BatchMove(ctx context.Context, pairs []*FTPair) (*MoveCopyResponse, *http.Response, error)
+	BatchCopy(ctx context.Context, pairs []*FTPair) (*MoveCopyResponse, *http.Response, error)
+	BatchDelete(ctx context.Context, paths []string) (*http.Response, error)
+	Search(ctx context.Context, opt *SearchOptions) ([]*File, *http.Response, error)
+	Thumbnail(ctx context.Context, opt *ThumbnailOptions) (*http.Response, error)
+	Diff(ctx context.Context, cursor string) (*http.Response, error)
+	Streaming(ctx context.Context, path, typ string) (*http.Response, error)
+	ListStream(ctx context.Context, opt *ListStreamOptions) (*StreamFile, *http.Response, error)
+	DownloadStream(ctx context.Context, path string) (*http.Response, error)
+}
+
+// TaskService 离线下载相关接口
+type TaskService interface {
+	QueryTorrentInfo(ctx context.Context, sourcePath string) (*TorrentInfo, *http.Response, error)
+	AddOfflineDownloadTask(ctx context.Context, opt *AddTaskOptions) (int64, *http.Response, error)
+	QueryOfflineDownloadTask(ctx context.Context, opt *QueryTaskOptions) (*http.Response, error)
+	ListOfflineDownloadTask(ctx context.Context, opt *ListTaskOptions) (*http.Response, error)
+	CancelOfflineDownloadTask(ctx context.Context, opt *CancelTaskOptions) (*http.Response, error)
+}
+
+// RecycleService 回收站相关接口
+type RecycleService interface {
+	ListRecycle(ctx context.Context, opt *ListRecycleOptions) (*ListRecycleResponse, *http.Response, error)
+	Restore(ctx context.Context, fsId string) (*RestoreResponse, *http.Response, error)
+	BatchRestore(ctx context.Context, fsIds []string) (*RestoreResponse, *http.Response, error)
+	EmptyRecycle(ctx context.Context) (*http.Response, error)
+}
+
+// ShareService 分享链接相关接口
+type ShareService interface {
+	OpenShare(ctx context.Context, link, pwd string) (*Share, *http.Response, error)
+	ListShare(ctx context.Context, s *Share, dir string) ([]ShareFile, *http.Response, error)
+	SaveShare(ctx context.Context, s *Share, dest string, fsids ...uint64) (*http.Response, error)
+	CreateShare(ctx context.Context, pwd string, period int, paths ...string) (*ShareLink, *http.Response, error)
+	ListShareLinks(ctx context.Context) ([]*ShareLink, *http.Response, error)
+	CancelShares(ctx context.Context, ids ...uint64) (*http.Response, error)
+}
+
+// API 涵盖 Client 的全部远程接口，便于在测试中替换为 pcstest.MockClient。
+type API interface {
+	QuotaService
+	AccountService
+	FileService
+	TaskService
+	RecycleService
+	ShareService
+}
+
+var _ API = (*Client)(nil)
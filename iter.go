@@ -0,0 +1,177 @@
+package pcs
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"strconv"
+)
+
+const (
+	// listPageSize 是迭代器每次请求的条目数，与各列表接口的缺省最大值相同
+	listPageSize = 1000
+
+	// taskPageSize 是 OfflineTasks 每次请求的任务数
+	taskPageSize = 100
+)
+
+// pages 依次调用 fetch 获取从 start 开始的每一页，将其中的条目交给 yield，
+// 某一页的条目少于 size 时结束。出错或 ctx 被取消时将错误交给 yield 后结束。
+func pages[T any](ctx context.Context, start, size int, fetch func(start, size int) ([]T, error)) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+		for {
+			if err := ctx.Err(); err != nil {
+				yield(zero, err)
+				return
+			}
+			page, err := fetch(start, size)
+			if err != nil {
+				yield(zero, err)
+				return
+			}
+			for _, v := range page {
+				if !yield(v, nil) {
+					return
+				}
+			}
+			if len(page) < size {
+				return
+			}
+			start += len(page)
+		}
+	}
+}
+
+// Files 返回遍历远程目录 path 的直接子项的迭代器，例如
+//
+//	for f, err := range c.Files(ctx, "/apps/app") {
+//		if err != nil {
+//			return err
+//		}
+//		fmt.Println(f)
+//	}
+//
+// 条目按页自动请求，每页 1000 项。出错或 ctx 被取消时产生一个 nil 文件和该错误，之后结束。
+func (c *Client) Files(ctx context.Context, path string) iter.Seq2[*File, error] {
+	return c.FilesWith(ctx, &ListFilesOptions{Path: path})
+}
+
+// FilesWith 与 Files 相同，但按 opt 排序。opt.Limit 被忽略。
+func (c *Client) FilesWith(ctx context.Context, opt *ListFilesOptions) iter.Seq2[*File, error] {
+	o := *opt
+	return pages(ctx, 0, listPageSize, func(start, size int) ([]*File, error) {
+		o.Limit = fmt.Sprintf("%d-%d", start, start+size)
+		files, _, err := c.ListFiles(ctx, &o)
+		return files, err
+	})
+}
+
+// listDir 按页列出远程目录 dir 的全部直接子项，出错时返回该错误以及此前已列出的条目。
+func (c *Client) listDir(ctx context.Context, dir string) ([]*File, error) {
+	var files []*File
+	for f, err := range c.Files(ctx, dir) {
+		if err != nil {
+			return files, err
+		}
+		files = append(files, f)
+	}
+	return files, nil
+}
+
+// StreamFiles 返回遍历 opt.Type 类型的流式文件的迭代器，见 Files。
+// 从 opt.Start 开始，opt.Limit 不为空时作为每页的条目数。
+func (c *Client) StreamFiles(ctx context.Context, opt *ListStreamOptions) iter.Seq2[*File, error] {
+	o := *opt
+	start, size, err := streamPage(o.Start, o.Limit)
+	if err != nil {
+		return func(yield func(*File, error) bool) { yield(nil, err) }
+	}
+	return pages(ctx, start, size, func(start, size int) ([]*File, error) {
+		o.Start, o.Limit = strconv.Itoa(start), strconv.Itoa(size)
+		s, _, err := c.ListStream(ctx, &o)
+		if err != nil {
+			return nil, err
+		}
+		return s.List, nil
+	})
+}
+
+func streamPage(start, limit string) (int, int, error) {
+	from, size := 0, listPageSize
+	var err error
+	if start != "" {
+		if from, err = strconv.Atoi(start); err != nil || from < 0 {
+			return 0, 0, invalid("start", "%q is not a valid offset", start)
+		}
+	}
+	if limit != "" {
+		if size, err = strconv.Atoi(limit); err != nil || size <= 0 {
+			return 0, 0, invalid("limit", "%q is not a valid page size", limit)
+		}
+	}
+	return from, size, nil
+}
+
+// RecycleFiles 返回遍历回收站中的文件和目录的迭代器，见 Files。
+func (c *Client) RecycleFiles(ctx context.Context) iter.Seq2[*File, error] {
+	return pages(ctx, 0, listPageSize, func(start, size int) ([]*File, error) {
+		r, _, err := c.ListRecycle(ctx, &ListRecycleOptions{Start: start, Limit: size})
+		if err != nil {
+			return nil, err
+		}
+		return r.List, nil
+	})
+}
+
+// listTaskResponse 是 list_task 接口的响应
+type listTaskResponse struct {
+	TaskInfo []*OfflineTask `json:"task_info"`
+	Total    int            `json:"total"`
+}
+
+// OfflineTasks 返回遍历离线下载任务的迭代器，见 Files。opt 为 nil 时按创建时间从新到旧遍历全部任务，
+// 否则从 opt.Start 开始，opt.Limit 大于0时作为每页的任务数；NeedTaskInfo 总是为1。
+func (c *Client) OfflineTasks(ctx context.Context, opt *ListTaskOptions) iter.Seq2[*OfflineTask, error] {
+	var o ListTaskOptions
+	if opt != nil {
+		o = *opt
+	}
+	o.NeedTaskInfo = 1
+	size := taskPageSize
+	if o.Limit > 0 {
+		size = o.Limit
+	}
+	return pages(ctx, o.Start, size, func(start, size int) ([]*OfflineTask, error) {
+		o.Start, o.Limit = start, size
+		u, err := c.addOptions("../services/cloud_dl", "list_task", &o)
+		if err != nil {
+			return nil, err
+		}
+		r, _, err := postForm[listTaskResponse](idempotent(ctx), c, u, nil)
+		if err != nil {
+			return nil, err
+		}
+		return r.TaskInfo, nil
+	})
+}
+
+// Walk 返回以广度优先的顺序遍历远程目录 root 下全部文件和目录的迭代器，见 Files。
+// 每个目录按页列出，因此可以遍历子项超过 1000 的目录。
+func (c *Client) Walk(ctx context.Context, root string) iter.Seq2[*File, error] {
+	return func(yield func(*File, error) bool) {
+		dirs := []string{CleanPath(root)}
+		for len(dirs) > 0 {
+			dir := dirs[0]
+			dirs = dirs[1:]
+			for f, err := range c.Files(ctx, dir) {
+				if !yield(f, err) || err != nil {
+					return
+				}
+				if f.IsDirectory() {
+					dirs = append(dirs, f.Path)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package pcs_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/holys/baidu-pcs/v2"
+	"github.com/holys/baidu-pcs/v2/pcstest"
+)
+
+// 单页最多 1000 项，目录中多于一页的子项必须分页列出
+func TestWalkersPaginate(t *testing.T) {
+	const n = 1500
+	ctx := context.Background()
+	srv := pcstest.NewServer()
+	defer srv.Close()
+	c := srv.NewClient()
+	for i := 0; i < n; i++ {
+		srv.PutFile(fmt.Sprintf("/apps/t/big/f%04d.txt", i), []byte("x"))
+	}
+
+	count := 0
+	for _, err := range c.Files(ctx, "/apps/t/big") {
+		if err != nil {
+			t.Fatal(err)
+		}
+		count++
+	}
+	if count != n {
+		t.Errorf("Files: %d entries, want %d", count, n)
+	}
+
+	found, err := c.Find(ctx, "/apps/t", &pcs.Filter{Kind: pcs.FindFiles})
+	if err != nil || len(found) != n {
+		t.Errorf("Find: %d entries, %v; want %d", len(found), err, n)
+	}
+	matched, err := c.Glob(ctx, "/apps/t/*/f1*.txt")
+	if err != nil || len(matched) != 500 {
+		t.Errorf("Glob: %d entries, %v; want 500", len(matched), err)
+	}
+	usage, err := c.Usage(ctx, "/apps/t")
+	if err != nil || len(usage) == 0 || usage[0].Files != n {
+		t.Errorf("Usage = %+v, %v; want %d files", usage, err, n)
+	}
+	walked := 0
+	err = c.Dir("/apps/t").Walk(ctx, func(f *pcs.File) error {
+		walked++
+		return nil
+	})
+	if err != nil || walked != n+1 {
+		t.Errorf("DirHandle.Walk: %d entries, %v; want %d", walked, err, n+1)
+	}
+	files, err := c.Dir("/apps/t/big").List(ctx)
+	if err != nil || len(files) != n {
+		t.Errorf("DirHandle.List: %d entries, %v; want %d", len(files), err, n)
+	}
+}
+
+// failListing 使列出目录 dir 的请求失败
+type failListing struct {
+	dir string
+}
+
+var errListing = errors.New("listing failed")
+
+func (f failListing) RoundTrip(req *http.Request) (*http.Response, error) {
+	q := req.URL.Query()
+	if q.Get("method") == "list" && q.Get("path") == f.dir {
+		return nil, errListing
+	}
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestWalkersReturnPartialResults(t *testing.T) {
+	ctx := context.Background()
+	srv := pcstest.NewServer()
+	defer srv.Close()
+	srv.PutFile("/apps/t/a/1.txt", []byte("1"))
+	srv.PutFile("/apps/t/a/deep/2.txt", []byte("22"))
+	srv.PutFile("/apps/t/b/3.txt", []byte("333"))
+	// 广度优先遍历时 deep 最后列出
+	c := srv.NewClient(pcs.WithHTTPClient(&http.Client{Transport: failListing{"/apps/t/a/deep"}}))
+
+	usage, err := c.Usage(ctx, "/apps/t")
+	if !errors.Is(err, errListing) {
+		t.Fatalf("Usage: %v, want the listing error", err)
+	}
+	want := []pcs.FolderUsage{{Path: "/apps/t/b", Bytes: 3, Files: 1}, {Path: "/apps/t/a", Bytes: 1, Files: 1}, {Path: "/apps/t"}}
+	if fmt.Sprint(usage) != fmt.Sprint(want) {
+		t.Errorf("Usage returned %+v, want %+v", usage, want)
+	}
+
+	var buf bytes.Buffer
+	n, err := c.ExportListing(ctx, "/apps/t", &buf, pcs.FormatJSON)
+	if !errors.Is(err, errListing) {
+		t.Fatalf("ExportListing: %v, want the listing error", err)
+	}
+	var entries []pcs.ListingEntry
+	if err := json.Unmarshal(buf.Bytes(), &entries); err != nil || len(entries) != n || n != 5 {
+		t.Errorf("ExportListing wrote %d of %d entries: %v", len(entries), n, err)
+	}
+	for _, e := range entries {
+		if strings.HasPrefix(e.Path, "/apps/t/a/deep/") {
+			t.Errorf("listed %s of the failing directory", e.Path)
+		}
+	}
+}
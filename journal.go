@@ -0,0 +1,303 @@
+package pcs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// 日志中保留的最大记录数，超出时丢弃最早的记录
+const maxJournalEntries = 1000
+
+var (
+	ErrNothingToUndo = errors.New("baidu-pcs: no operation to undo")
+	ErrUndoConflict  = errors.New("baidu-pcs: files changed since the operation")
+)
+
+// JournalOp 表示日志记录的操作类型
+type JournalOp string
+
+const (
+	JournalMove   JournalOp = "move" // 包括重命名
+	JournalCopy   JournalOp = "copy"
+	JournalDelete JournalOp = "delete"
+)
+
+// JournalEntry 是一次移动、复制或删除操作的记录，包含撤销所需的信息。
+type JournalEntry struct {
+	Op    JournalOp `json:"op"`
+	Time  time.Time `json:"time"`
+	Pairs []*FTPair `json:"pairs,omitempty"` // 移动和复制：实际的源路径和目标路径
+	Paths []string  `json:"paths,omitempty"` // 删除：被删除的路径
+	// 删除：被删除的文件或目录的 fs_id，用于从回收站还原。
+	// 移动和复制：操作后各目标的 fs_id，撤销前据此确认目标没有被替换；未能获取时为空。
+	FsIds  []uint64 `json:"fs_ids,omitempty"`
+	Undone bool     `json:"undone,omitempty"`
+}
+
+// Journal 通过 Client 执行移动、复制和删除，并把每次操作记录在本地文件中，
+// 以便用 Undo 撤销最近的操作：将移动的文件移回原处，删除复制出的文件，或从回收站还原删除的文件和目录。
+// 可被多个 goroutine 并发使用。
+type Journal struct {
+	client *Client
+	path   string
+
+	undo    sync.Mutex // 串行执行 Undo，避免同一操作被撤销两次
+	mu      sync.Mutex
+	entries []*JournalEntry
+}
+
+// NewJournal 创建使用 c 执行操作的 Journal，并从 path 加载已有的记录。
+func NewJournal(c *Client, path string) (*Journal, error) {
+	j := &Journal{client: c, path: path}
+
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &j.entries); err != nil {
+			return nil, err
+		}
+	}
+	return j, nil
+}
+
+// Entries 返回全部记录，最近的操作在最后。
+func (j *Journal) Entries() []JournalEntry {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	entries := make([]JournalEntry, len(j.entries))
+	for i, e := range j.entries {
+		entries[i] = *e
+	}
+	return entries
+}
+
+// Move 移动（或重命名）单个文件或目录，并记录该操作。
+func (j *Journal) Move(ctx context.Context, from, to string) (*MoveCopyResponse, error) {
+	return j.BatchMove(ctx, []*FTPair{{From: from, To: to}})
+}
+
+// BatchMove 批量移动文件或目录，并作为一次操作记录。
+func (j *Journal) BatchMove(ctx context.Context, pairs []*FTPair) (*MoveCopyResponse, error) {
+	return j.moveCopy(ctx, JournalMove, pairs)
+}
+
+// Copy 复制单个文件或目录，并记录该操作。
+func (j *Journal) Copy(ctx context.Context, from, to string) (*MoveCopyResponse, error) {
+	return j.BatchCopy(ctx, []*FTPair{{From: from, To: to}})
+}
+
+// BatchCopy 批量复制文件或目录，并作为一次操作记录。
+func (j *Journal) BatchCopy(ctx context.Context, pairs []*FTPair) (*MoveCopyResponse, error) {
+	return j.moveCopy(ctx, JournalCopy, pairs)
+}
+
+func (j *Journal) moveCopy(ctx context.Context, op JournalOp, pairs []*FTPair) (*MoveCopyResponse, error) {
+	var (
+		res *MoveCopyResponse
+		err error
+	)
+	switch {
+	case op == JournalMove && len(pairs) == 1:
+		res, _, err = j.client.Move(ctx, pairs[0].From, pairs[0].To)
+	case op == JournalMove:
+		res, _, err = j.client.BatchMove(ctx, pairs)
+	case len(pairs) == 1:
+		res, _, err = j.client.Copy(ctx, pairs[0].From, pairs[0].To)
+	default:
+		res, _, err = j.client.BatchCopy(ctx, pairs)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// 优先记录服务端返回的实际路径
+	done := make([]*FTPair, 0, len(pairs))
+	for _, p := range res.Extra.List {
+		done = append(done, &FTPair{From: p.From, To: p.To})
+	}
+	if len(done) == 0 {
+		for _, p := range pairs {
+			done = append(done, &FTPair{From: CleanPath(p.From), To: CleanPath(p.To)})
+		}
+	}
+
+	// 操作已经完成，无法获取目标的 fs_id 时照常记录，撤销时不再检查
+	targets := make([]string, len(done))
+	for i, p := range done {
+		targets[i] = p.To
+	}
+	var fsIds []uint64
+	if metas, _, err := j.client.BatchGetMeta(ctx, targets); err == nil && len(metas) == len(targets) {
+		for _, m := range metas {
+			if m.File == nil {
+				fsIds = nil
+				break
+			}
+			fsIds = append(fsIds, m.FsId)
+		}
+	}
+	return res, j.record(&JournalEntry{Op: op, Time: time.Now(), Pairs: done, FsIds: fsIds})
+}
+
+// Delete 删除单个文件或目录，并记录该操作。
+func (j *Journal) Delete(ctx context.Context, path string) error {
+	return j.BatchDelete(ctx, []string{path})
+}
+
+// BatchDelete 批量删除文件或目录，并作为一次操作记录。
+// 删除前先获取各路径的 fs_id，撤销时据此从回收站还原。
+func (j *Journal) BatchDelete(ctx context.Context, paths []string) error {
+	metas, _, err := j.client.BatchGetMeta(ctx, paths)
+	if err != nil {
+		return err
+	}
+	fsIds := make([]uint64, 0, len(metas))
+	for _, m := range metas {
+		if m.File != nil {
+			fsIds = append(fsIds, m.FsId)
+		}
+	}
+
+	if len(paths) == 1 {
+		_, err = j.client.Delete(ctx, paths[0])
+	} else {
+		_, err = j.client.BatchDelete(ctx, paths)
+	}
+	if err != nil {
+		return err
+	}
+	return j.record(&JournalEntry{Op: JournalDelete, Time: time.Now(), Paths: cleanPaths(paths), FsIds: fsIds})
+}
+
+// Undo 撤销最近一次尚未撤销的操作，返回被撤销的记录。
+// 删除的文件只能在其仍在回收站中时还原。撤销前先确认操作的结果没有被改动：移动或复制的目标
+// 仍是当时的文件（按 fs_id 比较），删除的路径没有被新的文件占用；否则不做任何修改，
+// 返回满足 errors.Is(err, ErrUndoConflict) 的错误，记录保持未撤销。
+func (j *Journal) Undo(ctx context.Context) (*JournalEntry, error) {
+	j.undo.Lock()
+	defer j.undo.Unlock()
+
+	j.mu.Lock()
+	var e *JournalEntry
+	for i := len(j.entries) - 1; i >= 0; i-- {
+		if !j.entries[i].Undone {
+			e = j.entries[i]
+			break
+		}
+	}
+	j.mu.Unlock()
+	if e == nil {
+		return nil, ErrNothingToUndo
+	}
+
+	if err := j.check(ctx, e); err != nil {
+		return nil, err
+	}
+
+	var err error
+	switch e.Op {
+	case JournalCopy:
+		targets := make([]string, len(e.Pairs))
+		for i, p := range e.Pairs {
+			targets[i] = p.To
+		}
+		if len(targets) == 1 {
+			_, err = j.client.Delete(ctx, targets[0])
+		} else {
+			_, err = j.client.BatchDelete(ctx, targets)
+		}
+	case JournalMove:
+		reversed := make([]*FTPair, len(e.Pairs))
+		for i, p := range e.Pairs {
+			reversed[i] = &FTPair{From: p.To, To: p.From}
+		}
+		if len(reversed) == 1 {
+			_, _, err = j.client.Move(ctx, reversed[0].From, reversed[0].To)
+		} else {
+			_, _, err = j.client.BatchMove(ctx, reversed)
+		}
+	case JournalDelete:
+		ids := make([]string, len(e.FsIds))
+		for i, id := range e.FsIds {
+			ids[i] = strconv.FormatUint(id, 10)
+		}
+		if len(ids) == 1 {
+			_, _, err = j.client.Restore(ctx, ids[0])
+		} else {
+			_, _, err = j.client.BatchRestore(ctx, ids)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	e.Undone = true
+	undone := *e
+	return &undone, j.save()
+}
+
+// check 确认撤销 e 不会覆盖或删除操作之后的改动，见 Undo
+func (j *Journal) check(ctx context.Context, e *JournalEntry) error {
+	if e.Op == JournalDelete {
+		for _, p := range e.Paths {
+			_, _, err := j.client.GetMeta(ctx, p)
+			if err == nil {
+				return fmt.Errorf("%w: %s exists again", ErrUndoConflict, p)
+			}
+			if !isNotExist(err) {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if len(e.FsIds) != len(e.Pairs) {
+		return nil
+	}
+	for i, p := range e.Pairs {
+		meta, _, err := j.client.GetMeta(ctx, p.To)
+		if isNotExist(err) {
+			return fmt.Errorf("%w: %s no longer exists", ErrUndoConflict, p.To)
+		}
+		if err != nil {
+			return err
+		}
+		if meta.File == nil || meta.FsId != e.FsIds[i] {
+			return fmt.Errorf("%w: %s was replaced", ErrUndoConflict, p.To)
+		}
+	}
+	return nil
+}
+
+func (j *Journal) record(e *JournalEntry) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.entries = append(j.entries, e)
+	if n := len(j.entries) - maxJournalEntries; n > 0 {
+		j.entries = append([]*JournalEntry(nil), j.entries[n:]...)
+	}
+	return j.save()
+}
+
+// save 将记录写入文件，调用时需持有 j.mu。
+func (j *Journal) save() error {
+	data, err := json.MarshalIndent(j.entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := j.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, j.path)
+}
@@ -0,0 +1,126 @@
+package pcs_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/holys/baidu-pcs/v2"
+	"github.com/holys/baidu-pcs/v2/pcstest"
+)
+
+func newJournal(t *testing.T, c *pcs.Client) *pcs.Journal {
+	t.Helper()
+	j, err := pcs.NewJournal(c, filepath.Join(t.TempDir(), "journal.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return j
+}
+
+func TestJournalUndo(t *testing.T) {
+	ctx := context.Background()
+	srv := pcstest.NewServer()
+	defer srv.Close()
+	c := srv.NewClient()
+	srv.PutFile("/apps/t/a.txt", []byte("a"))
+	srv.PutFile("/apps/t/d/b.txt", []byte("b"))
+	state := filepath.Join(t.TempDir(), "journal.json")
+	j, err := pcs.NewJournal(c, state)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := j.Move(ctx, "/apps/t/a.txt", "/apps/t/moved.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := j.Copy(ctx, "/apps/t/d", "/apps/t/copy"); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.Delete(ctx, "/apps/t/d"); err != nil {
+		t.Fatal(err)
+	}
+
+	// 记录保存在文件中，重新打开后从最近的操作开始撤销
+	j, err = pcs.NewJournal(c, state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		op      pcs.JournalOp
+		present []string
+		absent  []string
+	}{
+		{pcs.JournalDelete, []string{"/apps/t/d/b.txt", "/apps/t/copy/b.txt"}, nil},
+		{pcs.JournalCopy, []string{"/apps/t/d/b.txt"}, []string{"/apps/t/copy"}},
+		{pcs.JournalMove, []string{"/apps/t/a.txt"}, []string{"/apps/t/moved.txt"}},
+	} {
+		e, err := j.Undo(ctx)
+		if err != nil || e.Op != tt.op || !e.Undone {
+			t.Fatalf("Undo = %+v, %v; want the %s undone", e, err, tt.op)
+		}
+		for _, p := range tt.present {
+			if !srv.Exists(p) {
+				t.Errorf("after undoing the %s, %s is missing", tt.op, p)
+			}
+		}
+		for _, p := range tt.absent {
+			if srv.Exists(p) {
+				t.Errorf("after undoing the %s, %s still exists", tt.op, p)
+			}
+		}
+	}
+	if _, err := j.Undo(ctx); !errors.Is(err, pcs.ErrNothingToUndo) {
+		t.Errorf("Undo with every operation undone: %v", err)
+	}
+}
+
+func TestJournalUndoRefusesChangedTargets(t *testing.T) {
+	ctx := context.Background()
+	for _, tt := range []struct {
+		name   string
+		op     func(j *pcs.Journal) error
+		change func(srv *pcstest.Server)
+		check  string // 撤销被拒绝后仍应保持的文件及内容
+		data   string
+	}{
+		{
+			"move target replaced",
+			func(j *pcs.Journal) error { _, err := j.Move(ctx, "/apps/t/a.txt", "/apps/t/b.txt"); return err },
+			func(srv *pcstest.Server) { srv.PutFile("/apps/t/b.txt", []byte("new")) },
+			"/apps/t/b.txt", "new",
+		},
+		{
+			"copy modified",
+			func(j *pcs.Journal) error { _, err := j.Copy(ctx, "/apps/t/a.txt", "/apps/t/b.txt"); return err },
+			func(srv *pcstest.Server) { srv.PutFile("/apps/t/b.txt", []byte("edited")) },
+			"/apps/t/b.txt", "edited",
+		},
+		{
+			"deleted path taken",
+			func(j *pcs.Journal) error { return j.Delete(ctx, "/apps/t/a.txt") },
+			func(srv *pcstest.Server) { srv.PutFile("/apps/t/a.txt", []byte("other")) },
+			"/apps/t/a.txt", "other",
+		},
+	} {
+		srv := pcstest.NewServer()
+		srv.PutFile("/apps/t/a.txt", []byte("a"))
+		j := newJournal(t, srv.NewClient())
+		if err := tt.op(j); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		tt.change(srv)
+
+		if _, err := j.Undo(ctx); !errors.Is(err, pcs.ErrUndoConflict) {
+			t.Errorf("%s: Undo = %v, want ErrUndoConflict", tt.name, err)
+		}
+		if data, ok := srv.ReadFile(tt.check); !ok || string(data) != tt.data {
+			t.Errorf("%s: %s = %q, %v after the refused Undo", tt.name, tt.check, data, ok)
+		}
+		if e := j.Entries(); len(e) != 1 || e[0].Undone {
+			t.Errorf("%s: entries %+v", tt.name, e)
+		}
+		srv.Close()
+	}
+}
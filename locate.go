@@ -0,0 +1,199 @@
+package pcs
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const probeTimeout = 5 * time.Second
+
+type UploadServer struct {
+	Server string `json:"server"` // 形如 https://c3.pcs.baidu.com
+}
+
+type UploadServers struct {
+	Host       string          `json:"host"`        // 默认上传域名
+	Servers    []*UploadServer `json:"servers"`     // 推荐的上传服务器
+	BakServers []*UploadServer `json:"bak_servers"` // 备用上传服务器
+	ClientIP   string          `json:"client_ip"`
+	Expire     int             `json:"expire"` // 结果的有效期（秒）
+}
+
+// 获取当前网络环境下推荐的上传服务器列表
+func (c *Client) LocateUpload(ctx context.Context) (*UploadServers, *http.Response, error) {
+	opt := struct {
+		UploadVersion string `url:"upload_version"`
+	}{"2.0"}
+
+	u, err := c.addOptions("file", "locateupload", &opt)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return get[UploadServers](ctx, c, u)
+}
+
+// 调用 locateupload 并探测各个候选服务器的响应时间，将本次会话的上传地址
+// 切换为响应最快的服务器，返回选中的地址。
+// 所有候选服务器均不可达时保持原有的上传地址不变，并返回 ErrNoUploadServer。
+func (c *Client) SelectUploadServer(ctx context.Context) (*url.URL, error) {
+	servers, _, err := c.LocateUpload(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.RLock()
+	current := *c.UploadURL
+	c.mu.RUnlock()
+
+	var candidates []*url.URL
+	for _, s := range append(servers.Servers, servers.BakServers...) {
+		u, err := url.Parse(strings.TrimRight(s.Server, "/"))
+		if err != nil || u.Host == "" {
+			continue
+		}
+		if u.Scheme == "" {
+			u.Scheme = current.Scheme
+		}
+		u.Path = current.Path
+		candidates = append(candidates, u)
+	}
+
+	best := c.fastestHost(ctx, candidates)
+	if best == nil {
+		return nil, ErrNoUploadServer
+	}
+	c.SetUploadURL(best)
+	return best, nil
+}
+
+// fastestHost 并发探测 candidates，返回最先响应的一个；都不可达时返回 nil。
+func (c *Client) fastestHost(ctx context.Context, candidates []*url.URL) *url.URL {
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+
+	results := make(chan *url.URL, len(candidates))
+	for _, u := range candidates {
+		go func(u *url.URL) {
+			req, err := http.NewRequest("HEAD", u.String(), nil)
+			if err != nil {
+				results <- nil
+				return
+			}
+			resp, err := c.client.Do(req.WithContext(ctx))
+			if err != nil {
+				results <- nil
+				return
+			}
+			resp.Body.Close()
+			results <- u
+		}(u)
+	}
+
+	for range candidates {
+		if u := <-results; u != nil {
+			return u
+		}
+	}
+	return nil
+}
+
+type DownloadLocation struct {
+	URL string `json:"url"`
+}
+
+type DownloadLocations struct {
+	URLs     []*DownloadLocation `json:"urls"` // 候选下载地址，已签名，可直接请求
+	ClientIP string              `json:"client_ip"`
+	Expire   int                 `json:"expire"` // 结果的有效期（秒）
+}
+
+// 获取文件的候选下载地址（CDN 节点）列表
+// path: 下载文件路径，以/开头的绝对路径。
+func (c *Client) LocateDownload(ctx context.Context, path string) (*DownloadLocations, *http.Response, error) {
+	opt := struct {
+		Path string `url:"path"`
+		Ver  string `url:"ver"`
+	}{path, "2.0"}
+
+	u, err := c.addOptions("file", "locatedownload", &opt)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	req, err := c.NewDownloadRequest("GET", u, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return do[DownloadLocations](ctx, c, req)
+}
+
+// DownloadMirrors 是同一文件的一组候选下载地址，Next 以轮询方式返回，
+// 使分段下载的各个分段分散到不同的节点上。可被多个 goroutine 并发使用。
+type DownloadMirrors struct {
+	urls []string
+	next int
+	mu   sync.Mutex
+}
+
+// NewDownloadMirrors 以 urls 创建 DownloadMirrors，首选地址排在最前。
+func NewDownloadMirrors(urls ...string) *DownloadMirrors {
+	return &DownloadMirrors{urls: urls}
+}
+
+// URLs 返回全部候选地址。
+func (m *DownloadMirrors) URLs() []string {
+	return append([]string(nil), m.urls...)
+}
+
+// Next 返回下一个候选地址；没有候选地址时返回空字符串。
+func (m *DownloadMirrors) Next() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.urls) == 0 {
+		return ""
+	}
+	u := m.urls[m.next%len(m.urls)]
+	m.next++
+	return u
+}
+
+// 调用 locatedownload 获取 path 的候选下载地址，探测后将响应最快的地址排在首位。
+// 分段下载时可通过 Next 在各节点间轮询。
+func (c *Client) LocateDownloadMirrors(ctx context.Context, path string) (*DownloadMirrors, error) {
+	locs, _, err := c.LocateDownload(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	var candidates []*url.URL
+	for _, l := range locs.URLs {
+		if u, err := url.Parse(l.URL); err == nil && u.Host != "" {
+			candidates = append(candidates, u)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, ErrNoDownloadServer
+	}
+
+	urls := make([]string, 0, len(candidates))
+	best := c.fastestHost(ctx, candidates)
+	if best != nil {
+		urls = append(urls, best.String())
+	}
+	for _, u := range candidates {
+		if u != best {
+			urls = append(urls, u.String())
+		}
+	}
+	return NewDownloadMirrors(urls...), nil
+}
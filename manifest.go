@@ -0,0 +1,169 @@
+package pcs
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+var manifestHeader = []string{"path", "size", "md5", "slice_md5", "crc32"}
+
+// ManifestEntry 是校验清单中一个文件的记录，各项值与 SumFile 相同。
+type ManifestEntry struct {
+	Path     string `json:"path"` // 相对于目录根的路径，以 / 分隔
+	Size     int64  `json:"size"`
+	Md5      string `json:"md5"`
+	SliceMd5 string `json:"slice_md5"` // 前 256KB 的 MD5
+	Crc32    uint32 `json:"crc32"`
+}
+
+// RapidUploadOptions 返回以 e 的校验值把文件秒传到 path 的参数。
+func (e ManifestEntry) RapidUploadOptions(path string, ondup OnDup) *RapiduUploadOptions {
+	return &RapiduUploadOptions{
+		Path:          path,
+		ContentLength: int(e.Size),
+		ContentMd5:    e.Md5,
+		SliceMd5:      e.SliceMd5,
+		ContentCrc32:  strconv.FormatUint(uint64(e.Crc32), 10),
+		Ondup:         ondup,
+	}
+}
+
+// WalkManifest 按路径的字典序遍历 root 下的全部普通文件，每个文件只读取一次，
+// 计算出校验值后调用 fn。符号链接等非普通文件被跳过。fn 返回错误时停止遍历并返回该错误。
+func WalkManifest(ctx context.Context, root string, fn func(ManifestEntry) error) error {
+	return filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		e, err := sumManifestEntry(p)
+		if err != nil {
+			return err
+		}
+		e.Path = filepath.ToSlash(rel)
+		return fn(e)
+	})
+}
+
+func sumManifestEntry(p string) (ManifestEntry, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return ManifestEntry{}, err
+	}
+	defer f.Close()
+
+	n, contentMd5, sliceMd5, crc, err := sumReader(f)
+	if err != nil {
+		return ManifestEntry{}, err
+	}
+	return ManifestEntry{Size: n, Md5: contentMd5, SliceMd5: sliceMd5, Crc32: crc}, nil
+}
+
+// BuildManifest 生成 root 下全部普通文件的校验清单，见 WalkManifest。
+func BuildManifest(ctx context.Context, root string) ([]ManifestEntry, error) {
+	var entries []ManifestEntry
+	err := WalkManifest(ctx, root, func(e ManifestEntry) error {
+		entries = append(entries, e)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// WriteManifest 以 format 格式将校验清单写入 w。
+func WriteManifest(w io.Writer, entries []ManifestEntry, format ExportFormat) error {
+	switch format {
+	case FormatJSON:
+		if entries == nil {
+			entries = []ManifestEntry{}
+		}
+		return writeJSON(w, entries)
+	case FormatCSV:
+		rows := make([][]string, len(entries))
+		for i, e := range entries {
+			rows[i] = []string{
+				e.Path,
+				strconv.FormatInt(e.Size, 10),
+				e.Md5,
+				e.SliceMd5,
+				strconv.FormatUint(uint64(e.Crc32), 10),
+			}
+		}
+		return writeCSV(w, manifestHeader, rows)
+	case FormatNDJSON:
+		return writeNDJSON(w, entries)
+	}
+	return invalid("format", "unknown format %d", format)
+}
+
+// ReadManifest 读取 WriteManifest 以 format 格式写入的校验清单。
+func ReadManifest(r io.Reader, format ExportFormat) ([]ManifestEntry, error) {
+	switch format {
+	case FormatJSON:
+		var entries []ManifestEntry
+		if err := json.NewDecoder(r).Decode(&entries); err != nil {
+			return nil, err
+		}
+		return entries, nil
+	case FormatNDJSON:
+		var entries []ManifestEntry
+		dec := json.NewDecoder(r)
+		for {
+			var e ManifestEntry
+			if err := dec.Decode(&e); err == io.EOF {
+				return entries, nil
+			} else if err != nil {
+				return nil, err
+			}
+			entries = append(entries, e)
+		}
+	case FormatCSV:
+		cr := csv.NewReader(r)
+		cr.FieldsPerRecord = len(manifestHeader)
+		records, err := cr.ReadAll()
+		if err != nil {
+			return nil, err
+		}
+		if len(records) == 0 {
+			return nil, fmt.Errorf("baidu-pcs: manifest has no header")
+		}
+		entries := make([]ManifestEntry, 0, len(records)-1)
+		for i, rec := range records[1:] {
+			size, err := strconv.ParseInt(rec[1], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("baidu-pcs: manifest line %d: %w", i+2, err)
+			}
+			crc, err := strconv.ParseUint(rec[4], 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("baidu-pcs: manifest line %d: %w", i+2, err)
+			}
+			entries = append(entries, ManifestEntry{
+				Path:     rec[0],
+				Size:     size,
+				Md5:      rec[2],
+				SliceMd5: rec[3],
+				Crc32:    uint32(crc),
+			})
+		}
+		return entries, nil
+	}
+	return nil, invalid("format", "unknown format %d", format)
+}
@@ -0,0 +1,72 @@
+package pcs
+
+import (
+	"context"
+	"io"
+	"sync"
+)
+
+// memoryBudget 限制并发上传时在内存中拼装的请求体所占的总字节数，见 WithMemoryLimit。
+// 预留超出预算时等待其他请求体释放；单个超过预算的请求体视为占用全部预算，
+// 因此仍可在没有其他请求体时单独执行。nil 表示不限。
+type memoryBudget struct {
+	mu    sync.Mutex
+	limit int64
+	used  int64
+	freed chan struct{} // 有内存释放时关闭并替换
+}
+
+func newMemoryBudget(limit int64) *memoryBudget {
+	return &memoryBudget{limit: limit, freed: make(chan struct{})}
+}
+
+// acquire 预留 n 字节，返回实际预留的字节数，用完后须以该值调用 release。
+func (b *memoryBudget) acquire(ctx context.Context, n int64) (int64, error) {
+	if b == nil {
+		return 0, nil
+	}
+	n = min(n, b.limit)
+	for {
+		b.mu.Lock()
+		if b.used+n <= b.limit {
+			b.used += n
+			b.mu.Unlock()
+			return n, nil
+		}
+		freed := b.freed
+		b.mu.Unlock()
+
+		select {
+		case <-freed:
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		}
+	}
+}
+
+func (b *memoryBudget) release(n int64) {
+	if b == nil || n == 0 {
+		return
+	}
+	b.mu.Lock()
+	b.used -= n
+	close(b.freed)
+	b.freed = make(chan struct{})
+	b.mu.Unlock()
+}
+
+// bufferedBody 与 multipartBody 相同，但先从 Client 的内存预算中预留 size 字节，
+// 在请求体 Close 时归还。
+func (c *Client) bufferedBody(ctx context.Context, name string, r io.Reader, size int64) (*pooledBody, string, error) {
+	reserved, err := c.memory.acquire(ctx, size)
+	if err != nil {
+		return nil, "", err
+	}
+	body, contentType, err := multipartBody(name, r, size)
+	if err != nil {
+		c.memory.release(reserved)
+		return nil, "", err
+	}
+	body.release = func() { c.memory.release(reserved) }
+	return body, contentType, nil
+}
@@ -0,0 +1,287 @@
+package pcs
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/json"
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	ErrInvalidName = errors.New("baidu-pcs: name was not encrypted with this key")
+
+	nameEncoding = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+
+	// 响应中表示远程路径的 json 字段，启用文件名加密时解密后返回
+	pathFields = map[string]bool{"path": true, "from": true, "to": true}
+)
+
+const nameIVSize = 16
+
+// NameCipher 对远程路径中位于 root 之下的各级文件名和目录名做确定性加密，
+// 使远端的列表不暴露文件名和目录结构。同一名称总是得到同一密文，因此无需额外
+// 的映射也能按路径访问文件；加密方式类似 SIV：以名称的 HMAC 作为 AES-CTR 的 IV，
+// 解密时校验，密钥不符或不是加密名称时返回 ErrInvalidName。
+//
+// 通过 WithNameEncryption 设置到 Client 后，请求中的路径自动加密，响应中的路径
+// 自动解密。由于名称已加密，Search 无法按文件名检索；加密后的名称长度约为原长度加16
+// 字节后的1.6倍，应注意服务端对文件名长度的限制。可被多个 goroutine 并发使用。
+type NameCipher struct {
+	root     string
+	block    cipher.Block
+	macKey   []byte
+	manifest atomic.Pointer[NameManifest]
+}
+
+// NewNameCipher 以 key 创建 NameCipher，root 及其上级目录（例如 /apps/应用名）保持明文。
+func NewNameCipher(key []byte, root string) (*NameCipher, error) {
+	if len(key) == 0 {
+		return nil, ErrInvalidArgument
+	}
+	block, err := aes.NewCipher(deriveKey(key, "baidu-pcs name encryption"))
+	if err != nil {
+		return nil, err
+	}
+	return &NameCipher{
+		root:   CleanPath(root),
+		block:  block,
+		macKey: deriveKey(key, "baidu-pcs name authentication"),
+	}, nil
+}
+
+func deriveKey(key []byte, purpose string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(purpose))
+	return mac.Sum(nil)
+}
+
+// SetManifest 使此后经 NameCipher 加密或解密的路径都记录到 m 中，m 为 nil 时停止记录。
+// 映射表随访问过的路径增长，因此默认不记录。
+func (nc *NameCipher) SetManifest(m *NameManifest) {
+	nc.manifest.Store(m)
+}
+
+// Manifest 返回 SetManifest 设置的映射表，没有设置时返回 nil。
+func (nc *NameCipher) Manifest() *NameManifest {
+	return nc.manifest.Load()
+}
+
+func (nc *NameCipher) record(remote, plain string) {
+	if m := nc.manifest.Load(); m != nil {
+		m.add(remote, plain)
+	}
+}
+
+// EncryptName 加密单个文件名或目录名。
+func (nc *NameCipher) EncryptName(name string) string {
+	mac := hmac.New(sha256.New, nc.macKey)
+	mac.Write([]byte(name))
+	iv := mac.Sum(nil)[:nameIVSize]
+
+	out := make([]byte, nameIVSize+len(name))
+	copy(out, iv)
+	cipher.NewCTR(nc.block, iv).XORKeyStream(out[nameIVSize:], []byte(name))
+	return nameEncoding.EncodeToString(out)
+}
+
+// DecryptName 解密 EncryptName 得到的名称。
+func (nc *NameCipher) DecryptName(name string) (string, error) {
+	data, err := nameEncoding.DecodeString(name)
+	if err != nil || len(data) < nameIVSize {
+		return "", ErrInvalidName
+	}
+	iv := data[:nameIVSize]
+	plain := make([]byte, len(data)-nameIVSize)
+	cipher.NewCTR(nc.block, iv).XORKeyStream(plain, data[nameIVSize:])
+
+	mac := hmac.New(sha256.New, nc.macKey)
+	mac.Write(plain)
+	if !hmac.Equal(mac.Sum(nil)[:nameIVSize], iv) {
+		return "", ErrInvalidName
+	}
+	return string(plain), nil
+}
+
+// EncryptPath 加密 p 中位于 root 之下的各级名称。
+func (nc *NameCipher) EncryptPath(p string) string {
+	p = CleanPath(p)
+	rel, ok := nc.relative(p)
+	if !ok {
+		return p
+	}
+	parts := strings.Split(rel, "/")
+	for i, name := range parts {
+		parts[i] = nc.EncryptName(name)
+	}
+	enc := joinPath(nc.root, parts)
+	nc.record(enc, p)
+	return enc
+}
+
+// DecryptPath 解密 p 中位于 root 之下的各级名称，无法解密的名称（例如加密前
+// 已存在的文件）保持原样。
+func (nc *NameCipher) DecryptPath(p string) string {
+	rel, ok := nc.relative(p)
+	if !ok {
+		return p
+	}
+	parts := strings.Split(rel, "/")
+	for i, name := range parts {
+		if plain, err := nc.DecryptName(name); err == nil {
+			parts[i] = plain
+		}
+	}
+	plain := joinPath(nc.root, parts)
+	nc.record(p, plain)
+	return plain
+}
+
+// relative 返回 p 相对于 root 的部分；p 不在 root 之下时返回 false。
+func (nc *NameCipher) relative(p string) (string, bool) {
+	prefix := nc.root
+	if prefix != "/" {
+		prefix += "/"
+	}
+	if !strings.HasPrefix(p, prefix) || len(p) == len(prefix) {
+		return "", false
+	}
+	return p[len(prefix):], true
+}
+
+func joinPath(root string, parts []string) string {
+	if root == "/" {
+		return "/" + strings.Join(parts, "/")
+	}
+	return root + "/" + strings.Join(parts, "/")
+}
+
+// remotePath 规范化 p，启用文件名加密时返回加密后的路径。
+func (c *Client) remotePath(p string) string {
+	if c.names != nil {
+		return c.names.EncryptPath(p)
+	}
+	return CleanPath(p)
+}
+
+func (c *Client) remotePaths(paths []string) []string {
+	out := make([]string, len(paths))
+	for i, p := range paths {
+		out[i] = c.remotePath(p)
+	}
+	return out
+}
+
+// decryptPathFields 解密 v 中 json 字段名为 path、from、to 的字符串，
+// 用于把响应中的远程路径还原为明文。
+func (nc *NameCipher) decryptPathFields(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if !v.IsNil() {
+			nc.decryptPathFields(v.Elem())
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			nc.decryptPathFields(v.Index(i))
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := v.Field(i)
+			if !f.CanSet() {
+				continue
+			}
+			name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+			if f.Kind() == reflect.String && pathFields[name] {
+				f.SetString(nc.DecryptPath(f.String()))
+				continue
+			}
+			nc.decryptPathFields(f)
+		}
+	}
+}
+
+// NameManifest 是远程（加密）路径与本地明文路径的映射表，可以保存到本地文件，
+// 在没有密钥的环境中查阅，或用于核对远端内容。可被多个 goroutine 并发使用。
+type NameManifest struct {
+	mu     sync.Mutex
+	remote map[string]string // 加密路径 -> 明文路径
+	plain  map[string]string // 明文路径 -> 加密路径
+}
+
+func NewNameManifest() *NameManifest {
+	return &NameManifest{remote: make(map[string]string), plain: make(map[string]string)}
+}
+
+func (m *NameManifest) add(remote, plain string) {
+	if remote == plain {
+		return
+	}
+	m.mu.Lock()
+	m.addLocked(remote, plain)
+	m.mu.Unlock()
+}
+
+func (m *NameManifest) addLocked(remote, plain string) {
+	if old, ok := m.remote[remote]; ok && old != plain {
+		delete(m.plain, old)
+	}
+	m.remote[remote] = plain
+	m.plain[plain] = remote
+}
+
+// Plain 返回加密路径 remote 对应的明文路径。
+func (m *NameManifest) Plain(remote string) (string, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	p, ok := m.remote[remote]
+	return p, ok
+}
+
+// Remote 返回明文路径 plain 对应的加密路径。
+func (m *NameManifest) Remote(plain string) (string, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r, ok := m.plain[plain]
+	return r, ok
+}
+
+// Save 将映射表以 JSON 格式写入 path。
+func (m *NameManifest) Save(path string) error {
+	m.mu.Lock()
+	data, err := json.MarshalIndent(m.remote, "", "  ")
+	m.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Load 从 path 读取之前保存的映射表，并合并到 m 中。
+func (m *NameManifest) Load(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var entries map[string]string
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for r, p := range entries {
+		m.addLocked(r, p)
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package pcs_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/holys/baidu-pcs/v2"
+	"github.com/holys/baidu-pcs/v2/pcstest"
+)
+
+func TestNameEncryption(t *testing.T) {
+	ctx := context.Background()
+	srv := pcstest.NewServer()
+	defer srv.Close()
+	nc, err := pcs.NewNameCipher([]byte("secret"), "/apps/x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := srv.NewClient(pcs.WithNameEncryption(nc))
+
+	local := filepath.Join(t.TempDir(), "a")
+	if err := os.WriteFile(local, []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	f, _, err := c.Upload(ctx, local, &pcs.FileOptions{Path: "/apps/x/docs/报告.txt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Path != "/apps/x/docs/报告.txt" {
+		t.Errorf("Upload returned %s, want the plaintext path", f.Path)
+	}
+	enc := nc.EncryptPath("/apps/x/docs/报告.txt")
+	if !srv.Exists(enc) || strings.Contains(enc, "docs") {
+		t.Errorf("stored as %s", enc)
+	}
+	list, _, err := c.ListFiles(ctx, &pcs.ListFilesOptions{Path: "/apps/x/docs"})
+	if err != nil || len(list) != 1 || list[0].Path != "/apps/x/docs/报告.txt" {
+		t.Errorf("ListFiles = %v, %v", list, err)
+	}
+
+	other, _ := pcs.NewNameCipher([]byte("other"), "/apps/x")
+	if _, err := other.DecryptName(strings.Split(enc, "/")[3]); !errors.Is(err, pcs.ErrInvalidName) {
+		t.Errorf("DecryptName with another key: %v", err)
+	}
+	if nc.Manifest() != nil {
+		t.Error("the manifest is recorded without SetManifest")
+	}
+}
+
+func TestNameManifest(t *testing.T) {
+	nc, _ := pcs.NewNameCipher([]byte("secret"), "/apps/x")
+	m := pcs.NewNameManifest()
+	nc.SetManifest(m)
+
+	enc := nc.EncryptPath("/apps/x/docs/a.txt")
+	if p, ok := m.Plain(enc); !ok || p != "/apps/x/docs/a.txt" {
+		t.Errorf("Plain(%s) = %q, %v", enc, p, ok)
+	}
+	if r, ok := m.Remote("/apps/x/docs/a.txt"); !ok || r != enc {
+		t.Errorf("Remote = %q, %v; want %s", r, ok, enc)
+	}
+	if _, ok := m.Remote("/apps/x"); ok {
+		t.Error("the plaintext root is in the manifest")
+	}
+
+	path := filepath.Join(t.TempDir(), "names.json")
+	if err := m.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded := pcs.NewNameManifest()
+	if err := loaded.Load(path); err != nil {
+		t.Fatal(err)
+	}
+	if r, ok := loaded.Remote("/apps/x/docs/a.txt"); !ok || r != enc {
+		t.Errorf("loaded Remote = %q, %v; want %s", r, ok, enc)
+	}
+
+	nc.SetManifest(nil)
+	nc.EncryptPath("/apps/x/b.txt")
+	if _, ok := m.Remote("/apps/x/b.txt"); ok {
+		t.Error("recorded a path after SetManifest(nil)")
+	}
+}
@@ -0,0 +1,373 @@
+package pcs
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// ClientOption configures a Client created by NewClient.
+type ClientOption func(*Client)
+
+// WithoutRedirects stops the Client from following 3xx responses; they are
+// returned as *RedirectError instead, which is useful to obtain the signed
+// location of a download without fetching it.
+func WithoutRedirects() ClientOption {
+	return func(c *Client) {
+		hc := *c.client
+		hc.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		}
+		c.client = &hc
+	}
+}
+
+// WithHTTPClient makes the Client send its requests through hc instead of
+// the client returned by NewHttpClient.
+func WithHTTPClient(hc *http.Client) ClientOption {
+	return func(c *Client) {
+		c.client = hc
+	}
+}
+
+// WithMaxIdleConns limits the number of idle connections kept across all
+// hosts. Zero means no limit.
+func WithMaxIdleConns(n int) ClientOption {
+	return withTransport(func(tr *http.Transport) {
+		tr.MaxIdleConns = n
+	})
+}
+
+// WithMaxIdleConnsPerHost limits the number of idle connections kept per
+// host; the default is 128.
+func WithMaxIdleConnsPerHost(n int) ClientOption {
+	return withTransport(func(tr *http.Transport) {
+		tr.MaxIdleConnsPerHost = n
+	})
+}
+
+// WithMaxConnsPerHost limits the total number of connections per host,
+// including those in use. Zero means no limit.
+func WithMaxConnsPerHost(n int) ClientOption {
+	return withTransport(func(tr *http.Transport) {
+		tr.MaxConnsPerHost = n
+	})
+}
+
+// WithIdleConnTimeout sets how long an idle connection is kept before it is
+// closed. Zero means no limit.
+func WithIdleConnTimeout(d time.Duration) ClientOption {
+	return withTransport(func(tr *http.Transport) {
+		tr.IdleConnTimeout = d
+	})
+}
+
+// WithDialTimeout sets how long to wait for a TCP connection to be
+// established; the default is 30 seconds.
+func WithDialTimeout(d time.Duration) ClientOption {
+	return withTransport(func(tr *http.Transport) {
+		tr.DialContext = (&net.Dialer{Timeout: d, KeepAlive: 30 * time.Second}).DialContext
+	})
+}
+
+// WithTLSHandshakeTimeout sets how long to wait for the TLS handshake; the
+// default is 10 seconds. Zero means no limit.
+func WithTLSHandshakeTimeout(d time.Duration) ClientOption {
+	return withTransport(func(tr *http.Transport) {
+		tr.TLSHandshakeTimeout = d
+	})
+}
+
+// WithIdleTimeout sets how long a response body, such as a download, may
+// go without delivering any data before the request fails with
+// ErrIdleTimeout; the default is 2 minutes. Zero means no limit.
+func WithIdleTimeout(d time.Duration) ClientOption {
+	return func(c *Client) {
+		c.idleTimeout = max(d, 0)
+	}
+}
+
+// WithRequestTimeout bounds the total time of each request, from sending it
+// to reading the whole response and including retries. Zero, the default,
+// means no limit; prefer a context deadline to bound a whole operation.
+func WithRequestTimeout(d time.Duration) ClientOption {
+	return func(c *Client) {
+		c.requestTimeout = max(d, 0)
+	}
+}
+
+// WithResponseHeaderTimeout sets how long to wait for the response headers
+// after the request has been written. Zero means no limit.
+func WithResponseHeaderTimeout(d time.Duration) ClientOption {
+	return withTransport(func(tr *http.Transport) {
+		tr.ResponseHeaderTimeout = d
+	})
+}
+
+// WithChunkTuners replaces the tuners that size upload blocks and download
+// segments. Pass FixedChunkTuner to disable automatic adjustment; a nil
+// tuner keeps the default.
+func WithChunkTuners(blocks, segments *ChunkTuner) ClientOption {
+	return func(c *Client) {
+		if blocks != nil {
+			c.blockTuner = blocks
+		}
+		if segments != nil {
+			c.segmentTuner = segments
+		}
+	}
+}
+
+// WithBlockSize fixes the size of the blocks large uploads are split into,
+// replacing the automatic adjustment between 1MB and 64MB. Large blocks
+// need fewer requests on fast, reliable links; small ones lose less work
+// when a flaky connection drops. Sizes outside MinBlockSize and
+// MaxBlockSize are clamped to that range; use ValidateBlockSize to reject
+// them instead. Uploads of known size still use larger blocks when a file
+// would otherwise need more than 1024 of them.
+func WithBlockSize(size int64) ClientOption {
+	return func(c *Client) {
+		c.blockTuner = FixedChunkTuner(min(max(size, MinBlockSize), MaxBlockSize))
+	}
+}
+
+// WithBandwidthLimit caps the combined throughput of all uploads and
+// downloads made through the Client at n bytes per second, shared among
+// concurrent transfers. Zero means no limit. See SetBandwidthLimit.
+func WithBandwidthLimit(n int64) ClientOption {
+	return func(c *Client) {
+		c.SetBandwidthLimit(n)
+	}
+}
+
+// WithEncryption makes Upload, DownloadTo and TransferManager encrypt file
+// content with ci before it leaves the machine and decrypt it on the way
+// back, so PCS only ever stores ciphertext. See NewCipher.
+func WithEncryption(ci *Cipher) ClientOption {
+	return func(c *Client) {
+		c.cipher = ci
+	}
+}
+
+// WithDownloadCache makes DownloadTo and TransferManager serve files whose md5
+// is already in dc from disk instead of downloading them again, and add
+// downloaded files to it. See NewDownloadCache.
+func WithDownloadCache(dc *DownloadCache) ClientOption {
+	return func(c *Client) {
+		c.cache = dc
+	}
+}
+
+// WithHashCache makes SumFile, Sync and Backup look up the checksums of
+// unchanged local files in h instead of reading them again. Call h.Save to
+// keep newly computed checksums for later runs. See NewHashCache.
+func WithHashCache(h *HashCache) ClientOption {
+	return func(c *Client) {
+		c.hashes = h
+	}
+}
+
+// WithNameEncryption encrypts file and directory names below the cipher's
+// root in every remote path sent to PCS and decrypts the paths in responses,
+// so remote listings reveal nothing about the directory structure. See
+// NewNameCipher.
+func WithNameEncryption(nc *NameCipher) ClientOption {
+	return func(c *Client) {
+		c.names = nc
+	}
+}
+
+// WithReadAhead sets how many 1MB chunks a RemoteFile downloads in the
+// background once it detects sequential reads. The default is 4; zero
+// disables read-ahead.
+func WithReadAhead(chunks int) ClientOption {
+	return func(c *Client) {
+		if chunks >= 0 {
+			c.readAhead = chunks
+		}
+	}
+}
+
+// WithListConcurrency sets how many directories Find, and Sync when it
+// lists the remote tree, list at the same time. The default is 8.
+func WithListConcurrency(n int) ClientOption {
+	return func(c *Client) {
+		if n > 0 {
+			c.listConcurrency = n
+		}
+	}
+}
+
+// WithHeader sets a header sent with every request made by the Client, e.g.
+// an application identifier. See SetHeader and WithRequestHeader.
+func WithHeader(key, value string) ClientOption {
+	return func(c *Client) {
+		c.SetHeader(key, value)
+	}
+}
+
+// WithQuotaCheck makes Upload and TransferManager uploads call CheckQuota
+// before sending any data, so an upload that cannot fit fails immediately
+// with ErrInsufficientQuota.
+func WithQuotaCheck() ClientOption {
+	return func(c *Client) {
+		c.quotaCheck = true
+	}
+}
+
+// WithEndpoints makes the Client talk to the hosts in e instead of the
+// public Baidu ones, e.g. an alternative gateway. Nil URLs in e keep the
+// default host. See DefaultEndpoints.
+func WithEndpoints(e EndpointSet) ClientOption {
+	return func(c *Client) {
+		c.SetEndpoints(e)
+	}
+}
+
+// WithBaseURL overrides the host API requests are resolved against.
+func WithBaseURL(u *url.URL) ClientOption {
+	return func(c *Client) {
+		c.SetBaseURL(u)
+	}
+}
+
+// WithUploadURL overrides the host upload requests are resolved against.
+func WithUploadURL(u *url.URL) ClientOption {
+	return func(c *Client) {
+		c.SetUploadURL(u)
+	}
+}
+
+// WithDownloadURL overrides the host download requests are resolved against.
+func WithDownloadURL(u *url.URL) ClientOption {
+	return func(c *Client) {
+		c.SetDownloadURL(u)
+	}
+}
+
+// WithPanURL overrides the host netdisk platform requests, such as
+// GetUserInfo, are resolved against.
+func WithPanURL(u *url.URL) ClientOption {
+	return func(c *Client) {
+		c.SetPanURL(u)
+	}
+}
+
+// WithRetry makes each request be attempted up to attempts times while it
+// fails with a network error or a temporary PCS error, waiting backoff
+// before the first retry and doubling it after each; a non-positive backoff
+// keeps the default of 500ms. Use WithRetryBudget to bound the retries of
+// a whole operation.
+//
+// Only idempotent requests are retried: GET requests, metadata and task
+// queries, the blocks of chunked uploads, and Mkdir, Move, Copy and Delete,
+// which recognize the outcome of their own earlier attempt. Requests that
+// may take effect twice, such as whole-file uploads, CreateSuperFile,
+// AddOfflineDownloadTask, BatchMove, BatchCopy and creating shares, fail
+// with the first error; throttled requests are the exception, see
+// WithThrottleRetry.
+func WithRetry(attempts int, backoff time.Duration) ClientOption {
+	return func(c *Client) {
+		if attempts > 0 {
+			c.retryAttempts = attempts
+		}
+		if backoff > 0 {
+			c.retryBackoff = backoff
+		}
+	}
+}
+
+// WithThrottleRetry sets how many times in total a request is attempted
+// while PCS throttles it, with 429 Too Many Requests or a frequency limit
+// error code, and the longest delay the Client waits before trying again.
+// The delay is taken from the Retry-After header when present, otherwise
+// it follows the backoff of WithRetry; a request asked to wait longer than
+// maxWait fails with the throttling error.
+//
+// Throttle retries are on by default, with 3 attempts and a wait of at
+// most one minute, and apply to every request, including those that are
+// not idempotent: PCS turns a throttled request away before acting on it.
+// Attempts of 1 surfaces throttling errors immediately.
+func WithThrottleRetry(attempts int, maxWait time.Duration) ClientOption {
+	return func(c *Client) {
+		if attempts > 0 {
+			c.throttleAttempts = attempts
+		}
+		if maxWait > 0 {
+			c.throttleWait = maxWait
+		}
+	}
+}
+
+// WithCircuitBreaker makes the Client stop sending requests to a host after
+// failures consecutive network errors or 5xx responses from it. For the
+// following cooldown, requests to that host fail fast with ErrCircuitOpen
+// (or move to a fallback host, see EndpointSet); after it, a single probe
+// request decides whether the host is healthy again.
+func WithCircuitBreaker(failures int, cooldown time.Duration) ClientOption {
+	return func(c *Client) {
+		if failures > 0 && cooldown > 0 {
+			c.breakers = newBreakers(failures, cooldown)
+		}
+	}
+}
+
+// WithMaxConcurrentRequests limits the Client to n requests in flight at
+// once, across all goroutines and helpers such as Find and TransferManager;
+// further requests wait for a free slot or for their context to end. A
+// retry waits for a slot again rather than holding one while it backs off.
+// Pipe streams a download into an upload under a single slot. Zero, the
+// default, means no limit.
+func WithMaxConcurrentRequests(n int) ClientOption {
+	return func(c *Client) {
+		c.requests = nil
+		if n > 0 {
+			c.requests = make(chan struct{}, n)
+		}
+	}
+}
+
+// WithMemoryLimit caps the memory held by upload request bodies, which are
+// assembled in memory a block at a time, at n bytes across all concurrent
+// uploads of the Client; once it is reached, new blocks wait for earlier
+// ones to be sent. A body larger than n waits until it can run alone. Zero,
+// the default, means no limit.
+func WithMemoryLimit(n int64) ClientOption {
+	return func(c *Client) {
+		c.memory = nil
+		if n > 0 {
+			c.memory = newMemoryBudget(n)
+		}
+	}
+}
+
+// WithQuotaLowRatio makes GetQuota publish a QuotaLow event once the used
+// space reaches ratio (0 to 1) of the quota. The default is 0.95; zero
+// disables the event.
+func WithQuotaLowRatio(ratio float64) ClientOption {
+	return func(c *Client) {
+		c.quotaLowRatio = ratio
+	}
+}
+
+// withTransport applies fn to a copy of the Client's *http.Transport, so a
+// transport shared through WithHTTPClient is never modified. It does
+// nothing if the Client uses another kind of http.RoundTripper.
+func withTransport(fn func(*http.Transport)) ClientOption {
+	return func(c *Client) {
+		tr, ok := c.client.Transport.(*http.Transport)
+		if !ok {
+			if c.client.Transport != nil {
+				return
+			}
+			tr = http.DefaultTransport.(*http.Transport)
+		}
+		tr = tr.Clone()
+		fn(tr)
+
+		hc := *c.client
+		hc.Transport = tr
+		c.client = &hc
+	}
+}
@@ -0,0 +1,31 @@
+package pcs
+
+import (
+	"path"
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// 请求参数中表示远程路径的字段，发送前会经过 CleanPath 规范化。
+var pathParams = []string{"path", "from", "to", "save_path", "filter_path", "source_path"}
+
+// CleanPath 规范化远程路径：
+//   - 转换为 Unicode NFC 形式（macOS 本地文件名为 NFD，不转换会在远端生成“不同”的文件）；
+//   - 合并重复的斜杠，解析 . 和 ..；
+//   - 保证以 / 开头，且除根目录外不以 / 结尾。
+func CleanPath(p string) string {
+	p = norm.NFC.String(p)
+	if !strings.HasPrefix(p, "/") {
+		p = "/" + p
+	}
+	return path.Clean(p)
+}
+
+func cleanPaths(paths []string) []string {
+	cleaned := make([]string, len(paths))
+	for i, p := range paths {
+		cleaned[i] = CleanPath(p)
+	}
+	return cleaned
+}
@@ -1,26 +1,30 @@
 package pcs
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
 	"crypto/tls"
 	"encoding/json"
 	"errors"
-	"fmt"
 	"io"
-	"io/ioutil"
 	"net"
 	"net/http"
 	"net/url"
 	"reflect"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 
-	"github.com/google/go-querystring/query"
+	"golang.org/x/time/rate"
 )
 
 const (
-	defaultBaseURL  = "https://pcs.baidu.com/rest/2.0/pcs"
-	uploadBaseURL   = "https://c.pcs.baidu.com/rest/2.0/pcs"
-	downloadBaseURL = "https://d.pcs.baidu.com/rest/2.0/pcs"
+	defaultBaseURL  = "https://pcs.baidu.com/rest/2.0/pcs/"
+	uploadBaseURL   = "https://c.pcs.baidu.com/rest/2.0/pcs/"
+	downloadBaseURL = "https://d.pcs.baidu.com/rest/2.0/pcs/"
+	panBaseURL      = "https://pan.baidu.com/rest/2.0/xpan/"
 
 	libraryVersion = "0.1"
 	userAgent      = "go-baidupcs/" + libraryVersion
@@ -28,12 +32,38 @@ const (
 	minRapidUploadFile = 256 * 1024
 
 	defaultIdleConns = 128
+	maxRedirects     = 10
 )
 
 var (
-	ErrInvalidArgument  = errors.New("baidu-pcs: invalid argument")
-	ErrMinRapidFileSize = errors.New("baidu-pcs: rapid upload file size must > 256KB")
-	ErrIncompleteFile   = errors.New("baidu-pcs: could not read the whole file")
+	ErrInvalidArgument   = errors.New("baidu-pcs: invalid argument")
+	ErrMinRapidFileSize  = errors.New("baidu-pcs: rapid upload file size must > 256KB")
+	ErrIncompleteFile    = errors.New("baidu-pcs: could not read the whole file")
+	ErrInvalidResponse   = errors.New("baidu-pcs: unexpected response from server")
+	ErrTooManyRedirects  = errors.New("baidu-pcs: stopped after too many redirects")
+	ErrNoUploadServer    = errors.New("baidu-pcs: no reachable upload server")
+	ErrNoDownloadServer  = errors.New("baidu-pcs: no download location available")
+	ErrClientClosed      = errors.New("baidu-pcs: client closed")
+	ErrInsufficientQuota = errors.New("baidu-pcs: not enough free quota")
+	ErrCircuitOpen       = errors.New("baidu-pcs: circuit breaker open for host")
+
+	ErrInsufficientDiskSpace = errors.New("baidu-pcs: not enough local disk space")
+	ErrChecksumMismatch      = errors.New("baidu-pcs: checksum mismatch")
+
+	// An *APIError unwraps to one of these according to its error code, so
+	// that callers can use errors.Is instead of comparing codes.
+	ErrFileNotExist     = errors.New("baidu-pcs: file does not exist")
+	ErrAlreadyExists    = errors.New("baidu-pcs: file already exists")
+	ErrDeleteFailed     = errors.New("baidu-pcs: file or directory could not be deleted")
+	ErrPermissionDenied = errors.New("baidu-pcs: permission denied")
+	ErrTokenExpired     = errors.New("baidu-pcs: access token invalid or expired")
+	ErrRateLimited      = errors.New("baidu-pcs: request frequency limit hit")
+	ErrTaskNotFound     = errors.New("baidu-pcs: offline download task does not exist")
+
+	// ErrDirNotEmpty is not returned for any error code at present: PCS
+	// deletes non-empty directories together with their contents, and
+	// reports other failed deletes as ErrDeleteFailed.
+	ErrDirNotEmpty = errors.New("baidu-pcs: directory not empty")
 )
 
 // TODO: 参考go-github 重构。
@@ -45,90 +75,270 @@ var (
 // 路径中不能包含以下字符：\\ ? | " > < : *
 // 文件名或路径名开头结尾不能是“.”或空白字符，空白字符包括: \r, \n, \t, 空格, \0, \x0B
 
+// Client is safe for concurrent use by multiple goroutines. Once a Client
+// is in use, its exported fields must only be changed through the Set*
+// methods, e.g. SetAccessToken after refreshing an expired token.
 type Client struct {
+	mu sync.RWMutex
+
 	BaseURL     *url.URL
 	UploadURL   *url.URL
 	DownloadURL *url.URL
+	PanURL      *url.URL // 网盘开放平台的接口，例如 GetUserInfo
+
+	endpointsName string
+	uploadHosts   *hostGroup // UploadURL and its fallbacks, see EndpointSet
+	downloadHosts *hostGroup
 
 	UserAgent   string
 	AccessToken string
 	client      *http.Client
+	header      http.Header // extra headers sent with every request
+
+	blockTuner   *ChunkTuner   // 分片上传的分片大小
+	segmentTuner *ChunkTuner   // 分段下载的分段大小
+	bandwidth    *rate.Limiter // 所有上传和下载共享的带宽限制
+
+	cipher        *Cipher     // 上传时加密、下载时解密，见 WithEncryption
+	names         *NameCipher // 加密远程路径中的文件名，见 WithNameEncryption
+	cache         *DownloadCache
+	hashes        *HashCache // 本地文件的校验值，见 WithHashCache
+	events        eventBus
+	quotaLowRatio float64
+	readAhead     int  // RemoteFile 顺序读取时预读的分块数
+	quotaCheck    bool // 上传前检查剩余空间，见 WithQuotaCheck
+
+	listConcurrency int // Find 并发列出的目录数，见 WithListConcurrency
+
+	retryAttempts int           // attempts per request, see WithRetry
+	retryBackoff  time.Duration // delay before the first retry, doubled after each
+	breakers      *breakers     // per host circuit breakers, see WithCircuitBreaker
+
+	throttleAttempts int           // attempts per throttled request, see WithThrottleRetry
+	throttleWait     time.Duration // longest delay waited for a throttled request
+
+	idleTimeout    time.Duration // see WithIdleTimeout
+	requestTimeout time.Duration // see WithRequestTimeout
+
+	requests chan struct{} // slots for requests in flight, see WithMaxConcurrentRequests
+	memory   *memoryBudget // 上传请求体占用的内存，见 WithMemoryLimit
+
+	closed   bool
+	inflight sync.WaitGroup
+	done     context.Context // cancelled when Close gives up waiting
+	abort    context.CancelFunc
 }
 
-func NewClient(accessToken string) *Client {
+func NewClient(accessToken string, opts ...ClientOption) *Client {
 	client := new(Client)
 
-	baseURL, _ := url.Parse(defaultBaseURL)
-	uploadURL, _ := url.Parse(uploadBaseURL)
-	downloadURL, _ := url.Parse(downloadBaseURL)
-
-	client.BaseURL = baseURL
-	client.UploadURL = uploadURL
-	client.DownloadURL = downloadURL
+	client.SetEndpoints(DefaultEndpoints())
 
 	client.UserAgent = userAgent
 	client.AccessToken = accessToken
 	client.client = NewHttpClient()
+	client.blockTuner = NewChunkTuner(DefaultBlockSize, minChunkSize, maxChunkSize)
+	client.segmentTuner = NewChunkTuner(DefaultSegmentSize, minChunkSize, maxChunkSize)
+	client.bandwidth = newBandwidthLimiter()
+	client.quotaLowRatio = defaultQuotaLowRatio
+	client.readAhead = defaultReadAhead
+	client.listConcurrency = defaultListConcurrency
+	client.retryAttempts = 1
+	client.retryBackoff = defaultRetryBackoff
+	client.throttleAttempts = defaultThrottleAttempts
+	client.throttleWait = defaultThrottleWait
+	client.idleTimeout = defaultIdleTimeout
+	client.done, client.abort = context.WithCancel(context.Background())
+
+	for _, opt := range opts {
+		opt(client)
+	}
 
 	return client
 }
 
+// Token returns the access token currently used by the Client.
+func (c *Client) Token() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.AccessToken
+}
+
+// SetAccessToken replaces the access token used by subsequent requests.
+func (c *Client) SetAccessToken(token string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.AccessToken = token
+}
+
+// SetUserAgent replaces the User-Agent sent with subsequent requests.
+func (c *Client) SetUserAgent(ua string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.UserAgent = ua
+}
+
+// SetHeader sets a header sent with every subsequent request, replacing the
+// User-Agent if key is "User-Agent". An empty value removes the header.
+func (c *Client) SetHeader(key, value string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.header == nil {
+		c.header = make(http.Header)
+	}
+	if value == "" {
+		c.header.Del(key)
+	} else {
+		c.header.Set(key, value)
+	}
+}
+
+// SetBaseURL replaces the URL that API requests are resolved against. Its
+// path names a directory, with or without the trailing slash.
+func (c *Client) SetBaseURL(u *url.URL) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.BaseURL = u
+}
+
+// SetUploadURL replaces the URL that upload requests are resolved against.
+func (c *Client) SetUploadURL(u *url.URL) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.UploadURL = u
+}
+
+// SetDownloadURL replaces the URL that download requests are resolved
+// against.
+func (c *Client) SetDownloadURL(u *url.URL) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.DownloadURL = u
+}
+
+// SetPanURL replaces the URL that netdisk platform requests, such as
+// GetUserInfo, are resolved against.
+func (c *Client) SetPanURL(u *url.URL) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.PanURL = u
+}
+
+// Close stops the Client from sending new requests, which then fail with
+// ErrClientClosed, and waits for the requests in flight to complete. If ctx
+// expires first, the remaining requests are cancelled and ctx's error is
+// returned. Idle connections are closed in either case.
+//
+// To stop a TransferManager without losing progress, call its Shutdown
+// before closing the Client it uses.
+func (c *Client) Close(ctx context.Context) error {
+	c.mu.Lock()
+	c.closed = true
+	c.mu.Unlock()
+
+	idle := make(chan struct{})
+	go func() {
+		c.inflight.Wait()
+		close(idle)
+	}()
+
+	var err error
+	select {
+	case <-idle:
+	case <-ctx.Done():
+		c.abort()
+		<-idle
+		err = ctx.Err()
+	}
+	c.client.CloseIdleConnections()
+	return err
+}
+
+// NewHttpClient returns the http.Client used by NewClient. Its transport
+// negotiates HTTP/2 via ALPN where the PCS hosts offer it, multiplexing
+// bursts of small requests over one connection, and falls back to HTTP/1.1
+// otherwise.
 func NewHttpClient() *http.Client {
 	tr := &http.Transport{
 		Proxy: http.ProxyFromEnvironment,
-		Dial: (&net.Dialer{
+		DialContext: (&net.Dialer{
 			Timeout:   30 * time.Second,
 			KeepAlive: 30 * time.Second,
-		}).Dial,
+		}).DialContext,
+		ForceAttemptHTTP2:   true,
 		TLSClientConfig:     &tls.Config{InsecureSkipVerify: true},
 		TLSHandshakeTimeout: 10 * time.Second,
 		MaxIdleConnsPerHost: defaultIdleConns,
 	}
-	return &http.Client{Transport: tr}
+	return &http.Client{Transport: tr, CheckRedirect: checkRedirect}
+}
+
+// checkRedirect follows up to maxRedirects redirects. When PCS redirects to
+// another of its own hosts without carrying the access token over (as the
+// download host does), the token of the original request is appended so the
+// redirected request stays authenticated.
+func checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= maxRedirects {
+		return ErrTooManyRedirects
+	}
+	token := via[0].URL.Query().Get("access_token")
+	if token != "" && req.URL.Query().Get("access_token") == "" &&
+		strings.HasSuffix(req.URL.Hostname(), "pcs.baidu.com") {
+		if req.URL.RawQuery != "" {
+			req.URL.RawQuery += "&"
+		}
+		req.URL.RawQuery += "access_token=" + url.QueryEscape(token)
+	}
+	return nil
 }
 
-func (c *Client) Get(url string, v interface{}) (*http.Response, error) {
+func (c *Client) Get(ctx context.Context, url string, v interface{}) (*http.Response, error) {
 	req, err := c.NewRequest("GET", url, nil)
 	if err != nil {
 		return nil, err
 	}
-	return c.Do(req, v)
+	return c.Do(ctx, req, v)
 }
 
-func (c *Client) Post(url string, contentType string, body io.Reader, v interface{}) (*http.Response, error) {
-	req, err := http.NewRequest("POST", url, body)
+func (c *Client) Post(ctx context.Context, url string, contentType string, body io.Reader, v interface{}) (*http.Response, error) {
+	req, err := c.NewRequest("POST", url, body)
 	if err != nil {
 		return nil, err
 	}
 	req.Header.Set("Content-Type", contentType)
-	return c.Do(req, v)
+	return c.Do(ctx, req, v)
 }
 
-func (c *Client) PostForm(url string, data url.Values, v interface{}) (*http.Response, error) {
-	return c.Post(url, "application/x-www-form-urlencoded", strings.NewReader(data.Encode()), v)
+func (c *Client) PostForm(ctx context.Context, url string, data url.Values, v interface{}) (*http.Response, error) {
+	return c.Post(ctx, url, "application/x-www-form-urlencoded", strings.NewReader(data.Encode()), v)
 }
 
 func (c *Client) addOptions(s string, method string, opt interface{}) (string, error) {
+	qs := url.Values{}
 	v := reflect.ValueOf(opt)
-	if v.Kind() == reflect.Ptr && v.IsNil() {
-		return s, nil
-	}
-
-	u, err := url.Parse(s)
-	if err != nil {
-		return s, err
+	if opt != nil && !(v.Kind() == reflect.Ptr && v.IsNil()) {
+		if val, ok := opt.(validator); ok {
+			if err := val.Validate(); err != nil {
+				return s, err
+			}
+		}
+		var err error
+		qs, err = encodeQuery(opt)
+		if err != nil {
+			return s, err
+		}
 	}
 
-	qs, err := query.Values(opt)
-	if err != nil {
-		return s, err
+	for _, key := range pathParams {
+		if p := qs.Get(key); p != "" {
+			qs.Set(key, c.remotePath(p))
+		}
 	}
 
-	qs.Set("access_token", c.AccessToken)
+	qs.Set("access_token", c.Token())
 	qs.Set("method", method)
 
-	u.RawQuery = qs.Encode()
-	return u.String(), nil
+	return s + "?" + qs.Encode(), nil
 }
 
 // NewRequest creates an API request. A relative URL can be provided in urlStr,
@@ -136,69 +346,230 @@ func (c *Client) addOptions(s string, method string, opt interface{}) (string, e
 // Relative URLs should always be specified without a preceding slash.  If
 // specified, the value pointed to by body is JSON encoded and included as the
 // request body.
+//
+// API responses are JSON and often large (listings, meta, diff), so the
+// request asks for gzip encoding explicitly; Do decompresses the response
+// regardless of the transport in use.
 func (c *Client) NewRequest(method, urlStr string, body io.Reader) (*http.Request, error) {
-	rel, err := url.Parse(urlStr)
-	if err != nil {
-		return nil, err
-	}
-
-	u := c.BaseURL.ResolveReference(rel)
-
-	req, err := http.NewRequest(method, u.String(), body)
+	req, err := c.newRequest(func() *url.URL { return c.BaseURL }, method, urlStr, body)
 	if err != nil {
 		return nil, err
 	}
-
-	if c.UserAgent != "" {
-		req.Header.Add("User-Agent", c.UserAgent)
-	}
+	req.Header.Set("Accept-Encoding", "gzip")
 	return req, nil
 }
 
+// NewUploadRequest is like NewRequest but resolves urlStr against UploadURL.
 func (c *Client) NewUploadRequest(method, urlStr string, body io.Reader) (*http.Request, error) {
+	return c.newRequest(func() *url.URL { return c.UploadURL }, method, urlStr, body)
+}
+
+// NewDownloadRequest is like NewRequest but resolves urlStr against
+// DownloadURL.
+func (c *Client) NewDownloadRequest(method, urlStr string, body io.Reader) (*http.Request, error) {
+	return c.newRequest(func() *url.URL { return c.DownloadURL }, method, urlStr, body)
+}
+
+func (c *Client) newRequest(base func() *url.URL, method, urlStr string, body io.Reader) (*http.Request, error) {
 	rel, err := url.Parse(urlStr)
 	if err != nil {
 		return nil, err
 	}
 
-	u := c.UploadURL.ResolveReference(rel)
+	c.mu.RLock()
+	b := *base()
+	ua := c.UserAgent
+	header := c.header.Clone()
+	c.mu.RUnlock()
+
+	// The base is a directory even when given without the trailing slash,
+	// otherwise its last element would be replaced.
+	if !strings.HasSuffix(b.Path, "/") {
+		b.Path += "/"
+		if b.RawPath != "" {
+			b.RawPath += "/"
+		}
+	}
+	u := b.ResolveReference(rel)
 	req, err := http.NewRequest(method, u.String(), body)
 	if err != nil {
 		return nil, err
 	}
 
-	if c.UserAgent != "" {
-		req.Header.Add("User-Agent", c.UserAgent)
+	if ua != "" {
+		req.Header.Add("User-Agent", ua)
+	}
+	for k, v := range header {
+		req.Header[k] = v
 	}
 	return req, nil
-
 }
 
-func (c *Client) NewDownloadRequest(method, urlStr string, body io.Reader) (*http.Request, error) {
-	rel, err := url.Parse(urlStr)
-	if err != nil {
-		return nil, err
+// Do sends an API request bound to ctx and decodes the JSON response into
+// v, or copies the body into v if it is an io.Writer. If v is nil, the body
+// of the returned response is left for the caller to read and must be
+// closed; the request, including ctx's deadline and its slot of
+// WithMaxConcurrentRequests, lasts until then.
+//
+// Errors are one of *RequestError (the request did not complete, including
+// a cancelled or expired ctx), *APIError (PCS rejected the request),
+// *RedirectError or *DecodeError, and can be told apart with errors.As.
+func (c *Client) Do(ctx context.Context, req *http.Request, v interface{}) (*http.Response, error) {
+	c.mu.RLock()
+	if c.closed {
+		c.mu.RUnlock()
+		closeBody(req)
+		return nil, &RequestError{Method: req.Method, URL: req.URL.String(), Err: ErrClientClosed}
 	}
+	c.inflight.Add(1)
+	c.mu.RUnlock()
+	defer c.inflight.Done()
 
-	u := c.DownloadURL.ResolveReference(rel)
-	req, err := http.NewRequest(method, u.String(), body)
-	if err != nil {
-		return nil, err
+	for k, v := range requestHeader(ctx) {
+		req.Header[k] = v
 	}
 
-	if c.UserAgent != "" {
-		req.Header.Add("User-Agent", c.UserAgent)
+	// end is unwound when Do returns, or handed to the body of a response
+	// returned unread.
+	var end []func()
+	defer func() { unwind(end) }()
+
+	// Close cancels the requests still in flight when it times out.
+	ctx, cancel := context.WithCancel(ctx)
+	stop := context.AfterFunc(c.done, cancel)
+	end = append(end, cancel, func() { stop() })
+	if c.requestTimeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, c.requestTimeout)
+		end = append(end, cancel)
 	}
-	return req, nil
 
+	c.mu.RLock()
+	attempts, backoff := c.retryAttempts, c.retryBackoff
+	throttleAttempts, throttleWait := c.throttleAttempts, c.throttleWait
+	c.mu.RUnlock()
+	budget := retryBudget(ctx)
+	for attempt := 1; ; attempt++ {
+		resp, err := c.do(ctx, req, v)
+		if b, ok := streamed(resp); ok {
+			b.end = append(end, b.end...)
+			end = nil
+			return resp, nil
+		}
+		// A throttled request was turned away before PCS acted on it, so
+		// it is safe to repeat even when it is not idempotent.
+		if err == nil || !retryable(resp, err) || !throttled(err) && !isIdempotent(ctx, req) {
+			return resp, err
+		}
+		limit, delay := attempts, backoff
+		if throttled(err) {
+			limit = max(limit, throttleAttempts)
+			if d, ok := retryAfter(resp); ok {
+				delay = d
+			}
+			if delay > throttleWait {
+				return resp, err
+			}
+		}
+		if attempt >= limit {
+			return resp, err
+		}
+		next := replay(req)
+		if next == nil || !budget.take() || !sleep(ctx, delay) {
+			return resp, err
+		}
+		backoff *= 2
+		req = next
+		markRetried(ctx)
+	}
+}
+
+// closeBody closes the body of a request that will not be sent, which
+// http.Client would otherwise have done.
+func closeBody(req *http.Request) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
 }
 
-func (c *Client) Do(req *http.Request, v interface{}) (*http.Response, error) {
-	resp, err := c.client.Do(req)
+type slotKey struct{}
+
+// holdSlot waits for one of the request slots of c, see
+// WithMaxConcurrentRequests, and returns a copy of ctx whose requests to c
+// share it rather than taking slots of their own, together with the
+// function that frees it. Helpers that keep a download open while they
+// upload what it returns run in such a context, since waiting for a second
+// slot could block forever.
+func (c *Client) holdSlot(ctx context.Context) (context.Context, func(), error) {
+	if c.requests == nil {
+		return ctx, func() {}, nil
+	}
+	select {
+	case c.requests <- struct{}{}:
+	case <-ctx.Done():
+		return nil, nil, ctx.Err()
+	}
+	return context.WithValue(ctx, slotKey{}, c), func() { <-c.requests }, nil
+}
+
+// do makes a single attempt at sending req, trying the fallback hosts of
+// an unreachable upload or download host.
+func (c *Client) do(ctx context.Context, req *http.Request, v interface{}) (*http.Response, error) {
+	// end is unwound when do returns, or when the caller closes a body
+	// returned unread.
+	var end []func()
+	defer func() { unwind(end) }()
+
+	if c.requests != nil && ctx.Value(slotKey{}) != c {
+		select {
+		case c.requests <- struct{}{}:
+			end = append(end, func() { <-c.requests })
+		case <-ctx.Done():
+			closeBody(req)
+			return nil, &RequestError{Method: req.Method, URL: req.URL.String(), Err: ctx.Err()}
+		}
+	}
+
+	// The idle timeout cancels the attempt when the response body stalls.
+	ctx, cancel := context.WithCancelCause(ctx)
+	end = append(end, func() { cancel(nil) })
+
+	req = req.WithContext(ctx)
+	if req.Body != nil && req.Body != http.NoBody {
+		req.Body = &throttledBody{ctx: ctx, lim: c.bandwidth, ReadCloser: req.Body}
+	}
+
+	resp, err := c.send(req)
+	// Try each fallback host once while the current one is unreachable.
+	tried := []string{req.URL.Host}
+	for err != nil && ctx.Err() == nil {
+		retry := c.failover(req, err)
+		if retry == nil || slices.Contains(tried, retry.URL.Host) {
+			break
+		}
+		tried = append(tried, retry.URL.Host)
+		if retry.Body != nil && retry.Body != http.NoBody {
+			retry.Body = &throttledBody{ctx: ctx, lim: c.bandwidth, ReadCloser: retry.Body}
+		}
+		req = retry
+		resp, err = c.send(req)
+	}
 	if err != nil {
-		return nil, err
+		// If the context has been cancelled, its error is more useful
+		// than the one reported by the transport.
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			err = ctxErr
+		}
+		return nil, &RequestError{Method: req.Method, URL: req.URL.String(), Err: err}
+	}
+	resp.Body = &throttledBody{ctx: ctx, lim: c.bandwidth, ReadCloser: resp.Body}
+	if c.idleTimeout > 0 {
+		resp.Body = newIdleTimeoutBody(resp.Body, c.idleTimeout, cancel)
+	}
+	end = append(end, func() { resp.Body.Close() })
+
+	if err := decompress(resp); err != nil {
+		return resp, readError(ctx, req, err)
 	}
-	defer resp.Body.Close()
 
 	err = CheckResponse(resp)
 	if err != nil {
@@ -207,37 +578,110 @@ func (c *Client) Do(req *http.Request, v interface{}) (*http.Response, error) {
 		return resp, err
 	}
 
-	if v != nil {
-		if w, ok := v.(io.Writer); ok {
-			io.Copy(w, resp.Body)
-		} else {
-			err = json.NewDecoder(resp.Body).Decode(v)
+	if w, ok := v.(io.Writer); ok {
+		if _, err := copyBuffered(w, resp.Body); err != nil {
+			return resp, readError(ctx, req, err)
 		}
+		return resp, nil
+	}
+	if v == nil && !isTextResponse(resp) {
+		resp.Body = &streamBody{ReadCloser: resp.Body, end: end[:len(end)-1]}
+		end = nil
+		return resp, nil
+	}
+
+	buf := getBytesBuffer()
+	defer putBytesBuffer(buf)
+	if _, err := buf.ReadFrom(resp.Body); err != nil {
+		return resp, readError(ctx, req, err)
+	}
+	data := buf.Bytes()
+
+	// Some endpoints report failures with a 2xx status and an error_code
+	// in the body.
+	if err := checkErrorBody(resp, data); err != nil {
+		return resp, err
+	}
+
+	if v == nil {
+		// Leave the body for the caller to read, as for file content.
+		resp.Body.Close()
+		resp.Body = io.NopCloser(bytes.NewReader(bytes.Clone(data)))
+		return resp, nil
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		body := append([]byte(nil), data...)
+		return resp, &DecodeError{Response: resp, Body: body, Err: err}
 	}
+	if c.names != nil {
+		c.names.decryptPathFields(reflect.ValueOf(v))
+	}
+	return resp, nil
+}
 
-	return resp, err
+// streamBody is the body of a response returned unread. Closing it ends the
+// request: its contexts are cancelled and its request slot is freed.
+type streamBody struct {
+	io.ReadCloser
+	once sync.Once
+	end  []func()
 }
 
-type ErrorResponse struct {
-	Response *http.Response // HTTP response that caused this error
-	Message  string         `json:"error_msg"`  // error message
-	Code     int            `json:"error_code"` // error code
+func (b *streamBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(func() { unwind(b.end) })
+	return err
 }
 
-func (r *ErrorResponse) Error() string {
-	return fmt.Sprintf("[%v] - %v - %d - %v - %d",
-		r.Response.Request.Method, r.Response.Request.URL,
-		r.Response.StatusCode, r.Message, r.Code)
+// streamed reports whether resp is a response whose body was returned
+// unread.
+func streamed(resp *http.Response) (*streamBody, bool) {
+	if resp == nil {
+		return nil, false
+	}
+	b, ok := resp.Body.(*streamBody)
+	return b, ok
+}
+
+// unwind calls fs in reverse order, the way deferred calls run.
+func unwind(fs []func()) {
+	for i := len(fs) - 1; i >= 0; i-- {
+		fs[i]()
+	}
 }
 
-func CheckResponse(r *http.Response) error {
-	if c := r.StatusCode; 200 <= c && c <= 299 {
+// decompress replaces the body of a gzip encoded response with its
+// decompressed content, the way http.Transport does when it negotiated the
+// encoding itself.
+func decompress(resp *http.Response) error {
+	if resp.Uncompressed || !strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
 		return nil
 	}
-	errorResponse := &ErrorResponse{Response: r}
-	data, err := ioutil.ReadAll(r.Body)
-	if err == nil && data != nil {
-		json.Unmarshal(data, errorResponse)
+	zr, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		return err
 	}
-	return errorResponse
+	resp.Body = &gzipBody{Reader: zr, body: resp.Body}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	return nil
+}
+
+type gzipBody struct {
+	*gzip.Reader
+	body io.ReadCloser
+}
+
+func (b *gzipBody) Close() error {
+	b.Reader.Close()
+	return b.body.Close()
+}
+
+// isTextResponse reports whether resp carries a JSON (or JSON served as
+// text/html) body rather than file content.
+func isTextResponse(resp *http.Response) bool {
+	ct := resp.Header.Get("Content-Type")
+	return strings.Contains(ct, "json") || strings.HasPrefix(ct, "text/")
 }
@@ -0,0 +1,433 @@
+// MockClient mirrors pcs.API; update it whenever interface.go changes.
+
+package pcstest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/holys/baidu-pcs/v2"
+)
+
+// ErrNotMocked is returned by MockClient methods whose function field is nil.
+var ErrNotMocked = errors.New("pcstest: method not mocked")
+
+// Call records a single invocation of a MockClient method.
+type Call struct {
+	Method string
+	Args   []interface{}
+}
+
+// MockClient implements pcs.API by delegating every method to the
+// corresponding function field, so tests can stub only what they use.
+// Calls are recorded in order and may be inspected with Calls.
+type MockClient struct {
+	GetQuotaFunc                  func(ctx context.Context) (*pcs.Quota, *http.Response, error)
+	GetUserInfoFunc               func(ctx context.Context) (*pcs.UserInfo, *http.Response, error)
+	UploadFunc                    func(ctx context.Context, srcPath string, opt *pcs.FileOptions) (*pcs.File, *http.Response, error)
+	BlockUploadFunc               func(ctx context.Context, srcPath string) (*pcs.File, *http.Response, error)
+	BlockUploadReaderFunc         func(ctx context.Context, r io.Reader, size int64) (*pcs.File, *http.Response, error)
+	CreateSuperFileFunc           func(ctx context.Context, targetPath string, md5 []string, opt *pcs.FileOptions) (*pcs.File, *http.Response, error)
+	RapidUploadFunc               func(ctx context.Context, opt *pcs.RapiduUploadOptions) (*pcs.File, *http.Response, error)
+	LocateUploadFunc              func(ctx context.Context) (*pcs.UploadServers, *http.Response, error)
+	DownloadFunc                  func(ctx context.Context, path string) (*http.Response, error)
+	DownloadToFunc                func(ctx context.Context, path string, w io.Writer) (*http.Response, error)
+	LocateDownloadFunc            func(ctx context.Context, path string) (*pcs.DownloadLocations, *http.Response, error)
+	PartialDownloadFunc           func(ctx context.Context, path string, start int64, end int64) (*http.Response, error)
+	MkdirFunc                     func(ctx context.Context, path string) (*pcs.File, *http.Response, error)
+	GetMetaFunc                   func(ctx context.Context, path string) (*pcs.FileMeta, *http.Response, error)
+	BatchGetMetaFunc              func(ctx context.Context, paths []string) ([]*pcs.FileMeta, *http.Response, error)
+	ListFilesFunc                 func(ctx context.Context, opt *pcs.ListFilesOptions) ([]*pcs.File, *http.Response, error)
+	MoveFunc                      func(ctx context.Context, from string, to string) (*pcs.MoveCopyResponse, *http.Response, error)
+	CopyFunc                      func(ctx context.Context, from string, to string) (*pcs.MoveCopyResponse, *http.Response, error)
+	DeleteFunc                    func(ctx context.Context, path string) (*http.Response, error)
+	BatchMoveFunc                 func(ctx context.Context, pairs []*pcs.FTPair) (*pcs.MoveCopyResponse, *http.Response, error)
+	BatchCopyFunc                 func(ctx context.Context, pairs []*pcs.FTPair) (*pcs.MoveCopyResponse, *http.Response, error)
+	BatchDeleteFunc               func(ctx context.Context, paths []string) (*http.Response, error)
+	SearchFunc                    func(ctx context.Context, opt *pcs.SearchOptions) ([]*pcs.File, *http.Response, error)
+	ThumbnailFunc                 func(ctx context.Context, opt *pcs.ThumbnailOptions) (*http.Response, error)
+	DiffFunc                      func(ctx context.Context, cursor string) (*http.Response, error)
+	StreamingFunc                 func(ctx context.Context, path string, typ string) (*http.Response, error)
+	ListStreamFunc                func(ctx context.Context, opt *pcs.ListStreamOptions) (*pcs.StreamFile, *http.Response, error)
+	DownloadStreamFunc            func(ctx context.Context, path string) (*http.Response, error)
+	QueryTorrentInfoFunc          func(ctx context.Context, sourcePath string) (*pcs.TorrentInfo, *http.Response, error)
+	AddOfflineDownloadTaskFunc    func(ctx context.Context, opt *pcs.AddTaskOptions) (int64, *http.Response, error)
+	QueryOfflineDownloadTaskFunc  func(ctx context.Context, opt *pcs.QueryTaskOptions) (*http.Response, error)
+	ListOfflineDownloadTaskFunc   func(ctx context.Context, opt *pcs.ListTaskOptions) (*http.Response, error)
+	CancelOfflineDownloadTaskFunc func(ctx context.Context, opt *pcs.CancelTaskOptions) (*http.Response, error)
+	ListRecycleFunc               func(ctx context.Context, opt *pcs.ListRecycleOptions) (*pcs.ListRecycleResponse, *http.Response, error)
+	RestoreFunc                   func(ctx context.Context, fsId string) (*pcs.RestoreResponse, *http.Response, error)
+	BatchRestoreFunc              func(ctx context.Context, fsIds []string) (*pcs.RestoreResponse, *http.Response, error)
+	EmptyRecycleFunc              func(ctx context.Context) (*http.Response, error)
+	OpenShareFunc                 func(ctx context.Context, link string, pwd string) (*pcs.Share, *http.Response, error)
+	ListShareFunc                 func(ctx context.Context, s *pcs.Share, dir string) ([]pcs.ShareFile, *http.Response, error)
+	SaveShareFunc                 func(ctx context.Context, s *pcs.Share, dest string, fsids ...uint64) (*http.Response, error)
+	CreateShareFunc               func(ctx context.Context, pwd string, period int, paths ...string) (*pcs.ShareLink, *http.Response, error)
+	ListShareLinksFunc            func(ctx context.Context) ([]*pcs.ShareLink, *http.Response, error)
+	CancelSharesFunc              func(ctx context.Context, ids ...uint64) (*http.Response, error)
+
+	mu    sync.Mutex
+	calls []Call
+}
+
+var _ pcs.API = (*MockClient)(nil)
+
+// Calls returns the invocations made on m so far.
+func (m *MockClient) Calls() []Call {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Call(nil), m.calls...)
+}
+
+func (m *MockClient) record(method string, args ...interface{}) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls = append(m.calls, Call{method, args})
+}
+
+func (m *MockClient) GetQuota(ctx context.Context) (*pcs.Quota, *http.Response, error) {
+	m.record("GetQuota")
+	if m.GetQuotaFunc == nil {
+		return nil, nil, ErrNotMocked
+	}
+	return m.GetQuotaFunc(ctx)
+}
+
+func (m *MockClient) GetUserInfo(ctx context.Context) (*pcs.UserInfo, *http.Response, error) {
+	m.record("GetUserInfo")
+	if m.GetUserInfoFunc == nil {
+		return nil, nil, ErrNotMocked
+	}
+	return m.GetUserInfoFunc(ctx)
+}
+
+func (m *MockClient) Upload(ctx context.Context, srcPath string, opt *pcs.FileOptions) (*pcs.File, *http.Response, error) {
+	m.record("Upload", srcPath, opt)
+	if m.UploadFunc == nil {
+		return nil, nil, ErrNotMocked
+	}
+	return m.UploadFunc(ctx, srcPath, opt)
+}
+
+func (m *MockClient) BlockUpload(ctx context.Context, srcPath string) (*pcs.File, *http.Response, error) {
+	m.record("BlockUpload", srcPath)
+	if m.BlockUploadFunc == nil {
+		return nil, nil, ErrNotMocked
+	}
+	return m.BlockUploadFunc(ctx, srcPath)
+}
+
+func (m *MockClient) BlockUploadReader(ctx context.Context, r io.Reader, size int64) (*pcs.File, *http.Response, error) {
+	m.record("BlockUploadReader", r, size)
+	if m.BlockUploadReaderFunc == nil {
+		return nil, nil, ErrNotMocked
+	}
+	return m.BlockUploadReaderFunc(ctx, r, size)
+}
+
+func (m *MockClient) CreateSuperFile(ctx context.Context, targetPath string, md5 []string, opt *pcs.FileOptions) (*pcs.File, *http.Response, error) {
+	m.record("CreateSuperFile", targetPath, md5, opt)
+	if m.CreateSuperFileFunc == nil {
+		return nil, nil, ErrNotMocked
+	}
+	return m.CreateSuperFileFunc(ctx, targetPath, md5, opt)
+}
+
+func (m *MockClient) RapidUpload(ctx context.Context, opt *pcs.RapiduUploadOptions) (*pcs.File, *http.Response, error) {
+	m.record("RapidUpload", opt)
+	if m.RapidUploadFunc == nil {
+		return nil, nil, ErrNotMocked
+	}
+	return m.RapidUploadFunc(ctx, opt)
+}
+
+func (m *MockClient) LocateUpload(ctx context.Context) (*pcs.UploadServers, *http.Response, error) {
+	m.record("LocateUpload")
+	if m.LocateUploadFunc == nil {
+		return nil, nil, ErrNotMocked
+	}
+	return m.LocateUploadFunc(ctx)
+}
+
+func (m *MockClient) Download(ctx context.Context, path string) (*http.Response, error) {
+	m.record("Download", path)
+	if m.DownloadFunc == nil {
+		return nil, ErrNotMocked
+	}
+	return m.DownloadFunc(ctx, path)
+}
+
+func (m *MockClient) DownloadTo(ctx context.Context, path string, w io.Writer) (*http.Response, error) {
+	m.record("DownloadTo", path, w)
+	if m.DownloadToFunc == nil {
+		return nil, ErrNotMocked
+	}
+	return m.DownloadToFunc(ctx, path, w)
+}
+
+func (m *MockClient) LocateDownload(ctx context.Context, path string) (*pcs.DownloadLocations, *http.Response, error) {
+	m.record("LocateDownload", path)
+	if m.LocateDownloadFunc == nil {
+		return nil, nil, ErrNotMocked
+	}
+	return m.LocateDownloadFunc(ctx, path)
+}
+
+func (m *MockClient) PartialDownload(ctx context.Context, path string, start int64, end int64) (*http.Response, error) {
+	m.record("PartialDownload", path, start, end)
+	if m.PartialDownloadFunc == nil {
+		return nil, ErrNotMocked
+	}
+	return m.PartialDownloadFunc(ctx, path, start, end)
+}
+
+func (m *MockClient) Mkdir(ctx context.Context, path string) (*pcs.File, *http.Response, error) {
+	m.record("Mkdir", path)
+	if m.MkdirFunc == nil {
+		return nil, nil, ErrNotMocked
+	}
+	return m.MkdirFunc(ctx, path)
+}
+
+func (m *MockClient) GetMeta(ctx context.Context, path string) (*pcs.FileMeta, *http.Response, error) {
+	m.record("GetMeta", path)
+	if m.GetMetaFunc == nil {
+		return nil, nil, ErrNotMocked
+	}
+	return m.GetMetaFunc(ctx, path)
+}
+
+func (m *MockClient) BatchGetMeta(ctx context.Context, paths []string) ([]*pcs.FileMeta, *http.Response, error) {
+	m.record("BatchGetMeta", paths)
+	if m.BatchGetMetaFunc == nil {
+		return nil, nil, ErrNotMocked
+	}
+	return m.BatchGetMetaFunc(ctx, paths)
+}
+
+func (m *MockClient) ListFiles(ctx context.Context, opt *pcs.ListFilesOptions) ([]*pcs.File, *http.Response, error) {
+	m.record("ListFiles", opt)
+	if m.ListFilesFunc == nil {
+		return nil, nil, ErrNotMocked
+	}
+	return m.ListFilesFunc(ctx, opt)
+}
+
+func (m *MockClient) Move(ctx context.Context, from string, to string) (*pcs.MoveCopyResponse, *http.Response, error) {
+	m.record("Move", from, to)
+	if m.MoveFunc == nil {
+		return nil, nil, ErrNotMocked
+	}
+	return m.MoveFunc(ctx, from, to)
+}
+
+func (m *MockClient) Copy(ctx context.Context, from string, to string) (*pcs.MoveCopyResponse, *http.Response, error) {
+	m.record("Copy", from, to)
+	if m.CopyFunc == nil {
+		return nil, nil, ErrNotMocked
+	}
+	return m.CopyFunc(ctx, from, to)
+}
+
+func (m *MockClient) Delete(ctx context.Context, path string) (*http.Response, error) {
+	m.record("Delete", path)
+	if m.DeleteFunc == nil {
+		return nil, ErrNotMocked
+	}
+	return m.DeleteFunc(ctx, path)
+}
+
+func (m *MockClient) BatchMove(ctx context.Context, pairs []*pcs.FTPair) (*pcs.MoveCopyResponse, *http.Response, error) {
+	m.record("BatchMove", pairs)
+	if m.BatchMoveFunc == nil {
+		return nil, nil, ErrNotMocked
+	}
+	return m.BatchMoveFunc(ctx, pairs)
+}
+
+func (m *MockClient) BatchCopy(ctx context.Context, pairs []*pcs.FTPair) (*pcs.MoveCopyResponse, *http.Response, error) {
+	m.record("BatchCopy", pairs)
+	if m.BatchCopyFunc == nil {
+		return nil, nil, ErrNotMocked
+	}
+	return m.BatchCopyFunc(ctx, pairs)
+}
+
+func (m *MockClient) BatchDelete(ctx context.Context, paths []string) (*http.Response, error) {
+	m.record("BatchDelete", paths)
+	if m.BatchDeleteFunc == nil {
+		return nil, ErrNotMocked
+	}
+	return m.BatchDeleteFunc(ctx, paths)
+}
+
+func (m *MockClient) Search(ctx context.Context, opt *pcs.SearchOptions) ([]*pcs.File, *http.Response, error) {
+	m.record("Search", opt)
+	if m.SearchFunc == nil {
+		return nil, nil, ErrNotMocked
+	}
+	return m.SearchFunc(ctx, opt)
+}
+
+func (m *MockClient) Thumbnail(ctx context.Context, opt *pcs.ThumbnailOptions) (*http.Response, error) {
+	m.record("Thumbnail", opt)
+	if m.ThumbnailFunc == nil {
+		return nil, ErrNotMocked
+	}
+	return m.ThumbnailFunc(ctx, opt)
+}
+
+func (m *MockClient) Diff(ctx context.Context, cursor string) (*http.Response, error) {
+	m.record("Diff", cursor)
+	if m.DiffFunc == nil {
+		return nil, ErrNotMocked
+	}
+	return m.DiffFunc(ctx, cursor)
+}
+
+func (m *MockClient) Streaming(ctx context.Context, path string, typ string) (*http.Response, error) {
+	m.record("Streaming", path, typ)
+	if m.StreamingFunc == nil {
+		return nil, ErrNotMocked
+	}
+	return m.StreamingFunc(ctx, path, typ)
+}
+
+func (m *MockClient) ListStream(ctx context.Context, opt *pcs.ListStreamOptions) (*pcs.StreamFile, *http.Response, error) {
+	m.record("ListStream", opt)
+	if m.ListStreamFunc == nil {
+		return nil, nil, ErrNotMocked
+	}
+	return m.ListStreamFunc(ctx, opt)
+}
+
+func (m *MockClient) DownloadStream(ctx context.Context, path string) (*http.Response, error) {
+	m.record("DownloadStream", path)
+	if m.DownloadStreamFunc == nil {
+		return nil, ErrNotMocked
+	}
+	return m.DownloadStreamFunc(ctx, path)
+}
+
+func (m *MockClient) QueryTorrentInfo(ctx context.Context, sourcePath string) (*pcs.TorrentInfo, *http.Response, error) {
+	m.record("QueryTorrentInfo", sourcePath)
+	if m.QueryTorrentInfoFunc == nil {
+		return nil, nil, ErrNotMocked
+	}
+	return m.QueryTorrentInfoFunc(ctx, sourcePath)
+}
+
+func (m *MockClient) AddOfflineDownloadTask(ctx context.Context, opt *pcs.AddTaskOptions) (int64, *http.Response, error) {
+	m.record("AddOfflineDownloadTask", opt)
+	if m.AddOfflineDownloadTaskFunc == nil {
+		return 0, nil, ErrNotMocked
+	}
+	return m.AddOfflineDownloadTaskFunc(ctx, opt)
+}
+
+func (m *MockClient) QueryOfflineDownloadTask(ctx context.Context, opt *pcs.QueryTaskOptions) (*http.Response, error) {
+	m.record("QueryOfflineDownloadTask", opt)
+	if m.QueryOfflineDownloadTaskFunc == nil {
+		return nil, ErrNotMocked
+	}
+	return m.QueryOfflineDownloadTaskFunc(ctx, opt)
+}
+
+func (m *MockClient) ListOfflineDownloadTask(ctx context.Context, opt *pcs.ListTaskOptions) (*http.Response, error) {
+	m.record("ListOfflineDownloadTask", opt)
+	if m.ListOfflineDownloadTaskFunc == nil {
+		return nil, ErrNotMocked
+	}
+	return m.ListOfflineDownloadTaskFunc(ctx, opt)
+}
+
+func (m *MockClient) CancelOfflineDownloadTask(ctx context.Context, opt *pcs.CancelTaskOptions) (*http.Response, error) {
+	m.record("CancelOfflineDownloadTask", opt)
+	if m.CancelOfflineDownloadTaskFunc == nil {
+		return nil, ErrNotMocked
+	}
+	return m.CancelOfflineDownloadTaskFunc(ctx, opt)
+}
+
+func (m *MockClient) ListRecycle(ctx context.Context, opt *pcs.ListRecycleOptions) (*pcs.ListRecycleResponse, *http.Response, error) {
+	m.record("ListRecycle", opt)
+	if m.ListRecycleFunc == nil {
+		return nil, nil, ErrNotMocked
+	}
+	return m.ListRecycleFunc(ctx, opt)
+}
+
+func (m *MockClient) Restore(ctx context.Context, fsId string) (*pcs.RestoreResponse, *http.Response, error) {
+	m.record("Restore", fsId)
+	if m.RestoreFunc == nil {
+		return nil, nil, ErrNotMocked
+	}
+	return m.RestoreFunc(ctx, fsId)
+}
+
+func (m *MockClient) BatchRestore(ctx context.Context, fsIds []string) (*pcs.RestoreResponse, *http.Response, error) {
+	m.record("BatchRestore", fsIds)
+	if m.BatchRestoreFunc == nil {
+		return nil, nil, ErrNotMocked
+	}
+	return m.BatchRestoreFunc(ctx, fsIds)
+}
+
+func (m *MockClient) EmptyRecycle(ctx context.Context) (*http.Response, error) {
+	m.record("EmptyRecycle")
+	if m.EmptyRecycleFunc == nil {
+		return nil, ErrNotMocked
+	}
+	return m.EmptyRecycleFunc(ctx)
+}
+
+func (m *MockClient) OpenShare(ctx context.Context, link string, pwd string) (*pcs.Share, *http.Response, error) {
+	m.record("OpenShare", link, pwd)
+	if m.OpenShareFunc == nil {
+		return nil, nil, ErrNotMocked
+	}
+	return m.OpenShareFunc(ctx, link, pwd)
+}
+
+func (m *MockClient) ListShare(ctx context.Context, s *pcs.Share, dir string) ([]pcs.ShareFile, *http.Response, error) {
+	m.record("ListShare", s, dir)
+	if m.ListShareFunc == nil {
+		return nil, nil, ErrNotMocked
+	}
+	return m.ListShareFunc(ctx, s, dir)
+}
+
+func (m *MockClient) SaveShare(ctx context.Context, s *pcs.Share, dest string, fsids ...uint64) (*http.Response, error) {
+	m.record("SaveShare", s, dest, fsids)
+	if m.SaveShareFunc == nil {
+		return nil, ErrNotMocked
+	}
+	return m.SaveShareFunc(ctx, s, dest, fsids...)
+}
+
+func (m *MockClient) CreateShare(ctx context.Context, pwd string, period int, paths ...string) (*pcs.ShareLink, *http.Response, error) {
+	m.record("CreateShare", pwd, period, paths)
+	if m.CreateShareFunc == nil {
+		return nil, nil, ErrNotMocked
+	}
+	return m.CreateShareFunc(ctx, pwd, period, paths...)
+}
+
+func (m *MockClient) ListShareLinks(ctx context.Context) ([]*pcs.ShareLink, *http.Response, error) {
+	m.record("ListShareLinks")
+	if m.ListShareLinksFunc == nil {
+		return nil, nil, ErrNotMocked
+	}
+	return m.ListShareLinksFunc(ctx)
+}
+
+func (m *MockClient) CancelShares(ctx context.Context, ids ...uint64) (*http.Response, error) {
+	m.record("CancelShares", ids)
+	if m.CancelSharesFunc == nil {
+		return nil, ErrNotMocked
+	}
+	return m.CancelSharesFunc(ctx, ids...)
+}
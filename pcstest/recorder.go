@@ -0,0 +1,249 @@
+package pcstest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Mode selects whether a Recorder talks to the network or to its fixtures.
+type Mode int
+
+const (
+	// Replay serves responses from previously recorded fixtures.
+	Replay Mode = iota
+
+	// Record forwards requests to the real transport and saves every
+	// exchange as a fixture.
+	Record
+)
+
+// scrubbed replaces credentials in recorded fixtures.
+const scrubbed = "REDACTED"
+
+// ErrNoFixture is returned by a replaying Recorder when no fixture matches
+// the request.
+var ErrNoFixture = errors.New("pcstest: no recorded fixture for request")
+
+// ErrFixtureMismatch is returned by a replaying Recorder when a request
+// differs from the one recorded in its fixture, in method, path, query
+// parameters or form body. Hosts and access tokens are not compared.
+var ErrFixtureMismatch = errors.New("pcstest: request does not match the recorded fixture")
+
+// Exchange is a single recorded request/response pair as stored on disk.
+type Exchange struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+type RecordedRequest struct {
+	Method string `json:"method"`
+	URL    string `json:"url"`
+	Body   string `json:"body,omitempty"`
+}
+
+type RecordedResponse struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header"`
+	Body       string      `json:"body,omitempty"`
+	BodyBytes  []byte      `json:"body_bytes,omitempty"` // used when Body is not valid UTF-8
+}
+
+// Recorder is an http.RoundTripper that records API exchanges into golden
+// files under Dir, or replays them, depending on Mode. Access tokens are
+// scrubbed before anything is written to disk.
+//
+// Fixtures are named after the PCS service and method of the request plus a
+// sequence number, e.g. "file.list.0.json", so a test replays the same
+// sequence of calls it recorded. A replayed request must match the recorded
+// one, see ErrFixtureMismatch.
+type Recorder struct {
+	Mode Mode
+	Dir  string
+
+	// Transport is used in Record mode. If nil, http.DefaultTransport is used.
+	Transport http.RoundTripper
+
+	mu  sync.Mutex
+	seq map[string]int
+}
+
+// NewRecorder returns a Recorder storing fixtures in dir.
+func NewRecorder(dir string, mode Mode) *Recorder {
+	return &Recorder{Mode: mode, Dir: dir, seq: make(map[string]int)}
+}
+
+// HTTPClient returns an http.Client using r as its transport, suitable for
+// pcs.WithHTTPClient.
+func (r *Recorder) HTTPClient() *http.Client {
+	return &http.Client{Transport: r}
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	name := r.fixtureName(req)
+	if r.Mode == Replay {
+		return r.replay(req, name)
+	}
+	return r.record(req, name)
+}
+
+func (r *Recorder) fixtureName(req *http.Request) string {
+	service := strings.Trim(req.URL.Path, "/")
+	if i := strings.LastIndex(service, "/"); i >= 0 {
+		service = service[i+1:]
+	}
+	key := service + "." + req.URL.Query().Get("method")
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.seq == nil {
+		r.seq = make(map[string]int)
+	}
+	n := r.seq[key]
+	r.seq[key] = n + 1
+	return fmt.Sprintf("%s.%d.json", key, n)
+}
+
+func (r *Recorder) replay(req *http.Request, name string) (*http.Response, error) {
+	data, err := ioutil.ReadFile(filepath.Join(r.Dir, name))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%w: %s", ErrNoFixture, name)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var ex Exchange
+	if err := json.Unmarshal(data, &ex); err != nil {
+		return nil, fmt.Errorf("pcstest: bad fixture %s: %v", name, err)
+	}
+	if err := ex.Request.match(req); err != nil {
+		return nil, fmt.Errorf("%w %s: %v", ErrFixtureMismatch, name, err)
+	}
+
+	body := ex.Response.BodyBytes
+	if body == nil {
+		body = []byte(ex.Response.Body)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", ex.Response.StatusCode, http.StatusText(ex.Response.StatusCode)),
+		StatusCode:    ex.Response.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        ex.Response.Header,
+		Body:          ioutil.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+// match reports how req differs from the recorded request, if at all.
+func (rr *RecordedRequest) match(req *http.Request) error {
+	if req.Method != rr.Method {
+		return fmt.Errorf("method %s, recorded %s", req.Method, rr.Method)
+	}
+	u, err := url.Parse(rr.URL)
+	if err != nil {
+		return err
+	}
+	if req.URL.Path != u.Path {
+		return fmt.Errorf("path %s, recorded %s", req.URL.Path, u.Path)
+	}
+	got, want := req.URL.Query(), u.Query()
+	got.Del("access_token")
+	want.Del("access_token")
+	if !reflect.DeepEqual(got, want) {
+		return fmt.Errorf("query %s, recorded %s", got.Encode(), want.Encode())
+	}
+	if rr.Body == "" {
+		return nil
+	}
+	var body []byte
+	if req.Body != nil {
+		body, err = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return err
+		}
+	}
+	gotForm, err1 := url.ParseQuery(string(body))
+	wantForm, err2 := url.ParseQuery(rr.Body)
+	if err1 != nil || err2 != nil || !reflect.DeepEqual(gotForm, wantForm) {
+		return fmt.Errorf("body %s, recorded %s", body, rr.Body)
+	}
+	return nil
+}
+
+func (r *Recorder) record(req *http.Request, name string) (*http.Response, error) {
+	var reqBody []byte
+	if req.Body != nil {
+		var err error
+		reqBody, err = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = ioutil.NopCloser(bytes.NewReader(reqBody))
+	}
+
+	tr := r.Transport
+	if tr == nil {
+		tr = http.DefaultTransport
+	}
+	resp, err := tr.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(respBody))
+
+	u := *req.URL
+	q := u.Query()
+	if q.Get("access_token") != "" {
+		q.Set("access_token", scrubbed)
+	}
+	u.RawQuery = q.Encode()
+
+	header := resp.Header.Clone()
+	header.Del("Set-Cookie")
+
+	ex := Exchange{
+		Request:  RecordedRequest{Method: req.Method, URL: u.String()},
+		Response: RecordedResponse{StatusCode: resp.StatusCode, Header: header},
+	}
+	if strings.HasPrefix(req.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+		ex.Request.Body = string(reqBody)
+	}
+	if utf8.Valid(respBody) {
+		ex.Response.Body = string(respBody)
+	} else {
+		ex.Response.BodyBytes = respBody
+	}
+
+	data, err := json.MarshalIndent(&ex, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(r.Dir, 0755); err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(filepath.Join(r.Dir, name), data, 0644); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
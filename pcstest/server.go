@@ -0,0 +1,1480 @@
+// Package pcstest provides an in-memory fake of the Baidu PCS REST API for
+// use in tests of applications built on top of package pcs.
+//
+// A Server keeps a small virtual file system and offline download task table
+// and speaks the same JSON dialect as the real service, including
+// error_code/error_msg payloads, so client code can be exercised end to end
+// without network access:
+//
+//	srv := pcstest.NewServer()
+//	defer srv.Close()
+//
+//	client := srv.NewClient()
+//	quota, _, err := client.GetQuota(context.Background())
+//
+// Code that depends on the pcs.API interface rather than *pcs.Client can use
+// MockClient instead and stub individual methods.
+package pcstest
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/holys/baidu-pcs/v2"
+)
+
+const (
+	// DefaultToken is the access token accepted by a Server created with
+	// NewServer.
+	DefaultToken = "pcstest-token"
+
+	// DefaultQuota is the initial quota of a Server, in bytes.
+	DefaultQuota = 2 << 40
+
+	apiPrefix      = "/rest/2.0/pcs/"
+	panPrefix      = "/rest/2.0/xpan/"
+	cloudDLPath    = "/rest/2.0/services/cloud_dl"
+	minRapidUpload = 256 * 1024
+	listPageSize   = 1000 // entries returned by list without a limit
+)
+
+// Errno values of the pan.baidu.com share endpoints produced by the fake
+// server.
+const (
+	ErrnoInvalidParam  = 2
+	ErrnoWrongPassword = -9
+	ErrnoFileExists    = 12
+)
+
+// Baidu PCS error codes produced by the fake server.
+const (
+	CodeInvalidToken   = 110
+	CodeInvalidParam   = 31023
+	CodeFileExists     = 31061
+	CodeFileNotExist   = 31066
+	CodeMd5NotFound    = 31079
+	CodeUnknownMethod  = 3
+	CodeTaskNotExist   = 36016
+	CodeFrequencyLimit = 31034
+)
+
+var errorMessages = map[int]string{
+	CodeInvalidToken:   "Access token invalid or no longer valid",
+	CodeInvalidParam:   "param error",
+	CodeFileExists:     "file already exists",
+	CodeFileNotExist:   "file does not exist",
+	CodeMd5NotFound:    "file md5 not found, you should use upload API to upload the whole file.",
+	CodeUnknownMethod:  "Unsupported openapi method",
+	CodeTaskNotExist:   "task not exist",
+	CodeFrequencyLimit: "hit frequency limit",
+}
+
+type node struct {
+	pcs.File
+	data   []byte
+	blocks []string // block_list of files created with createsuperfile
+}
+
+type task struct {
+	ID         int64
+	SourceURL  string
+	SourcePath string // torrent tasks
+	Selected   string
+	SavePath   string
+	Status     int
+	CreateTime int64
+}
+
+// share is a snapshot of shared files, as seen by the users it is shared with.
+type share struct {
+	id     uint64
+	uk     uint64 // owner
+	pwd    string
+	sekey  string
+	link   string
+	ctime  int64
+	expire int64 // 0 for permanent shares
+	roots  []string
+	files  map[string]*node
+}
+
+// change is an entry of the log served by the diff endpoint.
+type change struct {
+	file    pcs.File
+	deleted bool
+}
+
+type failure struct {
+	status     int
+	code       int
+	retryAfter string
+}
+
+// Server is a fake PCS endpoint backed by an httptest.Server. All hosts
+// (API, upload and download) are served by the same listener.
+type Server struct {
+	*httptest.Server
+
+	// Token is the access token that requests must carry.
+	Token string
+
+	mu       sync.Mutex
+	quota    uint64
+	user     pcs.UserInfo
+	files    map[string]*node
+	blocks   map[string][]byte
+	tasks    map[int64]*task
+	torrents map[string][]pcs.TorrentFile
+	recycle  map[uint64][]*node // deleted subtrees by the fs_id of their root
+	shares   map[string]*share  // by short url
+	failures map[string][]failure
+	corrupt  int      // uploads to damage in transit, see CorruptNextUpload
+	changes  []change // cursors are offsets into the log
+	nextID   uint64
+	nextTask int64
+
+	nextShare uint64
+}
+
+// NewServer starts and returns a new Server with an empty root directory.
+// The caller should call Close when finished, to shut it down.
+func NewServer() *Server {
+	s := &Server{
+		Token:    DefaultToken,
+		quota:    DefaultQuota,
+		user:     pcs.UserInfo{BaiduName: "pcstest", NetdiskName: "pcstest", UK: 1},
+		files:    make(map[string]*node),
+		blocks:   make(map[string][]byte),
+		tasks:    make(map[int64]*task),
+		torrents: make(map[string][]pcs.TorrentFile),
+		recycle:  make(map[uint64][]*node),
+		shares:   make(map[string]*share),
+		failures: make(map[string][]failure),
+		nextID:   1,
+		nextTask: 1,
+
+		nextShare: 1,
+	}
+	s.files["/"] = &node{File: pcs.File{Path: "/", IsDir: 1}}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// NewClient returns a pcs.Client whose base, upload and download URLs all
+// point at s.
+func (s *Server) NewClient(opts ...pcs.ClientOption) *pcs.Client {
+	c := pcs.NewClient(s.Token, opts...)
+	u, _ := url.Parse(s.URL + apiPrefix)
+	pan, _ := url.Parse(s.URL + panPrefix)
+	c.SetEndpoints(pcs.EndpointSet{Name: "pcstest", Base: u, Upload: u, Download: u, Pan: pan})
+	return c
+}
+
+// SetUserInfo changes the account information reported by the server.
+func (s *Server) SetUserInfo(info pcs.UserInfo) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.user = info
+}
+
+// SetQuota changes the total quota reported by the server.
+func (s *Server) SetQuota(quota uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.quota = quota
+}
+
+// PutFile stores data at the absolute path p, creating parent directories
+// as needed, and returns the resulting file entry.
+func (s *Server) PutFile(p string, data []byte) *pcs.File {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f := s.putFile(path.Clean(p), data)
+	return &f.File
+}
+
+// Mkdir creates the directory p and its parents.
+func (s *Server) Mkdir(p string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.mkdirAll(path.Clean(p))
+}
+
+// ReadFile returns the content stored at p and whether it exists.
+func (s *Server) ReadFile(p string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n, ok := s.files[path.Clean(p)]
+	if !ok || n.IsDir == 1 {
+		return nil, false
+	}
+	return append([]byte(nil), n.data...), true
+}
+
+// Exists reports whether a file or directory exists at p.
+func (s *Server) Exists(p string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.files[path.Clean(p)]
+	return ok
+}
+
+// CorruptNextUpload flips a byte of the file data in the next upload,
+// simulating damage in transit. Requests with a Content-MD5 header are then
+// rejected with http.StatusBadRequest and CodeInvalidParam; others store the
+// damaged data. Calls queue up when made repeatedly.
+func (s *Server) CorruptNextUpload() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.corrupt++
+}
+
+// FailNext makes the next request to service/method (for example "file" and
+// "upload", "cloud_dl" and "add_task", or "xpan/nas" and "uinfo") fail with the given HTTP status and
+// Baidu error code. Failures queue up when called repeatedly.
+func (s *Server) FailNext(service, method string, status, code int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := service + "/" + method
+	s.failures[key] = append(s.failures[key], failure{status: status, code: code})
+}
+
+// ThrottleNext makes the next request to service/method fail with 429 Too
+// Many Requests and CodeFrequencyLimit, asking the client to retry after d.
+func (s *Server) ThrottleNext(service, method string, d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := service + "/" + method
+	s.failures[key] = append(s.failures[key], failure{
+		status:     http.StatusTooManyRequests,
+		code:       CodeFrequencyLimit,
+		retryAfter: strconv.Itoa(int(d / time.Second)),
+	})
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	var service string
+	switch {
+	case r.URL.Path == cloudDLPath:
+		service = "cloud_dl"
+	case strings.HasPrefix(r.URL.Path, apiPrefix):
+		service = strings.TrimPrefix(r.URL.Path, apiPrefix)
+	case strings.HasPrefix(r.URL.Path, panPrefix):
+		service = "xpan/" + strings.TrimPrefix(r.URL.Path, panPrefix)
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil && !strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/") {
+		writeError(w, http.StatusBadRequest, CodeInvalidParam)
+		return
+	}
+	q := r.URL.Query()
+	if q.Get("access_token") != s.Token {
+		writeError(w, http.StatusUnauthorized, CodeInvalidToken)
+		return
+	}
+	method := q.Get("method")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := service + "/" + method
+	if fs := s.failures[key]; len(fs) > 0 {
+		s.failures[key] = fs[1:]
+		if fs[0].retryAfter != "" {
+			w.Header().Set("Retry-After", fs[0].retryAfter)
+		}
+		writeError(w, fs[0].status, fs[0].code)
+		return
+	}
+
+	switch key {
+	case "quota/info":
+		s.quotaInfo(w)
+	case "xpan/nas/uinfo":
+		writeJSON(w, s.user)
+	case "xpan/share/verify":
+		s.verifyShare(w, r)
+	case "xpan/share/list":
+		s.listShare(w, r)
+	case "xpan/share/transfer":
+		s.transferShare(w, r)
+	case "xpan/share/set":
+		s.setShare(w, r)
+	case "xpan/share/record":
+		s.shareRecord(w, r)
+	case "xpan/share/cancel":
+		s.cancelShare(w, r)
+	case "file/upload":
+		s.upload(w, r)
+	case "file/createsuperfile":
+		s.createSuperFile(w, r)
+	case "file/rapidupload":
+		s.rapidUpload(w, r)
+	case "file/locateupload":
+		s.locateUpload(w)
+	case "file/locatedownload":
+		s.locateDownload(w, r)
+	case "file/download":
+		s.download(w, r)
+	case "file/streaming":
+		s.streaming(w, r)
+	case "file/mkdir":
+		s.mkdir(w, r)
+	case "file/meta":
+		s.meta(w, r)
+	case "file/list":
+		s.list(w, r)
+	case "file/search":
+		s.search(w, r)
+	case "file/move", "file/copy":
+		s.moveCopy(w, r, method == "move")
+	case "file/delete":
+		s.delete(w, r)
+	case "file/restore":
+		s.restore(w, r)
+	case "file/listrecycle":
+		s.listRecycle(w, r)
+	case "stream/list":
+		s.listStream(w, r)
+	case "thumbnail/generate":
+		s.thumbnail(w, r)
+	case "file/diff":
+		s.diff(w, r)
+	case "cloud_dl/add_task":
+		s.addTask(w, r)
+	case "cloud_dl/query_task":
+		s.queryTask(w, r)
+	case "cloud_dl/list_task":
+		s.listTask(w, r)
+	case "cloud_dl/cancel_task":
+		s.cancelTask(w, r)
+	case "cloud_dl/query_sinfo":
+		s.querySinfo(w, r)
+	default:
+		writeError(w, http.StatusBadRequest, CodeUnknownMethod)
+	}
+}
+
+// SetTaskStatus sets the status of offline download task id, e.g. to
+// pcs.TaskSuccess to finish it. It reports whether the task exists.
+func (s *Server) SetTaskStatus(id int64, status int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.tasks[id]
+	if ok {
+		t.Status = status
+	}
+	return ok
+}
+
+// Share shares the files and directories at paths with password pwd, as
+// another user with uk 2 would, and returns the share link. The link
+// serves a snapshot: later changes to the files are not visible through it.
+func (s *Server) Share(pwd string, paths ...string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.share(2, pwd, paths).link
+}
+
+func (s *Server) share(uk uint64, pwd string, paths []string) *share {
+	sh := &share{
+		id:    s.nextShare,
+		uk:    uk,
+		pwd:   pwd,
+		sekey: fmt.Sprintf("sekey-%d", s.nextShare),
+		ctime: time.Now().Unix(),
+		files: make(map[string]*node),
+	}
+	s.nextShare++
+	for _, p := range paths {
+		p = path.Clean(p)
+		sh.roots = append(sh.roots, p)
+		for _, n := range s.subtree(p) {
+			c := *n
+			sh.files[n.Path] = &c
+		}
+	}
+	surl := fmt.Sprintf("pcstest%d", sh.id)
+	sh.link = "https://pan.baidu.com/s/1" + surl
+	s.shares[surl] = sh
+	return sh
+}
+
+func shareEntry(n *node) map[string]interface{} {
+	return map[string]interface{}{
+		"fs_id":           n.FsId,
+		"path":            n.Path,
+		"server_filename": path.Base(n.Path),
+		"size":            n.Size,
+		"isdir":           n.IsDir,
+	}
+}
+
+func (s *Server) verifyShare(w http.ResponseWriter, r *http.Request) {
+	sh, ok := s.shares[r.URL.Query().Get("surl")]
+	if !ok {
+		writeErrno(w, ErrnoInvalidParam)
+		return
+	}
+	if r.PostForm.Get("pwd") != sh.pwd {
+		writeErrno(w, ErrnoWrongPassword)
+		return
+	}
+	writeJSON(w, map[string]interface{}{"errno": 0, "randsk": sh.sekey})
+}
+
+func (s *Server) listShare(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	sh, ok := s.shares[q.Get("shorturl")]
+	if !ok || q.Get("sekey") != sh.sekey {
+		writeErrno(w, ErrnoInvalidParam)
+		return
+	}
+	list := []map[string]interface{}{}
+	if q.Get("root") == "1" {
+		for _, p := range sh.roots {
+			list = append(list, shareEntry(sh.files[p]))
+		}
+	} else {
+		dir := path.Clean(q.Get("dir"))
+		if n, ok := sh.files[dir]; !ok || n.IsDir == 0 {
+			writeErrno(w, ErrnoInvalidParam)
+			return
+		}
+		for p, n := range sh.files {
+			if path.Dir(p) == dir {
+				list = append(list, shareEntry(n))
+			}
+		}
+	}
+	writeJSON(w, map[string]interface{}{"errno": 0, "share_id": sh.id, "uk": sh.uk, "list": list})
+}
+
+func (s *Server) transferShare(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	var sh *share
+	for _, v := range s.shares {
+		if strconv.FormatUint(v.id, 10) == q.Get("shareid") {
+			sh = v
+		}
+	}
+	var fsids []uint64
+	dest := path.Clean(r.PostForm.Get("path"))
+	if sh == nil || q.Get("sekey") != sh.sekey || q.Get("from") != strconv.FormatUint(sh.uk, 10) || !path.IsAbs(dest) ||
+		json.Unmarshal([]byte(r.PostForm.Get("fsidlist")), &fsids) != nil || len(fsids) == 0 {
+		writeErrno(w, ErrnoInvalidParam)
+		return
+	}
+
+	var roots []*node
+	for _, id := range fsids {
+		var found *node
+		for _, n := range sh.files {
+			if n.FsId == id {
+				found = n
+			}
+		}
+		if found == nil {
+			writeErrno(w, ErrnoInvalidParam)
+			return
+		}
+		if _, ok := s.files[path.Join(dest, path.Base(found.Path))]; ok {
+			writeErrno(w, ErrnoFileExists)
+			return
+		}
+		roots = append(roots, found)
+	}
+
+	s.mkdirAll(dest)
+	for _, root := range roots {
+		target := path.Join(dest, path.Base(root.Path))
+		for p, n := range sh.files {
+			if p != root.Path && !isUnder(p, root.Path) {
+				continue
+			}
+			to := target + strings.TrimPrefix(p, root.Path)
+			if n.IsDir == 1 {
+				s.mkdirAll(to)
+			} else {
+				s.putFile(to, n.data)
+			}
+		}
+	}
+	writeJSON(w, map[string]interface{}{"errno": 0})
+}
+
+func (s *Server) setShare(w http.ResponseWriter, r *http.Request) {
+	var fsids []uint64
+	period, err := strconv.Atoi(r.PostForm.Get("period"))
+	if err != nil || len(r.PostForm.Get("pwd")) != 4 ||
+		json.Unmarshal([]byte(r.PostForm.Get("fid_list")), &fsids) != nil || len(fsids) == 0 {
+		writeErrno(w, ErrnoInvalidParam)
+		return
+	}
+	var paths []string
+	for _, id := range fsids {
+		var found *node
+		for _, n := range s.files {
+			if n.FsId == id {
+				found = n
+			}
+		}
+		if found == nil {
+			writeErrno(w, ErrnoInvalidParam)
+			return
+		}
+		paths = append(paths, found.Path)
+	}
+
+	sh := s.share(s.user.UK, r.PostForm.Get("pwd"), paths)
+	if period > 0 {
+		sh.expire = sh.ctime + int64(period)*24*3600
+	}
+	writeJSON(w, map[string]interface{}{
+		"errno":      0,
+		"shareid":    sh.id,
+		"link":       sh.link,
+		"ctime":      sh.ctime,
+		"expiretime": sh.expire,
+	})
+}
+
+// shareRecord lists the shares of the user, newest first.
+func (s *Server) shareRecord(w http.ResponseWriter, r *http.Request) {
+	var own []*share
+	for _, sh := range s.shares {
+		if sh.uk == s.user.UK {
+			own = append(own, sh)
+		}
+	}
+	sort.Slice(own, func(i, j int) bool { return own[i].id > own[j].id })
+
+	q := r.URL.Query()
+	page, _ := strconv.Atoi(q.Get("page"))
+	num, _ := strconv.Atoi(q.Get("num"))
+	if page < 1 || num < 1 {
+		writeErrno(w, ErrnoInvalidParam)
+		return
+	}
+	start := min((page-1)*num, len(own))
+	end := min(start+num, len(own))
+
+	list := []map[string]interface{}{}
+	for _, sh := range own[start:end] {
+		fsids := make([]uint64, len(sh.roots))
+		for i, p := range sh.roots {
+			fsids[i] = sh.files[p].FsId
+		}
+		list = append(list, map[string]interface{}{
+			"shareId":     sh.id,
+			"shortlink":   sh.link,
+			"passwd":      sh.pwd,
+			"fsIds":       fsids,
+			"typicalPath": sh.roots[0],
+			"ctime":       sh.ctime,
+			"expiredTime": sh.expire,
+		})
+	}
+	writeJSON(w, map[string]interface{}{"errno": 0, "list": list, "count": len(own)})
+}
+
+func (s *Server) cancelShare(w http.ResponseWriter, r *http.Request) {
+	var ids []uint64
+	if json.Unmarshal([]byte(r.PostForm.Get("shareid_list")), &ids) != nil || len(ids) == 0 {
+		writeErrno(w, ErrnoInvalidParam)
+		return
+	}
+	for _, id := range ids {
+		found := false
+		for surl, sh := range s.shares {
+			if sh.id == id && sh.uk == s.user.UK {
+				delete(s.shares, surl)
+				found = true
+			}
+		}
+		if !found {
+			writeErrno(w, ErrnoInvalidParam)
+			return
+		}
+	}
+	writeJSON(w, map[string]interface{}{"errno": 0})
+}
+
+func (s *Server) quotaInfo(w http.ResponseWriter) {
+	var used uint64
+	for _, n := range s.files {
+		used += n.Size
+	}
+	writeJSON(w, map[string]interface{}{"quota": s.quota, "used": used})
+}
+
+func (s *Server) upload(w http.ResponseWriter, r *http.Request) {
+	raw, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, CodeInvalidParam)
+		return
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(raw))
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, CodeInvalidParam)
+		return
+	}
+	defer file.Close()
+	data, err := ioutil.ReadAll(file)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, CodeInvalidParam)
+		return
+	}
+
+	if s.corrupt > 0 && len(data) > 0 {
+		s.corrupt--
+		if i := bytes.Index(raw, data); i >= 0 {
+			raw[i+len(data)/2] ^= 0xff
+		}
+		data[len(data)/2] ^= 0xff
+	}
+	if want := r.Header.Get("Content-MD5"); want != "" {
+		sum := md5.Sum(raw)
+		if base64.StdEncoding.EncodeToString(sum[:]) != want {
+			writeError(w, http.StatusBadRequest, CodeInvalidParam)
+			return
+		}
+	}
+
+	if r.URL.Query().Get("type") == "tmpfile" {
+		sum := md5hex(data)
+		s.blocks[sum] = data
+		writeJSON(w, map[string]string{"md5": sum})
+		return
+	}
+
+	p, ok := s.targetPath(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, &s.putFile(p, data).File)
+}
+
+func (s *Server) createSuperFile(w http.ResponseWriter, r *http.Request) {
+	var param struct {
+		BlockList []string `json:"block_list"`
+	}
+	if err := json.Unmarshal([]byte(r.PostForm.Get("param")), &param); err != nil || len(param.BlockList) == 0 {
+		writeError(w, http.StatusBadRequest, CodeInvalidParam)
+		return
+	}
+
+	var data []byte
+	for _, sum := range param.BlockList {
+		b, ok := s.block(sum)
+		if !ok {
+			writeError(w, http.StatusBadRequest, CodeMd5NotFound)
+			return
+		}
+		// Keep the block available to later super files even when the
+		// file it came from is replaced.
+		s.blocks[sum] = b
+		data = append(data, b...)
+	}
+
+	p, ok := s.targetPath(w, r)
+	if !ok {
+		return
+	}
+	n := s.putFile(p, data)
+	n.blocks = param.BlockList
+	writeJSON(w, &n.File)
+}
+
+// block returns the data of the block with md5 sum: an uploaded tmpfile,
+// or a block of an existing file as listed in its block_list.
+func (s *Server) block(sum string) ([]byte, bool) {
+	if b, ok := s.blocks[sum]; ok {
+		return b, true
+	}
+	for _, n := range s.files {
+		if n.IsDir == 0 && n.blocks == nil && n.Md5 == sum {
+			return n.data, true
+		}
+	}
+	return nil, false
+}
+
+func (s *Server) rapidUpload(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	size, _ := strconv.Atoi(q.Get("content-length"))
+	if size <= minRapidUpload {
+		writeError(w, http.StatusBadRequest, CodeInvalidParam)
+		return
+	}
+
+	var src *node
+	for _, n := range s.files {
+		if n.IsDir == 0 && n.Md5 == q.Get("content-md5") && int(n.Size) == size {
+			src = n
+			break
+		}
+	}
+	if src == nil {
+		writeError(w, http.StatusNotFound, CodeMd5NotFound)
+		return
+	}
+
+	p, ok := s.targetPath(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, &s.putFile(p, src.data).File)
+}
+
+func (s *Server) locateUpload(w http.ResponseWriter) {
+	u, _ := url.Parse(s.URL)
+	writeJSON(w, map[string]interface{}{
+		"host":        u.Host,
+		"servers":     []map[string]string{{"server": s.URL}},
+		"bak_servers": []map[string]string{},
+		"client_ip":   "127.0.0.1",
+		"expire":      60,
+	})
+}
+
+func (s *Server) locateDownload(w http.ResponseWriter, r *http.Request) {
+	p := path.Clean(r.URL.Query().Get("path"))
+	if n, ok := s.files[p]; !ok || n.IsDir == 1 {
+		writeError(w, http.StatusNotFound, CodeFileNotExist)
+		return
+	}
+	u := s.URL + apiPrefix + "file?" + url.Values{
+		"method":       {"download"},
+		"path":         {p},
+		"access_token": {s.Token},
+	}.Encode()
+	writeJSON(w, map[string]interface{}{
+		"urls":      []map[string]string{{"url": u}},
+		"client_ip": "127.0.0.1",
+		"expire":    8,
+	})
+}
+
+func (s *Server) download(w http.ResponseWriter, r *http.Request) {
+	n, ok := s.files[path.Clean(r.URL.Query().Get("path"))]
+	if !ok || n.IsDir == 1 {
+		writeError(w, http.StatusNotFound, CodeFileNotExist)
+		return
+	}
+
+	data := n.data
+	if rng := r.Header.Get("Range"); rng != "" {
+		var start, end int
+		if _, err := fmt.Sscanf(rng, "bytes=%d-%d", &start, &end); err != nil || start > end || start >= len(data) {
+			w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+			return
+		}
+		if end >= len(data) {
+			end = len(data) - 1
+		}
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, len(data)))
+		w.Header().Set("Content-Length", strconv.Itoa(end-start+1))
+		w.WriteHeader(http.StatusPartialContent)
+		data = data[start : end+1]
+	} else {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	}
+
+	// Send the body without the lock, so that a client reading it slowly,
+	// such as pcs.Client.Pipe, does not hold up its other requests. File
+	// data is never modified in place.
+	s.mu.Unlock()
+	defer s.mu.Lock()
+	w.Write(data)
+}
+
+// streaming serves a single segment playlist whose segment is the
+// untranscoded file.
+func (s *Server) streaming(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	p := path.Clean(q.Get("path"))
+	if n, ok := s.files[p]; !ok || n.IsDir == 1 {
+		writeError(w, http.StatusNotFound, CodeFileNotExist)
+		return
+	}
+	if !strings.HasPrefix(q.Get("type"), "M3U8_") {
+		writeError(w, http.StatusBadRequest, CodeInvalidParam)
+		return
+	}
+	segment := fmt.Sprintf("%s%sfile?method=download&access_token=%s&path=%s",
+		s.URL, apiPrefix, url.QueryEscape(s.Token), url.QueryEscape(p))
+	w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+	fmt.Fprintf(w, "#EXTM3U\n#EXT-X-TARGETDURATION:10\n#EXTINF:10,\n%s\n#EXT-X-ENDLIST\n", segment)
+}
+
+func (s *Server) mkdir(w http.ResponseWriter, r *http.Request) {
+	p := path.Clean(r.URL.Query().Get("path"))
+	if _, ok := s.files[p]; ok {
+		writeError(w, http.StatusBadRequest, CodeFileExists)
+		return
+	}
+	writeJSON(w, &s.mkdirAll(p).File)
+}
+
+type meta struct {
+	pcs.File
+	BlockList   string `json:"block_list"`
+	IfHasSubDir uint   `json:"ifhassubdir"`
+}
+
+func (s *Server) meta(w http.ResponseWriter, r *http.Request) {
+	var paths []string
+	if param := r.PostForm.Get("param"); param != "" {
+		var v struct {
+			List []struct {
+				Path string `json:"path"`
+			} `json:"list"`
+		}
+		if err := json.Unmarshal([]byte(param), &v); err != nil {
+			writeError(w, http.StatusBadRequest, CodeInvalidParam)
+			return
+		}
+		for _, e := range v.List {
+			paths = append(paths, e.Path)
+		}
+	} else {
+		paths = append(paths, r.URL.Query().Get("path"))
+	}
+
+	list := make([]*meta, 0, len(paths))
+	for _, p := range paths {
+		n, ok := s.files[path.Clean(p)]
+		if !ok {
+			writeError(w, http.StatusNotFound, CodeFileNotExist)
+			return
+		}
+		m := &meta{File: n.File}
+		if n.IsDir == 0 {
+			blocks := n.blocks
+			if blocks == nil {
+				blocks = []string{n.Md5}
+			}
+			list, _ := json.Marshal(blocks)
+			m.BlockList = string(list)
+		} else {
+			for _, c := range s.children(n.Path) {
+				if c.IsDir == 1 {
+					m.IfHasSubDir = 1
+					break
+				}
+			}
+		}
+		list = append(list, m)
+	}
+	writeJSON(w, map[string]interface{}{"list": list})
+}
+
+func (s *Server) list(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	n, ok := s.files[path.Clean(q.Get("path"))]
+	if !ok || n.IsDir == 0 {
+		writeError(w, http.StatusNotFound, CodeFileNotExist)
+		return
+	}
+
+	files := s.children(n.Path)
+	by, desc := q.Get("by"), q.Get("order") != "asc"
+	sort.SliceStable(files, func(i, j int) bool {
+		a, b := files[i], files[j]
+		var less bool
+		switch by {
+		case "time":
+			less = a.Mtime < b.Mtime
+		case "size":
+			less = a.Size < b.Size
+		default:
+			less = a.Path < b.Path
+		}
+		if desc {
+			return !less
+		}
+		return less
+	})
+
+	if limit := q.Get("limit"); limit != "" {
+		var from, to int
+		if _, err := fmt.Sscanf(limit, "%d-%d", &from, &to); err != nil || from > to {
+			writeError(w, http.StatusBadRequest, CodeInvalidParam)
+			return
+		}
+		if from > len(files) {
+			from = len(files)
+		}
+		if to > len(files) {
+			to = len(files)
+		}
+		files = files[from:to]
+	} else if len(files) > listPageSize {
+		files = files[:listPageSize]
+	}
+	writeJSON(w, map[string]interface{}{"list": files})
+}
+
+func (s *Server) search(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	dir := path.Clean(q.Get("path"))
+	word := q.Get("wd")
+	recursive := q.Get("re") == "1"
+
+	files := []*pcs.File{}
+	for p, n := range s.files {
+		if n.IsDir == 1 || !strings.Contains(path.Base(p), word) {
+			continue
+		}
+		if path.Dir(p) == dir || (recursive && isUnder(p, dir)) {
+			f := n.File
+			files = append(files, &f)
+		}
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	writeJSON(w, map[string]interface{}{"list": files})
+}
+
+func (s *Server) moveCopy(w http.ResponseWriter, r *http.Request, move bool) {
+	var pairs []pcs.FTPair
+	if param := r.PostForm.Get("param"); param != "" {
+		var v struct {
+			List []pcs.FTPair `json:"list"`
+		}
+		if err := json.Unmarshal([]byte(param), &v); err != nil {
+			writeError(w, http.StatusBadRequest, CodeInvalidParam)
+			return
+		}
+		pairs = v.List
+	} else {
+		q := r.URL.Query()
+		pairs = append(pairs, pcs.FTPair{From: q.Get("from"), To: q.Get("to")})
+	}
+
+	for _, p := range pairs {
+		from, to := path.Clean(p.From), path.Clean(p.To)
+		if _, ok := s.files[from]; !ok {
+			writeError(w, http.StatusNotFound, CodeFileNotExist)
+			return
+		}
+		if _, ok := s.files[to]; ok {
+			writeError(w, http.StatusBadRequest, CodeFileExists)
+			return
+		}
+	}
+
+	done := make([]pcs.FTPair, 0, len(pairs))
+	for _, p := range pairs {
+		from, to := path.Clean(p.From), path.Clean(p.To)
+		s.mkdirAll(path.Dir(to))
+		for _, src := range s.subtree(from) {
+			n := *src
+			n.Path = to + strings.TrimPrefix(src.Path, from)
+			n.FsId = s.nextID
+			s.nextID++
+			s.files[n.Path] = &n
+			s.changed(&n, false)
+			if move {
+				delete(s.files, src.Path)
+				if src.Path == from {
+					s.changed(src, true)
+				}
+			}
+		}
+		done = append(done, pcs.FTPair{From: from, To: to})
+	}
+	writeJSON(w, map[string]interface{}{
+		"extra": map[string]interface{}{"list": done},
+	})
+}
+
+func (s *Server) delete(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("type") == "recycle" {
+		s.recycle = make(map[uint64][]*node)
+		writeJSON(w, map[string]interface{}{})
+		return
+	}
+
+	var paths []string
+	if param := r.PostForm.Get("param"); param != "" {
+		var v struct {
+			List []string `json:"list"`
+		}
+		if err := json.Unmarshal([]byte(param), &v); err != nil {
+			writeError(w, http.StatusBadRequest, CodeInvalidParam)
+			return
+		}
+		paths = v.List
+	} else {
+		paths = append(paths, q.Get("path"))
+	}
+
+	for _, p := range paths {
+		p = path.Clean(p)
+		if _, ok := s.files[p]; !ok || p == "/" {
+			writeError(w, http.StatusNotFound, CodeFileNotExist)
+			return
+		}
+	}
+	for _, p := range paths {
+		p = path.Clean(p)
+		root := s.files[p]
+		nodes := s.subtree(p)
+		for _, n := range nodes {
+			delete(s.files, n.Path)
+		}
+		// PCS records a deleted directory as a single change
+		s.changed(root, true)
+		s.recycle[root.FsId] = nodes
+	}
+	writeJSON(w, map[string]interface{}{})
+}
+
+func (s *Server) restore(w http.ResponseWriter, r *http.Request) {
+	var ids []string
+	if param := r.PostForm.Get("param"); param != "" {
+		var v struct {
+			List []struct {
+				FsID string `json:"fs_id"`
+			} `json:"list"`
+		}
+		if err := json.Unmarshal([]byte(param), &v); err != nil {
+			writeError(w, http.StatusBadRequest, CodeInvalidParam)
+			return
+		}
+		for _, item := range v.List {
+			ids = append(ids, item.FsID)
+		}
+	} else {
+		ids = append(ids, r.URL.Query().Get("fs_id"))
+	}
+
+	var restored []map[string]string
+	for _, id := range ids {
+		fsID, _ := strconv.ParseUint(id, 10, 64)
+		nodes, ok := s.recycle[fsID]
+		if !ok {
+			writeError(w, http.StatusNotFound, CodeFileNotExist)
+			return
+		}
+		for _, n := range nodes {
+			if _, ok := s.files[n.Path]; ok && n.IsDir == 0 {
+				writeError(w, http.StatusBadRequest, CodeFileExists)
+				return
+			}
+		}
+		for _, n := range nodes {
+			s.mkdirAll(path.Dir(n.Path))
+			s.files[n.Path] = n
+			s.changed(n, false)
+		}
+		delete(s.recycle, fsID)
+		restored = append(restored, map[string]string{"fs_id": id})
+	}
+	writeJSON(w, map[string]interface{}{"extra": map[string]interface{}{"list": restored}})
+}
+
+func (s *Server) listRecycle(w http.ResponseWriter, r *http.Request) {
+	files := []*pcs.File{}
+	for id, nodes := range s.recycle {
+		for _, n := range nodes {
+			if n.FsId == id {
+				f := n.File
+				files = append(files, &f)
+			}
+		}
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].FsId < files[j].FsId })
+	writeJSON(w, map[string]interface{}{"list": page(files, r.URL.Query())})
+}
+
+// streamTypes maps the type parameter of stream/list to file extensions.
+var streamTypes = map[string][]string{
+	"video": {".mp4", ".mkv", ".avi", ".mov", ".flv", ".rmvb", ".ts", ".webm"},
+	"audio": {".mp3", ".m4a", ".flac", ".wav", ".aac", ".ogg"},
+	"image": {".jpg", ".jpeg", ".png", ".gif", ".bmp", ".webp"},
+	"doc":   {".pdf", ".doc", ".docx", ".txt", ".xls", ".xlsx", ".ppt", ".pptx"},
+}
+
+func (s *Server) listStream(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	exts, ok := streamTypes[q.Get("type")]
+	if !ok {
+		writeError(w, http.StatusBadRequest, CodeInvalidParam)
+		return
+	}
+	prefix := path.Clean("/" + q.Get("filter_path"))
+	files := []*pcs.File{}
+	for p, n := range s.files {
+		if n.IsDir == 1 || !(p == prefix || isUnder(p, prefix)) {
+			continue
+		}
+		for _, ext := range exts {
+			if strings.EqualFold(path.Ext(p), ext) {
+				f := n.File
+				files = append(files, &f)
+				break
+			}
+		}
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	total := len(files)
+	start, _ := strconv.Atoi(q.Get("start"))
+	files = page(files, q)
+	writeJSON(w, map[string]interface{}{"total": total, "start": start, "limit": len(files), "list": files})
+}
+
+// page applies the start and limit parameters of a listing; limit
+// defaults to 1000 like the real service.
+func page(files []*pcs.File, q url.Values) []*pcs.File {
+	start, _ := strconv.Atoi(q.Get("start"))
+	limit, err := strconv.Atoi(q.Get("limit"))
+	if err != nil || limit <= 0 {
+		limit = 1000
+	}
+	start = min(max(start, 0), len(files))
+	return files[start:min(start+limit, len(files))]
+}
+
+// thumbnail serves a gray JPEG of the requested size for existing image
+// files.
+func (s *Server) thumbnail(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	n, ok := s.files[path.Clean(q.Get("path"))]
+	if !ok || n.IsDir == 1 {
+		writeError(w, http.StatusNotFound, CodeFileNotExist)
+		return
+	}
+	width, _ := strconv.Atoi(q.Get("width"))
+	height, _ := strconv.Atoi(q.Get("height"))
+	if width <= 0 || width > 1600 || height <= 0 || height > 1600 {
+		writeError(w, http.StatusBadRequest, CodeInvalidParam)
+		return
+	}
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	for i := range img.Pix {
+		img.Pix[i] = 0x80
+	}
+	var buf bytes.Buffer
+	jpeg.Encode(&buf, img, nil)
+	w.Header().Set("Content-Type", "image/jpeg")
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.Write(buf.Bytes())
+}
+
+// PutTorrent stores a torrent file at p whose content lists files, for
+// QueryTorrentInfo and torrent offline download tasks.
+func (s *Server) PutTorrent(p string, files []pcs.TorrentFile) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p = path.Clean(p)
+	s.putFile(p, []byte("d8:announce0:e"))
+	s.torrents[p] = files
+}
+
+func torrentSha1(p string) string {
+	return fmt.Sprintf("%x", sha1.Sum([]byte(p)))
+}
+
+func (s *Server) querySinfo(w http.ResponseWriter, r *http.Request) {
+	p := path.Clean(r.URL.Query().Get("source_path"))
+	files, ok := s.torrents[p]
+	if !ok {
+		writeError(w, http.StatusNotFound, CodeFileNotExist)
+		return
+	}
+	info := make([]map[string]string, len(files))
+	for i, f := range files {
+		info[i] = map[string]string{"file_name": f.Name, "size": strconv.FormatInt(f.Size, 10)}
+	}
+	writeJSON(w, map[string]interface{}{
+		"torrent_info": map[string]interface{}{
+			"file_info":  info,
+			"sha1":       torrentSha1(p),
+			"file_count": strconv.Itoa(len(files)),
+		},
+	})
+}
+
+func (s *Server) addTask(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("type") == "2" {
+		p := path.Clean(q.Get("source_path"))
+		files, ok := s.torrents[p]
+		if !ok || q.Get("sha1") != torrentSha1(p) {
+			writeError(w, http.StatusBadRequest, CodeInvalidParam)
+			return
+		}
+		if sel := q.Get("selected_idx"); sel != "" {
+			for _, idx := range strings.Split(sel, ",") {
+				if n, err := strconv.Atoi(idx); err != nil || n < 1 || n > len(files) {
+					writeError(w, http.StatusBadRequest, CodeInvalidParam)
+					return
+				}
+			}
+		}
+	} else if q.Get("source_url") == "" {
+		writeError(w, http.StatusBadRequest, CodeInvalidParam)
+		return
+	}
+	if q.Get("save_path") == "" {
+		writeError(w, http.StatusBadRequest, CodeInvalidParam)
+		return
+	}
+	t := &task{
+		ID:         s.nextTask,
+		SourceURL:  q.Get("source_url"),
+		SourcePath: q.Get("source_path"),
+		Selected:   q.Get("selected_idx"),
+		SavePath:   q.Get("save_path"),
+		Status:     1,
+		CreateTime: time.Now().Unix(),
+	}
+	s.nextTask++
+	s.tasks[t.ID] = t
+	writeJSON(w, map[string]int64{"task_id": t.ID})
+}
+
+func (t *task) info() map[string]interface{} {
+	return map[string]interface{}{
+		"task_id":     strconv.FormatInt(t.ID, 10),
+		"source_url":  t.SourceURL,
+		"save_path":   t.SavePath,
+		"status":      strconv.Itoa(t.Status),
+		"create_time": strconv.FormatInt(t.CreateTime, 10),
+	}
+}
+
+func (s *Server) queryTask(w http.ResponseWriter, r *http.Request) {
+	info := make(map[string]interface{})
+	for _, id := range strings.Split(r.URL.Query().Get("task_ids"), ",") {
+		n, err := strconv.ParseInt(strings.TrimSpace(id), 10, 64)
+		if err != nil {
+			continue
+		}
+		if t, ok := s.tasks[n]; ok {
+			info[id] = t.info()
+		} else {
+			info[id] = map[string]string{"result": "1"}
+		}
+	}
+	writeJSON(w, map[string]interface{}{"task_info": info})
+}
+
+func (s *Server) listTask(w http.ResponseWriter, r *http.Request) {
+	ids := make([]int64, 0, len(s.tasks))
+	for id := range s.tasks {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] > ids[j] })
+
+	total := len(ids)
+	q := r.URL.Query()
+	if start, _ := strconv.Atoi(q.Get("start")); start > 0 {
+		ids = ids[min(start, len(ids)):]
+	}
+	if limit, _ := strconv.Atoi(q.Get("limit")); limit > 0 && limit < len(ids) {
+		ids = ids[:limit]
+	}
+
+	list := make([]map[string]interface{}, 0, len(ids))
+	for _, id := range ids {
+		list = append(list, s.tasks[id].info())
+	}
+	writeJSON(w, map[string]interface{}{"task_info": list, "total": total})
+}
+
+func (s *Server) cancelTask(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.ParseInt(r.URL.Query().Get("task_id"), 10, 64)
+	if _, ok := s.tasks[id]; !ok {
+		writeError(w, http.StatusNotFound, CodeTaskNotExist)
+		return
+	}
+	delete(s.tasks, id)
+	writeJSON(w, map[string]interface{}{})
+}
+
+// targetPath returns the cleaned destination path of an upload, honoring
+// the ondup parameter. It writes an error response and returns false when
+// the path is taken.
+func (s *Server) targetPath(w http.ResponseWriter, r *http.Request) (string, bool) {
+	q := r.URL.Query()
+	p := path.Clean(q.Get("path"))
+	if !path.IsAbs(p) {
+		writeError(w, http.StatusBadRequest, CodeInvalidParam)
+		return "", false
+	}
+
+	n, ok := s.files[p]
+	if !ok {
+		return p, true
+	}
+	switch q.Get("ondup") {
+	case "overwrite":
+		if n.IsDir == 0 {
+			return p, true
+		}
+	case "newcopy":
+		ext := path.Ext(p)
+		return strings.TrimSuffix(p, ext) + "_" + time.Now().Format("20060102150405") + ext, true
+	}
+	writeError(w, http.StatusBadRequest, CodeFileExists)
+	return "", false
+}
+
+func (s *Server) putFile(p string, data []byte) *node {
+	s.mkdirAll(path.Dir(p))
+	now := uint64(time.Now().Unix())
+	n := &node{
+		File: pcs.File{
+			Path:  p,
+			Size:  uint64(len(data)),
+			Ctime: now,
+			Mtime: now,
+			Md5:   md5hex(data),
+			FsId:  s.nextID,
+		},
+		data: append([]byte(nil), data...),
+	}
+	if old, ok := s.files[p]; ok {
+		n.Ctime = old.Ctime
+	}
+	s.nextID++
+	s.files[p] = n
+	s.changed(n, false)
+	return n
+}
+
+func (s *Server) mkdirAll(p string) *node {
+	if n, ok := s.files[p]; ok {
+		return n
+	}
+	s.mkdirAll(path.Dir(p))
+	now := uint64(time.Now().Unix())
+	n := &node{File: pcs.File{Path: p, Ctime: now, Mtime: now, FsId: s.nextID, IsDir: 1}}
+	s.nextID++
+	s.files[p] = n
+	s.changed(n, false)
+	return n
+}
+
+func (s *Server) children(dir string) []*pcs.File {
+	files := []*pcs.File{}
+	for p, n := range s.files {
+		if p != "/" && path.Dir(p) == dir {
+			f := n.File
+			files = append(files, &f)
+		}
+	}
+	return files
+}
+
+func (s *Server) subtree(root string) []*node {
+	var nodes []*node
+	for p, n := range s.files {
+		if p == root || isUnder(p, root) {
+			nodes = append(nodes, n)
+		}
+	}
+	return nodes
+}
+
+func isUnder(p, dir string) bool {
+	if dir == "/" {
+		return p != "/"
+	}
+	return strings.HasPrefix(p, dir+"/")
+}
+
+func md5hex(data []byte) string {
+	return fmt.Sprintf("%x", md5.Sum(data))
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeErrno writes a failure in the errno dialect of the pan.baidu.com
+// endpoints, which answer with status 200.
+func writeErrno(w http.ResponseWriter, errno int) {
+	writeJSON(w, map[string]interface{}{"errno": errno, "errmsg": "error"})
+}
+
+func writeError(w http.ResponseWriter, status, code int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error_code": code,
+		"error_msg":  errorMessages[code],
+	})
+}
+
+func (s *Server) changed(n *node, deleted bool) {
+	s.changes = append(s.changes, change{file: n.File, deleted: deleted})
+}
+
+// diff serves the whole tree for the "null" cursor, and otherwise the
+// latest change of every path modified since the cursor was issued. As in
+// PCS, a deleted or moved directory is reported as a single entry, not one
+// per file below it.
+func (s *Server) diff(w http.ResponseWriter, r *http.Request) {
+	entries := make(map[string]interface{})
+	entry := func(f pcs.File, deleted bool) {
+		isdelete := 0
+		if deleted {
+			isdelete = 1
+		}
+		entries[f.Path] = map[string]interface{}{
+			"path": f.Path, "size": f.Size, "ctime": f.Ctime, "mtime": f.Mtime,
+			"md5": f.Md5, "fs_id": f.FsId, "isdir": f.IsDir, "isdelete": isdelete,
+		}
+	}
+
+	cursor := r.URL.Query().Get("cursor")
+	if cursor == "null" {
+		for p, n := range s.files {
+			if p != "/" {
+				entry(n.File, false)
+			}
+		}
+	} else {
+		off, err := strconv.Atoi(strings.TrimPrefix(cursor, "pcstest-"))
+		if err != nil || off < 0 || off > len(s.changes) {
+			writeError(w, http.StatusBadRequest, CodeInvalidParam)
+			return
+		}
+		for _, c := range s.changes[off:] {
+			entry(c.file, c.deleted)
+		}
+	}
+	writeJSON(w, map[string]interface{}{
+		"entries":  entries,
+		"has_more": false,
+		"reset":    cursor == "null",
+		"cursor":   "pcstest-" + strconv.Itoa(len(s.changes)),
+	})
+}
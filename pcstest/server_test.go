@@ -0,0 +1,209 @@
+package pcstest_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/holys/baidu-pcs/v2"
+	"github.com/holys/baidu-pcs/v2/pcstest"
+)
+
+func TestServerFiles(t *testing.T) {
+	ctx := context.Background()
+	srv := pcstest.NewServer()
+	defer srv.Close()
+	c := srv.NewClient()
+
+	f := srv.PutFile("/apps/t/a/b.txt", []byte("hello"))
+	if f.Size != 5 || f.Md5 != "5d41402abc4b2a76b9719d911017c592" {
+		t.Errorf("PutFile = %+v", f)
+	}
+	srv.Mkdir("/apps/t/empty")
+	if !srv.Exists("/apps/t/a") || !srv.Exists("/apps/t/empty") || srv.Exists("/apps/t/none") {
+		t.Error("Exists does not reflect PutFile and Mkdir")
+	}
+
+	meta, _, err := c.GetMeta(ctx, "/apps/t/a/b.txt")
+	if err != nil || meta.Size != 5 {
+		t.Errorf("GetMeta = %+v, %v", meta, err)
+	}
+	var buf bytes.Buffer
+	if _, err := c.DownloadTo(ctx, "/apps/t/a/b.txt", &buf); err != nil || buf.String() != "hello" {
+		t.Errorf("DownloadTo = %q, %v", buf.String(), err)
+	}
+
+	resp, err := c.PartialDownload(ctx, "/apps/t/a/b.txt", 1, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusPartialContent || resp.Header.Get("Content-Range") != "bytes 1-3/5" {
+		t.Errorf("PartialDownload = %d %s", resp.StatusCode, resp.Header.Get("Content-Range"))
+	}
+
+	local := filepath.Join(t.TempDir(), "c.txt")
+	if err := os.WriteFile(local, []byte("uploaded"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := c.Upload(ctx, local, &pcs.FileOptions{Path: "/apps/t/c.txt"}); err != nil {
+		t.Fatal(err)
+	}
+	if data, ok := srv.ReadFile("/apps/t/c.txt"); !ok || string(data) != "uploaded" {
+		t.Errorf("ReadFile = %q, %v", data, ok)
+	}
+	if _, _, err := c.Upload(ctx, local, &pcs.FileOptions{Path: "/apps/t/c.txt"}); !errors.Is(err, pcs.ErrAlreadyExists) {
+		t.Errorf("Upload over an existing file: %v", err)
+	}
+	if _, _, err := c.GetMeta(ctx, "/apps/t/none"); !errors.Is(err, pcs.ErrFileNotExist) {
+		t.Errorf("GetMeta of a missing file: %v", err)
+	}
+}
+
+func TestServerListPages(t *testing.T) {
+	ctx := context.Background()
+	srv := pcstest.NewServer()
+	defer srv.Close()
+	c := srv.NewClient()
+	for i := 0; i < 1200; i++ {
+		srv.PutFile(fmt.Sprintf("/apps/t/f%04d", i), nil)
+	}
+
+	list, _, err := c.ListFiles(ctx, &pcs.ListFilesOptions{Path: "/apps/t"})
+	if err != nil || len(list) != 1000 {
+		t.Errorf("ListFiles returned %d entries, %v; want a page of 1000", len(list), err)
+	}
+	list, _, err = c.ListFiles(ctx, &pcs.ListFilesOptions{Path: "/apps/t", Order: "asc", Limit: "1190-1240"})
+	if err != nil || len(list) != 10 || list[0].Path != "/apps/t/f1190" {
+		t.Errorf("ListFiles from 1190 returned %d entries, %v", len(list), err)
+	}
+}
+
+func TestServerFailures(t *testing.T) {
+	ctx := context.Background()
+	srv := pcstest.NewServer()
+	defer srv.Close()
+	c := srv.NewClient(pcs.WithThrottleRetry(1, 0))
+	srv.PutFile("/apps/t/a.txt", []byte("a"))
+
+	srv.FailNext("file", "meta", http.StatusBadRequest, pcstest.CodeFileNotExist)
+	srv.FailNext("file", "meta", http.StatusForbidden, pcstest.CodeInvalidToken)
+	if _, _, err := c.GetMeta(ctx, "/apps/t/a.txt"); !errors.Is(err, pcs.ErrFileNotExist) {
+		t.Errorf("first queued failure: %v", err)
+	}
+	if _, _, err := c.GetMeta(ctx, "/apps/t/a.txt"); !errors.Is(err, pcs.ErrTokenExpired) {
+		t.Errorf("second queued failure: %v", err)
+	}
+	if _, _, err := c.GetMeta(ctx, "/apps/t/a.txt"); err != nil {
+		t.Errorf("after the queue drained: %v", err)
+	}
+
+	srv.ThrottleNext("file", "meta", 2*time.Second)
+	_, resp, err := c.GetMeta(ctx, "/apps/t/a.txt")
+	var ae *pcs.APIError
+	if !errors.As(err, &ae) || ae.Code != pcstest.CodeFrequencyLimit {
+		t.Errorf("throttled request: %v", err)
+	}
+	if resp == nil || resp.StatusCode != http.StatusTooManyRequests || resp.Header.Get("Retry-After") != "2" {
+		t.Errorf("throttled response: %+v", resp)
+	}
+
+	srv.Token = "rotated"
+	if _, _, err := c.GetQuota(ctx); !errors.Is(err, pcs.ErrTokenExpired) {
+		t.Errorf("request with a wrong token: %v", err)
+	}
+}
+
+func TestServerCorruptUpload(t *testing.T) {
+	ctx := context.Background()
+	srv := pcstest.NewServer()
+	defer srv.Close()
+	c := srv.NewClient()
+	local := filepath.Join(t.TempDir(), "a.txt")
+	if err := os.WriteFile(local, []byte("some data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	srv.CorruptNextUpload()
+	if _, _, err := c.Upload(ctx, local, &pcs.FileOptions{Path: "/apps/t/a.txt"}); err == nil {
+		t.Error("corrupted upload was accepted")
+	}
+	if srv.Exists("/apps/t/a.txt") {
+		t.Error("corrupted upload was stored")
+	}
+	if _, _, err := c.Upload(ctx, local, &pcs.FileOptions{Path: "/apps/t/a.txt"}); err != nil {
+		t.Errorf("next upload: %v", err)
+	}
+}
+
+func TestServerOfflineTasks(t *testing.T) {
+	ctx := context.Background()
+	srv := pcstest.NewServer()
+	defer srv.Close()
+	c := srv.NewClient()
+
+	id, _, err := c.AddOfflineDownloadTask(ctx, &pcs.AddTaskOptions{SavePath: "/apps/t", SourceURL: "https://example.com/a.iso"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !srv.SetTaskStatus(id, pcs.TaskSuccess) || srv.SetTaskStatus(id+1, pcs.TaskSuccess) {
+		t.Error("SetTaskStatus does not report whether the task exists")
+	}
+	tasks, err := c.QueryOfflineTasks(ctx, id)
+	if err != nil || tasks[id] == nil || tasks[id].Status != pcs.TaskSuccess {
+		t.Errorf("QueryOfflineTasks = %v, %v", tasks, err)
+	}
+}
+
+func TestServerShare(t *testing.T) {
+	ctx := context.Background()
+	srv := pcstest.NewServer()
+	defer srv.Close()
+	c := srv.NewClient()
+	srv.PutFile("/apps/t/shared/a.txt", []byte("a"))
+
+	link := srv.Share("abcd", "/apps/t/shared")
+	if _, _, err := c.OpenShare(ctx, link, "wxyz"); err == nil {
+		t.Error("OpenShare accepted a wrong password")
+	}
+	s, _, err := c.OpenShare(ctx, link, "abcd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 分享的是快照
+	srv.PutFile("/apps/t/shared/b.txt", []byte("b"))
+	if len(s.Files) != 1 || s.Files[0].IsDir != 1 {
+		t.Fatalf("shared %+v", s.Files)
+	}
+	files, _, err := c.ListShare(ctx, s, s.Files[0].Path)
+	if err != nil || len(files) != 1 {
+		t.Errorf("ListShare = %+v, %v", files, err)
+	}
+}
+
+func TestRecorderMatching(t *testing.T) {
+	ctx := context.Background()
+	srv := pcstest.NewServer()
+	defer srv.Close()
+	srv.PutFile("/apps/t/a.txt", []byte("a"))
+	dir := t.TempDir()
+
+	rec := pcstest.NewRecorder(dir, pcstest.Record)
+	if _, _, err := srv.NewClient(pcs.WithHTTPClient(rec.HTTPClient())).GetMeta(ctx, "/apps/t/a.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	rec = pcstest.NewRecorder(dir, pcstest.Replay)
+	c := srv.NewClient(pcs.WithHTTPClient(rec.HTTPClient()))
+	if _, _, err := c.GetMeta(ctx, "/apps/t/b.txt"); !errors.Is(err, pcstest.ErrFixtureMismatch) {
+		t.Errorf("replaying a different path: %v", err)
+	}
+	if _, _, err := c.GetQuota(ctx); !errors.Is(err, pcstest.ErrNoFixture) {
+		t.Errorf("replaying an unrecorded call: %v", err)
+	}
+}
@@ -0,0 +1,26 @@
+package pcs
+
+import (
+	"context"
+	"time"
+)
+
+// Ping 未设置更早的截止时间时使用的超时
+const pingTimeout = 5 * time.Second
+
+// Ping 发送一次需要授权的轻量请求（获取配额），返回往返耗时，可用于就绪检查。
+// ctx 没有更早的截止时间时最多等待5秒。access token 失效时返回 *APIError。
+func (c *Client) Ping(ctx context.Context) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, pingTimeout)
+	defer cancel()
+
+	u, err := c.addOptions("quota", "info", nil)
+	if err != nil {
+		return 0, err
+	}
+	start := time.Now()
+	if _, err := c.Get(ctx, u, new(Quota)); err != nil {
+		return 0, err
+	}
+	return time.Since(start), nil
+}
@@ -0,0 +1,33 @@
+package pcs_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/holys/baidu-pcs/v2"
+	"github.com/holys/baidu-pcs/v2/pcstest"
+)
+
+func TestPing(t *testing.T) {
+	srv := pcstest.NewServer()
+	defer srv.Close()
+	c := srv.NewClient()
+
+	if d, err := c.Ping(context.Background()); err != nil || d <= 0 {
+		t.Errorf("Ping = %v, %v", d, err)
+	}
+
+	srv.FailNext("quota", "info", http.StatusUnauthorized, pcstest.CodeInvalidToken)
+	var apiErr *pcs.APIError
+	if _, err := c.Ping(context.Background()); !errors.As(err, &apiErr) || apiErr.Code != pcstest.CodeInvalidToken {
+		t.Errorf("Ping with an invalid token: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := c.Ping(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Ping with a canceled context: %v", err)
+	}
+}
@@ -0,0 +1,36 @@
+package pcs
+
+import (
+	"context"
+	"io"
+)
+
+// Pipe 下载远程文件 srcPath，经 transform 处理后上传为 dstPath（已存在时失败），
+// 例如重新加密、重新压缩或清除敏感内容。数据以流的方式处理，内存中只保留正在上传的分片，
+// 不在本地保存完整副本。transform 返回的 Reader 读完时即视为处理结束。
+// 设置了 Cipher 时 transform 处理的是解密后的内容，结果加密后上传。
+// 下载和上传共用 WithMaxConcurrentRequests 中的一个名额。
+func (c *Client) Pipe(ctx context.Context, srcPath, dstPath string, transform func(io.Reader) io.Reader) (*File, error) {
+	if err := validateRemotePath("path", dstPath); err != nil {
+		return nil, err
+	}
+
+	// 下载在上传结束前一直占用连接，两者共用一个请求名额，见 WithMaxConcurrentRequests
+	ctx, release, err := c.holdSlot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	pr, pw := io.Pipe()
+	go func() {
+		_, err := c.DownloadTo(ctx, srcPath, pw)
+		pw.CloseWithError(err)
+	}()
+	// 上传失败时使下载停止写入
+	defer pr.Close()
+
+	return c.UploadReader(ctx, transform(pr), &FileOptions{Path: dstPath})
+}
@@ -0,0 +1,38 @@
+package pcs_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/holys/baidu-pcs/v2"
+	"github.com/holys/baidu-pcs/v2/pcstest"
+)
+
+func TestPipeWithOneRequestSlot(t *testing.T) {
+	srv := pcstest.NewServer()
+	defer srv.Close()
+	c := srv.NewClient(pcs.WithChunkTuners(pcs.FixedChunkTuner(64<<10), nil), pcs.WithMaxConcurrentRequests(1))
+	// 远大于分片和连接缓冲区，上传第一个分片时下载还没有结束
+	data := strings.Repeat("abcdefghij", 500000)
+	srv.PutFile("/apps/t/a", []byte(data))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := c.Pipe(ctx, "/apps/t/a", "/apps/t/b", func(r io.Reader) io.Reader { return r }); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := srv.ReadFile("/apps/t/b"); !bytes.Equal(got, []byte(data)) {
+		t.Errorf("Pipe stored %d bytes, want %d", len(got), len(data))
+	}
+	if _, err := pcs.CopyBetween(ctx, c, c, "/apps/t/a", "/apps/t/c"); err != nil {
+		t.Fatal(err)
+	}
+	// 结束后名额已经归还
+	if _, _, err := c.GetQuota(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
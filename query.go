@@ -0,0 +1,139 @@
+package pcs
+
+import (
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/google/go-querystring/query"
+)
+
+// queryField describes how one struct field is encoded into the query
+// string. Encoders are built once per options type from its url tags and
+// cached, so encoding a request only reads field values instead of
+// re-parsing tags through go-querystring on every call.
+type queryField struct {
+	index     int
+	name      string
+	omitempty bool
+	kind      reflect.Kind
+}
+
+type queryEncoder struct {
+	fields []queryField
+
+	// fallback is set for types using features the fast path does not
+	// handle (nested structs, slices, custom encoders); those are encoded
+	// with go-querystring.
+	fallback bool
+}
+
+var queryEncoders sync.Map // reflect.Type -> *queryEncoder
+
+var encoderType = reflect.TypeOf((*query.Encoder)(nil)).Elem()
+
+// encodeQuery encodes opt, a struct or pointer to struct with url tags, the
+// same way query.Values does for the types used by this package.
+func encodeQuery(opt interface{}) (url.Values, error) {
+	v := reflect.ValueOf(opt)
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return url.Values{}, nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return query.Values(opt)
+	}
+
+	enc := encoderFor(v.Type())
+	if enc.fallback {
+		return query.Values(opt)
+	}
+
+	qs := make(url.Values, len(enc.fields)+2)
+	for _, f := range enc.fields {
+		fv := v.Field(f.index)
+		var s string
+		switch f.kind {
+		case reflect.String:
+			s = fv.String()
+			if f.omitempty && s == "" {
+				continue
+			}
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			n := fv.Int()
+			if f.omitempty && n == 0 {
+				continue
+			}
+			s = strconv.FormatInt(n, 10)
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			n := fv.Uint()
+			if f.omitempty && n == 0 {
+				continue
+			}
+			s = strconv.FormatUint(n, 10)
+		case reflect.Bool:
+			b := fv.Bool()
+			if f.omitempty && !b {
+				continue
+			}
+			s = strconv.FormatBool(b)
+		}
+		qs[f.name] = []string{s}
+	}
+	return qs, nil
+}
+
+func encoderFor(t reflect.Type) *queryEncoder {
+	if enc, ok := queryEncoders.Load(t); ok {
+		return enc.(*queryEncoder)
+	}
+
+	enc := new(queryEncoder)
+	if t.Implements(encoderType) || reflect.PtrTo(t).Implements(encoderType) {
+		enc.fallback = true
+	}
+	for i := 0; i < t.NumField() && !enc.fallback; i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" && !sf.Anonymous {
+			continue // unexported
+		}
+		tag := sf.Tag.Get("url")
+		if tag == "-" {
+			continue
+		}
+		name, opts := tag, ""
+		if i := strings.Index(tag, ","); i >= 0 {
+			name, opts = tag[:i], tag[i+1:]
+		}
+		if name == "" {
+			name = sf.Name
+		}
+
+		switch sf.Type.Kind() {
+		case reflect.String, reflect.Bool,
+			reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		default:
+			enc.fallback = true
+			continue
+		}
+		if sf.Anonymous || opts != "" && opts != "omitempty" {
+			enc.fallback = true
+			continue
+		}
+
+		enc.fields = append(enc.fields, queryField{
+			index:     i,
+			name:      name,
+			omitempty: opts == "omitempty",
+			kind:      sf.Type.Kind(),
+		})
+	}
+
+	queryEncoders.Store(t, enc)
+	return enc
+}
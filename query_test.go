@@ -0,0 +1,66 @@
+package pcs
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/google/go-querystring/query"
+)
+
+var queryOpts = []interface{}{
+	&ListFilesOptions{Path: "/apps/test", Order: "asc", By: "name", Limit: "0-100"},
+	&ListFilesOptions{},
+	&struct {
+		Path     string `url:"path"`
+		Size     int64  `url:"size,omitempty"`
+		Recurse  bool   `url:"re"`
+		Ignored  string `url:"-"`
+		internal string
+	}{Path: "/apps/test", Recurse: true, Ignored: "x", internal: "y"},
+	&struct {
+		Name  string   `url:"name"`
+		Items []string `url:"item"`
+	}{"a", []string{"b", "c"}},
+	(*ListFilesOptions)(nil),
+}
+
+func TestEncodeQueryMatchesQueryValues(t *testing.T) {
+	for _, opt := range queryOpts {
+		want, err := query.Values(opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := encodeQuery(opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("encodeQuery(%#v) = %v, want %v", opt, got, want)
+		}
+	}
+}
+
+func BenchmarkQueryValues(b *testing.B) {
+	opt := queryOpts[0]
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		query.Values(opt)
+	}
+}
+
+func BenchmarkEncodeQuery(b *testing.B) {
+	opt := queryOpts[0]
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		encodeQuery(opt)
+	}
+}
+
+func BenchmarkAddOptions(b *testing.B) {
+	c := NewClient("token")
+	opt := queryOpts[0]
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		c.addOptions("file", "list", opt)
+	}
+}
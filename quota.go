@@ -0,0 +1,162 @@
+package pcs
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+const defaultQuotaInterval = 10 * time.Minute
+
+var defaultQuotaThresholds = []float64{0.8, 0.95}
+
+// Free 返回剩余空间（字节）。
+func (q Quota) Free() uint64 {
+	if q.Used >= q.Quota {
+		return 0
+	}
+	return q.Quota - q.Used
+}
+
+// PercentUsed 返回已用空间占配额的百分比，配额为0时返回0。
+func (q Quota) PercentUsed() float64 {
+	if q.Quota == 0 {
+		return 0
+	}
+	return float64(q.Used) / float64(q.Quota) * 100
+}
+
+// CheckQuota 检查剩余空间是否足以上传 size 字节，不足时返回包装了 ErrInsufficientQuota 的错误。
+// 上传目录等多个文件时可先以总大小调用一次。
+func (c *Client) CheckQuota(ctx context.Context, size int64) error {
+	q, _, err := c.GetQuota(ctx)
+	if err != nil {
+		return err
+	}
+	if free := q.Free(); uint64(size) > free {
+		return fmt.Errorf("%w: need %d bytes, %d free", ErrInsufficientQuota, size, free)
+	}
+	return nil
+}
+
+// checkQuota 在设置了 WithQuotaCheck 时检查剩余空间。
+func (c *Client) checkQuota(ctx context.Context, size int64) error {
+	if !c.quotaCheck {
+		return nil
+	}
+	return c.CheckQuota(ctx, size)
+}
+
+// QuotaCrossing 描述一次已用空间越过阈值的变化。
+type QuotaCrossing struct {
+	Quota     Quota
+	Threshold float64 // 越过的阈值，为占配额的比例
+	Rising    bool    // true 表示用量升至阈值以上，false 表示降回阈值以下
+}
+
+// QuotaMonitor 定期调用 GetQuota，在已用空间越过设定的阈值时调用回调。
+// 每个阈值只在越过时通知一次，用量降回阈值以下后再次越过时重新通知。
+type QuotaMonitor struct {
+	client     *Client
+	interval   time.Duration
+	thresholds []float64
+
+	mu        sync.Mutex
+	callbacks []func(QuotaCrossing)
+	history   *QuotaHistory
+	last      *Quota
+	level     int // 已越过的阈值个数
+}
+
+// NewQuotaMonitor 创建每隔 interval（不大于0时为10分钟）检查一次配额的 QuotaMonitor。
+// thresholds 为占配额的比例，缺省为 0.8 和 0.95。
+func NewQuotaMonitor(c *Client, interval time.Duration, thresholds ...float64) *QuotaMonitor {
+	if interval <= 0 {
+		interval = defaultQuotaInterval
+	}
+	if len(thresholds) == 0 {
+		thresholds = defaultQuotaThresholds
+	}
+	t := append([]float64(nil), thresholds...)
+	sort.Float64s(t)
+	return &QuotaMonitor{client: c, interval: interval, thresholds: t}
+}
+
+// OnCross 注册越过阈值时调用的 fn，回调在 Check 或 Run 的 goroutine 中依次执行。
+func (m *QuotaMonitor) OnCross(fn func(QuotaCrossing)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.callbacks = append(m.callbacks, fn)
+}
+
+// RecordHistory 使每次检查得到的配额都记录到 h 中。
+func (m *QuotaMonitor) RecordHistory(h *QuotaHistory) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.history = h
+}
+
+// Last 返回最近一次获取的配额，尚未获取时返回 nil。
+func (m *QuotaMonitor) Last() *Quota {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.last == nil {
+		return nil
+	}
+	q := *m.last
+	return &q
+}
+
+// Check 立即获取一次配额，并对越过的每个阈值调用回调。
+func (m *QuotaMonitor) Check(ctx context.Context) (*Quota, error) {
+	q, _, err := m.client.GetQuota(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	used := q.PercentUsed() / 100
+	level := sort.Search(len(m.thresholds), func(i int) bool { return m.thresholds[i] > used })
+
+	m.mu.Lock()
+	var crossings []QuotaCrossing
+	for i := m.level; i < level; i++ {
+		crossings = append(crossings, QuotaCrossing{Quota: *q, Threshold: m.thresholds[i], Rising: true})
+	}
+	for i := m.level - 1; i >= level; i-- {
+		crossings = append(crossings, QuotaCrossing{Quota: *q, Threshold: m.thresholds[i], Rising: false})
+	}
+	m.level = level
+	m.last = q
+	callbacks, history := m.callbacks, m.history
+	m.mu.Unlock()
+
+	if history != nil {
+		if err := history.Add(time.Now(), *q); err != nil {
+			return q, err
+		}
+	}
+
+	for _, c := range crossings {
+		for _, fn := range callbacks {
+			fn(c)
+		}
+	}
+	return q, nil
+}
+
+// Run 立即检查一次配额，之后每隔 interval 检查一次，直到 ctx 结束。
+// 获取配额失败时等待下一次检查，返回值总是 ctx.Err()。
+func (m *QuotaMonitor) Run(ctx context.Context) error {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+	for {
+		m.Check(ctx)
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
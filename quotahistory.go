@@ -0,0 +1,127 @@
+package pcs
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// 历史中保留的最大样本数，超出时丢弃最早的样本
+	maxQuotaSamples = 10000
+
+	day  = 24 * time.Hour
+	week = 7 * day
+)
+
+// QuotaSample 是某一时刻的配额记录
+type QuotaSample struct {
+	Time  time.Time `json:"time"`
+	Quota uint64    `json:"quota"`
+	Used  uint64    `json:"used"`
+}
+
+// QuotaReport 是根据配额历史得出的用量统计
+type QuotaReport struct {
+	Latest  QuotaSample
+	PerDay  float64 // 最近7天平均每天增长的字节数，样本不足时为0
+	PerWeek float64 // 最近4周平均每周增长的字节数，样本不足时为0
+
+	// 按 PerDay 估计的空间用完的时间，用量没有增长或100年内不会用完时为零值
+	ProjectedFull time.Time
+}
+
+// QuotaHistory 将配额样本保存在本地文件中，用于容量规划。
+// 可通过 QuotaMonitor.RecordHistory 定期记录。可被多个 goroutine 并发使用。
+type QuotaHistory struct {
+	path string
+
+	mu      sync.Mutex
+	samples []QuotaSample
+}
+
+// NewQuotaHistory 创建保存在 path 的 QuotaHistory，并加载已有的样本。
+func NewQuotaHistory(path string) (*QuotaHistory, error) {
+	h := &QuotaHistory{path: path}
+
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &h.samples); err != nil {
+			return nil, err
+		}
+	}
+	return h, nil
+}
+
+// Add 记录 t 时刻的配额 q 并保存。
+func (h *QuotaHistory) Add(t time.Time, q Quota) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.samples = append(h.samples, QuotaSample{Time: t, Quota: q.Quota, Used: q.Used})
+	if n := len(h.samples) - maxQuotaSamples; n > 0 {
+		h.samples = append([]QuotaSample(nil), h.samples[n:]...)
+	}
+
+	data, err := json.Marshal(h.samples)
+	if err != nil {
+		return err
+	}
+	tmp := h.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, h.path)
+}
+
+// Samples 返回全部样本，最早的在前。
+func (h *QuotaHistory) Samples() []QuotaSample {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]QuotaSample(nil), h.samples...)
+}
+
+// Report 根据已有样本计算用量增长，没有样本时返回 nil。
+func (h *QuotaHistory) Report() *QuotaReport {
+	samples := h.Samples()
+	if len(samples) == 0 {
+		return nil
+	}
+	latest := samples[len(samples)-1]
+	r := &QuotaReport{
+		Latest:  latest,
+		PerDay:  growth(samples, latest.Time.Add(-week)),
+		PerWeek: growth(samples, latest.Time.Add(-4*week)) * 7,
+	}
+	if r.PerDay > 0 && latest.Quota > latest.Used {
+		if days := float64(latest.Quota-latest.Used) / r.PerDay; days < 100*365 {
+			r.ProjectedFull = latest.Time.Add(time.Duration(days * float64(day)))
+		}
+	}
+	return r
+}
+
+// growth 以最小二乘法拟合 since 之后的样本，返回用量每天的增长。
+func growth(samples []QuotaSample, since time.Time) float64 {
+	var n, sx, sy, sxx, sxy float64
+	for _, s := range samples {
+		if s.Time.Before(since) {
+			continue
+		}
+		x := float64(s.Time.Sub(since)) / float64(day)
+		y := float64(s.Used)
+		n++
+		sx += x
+		sy += y
+		sxx += x * x
+		sxy += x * y
+	}
+	d := n*sxx - sx*sx
+	if n < 2 || d == 0 {
+		return 0
+	}
+	return (n*sxy - sx*sy) / d
+}
@@ -0,0 +1,55 @@
+package pcs_test
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/holys/baidu-pcs/v2"
+)
+
+func TestQuotaHistoryReport(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "quota.json")
+	h, err := pcs.NewQuotaHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := h.Report(); r != nil {
+		t.Errorf("Report without samples = %+v", r)
+	}
+
+	// 30天内每天增长100字节
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i <= 30; i++ {
+		if err := h.Add(start.AddDate(0, 0, i), pcs.Quota{Quota: 10000, Used: uint64(1000 + 100*i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 样本保存在文件中
+	h, err = pcs.NewQuotaHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(h.Samples()); n != 31 {
+		t.Fatalf("%d samples after reopening, want 31", n)
+	}
+	r := h.Report()
+	if r.Latest.Used != 4000 || math.Abs(r.PerDay-100) > 1e-6 || math.Abs(r.PerWeek-700) > 1e-6 {
+		t.Errorf("Report = %+v", r)
+	}
+	if want := start.AddDate(0, 0, 90); !r.ProjectedFull.Equal(want) {
+		t.Errorf("ProjectedFull = %v, want %v", r.ProjectedFull, want)
+	}
+
+	// 用量不再增长时不估计用完的时间
+	for i := 31; i <= 45; i++ {
+		if err := h.Add(start.AddDate(0, 0, i), pcs.Quota{Quota: 10000, Used: 4000}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if r := h.Report(); r.PerDay != 0 || !r.ProjectedFull.IsZero() {
+		t.Errorf("Report with flat usage = %+v", r)
+	}
+}
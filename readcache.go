@@ -0,0 +1,280 @@
+package pcs
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"io"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultMetaTTL        = 30 * time.Second
+	defaultCacheChunkSize = 1 << 20
+	defaultCacheMemory    = 64 << 20
+)
+
+// ReadCacheOption 用于配置 ReadCache
+type ReadCacheOption func(*ReadCache)
+
+// WithMetaTTL 设置元信息和目录列表的缓存时间，默认为30秒。
+func WithMetaTTL(ttl time.Duration) ReadCacheOption {
+	return func(rc *ReadCache) {
+		if ttl > 0 {
+			rc.ttl = ttl
+		}
+	}
+}
+
+// WithCacheChunks 设置文件内容按 chunkSize 字节分块缓存，全部分块最多占用 maxMemory 字节内存。
+// 默认分块为1MB，最多64MB。
+func WithCacheChunks(chunkSize, maxMemory int64) ReadCacheOption {
+	return func(rc *ReadCache) {
+		if chunkSize > 0 {
+			rc.chunkSize = chunkSize
+		}
+		if maxMemory > 0 {
+			rc.maxMemory = maxMemory
+		}
+	}
+}
+
+// ReadCache 是位于文件系统类访问与 API 之间的读缓存：元信息和目录列表在一段时间内
+// 直接使用缓存，文件内容按分块缓存在内存中并按最近最少使用的顺序淘汰，
+// 以减少高延迟网络下挂载访问时的请求次数。
+//
+// 内容分块以文件的 md5 区分，文件被修改后元信息过期即读取新内容。通过同一 Client
+// 删除或上传文件时会收到事件并立即使对应的缓存失效，其他途径的修改可调用 Invalidate。
+// ReadAt 读取的是远端保存的原始内容，不经过 WithEncryption 的解密。可被多个 goroutine 并发使用。
+type ReadCache struct {
+	client      *Client
+	ttl         time.Duration
+	chunkSize   int64
+	maxMemory   int64
+	unsubscribe func()
+
+	mu     sync.Mutex
+	metas  map[string]*metaEntry
+	dirs   map[string]*dirEntry
+	chunks map[chunkKey]*list.Element
+	lru    *list.List // 最近使用的在前
+	memory int64
+	fetch  map[chunkKey]*chunkFetch
+}
+
+type metaEntry struct {
+	file    *File
+	expires time.Time
+}
+
+type dirEntry struct {
+	files   []*File
+	expires time.Time
+}
+
+type chunkKey struct {
+	md5   string
+	index int64
+}
+
+type chunkEntry struct {
+	key  chunkKey
+	data []byte
+}
+
+// chunkFetch 是正在下载的分块，并发读取同一分块时只下载一次
+type chunkFetch struct {
+	done chan struct{}
+	data []byte
+	err  error
+}
+
+// NewReadCache 创建通过 c 访问 API 的 ReadCache，不再使用时应调用 Close。
+func NewReadCache(c *Client, opts ...ReadCacheOption) *ReadCache {
+	rc := &ReadCache{
+		client:    c,
+		ttl:       defaultMetaTTL,
+		chunkSize: defaultCacheChunkSize,
+		maxMemory: defaultCacheMemory,
+		metas:     make(map[string]*metaEntry),
+		dirs:      make(map[string]*dirEntry),
+		chunks:    make(map[chunkKey]*list.Element),
+		lru:       list.New(),
+		fetch:     make(map[chunkKey]*chunkFetch),
+	}
+	for _, opt := range opts {
+		opt(rc)
+	}
+	rc.unsubscribe = c.Subscribe(rc.handleEvent)
+	return rc
+}
+
+// Close 停止接收 Client 的事件并丢弃全部缓存。
+func (rc *ReadCache) Close() {
+	rc.unsubscribe()
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.metas = make(map[string]*metaEntry)
+	rc.dirs = make(map[string]*dirEntry)
+	rc.chunks = make(map[chunkKey]*list.Element)
+	rc.lru.Init()
+	rc.memory = 0
+}
+
+func (rc *ReadCache) handleEvent(e Event) {
+	switch e := e.(type) {
+	case FileDeleted:
+		rc.Invalidate(e.Paths...)
+	case UploadCompleted:
+		rc.Invalidate(e.Job.RemotePath)
+	}
+}
+
+// Invalidate 使 paths 及其下全部路径的元信息和所在目录的列表失效。
+// 内容分块以 md5 区分，无需单独失效。
+func (rc *ReadCache) Invalidate(paths ...string) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	for _, p := range paths {
+		p = CleanPath(p)
+		prefix := strings.TrimSuffix(p, "/") + "/"
+		for k := range rc.metas {
+			if k == p || strings.HasPrefix(k, prefix) {
+				delete(rc.metas, k)
+			}
+		}
+		for k := range rc.dirs {
+			if k == p || strings.HasPrefix(k, prefix) {
+				delete(rc.dirs, k)
+			}
+		}
+		delete(rc.dirs, path.Dir(p))
+	}
+}
+
+// Stat 返回 path 的元信息。
+func (rc *ReadCache) Stat(ctx context.Context, p string) (*File, error) {
+	p = CleanPath(p)
+	rc.mu.Lock()
+	if e, ok := rc.metas[p]; ok && time.Now().Before(e.expires) {
+		rc.mu.Unlock()
+		return e.file, nil
+	}
+	rc.mu.Unlock()
+
+	meta, _, err := rc.client.GetMeta(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+	if meta.File == nil {
+		return nil, ErrInvalidResponse
+	}
+	rc.mu.Lock()
+	rc.metas[p] = &metaEntry{file: meta.File, expires: time.Now().Add(rc.ttl)}
+	rc.mu.Unlock()
+	return meta.File, nil
+}
+
+// ReadDir 返回目录 path 下的文件列表，同时缓存其中各项的元信息。
+func (rc *ReadCache) ReadDir(ctx context.Context, p string) ([]*File, error) {
+	p = CleanPath(p)
+	rc.mu.Lock()
+	if e, ok := rc.dirs[p]; ok && time.Now().Before(e.expires) {
+		rc.mu.Unlock()
+		return e.files, nil
+	}
+	rc.mu.Unlock()
+
+	files, err := rc.client.listDir(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+	expires := time.Now().Add(rc.ttl)
+	rc.mu.Lock()
+	rc.dirs[p] = &dirEntry{files: files, expires: expires}
+	for _, f := range files {
+		rc.metas[CleanPath(f.Path)] = &metaEntry{file: f, expires: expires}
+	}
+	rc.mu.Unlock()
+	return files, nil
+}
+
+// ReadAt 从文件 path 的偏移 off 处读取 len(p) 字节，语义同 io.ReaderAt。
+func (rc *ReadCache) ReadAt(ctx context.Context, p string, buf []byte, off int64) (int, error) {
+	f, err := rc.Stat(ctx, p)
+	if err != nil {
+		return 0, err
+	}
+	if f.IsDirectory() {
+		return 0, invalid("path", "%q is a directory", p)
+	}
+	size := int64(f.Size)
+
+	var n int
+	for len(buf) > 0 {
+		if off >= size {
+			return n, io.EOF
+		}
+		index := off / rc.chunkSize
+		data, err := rc.chunk(ctx, f, index)
+		if err != nil {
+			return n, err
+		}
+		m := copy(buf, data[off-index*rc.chunkSize:])
+		n, off, buf = n+m, off+int64(m), buf[m:]
+	}
+	return n, nil
+}
+
+// chunk 返回文件 f 的第 index 个分块，未缓存时下载。
+func (rc *ReadCache) chunk(ctx context.Context, f *File, index int64) ([]byte, error) {
+	key := chunkKey{md5: f.Md5, index: index}
+
+	rc.mu.Lock()
+	if el, ok := rc.chunks[key]; ok {
+		rc.lru.MoveToFront(el)
+		rc.mu.Unlock()
+		return el.Value.(*chunkEntry).data, nil
+	}
+	if cf, ok := rc.fetch[key]; ok {
+		rc.mu.Unlock()
+		select {
+		case <-cf.done:
+			return cf.data, cf.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	cf := &chunkFetch{done: make(chan struct{})}
+	rc.fetch[key] = cf
+	rc.mu.Unlock()
+
+	start := index * rc.chunkSize
+	end := start + rc.chunkSize - 1
+	if end >= int64(f.Size) {
+		end = int64(f.Size) - 1
+	}
+	buf := bytes.NewBuffer(make([]byte, 0, end-start+1))
+	_, cf.err = rc.client.downloadRange(ctx, f.Path, f.Md5, start, end, buf)
+	cf.data = buf.Bytes()
+
+	rc.mu.Lock()
+	delete(rc.fetch, key)
+	if cf.err == nil {
+		rc.chunks[key] = rc.lru.PushFront(&chunkEntry{key: key, data: cf.data})
+		rc.memory += int64(len(cf.data))
+		for rc.memory > rc.maxMemory && rc.lru.Len() > 1 {
+			el := rc.lru.Back()
+			e := el.Value.(*chunkEntry)
+			rc.lru.Remove(el)
+			delete(rc.chunks, e.key)
+			rc.memory -= int64(len(e.data))
+		}
+	}
+	rc.mu.Unlock()
+	close(cf.done)
+	return cf.data, cf.err
+}
@@ -0,0 +1,125 @@
+package pcs_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/holys/baidu-pcs/v2"
+	"github.com/holys/baidu-pcs/v2/pcstest"
+)
+
+// countMethods 按 method 参数统计发出的请求
+type countMethods struct {
+	mu sync.Mutex
+	n  map[string]int
+}
+
+func (c *countMethods) RoundTrip(req *http.Request) (*http.Response, error) {
+	c.mu.Lock()
+	if c.n == nil {
+		c.n = make(map[string]int)
+	}
+	c.n[req.URL.Query().Get("method")]++
+	c.mu.Unlock()
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func (c *countMethods) count(method string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.n[method]
+}
+
+func TestReadCacheMeta(t *testing.T) {
+	ctx := context.Background()
+	srv := pcstest.NewServer()
+	defer srv.Close()
+	reqs := &countMethods{}
+	c := srv.NewClient(pcs.WithHTTPClient(&http.Client{Transport: reqs}))
+	srv.PutFile("/apps/t/a", []byte("alpha"))
+	srv.PutFile("/apps/t/d/b", []byte("bravo"))
+	rc := pcs.NewReadCache(c)
+	defer rc.Close()
+
+	for i := 0; i < 2; i++ {
+		if f, err := rc.Stat(ctx, "/apps/t/a"); err != nil || f.Size != 5 {
+			t.Fatalf("Stat = %+v, %v", f, err)
+		}
+	}
+	// 列目录时一并缓存其中各项的元信息
+	if files, err := rc.ReadDir(ctx, "/apps/t/d"); err != nil || len(files) != 1 {
+		t.Fatalf("ReadDir = %v, %v", files, err)
+	}
+	if _, err := rc.Stat(ctx, "/apps/t/d/b"); err != nil {
+		t.Fatal(err)
+	}
+	if n := reqs.count("meta"); n != 1 {
+		t.Errorf("%d meta requests, want 1", n)
+	}
+
+	// 通过同一 Client 删除后缓存失效
+	if _, err := c.Delete(ctx, "/apps/t/d"); err != nil {
+		t.Fatal(err)
+	}
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(time.Millisecond) {
+		if _, err := rc.Stat(ctx, "/apps/t/d/b"); err != nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Stat still returns the deleted file")
+		}
+	}
+
+	// 其他途径的修改在 Invalidate 后可见
+	srv.PutFile("/apps/t/a", []byte("longer alpha"))
+	if f, _ := rc.Stat(ctx, "/apps/t/a"); f.Size != 5 {
+		t.Errorf("Stat before Invalidate: size %d, want the cached 5", f.Size)
+	}
+	rc.Invalidate("/apps/t/a")
+	if f, err := rc.Stat(ctx, "/apps/t/a"); err != nil || f.Size != 12 {
+		t.Errorf("Stat after Invalidate = %+v, %v", f, err)
+	}
+}
+
+func TestReadCacheChunks(t *testing.T) {
+	ctx := context.Background()
+	srv := pcstest.NewServer()
+	defer srv.Close()
+	reqs := &countMethods{}
+	c := srv.NewClient(pcs.WithHTTPClient(&http.Client{Transport: reqs}))
+	srv.PutFile("/apps/t/a", []byte("0123456789ab"))
+	// 每块4字节，最多同时缓存两块
+	rc := pcs.NewReadCache(c, pcs.WithCacheChunks(4, 8))
+	defer rc.Close()
+
+	for _, tt := range []struct {
+		off       int64
+		want      string
+		downloads int
+	}{
+		{0, "0123", 1},
+		{4, "4567", 2},
+		{0, "0123", 2},
+		{8, "89ab", 3}, // 淘汰最久未使用的 4567
+		{0, "0123", 3},
+		{4, "4567", 4},
+		{2, "2345", 4}, // 跨越两块
+	} {
+		buf := make([]byte, 4)
+		if n, err := rc.ReadAt(ctx, "/apps/t/a", buf, tt.off); err != nil || string(buf[:n]) != tt.want {
+			t.Fatalf("ReadAt(%d) = %q, %v; want %q", tt.off, buf[:n], err, tt.want)
+		}
+		if n := reqs.count("download"); n != tt.downloads {
+			t.Errorf("after ReadAt(%d): %d downloads, want %d", tt.off, n, tt.downloads)
+		}
+	}
+
+	buf := make([]byte, 8)
+	if n, err := rc.ReadAt(ctx, "/apps/t/a", buf, 8); n != 4 || err != io.EOF {
+		t.Errorf("ReadAt past the end = %d, %v; want 4 and io.EOF", n, err)
+	}
+}
@@ -0,0 +1,203 @@
+package pcs
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sync"
+)
+
+const (
+	remoteFileChunk  = 1 << 20
+	defaultReadAhead = 4
+
+	// 连续这么多次顺序读取后开始预读
+	sequentialReads = 2
+)
+
+// RemoteFile 以 io.ReadSeeker 和 io.ReaderAt 的形式读取远程文件，每次读取按需发送 Range 请求。
+// 检测到顺序读取时在后台预先下载之后的若干分块（见 WithReadAhead），
+// 避免播放媒体或流式处理时每个分块都等待一次往返。
+// 读取的是远端保存的原始内容，不经过 WithEncryption 的解密。
+// Read 和 Seek 不可并发调用，ReadAt 可被多个 goroutine 并发使用。
+type RemoteFile struct {
+	c      *Client
+	ctx    context.Context
+	cancel context.CancelFunc
+	path   string
+	md5    string
+	size   int64
+	ahead  int64
+
+	off  int64 // Read 和 Seek 使用的偏移
+	next int64 // 下一次顺序读取的偏移
+	seq  int   // 连续顺序读取的次数
+
+	mu     sync.Mutex
+	chunks map[int64]*chunkFetch
+}
+
+// Open 打开远程文件 path 用于读取，ctx 用于之后的全部请求，包括后台预读。不再使用时应调用 Close。
+func (c *Client) Open(ctx context.Context, path string) (*RemoteFile, error) {
+	meta, _, err := c.GetMeta(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	if meta.File == nil {
+		return nil, ErrInvalidResponse
+	}
+	if meta.IsDirectory() {
+		return nil, invalid("path", "%q is a directory", path)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	return &RemoteFile{
+		c:      c,
+		ctx:    ctx,
+		cancel: cancel,
+		path:   path,
+		md5:    meta.Md5,
+		size:   int64(meta.Size),
+		ahead:  int64(c.readAhead),
+		chunks: make(map[int64]*chunkFetch),
+	}, nil
+}
+
+// Size 返回文件大小。
+func (f *RemoteFile) Size() int64 { return f.size }
+
+func (f *RemoteFile) Read(p []byte) (int, error) {
+	if f.off >= f.size {
+		return 0, io.EOF
+	}
+	if f.off == f.next {
+		f.seq++
+	} else {
+		f.seq = 0
+	}
+
+	index := f.off / remoteFileChunk
+	if f.seq >= sequentialReads {
+		f.prefetch(index)
+	}
+	data, err := f.chunk(index)
+	if err != nil {
+		return 0, err
+	}
+	n := copy(p, data[f.off-index*remoteFileChunk:])
+	f.off += int64(n)
+	f.next = f.off
+	return n, nil
+}
+
+func (f *RemoteFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.off
+	case io.SeekEnd:
+		offset += f.size
+	default:
+		return 0, invalid("whence", "unknown whence %d", whence)
+	}
+	if offset < 0 {
+		return 0, invalid("offset", "negative position %d", offset)
+	}
+	f.off = offset
+	return offset, nil
+}
+
+func (f *RemoteFile) ReadAt(p []byte, off int64) (int, error) {
+	var n int
+	for len(p) > 0 {
+		if off >= f.size {
+			return n, io.EOF
+		}
+		index := off / remoteFileChunk
+		data, err := f.chunk(index)
+		if err != nil {
+			return n, err
+		}
+		m := copy(p, data[off-index*remoteFileChunk:])
+		n, off, p = n+m, off+int64(m), p[m:]
+	}
+	return n, nil
+}
+
+// Close 取消正在进行的预读并释放缓存的分块。
+func (f *RemoteFile) Close() error {
+	f.cancel()
+	f.mu.Lock()
+	f.chunks = make(map[int64]*chunkFetch)
+	f.mu.Unlock()
+	return nil
+}
+
+// prefetch 开始下载 index 之后的 ahead 个分块，并丢弃 index 之前的分块。
+func (f *RemoteFile) prefetch(index int64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i := range f.chunks {
+		if i < index {
+			delete(f.chunks, i)
+		}
+	}
+	for i := index + 1; i <= index+f.ahead && i*remoteFileChunk < f.size; i++ {
+		f.fetchLocked(i)
+	}
+}
+
+// chunk 返回第 index 个分块，必要时下载并等待。
+func (f *RemoteFile) chunk(index int64) ([]byte, error) {
+	f.mu.Lock()
+	cf := f.fetchLocked(index)
+	f.mu.Unlock()
+
+	select {
+	case <-cf.done:
+	case <-f.ctx.Done():
+		return nil, f.ctx.Err()
+	}
+	if cf.err != nil {
+		// 下次读取时重新下载
+		f.mu.Lock()
+		if f.chunks[index] == cf {
+			delete(f.chunks, index)
+		}
+		f.mu.Unlock()
+	}
+	return cf.data, cf.err
+}
+
+// fetchLocked 返回第 index 个分块的下载，尚未开始时在后台开始，调用时需持有 f.mu。
+func (f *RemoteFile) fetchLocked(index int64) *chunkFetch {
+	if cf, ok := f.chunks[index]; ok {
+		return cf
+	}
+	// 限制缓存的分块数量，随机读取时丢弃已下载完成的其他分块
+	if int64(len(f.chunks)) > 2*f.ahead+1 {
+		for i, old := range f.chunks {
+			select {
+			case <-old.done:
+				if i < index || i > index+f.ahead {
+					delete(f.chunks, i)
+				}
+			default:
+			}
+		}
+	}
+	cf := &chunkFetch{done: make(chan struct{})}
+	f.chunks[index] = cf
+
+	start := index * remoteFileChunk
+	end := start + remoteFileChunk - 1
+	if end >= f.size {
+		end = f.size - 1
+	}
+	go func() {
+		defer close(cf.done)
+		buf := bytes.NewBuffer(make([]byte, 0, end-start+1))
+		_, cf.err = f.c.downloadRange(f.ctx, f.path, f.md5, start, end, buf)
+		cf.data = buf.Bytes()
+	}()
+	return cf
+}
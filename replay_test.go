@@ -0,0 +1,606 @@
+package pcs_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/jpeg"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/holys/baidu-pcs/v2"
+)
+
+// The replay tests call every endpoint of the API at least once against the
+// fixtures in testdata/fixtures. Each works in its own directory below
+// testRoot, creating what it needs through the API and deleting the
+// directory when it ends, so it can be recorded again with -record.
+
+// putFile uploads data as the remote file p.
+func putFile(t *testing.T, c *pcs.Client, p string, data []byte) *pcs.File {
+	t.Helper()
+	local := filepath.Join(t.TempDir(), path.Base(p))
+	if err := os.WriteFile(local, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	f, _, err := c.Upload(context.Background(), local, &pcs.FileOptions{Path: p, OnDup: pcs.OnDupOverwrite})
+	if err != nil {
+		t.Fatalf("Upload(%s): %v", p, err)
+	}
+	return f
+}
+
+// removeDir deletes dir when the test ends.
+func removeDir(t *testing.T, c *pcs.Client, dir string) {
+	t.Cleanup(func() {
+		if _, err := c.Delete(context.Background(), dir); err != nil {
+			t.Errorf("Delete(%s): %v", dir, err)
+		}
+	})
+}
+
+func readBody(t *testing.T, resp *http.Response) []byte {
+	t.Helper()
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestReplayGetQuota(t *testing.T) {
+	ctx := context.Background()
+	c, _ := replayClient(t)
+	quota, _, err := c.GetQuota(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if quota.Quota == 0 {
+		t.Errorf("GetQuota returned zero quota: %+v", quota)
+	}
+}
+
+func TestReplayGetUserInfo(t *testing.T) {
+	ctx := context.Background()
+	c, _ := replayClient(t)
+	info, _, err := c.GetUserInfo(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.UK == 0 || info.BaiduName == "" {
+		t.Errorf("GetUserInfo returned incomplete info: %+v", info)
+	}
+}
+
+func TestReplayUploadAndGetMeta(t *testing.T) {
+	ctx := context.Background()
+	c, _ := replayClient(t)
+	dir := replayDir(t)
+	removeDir(t, c, dir)
+
+	content := []byte("hello from the replay tests\n")
+	f := putFile(t, c, path.Join(dir, "hello.txt"), content)
+	if f.Size != uint64(len(content)) || f.Md5 == "" {
+		t.Errorf("Upload returned %+v", f)
+	}
+
+	meta, _, err := c.GetMeta(ctx, f.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.IsDirectory() || meta.Size != f.Size || meta.FsId != f.FsId {
+		t.Errorf("GetMeta(%q) = %+v, uploaded %+v", f.Path, meta.File, f)
+	}
+	metas, _, err := c.BatchGetMeta(ctx, []string{dir, f.Path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(metas) != 2 || !metas[0].IsDirectory() || metas[1].Path != f.Path {
+		t.Errorf("BatchGetMeta returned %d entries: %+v", len(metas), metas)
+	}
+	if _, _, err := c.GetMeta(ctx, path.Join(dir, "missing")); !errors.Is(err, pcs.ErrFileNotExist) {
+		t.Errorf("GetMeta of a missing file: %v, want ErrFileNotExist", err)
+	}
+}
+
+func TestReplayBlockUpload(t *testing.T) {
+	ctx := context.Background()
+	c, _ := replayClient(t)
+	dir := replayDir(t)
+	removeDir(t, c, dir)
+
+	first := bytes.Repeat([]byte("a"), 1000)
+	local := filepath.Join(t.TempDir(), "first")
+	if err := os.WriteFile(local, first, 0644); err != nil {
+		t.Fatal(err)
+	}
+	b1, _, err := c.BlockUpload(ctx, local)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second := []byte("second block")
+	b2, _, err := c.BlockUploadReader(ctx, bytes.NewReader(second), int64(len(second)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	target := path.Join(dir, "joined.bin")
+	f, _, err := c.CreateSuperFile(ctx, target, []string{b1.Md5, b2.Md5}, &pcs.FileOptions{Path: target})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Size != uint64(len(first)+len(second)) {
+		t.Errorf("CreateSuperFile returned %+v", f)
+	}
+}
+
+func TestReplayRapidUpload(t *testing.T) {
+	ctx := context.Background()
+	c, _ := replayClient(t)
+	dir := replayDir(t)
+	removeDir(t, c, dir)
+
+	// 秒传只接受大于256KB的文件
+	content := bytes.Repeat([]byte("rapid upload "), 30000)
+	local := filepath.Join(t.TempDir(), "big.bin")
+	if err := os.WriteFile(local, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := c.Upload(ctx, local, &pcs.FileOptions{Path: path.Join(dir, "big.bin")}); err != nil {
+		t.Fatal(err)
+	}
+	n, md5, sliceMd5, crc, err := c.SumFile(local)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, _, err := c.RapidUpload(ctx, &pcs.RapiduUploadOptions{
+		Path:          path.Join(dir, "copy.bin"),
+		ContentLength: n,
+		ContentMd5:    md5,
+		SliceMd5:      sliceMd5,
+		ContentCrc32:  strconv.FormatUint(uint64(crc), 10),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Size != uint64(len(content)) || f.Md5 != md5 {
+		t.Errorf("RapidUpload returned %+v, want md5 %s", f, md5)
+	}
+}
+
+func TestReplayLocate(t *testing.T) {
+	ctx := context.Background()
+	c, _ := replayClient(t)
+	dir := replayDir(t)
+	removeDir(t, c, dir)
+
+	servers, _, err := c.LocateUpload(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if servers.Host == "" || len(servers.Servers) == 0 {
+		t.Errorf("LocateUpload returned %+v", servers)
+	}
+
+	f := putFile(t, c, path.Join(dir, "a.txt"), []byte("located"))
+	locs, _, err := c.LocateDownload(ctx, f.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(locs.URLs) == 0 || locs.URLs[0].URL == "" {
+		t.Errorf("LocateDownload returned %+v", locs)
+	}
+}
+
+func TestReplayDownload(t *testing.T) {
+	ctx := context.Background()
+	c, _ := replayClient(t)
+	dir := replayDir(t)
+	removeDir(t, c, dir)
+
+	content := []byte("0123456789abcdef")
+	f := putFile(t, c, path.Join(dir, "digits.txt"), content)
+
+	resp, err := c.Download(ctx, f.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := readBody(t, resp); !bytes.Equal(got, content) {
+		t.Errorf("Download = %q, want %q", got, content)
+	}
+	resp, err = c.PartialDownload(ctx, f.Path, 2, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := resp.Header.Get("Content-Range"); resp.StatusCode != http.StatusPartialContent || got != "bytes 2-5/16" {
+		t.Errorf("PartialDownload(2, 5) = %d %q, want 206 %q", resp.StatusCode, got, "bytes 2-5/16")
+	}
+	if got := readBody(t, resp); string(got) != "2345" {
+		t.Errorf("PartialDownload(2, 5) = %q, want %q", got, "2345")
+	}
+	var buf bytes.Buffer
+	if _, err := c.DownloadTo(ctx, f.Path, &buf); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), content) {
+		t.Errorf("DownloadTo = %q, want %q", buf.Bytes(), content)
+	}
+	resp, err = c.DownloadStream(ctx, f.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := readBody(t, resp); !bytes.Equal(got, content) {
+		t.Errorf("DownloadStream = %q, want %q", got, content)
+	}
+	if _, err := c.Download(ctx, path.Join(dir, "missing")); !errors.Is(err, pcs.ErrFileNotExist) {
+		t.Errorf("Download of a missing file: %v, want ErrFileNotExist", err)
+	}
+}
+
+func TestReplayListFiles(t *testing.T) {
+	ctx := context.Background()
+	c, _ := replayClient(t)
+	dir := replayDir(t)
+	removeDir(t, c, dir)
+
+	if _, _, err := c.Mkdir(ctx, path.Join(dir, "sub")); err != nil {
+		t.Fatal(err)
+	}
+	putFile(t, c, path.Join(dir, "a.txt"), []byte("a"))
+	putFile(t, c, path.Join(dir, "b.txt"), []byte("bb"))
+
+	files, _, err := c.ListFiles(ctx, &pcs.ListFilesOptions{Path: dir, By: "name", Order: "asc"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, f := range files {
+		if f.FsId == 0 {
+			t.Errorf("ListFiles returned incomplete entry: %+v", f)
+		}
+		names = append(names, path.Base(f.Path))
+	}
+	if strings.Join(names, ",") != "a.txt,b.txt,sub" {
+		t.Errorf("ListFiles(%q) = %v", dir, names)
+	}
+}
+
+func TestReplayMkdir(t *testing.T) {
+	ctx := context.Background()
+	c, _ := replayClient(t)
+	dir := replayDir(t)
+	removeDir(t, c, dir)
+
+	f, _, err := c.Mkdir(ctx, path.Join(dir, "new"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !f.IsDirectory() || f.Path != path.Join(dir, "new") {
+		t.Errorf("Mkdir returned %+v", f)
+	}
+	meta, _, err := c.GetMeta(ctx, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !meta.HasSubDir() {
+		t.Errorf("GetMeta(%q) reports no subdirectory: %+v", dir, meta)
+	}
+}
+
+func TestReplayMoveCopy(t *testing.T) {
+	ctx := context.Background()
+	c, _ := replayClient(t)
+	dir := replayDir(t)
+	removeDir(t, c, dir)
+
+	a := putFile(t, c, path.Join(dir, "a.txt"), []byte("a"))
+	b := putFile(t, c, path.Join(dir, "b.txt"), []byte("b"))
+
+	if _, _, err := c.Copy(ctx, a.Path, path.Join(dir, "a-copy.txt")); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := c.Move(ctx, b.Path, path.Join(dir, "b-moved.txt")); err != nil {
+		t.Fatal(err)
+	}
+	copied, _, err := c.BatchCopy(ctx, []*pcs.FTPair{
+		{From: a.Path, To: path.Join(dir, "copies", "a.txt")},
+		{From: path.Join(dir, "b-moved.txt"), To: path.Join(dir, "copies", "b.txt")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(copied.Extra.List) != 2 {
+		t.Errorf("BatchCopy returned %+v", copied.Extra.List)
+	}
+	moved, _, err := c.BatchMove(ctx, []*pcs.FTPair{
+		{From: path.Join(dir, "copies", "a.txt"), To: path.Join(dir, "moved", "a.txt")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(moved.Extra.List) != 1 || moved.Extra.List[0].To != path.Join(dir, "moved", "a.txt") {
+		t.Errorf("BatchMove returned %+v", moved.Extra.List)
+	}
+	if _, _, err := c.Move(ctx, a.Path, path.Join(dir, "a-copy.txt")); !errors.Is(err, pcs.ErrAlreadyExists) {
+		t.Errorf("Move onto an existing file: %v, want ErrAlreadyExists", err)
+	}
+}
+
+func TestReplayDeleteAndRestore(t *testing.T) {
+	ctx := context.Background()
+	c, _ := replayClient(t)
+	dir := replayDir(t)
+	removeDir(t, c, dir)
+
+	a := putFile(t, c, path.Join(dir, "a.txt"), []byte("a"))
+	b := putFile(t, c, path.Join(dir, "b.txt"), []byte("b"))
+	d := putFile(t, c, path.Join(dir, "d.txt"), []byte("d"))
+	if _, err := c.Delete(ctx, a.Path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.BatchDelete(ctx, []string{b.Path, d.Path}); err != nil {
+		t.Fatal(err)
+	}
+
+	recycled, _, err := c.ListRecycle(ctx, &pcs.ListRecycleOptions{Limit: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := make(map[uint64]bool)
+	for _, f := range recycled.List {
+		found[f.FsId] = true
+	}
+	if !found[a.FsId] || !found[b.FsId] || !found[d.FsId] {
+		t.Errorf("ListRecycle misses deleted files: %+v", recycled.List)
+	}
+
+	id := func(f *pcs.File) string { return strconv.FormatUint(f.FsId, 10) }
+	if _, _, err := c.Restore(ctx, id(a)); err != nil {
+		t.Fatal(err)
+	}
+	restored, _, err := c.BatchRestore(ctx, []string{id(b), id(d)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(restored.Extra.List) != 2 {
+		t.Errorf("BatchRestore returned %+v", restored.Extra.List)
+	}
+	files, _, err := c.ListFiles(ctx, &pcs.ListFilesOptions{Path: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 3 {
+		t.Errorf("%d files after restoring 3", len(files))
+	}
+}
+
+// TestReplayEmptyRecycle empties the recycle bin of the account, so record
+// it only with a test account.
+func TestReplayEmptyRecycle(t *testing.T) {
+	ctx := context.Background()
+	c, _ := replayClient(t)
+	dir := replayDir(t)
+
+	putFile(t, c, path.Join(dir, "a.txt"), []byte("a"))
+	if _, err := c.Delete(ctx, dir); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.EmptyRecycle(ctx); err != nil {
+		t.Fatal(err)
+	}
+	recycled, _, err := c.ListRecycle(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recycled.List) != 0 {
+		t.Errorf("recycle bin not empty: %+v", recycled.List)
+	}
+}
+
+func TestReplaySearch(t *testing.T) {
+	ctx := context.Background()
+	c, _ := replayClient(t)
+	dir := replayDir(t)
+	removeDir(t, c, dir)
+
+	putFile(t, c, path.Join(dir, "report-2020.txt"), []byte("1"))
+	putFile(t, c, path.Join(dir, "sub", "report-2021.txt"), []byte("2"))
+	putFile(t, c, path.Join(dir, "notes.txt"), []byte("3"))
+
+	files, _, err := c.Search(ctx, &pcs.SearchOptions{Path: dir, Word: "report", Re: "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Errorf("Search found %d files, want 2: %+v", len(files), files)
+	}
+	files, _, err = c.Search(ctx, &pcs.SearchOptions{Path: dir, Word: "report"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Errorf("non-recursive Search found %d files, want 1: %+v", len(files), files)
+	}
+}
+
+func TestReplayThumbnail(t *testing.T) {
+	ctx := context.Background()
+	c, _ := replayClient(t)
+	dir := replayDir(t)
+	removeDir(t, c, dir)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, image.NewGray(image.Rect(0, 0, 64, 64)), nil); err != nil {
+		t.Fatal(err)
+	}
+	f := putFile(t, c, path.Join(dir, "photo.jpg"), buf.Bytes())
+	resp, err := c.Thumbnail(ctx, &pcs.ThumbnailOptions{Path: f.Path, Width: 32, Height: 32})
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, _, err := image.Decode(bytes.NewReader(readBody(t, resp)))
+	if err != nil {
+		t.Fatalf("thumbnail is not an image: %v", err)
+	}
+	if b := img.Bounds(); b.Dx() > 32 || b.Dy() > 32 {
+		t.Errorf("thumbnail is %v, want at most 32x32", b)
+	}
+}
+
+func TestReplayDiff(t *testing.T) {
+	ctx := context.Background()
+	c, _ := replayClient(t)
+	dir := replayDir(t)
+	removeDir(t, c, dir)
+
+	first, _, err := c.Changes(ctx, "")
+	if err != nil || first.Cursor == "" {
+		t.Fatalf("Changes(\"\") = %+v, %v", first, err)
+	}
+	// 已有的全部内容可能分多页返回，取最后一页的 cursor
+	for first.HasMore {
+		if first, _, err = c.Changes(ctx, first.Cursor); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	f := putFile(t, c, path.Join(dir, "changed.txt"), []byte("changed"))
+	next, _, err := c.Changes(ctx, first.Cursor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := next.Entries[f.Path]; e == nil || e.IsDelete != 0 {
+		t.Errorf("Changes(%q) = %+v, want %s", first.Cursor, next.Entries, f.Path)
+	}
+}
+
+func TestReplayStreaming(t *testing.T) {
+	ctx := context.Background()
+	c, _ := replayClient(t)
+	dir := replayDir(t)
+	removeDir(t, c, dir)
+
+	f := putFile(t, c, path.Join(dir, "clip.mp4"), []byte("not really a video"))
+	resp, err := c.Streaming(ctx, f.Path, "M3U8_480_360")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body := readBody(t, resp); !bytes.HasPrefix(body, []byte("#EXTM3U")) {
+		t.Errorf("Streaming returned %q, want a playlist", body)
+	}
+
+	list, _, err := c.ListStream(ctx, &pcs.ListStreamOptions{Type: "video", FilterPath: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if list.Total != 1 || len(list.List) != 1 || list.List[0].Path != f.Path {
+		t.Errorf("ListStream returned %+v", list)
+	}
+}
+
+func TestReplayOfflineDownload(t *testing.T) {
+	ctx := context.Background()
+	c, _ := replayClient(t)
+	dir := replayDir(t)
+
+	id, _, err := c.AddOfflineDownloadTask(ctx, &pcs.AddTaskOptions{
+		SavePath:  dir,
+		SourceURL: "https://example.com/index.html",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tasks, err := c.QueryOfflineTasks(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tasks[id] == nil || tasks[id].SourceURL != "https://example.com/index.html" {
+		t.Errorf("QueryOfflineTasks(%d) = %+v", id, tasks)
+	}
+	found := false
+	for task, err := range c.OfflineTasks(ctx, &pcs.ListTaskOptions{Limit: 10}) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		found = found || task.ID == id
+	}
+	if !found {
+		t.Errorf("OfflineTasks misses task %d", id)
+	}
+	if _, err := c.CancelOfflineDownloadTask(ctx, &pcs.CancelTaskOptions{TaskId: strconv.FormatInt(id, 10)}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestReplayTorrentInfo needs a torrent with two files at testRoot/replay.torrent
+// when it is recorded against the live service.
+func TestReplayTorrentInfo(t *testing.T) {
+	ctx := context.Background()
+	c, srv := replayClient(t)
+	p := path.Join(testRoot, "replay.torrent")
+	if srv != nil {
+		srv.PutTorrent(p, []pcs.TorrentFile{{Name: "a/one.txt", Size: 1}, {Name: "a/two.txt", Size: 2}})
+	}
+
+	info, _, err := c.QueryTorrentInfo(ctx, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Sha1 == "" || len(info.Files) != 2 || info.Files[0].Index != 1 {
+		t.Errorf("QueryTorrentInfo returned %+v", info)
+	}
+}
+
+func TestReplayShare(t *testing.T) {
+	ctx := context.Background()
+	c, _ := replayClient(t)
+	dir := replayDir(t)
+	removeDir(t, c, dir)
+
+	putFile(t, c, path.Join(dir, "shared", "a.txt"), []byte("a"))
+	putFile(t, c, path.Join(dir, "shared", "sub", "b.txt"), []byte("b"))
+	link, _, err := c.CreateShare(ctx, "abcd", pcs.ShareOneDay, path.Join(dir, "shared"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	links, _, err := c.ListShareLinks(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, l := range links {
+		found = found || l.ShareID == link.ShareID
+	}
+	if !found {
+		t.Errorf("ListShareLinks misses share %d: %+v", link.ShareID, links)
+	}
+
+	s, _, err := c.OpenShare(ctx, link.Link, "abcd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Files) != 1 || !s.Files[0].IsDirectory() {
+		t.Fatalf("OpenShare returned %+v", s.Files)
+	}
+	files, _, err := c.ListShare(ctx, s, s.Files[0].Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Errorf("ListShare returned %+v", files)
+	}
+	if _, err := c.SaveShare(ctx, s, path.Join(dir, "saved"), s.Files[0].FsId); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.CancelShares(ctx, link.ShareID); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,217 @@
+package pcs_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/holys/baidu-pcs/v2"
+	"github.com/holys/baidu-pcs/v2/pcstest"
+)
+
+// captureTransport 记录每个请求，并以 body 作为响应
+type captureTransport struct {
+	body string
+
+	mu   sync.Mutex
+	reqs []*http.Request
+	form []url.Values
+	raw  [][]byte
+}
+
+func (ct *captureTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var form url.Values
+	var data []byte
+	if req.Body != nil {
+		data, _ = io.ReadAll(req.Body)
+		req.Body.Close()
+		if strings.HasPrefix(req.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+			form, _ = url.ParseQuery(string(data))
+		}
+	}
+	ct.mu.Lock()
+	ct.reqs = append(ct.reqs, req)
+	ct.form = append(ct.form, form)
+	ct.raw = append(ct.raw, data)
+	ct.mu.Unlock()
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(ct.body)),
+		Request:    req,
+	}, nil
+}
+
+func (ct *captureTransport) last(t *testing.T) (*http.Request, url.Values) {
+	t.Helper()
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+	if len(ct.reqs) == 0 {
+		t.Fatal("no request sent")
+	}
+	return ct.reqs[len(ct.reqs)-1], ct.form[len(ct.form)-1]
+}
+
+func captureClient(body string) (*pcs.Client, *captureTransport) {
+	ct := &captureTransport{body: body}
+	return pcs.NewClient("tok", pcs.WithHTTPClient(&http.Client{Transport: ct})), ct
+}
+
+func checkURL(t *testing.T, req *http.Request, host, path, method string) {
+	t.Helper()
+	q := req.URL.Query()
+	if req.URL.Host != host || req.URL.Path != path || q.Get("method") != method || q.Get("access_token") != "tok" {
+		t.Errorf("request sent to %s, want https://%s%s?method=%s with the access token", req.URL, host, path, method)
+	}
+}
+
+func TestRequestURLs(t *testing.T) {
+	ctx := context.Background()
+	c, ct := captureClient(`{"quota": 10, "used": 1}`)
+
+	if _, _, err := c.GetQuota(ctx); err != nil {
+		t.Fatal(err)
+	}
+	req, _ := ct.last(t)
+	checkURL(t, req, "pcs.baidu.com", "/rest/2.0/pcs/quota", "info")
+
+	// 没有以 / 结尾的基准 URL 也按目录解析
+	c.SetBaseURL(&url.URL{Scheme: "https", Host: "pcs.example", Path: "/rest/2.0/pcs"})
+	if _, _, err := c.GetQuota(ctx); err != nil {
+		t.Fatal(err)
+	}
+	req, _ = ct.last(t)
+	checkURL(t, req, "pcs.example", "/rest/2.0/pcs/quota", "info")
+}
+
+func TestRequestHostsAndParameters(t *testing.T) {
+	ctx := context.Background()
+	local := filepath.Join(t.TempDir(), "a.txt")
+	if err := os.WriteFile(local, []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c, ct := captureClient(`{"path": "/apps/t/a.txt", "size": 5, "md5": "5d41402abc4b2a76b9719d911017c592"}`)
+	if _, _, err := c.Upload(ctx, local, &pcs.FileOptions{Path: "/apps/t/a.txt"}); err != nil {
+		t.Fatal(err)
+	}
+	req, _ := ct.last(t)
+	checkURL(t, req, "c.pcs.baidu.com", "/rest/2.0/pcs/file", "upload")
+	// 整个 multipart 请求体的md5，不只是文件内容的
+	ct.mu.Lock()
+	sum := md5.Sum(ct.raw[len(ct.raw)-1])
+	ct.mu.Unlock()
+	if got := req.Header.Get("Content-MD5"); got != base64.StdEncoding.EncodeToString(sum[:]) {
+		t.Errorf("Content-MD5 = %s, want the digest of the request body", got)
+	}
+
+	var buf bytes.Buffer
+	if _, err := c.DownloadTo(ctx, "/apps/t/a.txt", &buf); err != nil {
+		t.Fatal(err)
+	}
+	req, _ = ct.last(t)
+	checkURL(t, req, "d.pcs.baidu.com", "/rest/2.0/pcs/file", "download")
+
+	blocks := []string{"5d41402abc4b2a76b9719d911017c592", "7d793037a0760186574b0282f2f435e7"}
+	if _, _, err := c.CreateSuperFile(ctx, "/apps/t/b.txt", blocks, &pcs.FileOptions{Path: "/apps/t/b.txt"}); err != nil {
+		t.Fatal(err)
+	}
+	req, form := ct.last(t)
+	checkURL(t, req, "pcs.baidu.com", "/rest/2.0/pcs/file", "createsuperfile")
+	if want := `{"block_list":["` + strings.Join(blocks, `","`) + `"]}`; form.Get("param") != want {
+		t.Errorf("createsuperfile param = %s, want %s", form.Get("param"), want)
+	}
+
+	c, ct = captureClient(`{"task_id": 7, "request_id": 1}`)
+	if _, _, err := c.AddOfflineDownloadTask(ctx, &pcs.AddTaskOptions{SavePath: "/apps/t", SourceURL: "https://example.com/a"}); err != nil {
+		t.Fatal(err)
+	}
+	req, _ = ct.last(t)
+	checkURL(t, req, "pcs.baidu.com", "/rest/2.0/services/cloud_dl", "add_task")
+}
+
+func TestGetMetaDecodesList(t *testing.T) {
+	c, _ := captureClient(`{"list": [{"path": "/apps/t/a.txt", "size": 5, "isdir": 0}], "request_id": 1}`)
+	meta, _, err := c.GetMeta(context.Background(), "/apps/t/a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.Path != "/apps/t/a.txt" || meta.Size != 5 {
+		t.Errorf("GetMeta = %+v", meta.File)
+	}
+
+	c, _ = captureClient(`{"list": [], "request_id": 1}`)
+	if _, _, err := c.GetMeta(context.Background(), "/apps/t/a.txt"); err != pcs.ErrInvalidResponse {
+		t.Errorf("empty list: %v, want ErrInvalidResponse", err)
+	}
+}
+
+func TestResponseBodiesAreReadable(t *testing.T) {
+	ctx := context.Background()
+	srv := pcstest.NewServer()
+	defer srv.Close()
+	c := srv.NewClient(pcs.WithMaxConcurrentRequests(1))
+	content := []byte("hello, world")
+	srv.PutFile("/apps/t/a.bin", content)
+	srv.PutFile("/apps/t/b.jpg", content)
+
+	read := func(name string, resp *http.Response, err error) []byte {
+		t.Helper()
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		defer resp.Body.Close()
+		data, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatalf("%s: reading the body: %v", name, err)
+		}
+		return data
+	}
+
+	resp, err := c.Download(ctx, "/apps/t/a.bin")
+	if got := read("Download", resp, err); !bytes.Equal(got, content) {
+		t.Errorf("Download = %q, want %q", got, content)
+	}
+	// 未指定类型的范围响应会被识别为文本
+	resp, err = c.PartialDownload(ctx, "/apps/t/a.bin", 0, 4)
+	if got := read("PartialDownload", resp, err); string(got) != "hello" {
+		t.Errorf("PartialDownload(0, 4) = %q, want %q", got, "hello")
+	}
+	resp, err = c.DownloadStream(ctx, "/apps/t/a.bin")
+	if got := read("DownloadStream", resp, err); !bytes.Equal(got, content) {
+		t.Errorf("DownloadStream = %q, want %q", got, content)
+	}
+	resp, err = c.Thumbnail(ctx, &pcs.ThumbnailOptions{Path: "/apps/t/b.jpg", Width: 8, Height: 8})
+	if got := read("Thumbnail", resp, err); !bytes.HasPrefix(got, []byte{0xff, 0xd8}) {
+		t.Errorf("Thumbnail returned %d bytes that are not a JPEG image", len(got))
+	}
+	resp, err = c.Diff(ctx, "null")
+	if got := read("Diff", resp, err); !bytes.Contains(got, []byte(`"/apps/t/a.bin"`)) {
+		t.Errorf("Diff = %s, want an entry for /apps/t/a.bin", got)
+	}
+
+	// 正文关闭前请求一直占用名额
+	resp, err = c.Download(ctx, "/apps/t/a.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	short, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if _, _, err := c.GetMeta(short, "/apps/t/a.bin"); err == nil {
+		t.Error("GetMeta got a request slot while a download body was open")
+	}
+	resp.Body.Close()
+	if _, _, err := c.GetMeta(ctx, "/apps/t/a.bin"); err != nil {
+		t.Errorf("GetMeta after closing the body: %v", err)
+	}
+}
@@ -0,0 +1,85 @@
+package pcs_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/holys/baidu-pcs/v2"
+	"github.com/holys/baidu-pcs/v2/pcstest"
+)
+
+func TestRestoreBackupRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	srv := pcstest.NewServer()
+	defer srv.Close()
+	c := srv.NewClient()
+	src := t.TempDir()
+	writeFiles(t, src, map[string]string{"a": "alpha", "d/b": "bravo", "d/e/c": "charlie"})
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := os.Chtimes(filepath.Join(src, "d", "b"), mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	set, err := c.Backup(ctx, src, "/apps/bk", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	set, err = c.ReadBackupSet(ctx, "/apps/bk", set.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 内容相同的本地文件不再下载，内容不同的被覆盖，清单之外的文件保持不变
+	dst := t.TempDir()
+	writeFiles(t, dst, map[string]string{"a": "alpha", "d/b": "stale", "extra": "x"})
+	done, err := c.RestoreBackup(ctx, set, dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(done) != 2 || done[0].Path != "d/b" || done[1].Path != "d/e/c" {
+		t.Errorf("restored %+v, want d/b and d/e/c", done)
+	}
+	for rel, want := range map[string]string{"a": "alpha", "d/b": "bravo", "d/e/c": "charlie", "extra": "x"} {
+		if data, err := os.ReadFile(filepath.Join(dst, filepath.FromSlash(rel))); err != nil || string(data) != want {
+			t.Errorf("%s = %q, %v; want %q", rel, data, err, want)
+		}
+	}
+	if fi, err := os.Stat(filepath.Join(dst, "d", "b")); err != nil || !fi.ModTime().Equal(mtime) {
+		t.Errorf("d/b modified at %v, want %v", fi.ModTime(), mtime)
+	}
+
+	done, err = c.RestoreBackup(ctx, set, dst)
+	if err != nil || len(done) != 0 {
+		t.Errorf("second RestoreBackup = %+v, %v; want nothing downloaded", done, err)
+	}
+}
+
+func TestRestoreBackupChecksumMismatch(t *testing.T) {
+	ctx := context.Background()
+	srv := pcstest.NewServer()
+	defer srv.Close()
+	c := srv.NewClient()
+	src := t.TempDir()
+	writeFiles(t, src, map[string]string{"a": "alpha"})
+	set, err := c.Backup(ctx, src, "/apps/bk", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.PutFile(set.Files[0].Remote, []byte("tampered"))
+
+	dst := t.TempDir()
+	writeFiles(t, dst, map[string]string{"a": "local"})
+	done, err := c.RestoreBackup(ctx, set, dst)
+	if !errors.Is(err, pcs.ErrChecksumMismatch) || len(done) != 0 {
+		t.Fatalf("RestoreBackup = %+v, %v; want ErrChecksumMismatch", done, err)
+	}
+	if data, err := os.ReadFile(filepath.Join(dst, "a")); err != nil || string(data) != "local" {
+		t.Errorf("a = %q, %v after the failed restore", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "a.part")); !os.IsNotExist(err) {
+		t.Errorf("a.part left behind: %v", err)
+	}
+}
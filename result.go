@@ -0,0 +1,56 @@
+package pcs
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// Result holds the decoded response of a request together with the
+// *http.Response it came from, so that the outcome of a call can be passed
+// around as one value, e.g. over a channel when issuing requests
+// concurrently.
+type Result[T any] struct {
+	Value    *T
+	Response *http.Response
+	Err      error
+}
+
+// Unwrap returns the fields of r in the order the Client methods return
+// them.
+func (r Result[T]) Unwrap() (*T, *http.Response, error) {
+	return r.Value, r.Response, r.Err
+}
+
+// DoJSON sends req with c.Do and decodes the JSON response into a new T.
+// It is meant for endpoints the Client does not wrap yet; req is usually
+// built with NewRequest and a URL from the PCS documentation.
+func DoJSON[T any](ctx context.Context, c *Client, req *http.Request) Result[T] {
+	v, resp, err := do[T](ctx, c, req)
+	return Result[T]{Value: v, Response: resp, Err: err}
+}
+
+// do sends req and decodes the JSON response into a new T, which is nil
+// when the request fails.
+func do[T any](ctx context.Context, c *Client, req *http.Request) (*T, *http.Response, error) {
+	return decode[T](func(v any) (*http.Response, error) { return c.Do(ctx, req, v) })
+}
+
+// get is do for a GET request of u.
+func get[T any](ctx context.Context, c *Client, u string) (*T, *http.Response, error) {
+	return decode[T](func(v any) (*http.Response, error) { return c.Get(ctx, u, v) })
+}
+
+// postForm is do for a POST request of u with the form data.
+func postForm[T any](ctx context.Context, c *Client, u string, data url.Values) (*T, *http.Response, error) {
+	return decode[T](func(v any) (*http.Response, error) { return c.PostForm(ctx, u, data, v) })
+}
+
+func decode[T any](send func(v any) (*http.Response, error)) (*T, *http.Response, error) {
+	v := new(T)
+	resp, err := send(v)
+	if err != nil {
+		return nil, resp, err
+	}
+	return v, resp, nil
+}
@@ -0,0 +1,173 @@
+package pcs
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	defaultRetryBackoff     = 500 * time.Millisecond
+	defaultThrottleAttempts = 3
+	defaultThrottleWait     = time.Minute
+)
+
+// RetryBudget caps the retries made on behalf of one logical operation,
+// such as a sync of many files, across all of its requests. Once either
+// limit is reached, failing requests return their error instead of being
+// retried. A RetryBudget is safe for concurrent use.
+type RetryBudget struct {
+	deadline time.Time // zero for no limit
+
+	mu      sync.Mutex
+	retries int // remaining, negative for no limit
+}
+
+// NewRetryBudget returns a budget that allows at most maxRetries retries in
+// total, and none after maxElapsed has passed. Zero or negative values mean
+// no limit.
+func NewRetryBudget(maxRetries int, maxElapsed time.Duration) *RetryBudget {
+	b := &RetryBudget{retries: -1}
+	if maxRetries > 0 {
+		b.retries = maxRetries
+	}
+	if maxElapsed > 0 {
+		b.deadline = time.Now().Add(maxElapsed)
+	}
+	return b
+}
+
+// Remaining returns the number of retries left, or -1 if unlimited.
+func (b *RetryBudget) Remaining() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.retries
+}
+
+// take consumes one retry, reporting false if the budget is exhausted. A
+// nil budget always allows the retry.
+func (b *RetryBudget) take() bool {
+	if b == nil {
+		return true
+	}
+	if !b.deadline.IsZero() && time.Now().After(b.deadline) {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch {
+	case b.retries < 0:
+		return true
+	case b.retries == 0:
+		return false
+	}
+	b.retries--
+	return true
+}
+
+type budgetKey struct{}
+
+// WithRetryBudget returns a copy of ctx whose requests share the retries
+// allowed by b. Requests are only retried when the Client was created
+// WithRetry; the budget bounds the total on top of the per-request limit.
+func WithRetryBudget(ctx context.Context, b *RetryBudget) context.Context {
+	return context.WithValue(ctx, budgetKey{}, b)
+}
+
+func retryBudget(ctx context.Context) *RetryBudget {
+	b, _ := ctx.Value(budgetKey{}).(*RetryBudget)
+	return b
+}
+
+type idempotentKey struct{}
+
+// idempotent returns a copy of ctx whose requests Do may retry although
+// they are sent with POST: repeating them has no further effect, or the
+// caller recognizes the error caused by its own earlier attempt, see
+// trackRetries. GET and HEAD requests are always idempotent.
+func idempotent(ctx context.Context) context.Context {
+	return context.WithValue(ctx, idempotentKey{}, true)
+}
+
+func isIdempotent(ctx context.Context, req *http.Request) bool {
+	switch req.Method {
+	case "GET", "HEAD":
+		return true
+	}
+	return ctx.Value(idempotentKey{}) != nil
+}
+
+// retryable reports whether a failed attempt may succeed when repeated:
+// the request never got a response, or PCS answered with a temporary
+// error. Failures after the response was received, such as an interrupted
+// download, are not retried since part of the body may have been consumed.
+func retryable(resp *http.Response, err error) bool {
+	var reqErr *RequestError
+	if errors.As(err, &reqErr) {
+		return resp == nil && !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) &&
+			!errors.Is(err, ErrClientClosed) && !errors.Is(err, ErrCircuitOpen)
+	}
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.Temporary()
+}
+
+// throttled reports whether err is PCS asking the client to slow down,
+// either with 429 Too Many Requests or a frequency limit error code.
+func throttled(err error) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Response == nil {
+		return false
+	}
+	return apiErr.Response.StatusCode == http.StatusTooManyRequests || temporaryCodes[apiErr.Code]
+}
+
+// retryAfter returns the delay requested by the Retry-After header of
+// resp, given either in seconds or as an HTTP date.
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	if resp == nil {
+		return 0, false
+	}
+	v := resp.Header.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return max(time.Until(t), 0), true
+	}
+	return 0, false
+}
+
+// replay returns a copy of req that can be sent again, or nil if its body
+// cannot be recreated.
+func replay(req *http.Request) *http.Request {
+	next := req.Clone(req.Context())
+	if req.Body != nil && req.Body != http.NoBody {
+		if req.GetBody == nil {
+			return nil
+		}
+		body, err := req.GetBody()
+		if err != nil {
+			return nil
+		}
+		next.Body = body
+	}
+	return next
+}
+
+// sleep waits for d, reporting false if ctx is done first.
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,45 @@
+package pcs_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/holys/baidu-pcs/v2"
+	"github.com/holys/baidu-pcs/v2/pcstest"
+)
+
+func TestRetryOnlyIdempotentRequests(t *testing.T) {
+	ctx := context.Background()
+	srv := pcstest.NewServer()
+	defer srv.Close()
+	c := srv.NewClient(pcs.WithRetry(3, time.Millisecond))
+	srv.PutFile("/apps/t/a.txt", []byte("a"))
+	task := &pcs.AddTaskOptions{SavePath: "/apps/t/dl", SourceURL: "https://example.com/a.iso"}
+
+	srv.FailNext("file", "meta", http.StatusServiceUnavailable, 0)
+	if _, _, err := c.GetMeta(ctx, "/apps/t/a.txt"); err != nil {
+		t.Errorf("GetMeta was not retried: %v", err)
+	}
+	srv.FailNext("file", "mkdir", http.StatusServiceUnavailable, 0)
+	if _, _, err := c.Mkdir(ctx, "/apps/t/dir"); err != nil {
+		t.Errorf("Mkdir was not retried: %v", err)
+	}
+
+	// 服务端可能已经创建了任务，重试会创建第二个
+	srv.FailNext("cloud_dl", "add_task", http.StatusServiceUnavailable, 0)
+	if _, _, err := c.AddOfflineDownloadTask(ctx, task); err == nil {
+		t.Error("AddOfflineDownloadTask was retried")
+	}
+	srv.FailNext("file", "move", http.StatusServiceUnavailable, 0)
+	if _, _, err := c.BatchMove(ctx, []*pcs.FTPair{{From: "/apps/t/a.txt", To: "/apps/t/b.txt"}}); err == nil {
+		t.Error("BatchMove was retried")
+	}
+
+	// 被限流的请求没有执行，总是可以重试
+	srv.ThrottleNext("cloud_dl", "add_task", time.Millisecond)
+	if _, _, err := c.AddOfflineDownloadTask(ctx, task); err != nil {
+		t.Errorf("throttled AddOfflineDownloadTask was not retried: %v", err)
+	}
+}
@@ -0,0 +1,74 @@
+package pcs
+
+import (
+	"fmt"
+	"time"
+)
+
+// 检查时间窗口的间隔，窗口以分钟为单位
+const scheduleInterval = 30 * time.Second
+
+// TimeWindow 是每天的一个时间段（本地时间），Start 和 End 为距离零点的时长。
+// End 小于 Start 时表示跨越零点，例如 23:00-06:00。
+type TimeWindow struct {
+	Start, End time.Duration
+}
+
+// ParseTimeWindow 解析形如 "01:00-07:00" 的时间段。
+func ParseTimeWindow(s string) (TimeWindow, error) {
+	var h1, m1, h2, m2 int
+	if _, err := fmt.Sscanf(s, "%d:%d-%d:%d", &h1, &m1, &h2, &m2); err != nil {
+		return TimeWindow{}, invalid("window", "%q is not of the form hh:mm-hh:mm", s)
+	}
+	for _, v := range [][2]int{{h1, m1}, {h2, m2}} {
+		if v[0] < 0 || v[0] > 24 || v[1] < 0 || v[1] > 59 || v[0] == 24 && v[1] != 0 {
+			return TimeWindow{}, invalid("window", "%q is not a valid time of day", s)
+		}
+	}
+	return TimeWindow{
+		Start: time.Duration(h1)*time.Hour + time.Duration(m1)*time.Minute,
+		End:   time.Duration(h2)*time.Hour + time.Duration(m2)*time.Minute,
+	}, nil
+}
+
+func (w TimeWindow) String() string {
+	return fmt.Sprintf("%02d:%02d-%02d:%02d",
+		int(w.Start.Hours()), int(w.Start.Minutes())%60,
+		int(w.End.Hours()), int(w.End.Minutes())%60)
+}
+
+// Contains 报告 t 是否在时间段内。
+func (w TimeWindow) Contains(t time.Time) bool {
+	y, mo, d := t.Date()
+	since := t.Sub(time.Date(y, mo, d, 0, 0, 0, 0, t.Location()))
+	if w.Start <= w.End {
+		return since >= w.Start && since < w.End
+	}
+	return since >= w.Start || since < w.End
+}
+
+func (w TimeWindow) MarshalText() ([]byte, error) {
+	return []byte(w.String()), nil
+}
+
+func (w *TimeWindow) UnmarshalText(text []byte) error {
+	v, err := ParseTimeWindow(string(text))
+	if err != nil {
+		return err
+	}
+	*w = v
+	return nil
+}
+
+// inWindows 报告 t 是否落在任一时间段内，没有设置时间段表示不受限制。
+func inWindows(windows []TimeWindow, t time.Time) bool {
+	if len(windows) == 0 {
+		return true
+	}
+	for _, w := range windows {
+		if w.Contains(t) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,380 @@
+package pcs
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// ShareFile 是分享链接中的一个文件或目录
+type ShareFile struct {
+	FsId  uint64 `json:"fs_id"`
+	Path  string `json:"path"` // 在分享者网盘中的路径
+	Name  string `json:"server_filename"`
+	Size  uint64 `json:"size"`
+	IsDir uint   `json:"isdir"`
+}
+
+// IsDirectory 报告 f 是否是目录
+func (f ShareFile) IsDirectory() bool {
+	return f.IsDir != 0
+}
+
+// Share 是通过提取码验证的分享链接，由 OpenShare 返回
+type Share struct {
+	ShortURL string      // 分享链接的短码
+	ShareID  uint64      // 分享ID
+	UK       uint64      // 分享者的用户ID
+	Files    []ShareFile // 分享的顶层文件和目录
+
+	sekey string // 验证提取码后得到的凭据
+}
+
+// ParseShareLink 从分享链接中取出短码和链接中附带的提取码（没有时为空）。
+// 支持 https://pan.baidu.com/s/1xxxx?pwd=abcd 、https://pan.baidu.com/share/init?surl=xxxx 以及 surl 参数形式的短码。
+func ParseShareLink(link string) (surl, pwd string, err error) {
+	link = strings.TrimSpace(link)
+	if !strings.Contains(link, "/") {
+		surl = link
+	} else {
+		u, err := url.Parse(link)
+		if err != nil {
+			return "", "", invalid("link", "%v", err)
+		}
+		q := u.Query()
+		pwd = q.Get("pwd")
+		if s := q.Get("surl"); s != "" {
+			surl = s
+		} else if i := strings.LastIndex(u.Path, "/s/"); i >= 0 {
+			surl = u.Path[i+len("/s/"):]
+		}
+	}
+	// /s/ 后的短码比 surl 参数多一个前导的 1
+	if len(surl) > 1 && surl[0] == '1' && strings.Contains(link, "/s/") {
+		surl = surl[1:]
+	}
+	if surl == "" || strings.ContainsAny(surl, "/?#") {
+		return "", "", invalid("link", "not a share link: %q", link)
+	}
+	return surl, pwd, nil
+}
+
+// panDo 以 method 调用网盘的 share 接口，form 不为 nil 时以 POST 发送。
+// opt 中的参数属于分享者的网盘，不经过 addOptions 对路径参数的处理。
+func (c *Client) panDo(ctx context.Context, method string, opt interface{}, form url.Values, v interface{}) (*http.Response, error) {
+	u, err := c.addOptions("share", method, nil)
+	if err != nil {
+		return nil, err
+	}
+	qs, err := encodeQuery(opt)
+	if err != nil {
+		return nil, err
+	}
+	u += "&" + qs.Encode()
+
+	var req *http.Request
+	if form == nil {
+		req, err = c.newRequest(func() *url.URL { return c.PanURL }, "GET", u, nil)
+	} else {
+		req, err = c.newRequest(func() *url.URL { return c.PanURL }, "POST", u, strings.NewReader(form.Encode()))
+		if req != nil {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return c.Do(ctx, req, v)
+}
+
+// 验证分享链接的提取码并列出分享的顶层文件。
+// link 的格式见 ParseShareLink，pwd 为空时使用链接中附带的提取码。
+func (c *Client) OpenShare(ctx context.Context, link, pwd string) (*Share, *http.Response, error) {
+	surl, linkPwd, err := ParseShareLink(link)
+	if err != nil {
+		return nil, nil, err
+	}
+	if pwd == "" {
+		pwd = linkPwd
+	}
+
+	verify := struct {
+		Randsk string `json:"randsk"`
+		panStatus
+	}{}
+	opt := struct {
+		Surl string `url:"surl"`
+	}{surl}
+	resp, err := c.panDo(idempotent(ctx), "verify", &opt, url.Values{"pwd": {pwd}}, &verify)
+	if err != nil {
+		return nil, resp, err
+	}
+	if err := verify.err(resp); err != nil {
+		return nil, resp, err
+	}
+
+	s := &Share{ShortURL: surl, sekey: verify.Randsk}
+	s.Files, resp, err = c.listShare(ctx, s, "")
+	if err != nil {
+		return nil, resp, err
+	}
+	return s, resp, nil
+}
+
+// 列出分享中目录 dir 下的文件，dir 为 ShareFile.Path
+func (c *Client) ListShare(ctx context.Context, s *Share, dir string) ([]ShareFile, *http.Response, error) {
+	if dir == "" {
+		return nil, nil, invalid("dir", "dir is required")
+	}
+	return c.listShare(ctx, s, dir)
+}
+
+// listShare 列出分享中的目录 dir，dir 为空时列出顶层文件并记录分享ID和分享者
+func (c *Client) listShare(ctx context.Context, s *Share, dir string) ([]ShareFile, *http.Response, error) {
+	opt := struct {
+		Shorturl string `url:"shorturl"`
+		Sekey    string `url:"sekey"`
+		Root     int    `url:"root,omitempty"`
+		Dir      string `url:"dir,omitempty"`
+	}{Shorturl: s.ShortURL, Sekey: s.sekey, Dir: dir}
+	if dir == "" {
+		opt.Root = 1
+	}
+
+	result := struct {
+		ShareID uint64      `json:"share_id"`
+		UK      uint64      `json:"uk"`
+		List    []ShareFile `json:"list"`
+		panStatus
+	}{}
+	resp, err := c.panDo(ctx, "list", &opt, nil, &result)
+	if err != nil {
+		return nil, resp, err
+	}
+	if err := result.err(resp); err != nil {
+		return nil, resp, err
+	}
+	if dir == "" {
+		s.ShareID, s.UK = result.ShareID, result.UK
+	}
+	return result.List, resp, nil
+}
+
+// 将分享中的文件或目录 fsids 转存到自己网盘的目录 dest 下
+func (c *Client) SaveShare(ctx context.Context, s *Share, dest string, fsids ...uint64) (*http.Response, error) {
+	if err := validateRemotePath("path", dest); err != nil {
+		return nil, err
+	}
+	if len(fsids) == 0 {
+		return nil, invalid("fsidlist", "at least one fs_id is required")
+	}
+	list, err := json.Marshal(fsids)
+	if err != nil {
+		return nil, err
+	}
+
+	opt := struct {
+		ShareID uint64 `url:"shareid"`
+		From    uint64 `url:"from"`
+		Sekey   string `url:"sekey"`
+	}{s.ShareID, s.UK, s.sekey}
+	form := url.Values{
+		"fsidlist": {string(list)},
+		"path":     {c.remotePath(dest)},
+	}
+
+	var result panStatus
+	resp, err := c.panDo(ctx, "transfer", &opt, form, &result)
+	if err != nil {
+		return resp, err
+	}
+	return resp, result.err(resp)
+}
+
+// SaveShareLink 打开分享链接，将 match 选中的文件和目录转存到自己网盘的 dest 下，
+// 并保持它们在分享中的目录结构。match 为 nil 时转存全部文件；
+// 未选中的目录会继续列出其中的文件。返回已转存的文件和目录，出错时也返回出错前转存的部分。
+func (c *Client) SaveShareLink(ctx context.Context, link, pwd, dest string, match func(ShareFile) bool) ([]ShareFile, error) {
+	s, _, err := c.OpenShare(ctx, link, pwd)
+	if err != nil {
+		return nil, err
+	}
+
+	var saved []ShareFile
+	var save func(rel string, files []ShareFile) error
+	save = func(rel string, files []ShareFile) error {
+		var selected []ShareFile
+		var fsids []uint64
+		for _, f := range files {
+			if match == nil || match(f) {
+				selected = append(selected, f)
+				fsids = append(fsids, f.FsId)
+				continue
+			}
+			if f.IsDirectory() {
+				children, _, err := c.ListShare(ctx, s, f.Path)
+				if err != nil {
+					return err
+				}
+				if err := save(path.Join(rel, f.Name), children); err != nil {
+					return err
+				}
+			}
+		}
+		if len(fsids) == 0 {
+			return nil
+		}
+		if _, err := c.SaveShare(ctx, s, path.Join(dest, rel), fsids...); err != nil {
+			return err
+		}
+		saved = append(saved, selected...)
+		return nil
+	}
+	if err := save("", s.Files); err != nil {
+		return saved, err
+	}
+	return saved, nil
+}
+
+// 分享链接的有效期，单位为天
+const (
+	SharePermanent = 0
+	ShareOneDay    = 1
+	ShareOneWeek   = 7
+	ShareOneMonth  = 30
+)
+
+// shareLinkPageSize 是 ListShareLinks 每次请求的分享数
+const shareLinkPageSize = 100
+
+// ShareLink 是自己创建的分享链接
+type ShareLink struct {
+	ShareID uint64   `json:"shareId"`
+	Link    string   `json:"shortlink"`
+	Pwd     string   `json:"passwd"`
+	FsIds   []uint64 `json:"fsIds"`
+	Path    string   `json:"typicalPath"` // 分享的第一个文件或目录
+	Ctime   int64    `json:"ctime"`
+	Expire  int64    `json:"expiredTime"` // 过期时间，0 表示永久有效
+}
+
+// 分享自己网盘中的文件和目录 paths，返回分享链接。pwd 是 4 位字母或数字的提取码，
+// period 是有效期天数，取值见 SharePermanent 等常量。
+func (c *Client) CreateShare(ctx context.Context, pwd string, period int, paths ...string) (*ShareLink, *http.Response, error) {
+	if len(pwd) != 4 || strings.IndexFunc(pwd, func(r rune) bool {
+		return !('0' <= r && r <= '9' || 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z')
+	}) >= 0 {
+		return nil, nil, invalid("pwd", "must be 4 letters or digits, got %q", pwd)
+	}
+	switch period {
+	case SharePermanent, ShareOneDay, ShareOneWeek, ShareOneMonth:
+	default:
+		return nil, nil, invalid("period", "must be 0, 1, 7 or 30 days, got %d", period)
+	}
+	if len(paths) == 0 {
+		return nil, nil, invalid("path", "at least one path is required")
+	}
+	for _, p := range paths {
+		if err := validateRemotePath("path", p); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	metas, resp, err := c.BatchGetMeta(ctx, paths)
+	if err != nil {
+		return nil, resp, err
+	}
+	fsids := make([]uint64, len(metas))
+	for i, m := range metas {
+		fsids[i] = m.FsId
+	}
+	list, err := json.Marshal(fsids)
+	if err != nil {
+		return nil, nil, err
+	}
+	form := url.Values{
+		"fid_list":     {string(list)},
+		"schannel":     {"4"}, // 带提取码的私密分享
+		"channel_list": {"[]"},
+		"pwd":          {pwd},
+		"period":       {strconv.Itoa(period)},
+	}
+
+	result := struct {
+		ShareID uint64 `json:"shareid"`
+		Link    string `json:"link"`
+		Ctime   int64  `json:"ctime"`
+		Expire  int64  `json:"expiretime"`
+		panStatus
+	}{}
+	resp, err = c.panDo(ctx, "set", struct{}{}, form, &result)
+	if err != nil {
+		return nil, resp, err
+	}
+	if err := result.err(resp); err != nil {
+		return nil, resp, err
+	}
+	return &ShareLink{
+		ShareID: result.ShareID,
+		Link:    result.Link,
+		Pwd:     pwd,
+		FsIds:   fsids,
+		Path:    c.remotePath(paths[0]),
+		Ctime:   result.Ctime,
+		Expire:  result.Expire,
+	}, resp, nil
+}
+
+// 列出自己创建的全部分享链接，按创建时间从新到旧排列
+func (c *Client) ListShareLinks(ctx context.Context) ([]*ShareLink, *http.Response, error) {
+	var (
+		links []*ShareLink
+		resp  *http.Response
+	)
+	for page := 1; ; page++ {
+		opt := struct {
+			Page  int    `url:"page"`
+			Num   int    `url:"num"`
+			Order string `url:"order"`
+			Desc  int    `url:"desc"`
+		}{page, shareLinkPageSize, "ctime", 1}
+		result := struct {
+			List []*ShareLink `json:"list"`
+			panStatus
+		}{}
+		var err error
+		resp, err = c.panDo(ctx, "record", &opt, nil, &result)
+		if err != nil {
+			return links, resp, err
+		}
+		if err := result.err(resp); err != nil {
+			return links, resp, err
+		}
+		links = append(links, result.List...)
+		if len(result.List) < shareLinkPageSize {
+			return links, resp, nil
+		}
+	}
+}
+
+// 取消自己创建的分享链接 ids
+func (c *Client) CancelShares(ctx context.Context, ids ...uint64) (*http.Response, error) {
+	if len(ids) == 0 {
+		return nil, invalid("shareid_list", "at least one share id is required")
+	}
+	list, err := json.Marshal(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	var result panStatus
+	resp, err := c.panDo(ctx, "cancel", struct{}{}, url.Values{"shareid_list": {string(list)}}, &result)
+	if err != nil {
+		return resp, err
+	}
+	return resp, result.err(resp)
+}
@@ -0,0 +1,118 @@
+package pcs
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SnapshotEntry 是远程目录快照中的一个文件或目录
+type SnapshotEntry struct {
+	Path  string `json:"path"` // 相对于快照根目录的路径，以 / 分隔
+	IsDir bool   `json:"isdir,omitempty"`
+	Size  uint64 `json:"size"`
+	Md5   string `json:"md5,omitempty"`
+	Mtime uint64 `json:"mtime"`
+	FsId  uint64 `json:"fs_id"`
+}
+
+// TreeSnapshot 是远程目录在某一时刻的完整列表，可用 WriteSnapshot 保存，
+// 之后用 DiffSnapshots 与新的快照比较，检查两次备份之间或与预期状态相比发生了哪些变化。
+type TreeSnapshot struct {
+	Root    string          `json:"root"`
+	Time    time.Time       `json:"time"`
+	Entries []SnapshotEntry `json:"entries"` // 按路径排序
+}
+
+// SnapshotChange 是同一路径在两个快照中不同的条目
+type SnapshotChange struct {
+	Path string        `json:"path"`
+	Old  SnapshotEntry `json:"old"`
+	New  SnapshotEntry `json:"new"`
+}
+
+// SnapshotDiff 是 DiffSnapshots 的结果，各项均按路径排序
+type SnapshotDiff struct {
+	Added   []SnapshotEntry  `json:"added"`
+	Removed []SnapshotEntry  `json:"removed"`
+	Changed []SnapshotChange `json:"changed"`
+}
+
+// Empty 报告两个快照是否没有差别
+func (d *SnapshotDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// SnapshotTree 列出远程目录 root 下的全部文件和目录，返回其快照。root 不存在时返回的快照为空。
+// 与 Find 不同，任一目录列出失败时不返回不完整的快照。
+func (c *Client) SnapshotTree(ctx context.Context, root string) (*TreeSnapshot, error) {
+	root = CleanPath(root)
+	start := time.Now()
+	files, err := c.Find(ctx, root, nil)
+	if err != nil && !isNotExist(err) {
+		return nil, err
+	}
+
+	prefix := strings.TrimSuffix(root, "/") + "/"
+	s := &TreeSnapshot{Root: root, Time: start, Entries: make([]SnapshotEntry, 0, len(files))}
+	for _, f := range files {
+		s.Entries = append(s.Entries, SnapshotEntry{
+			Path:  strings.TrimPrefix(f.Path, prefix),
+			IsDir: f.IsDirectory(),
+			Size:  f.Size,
+			Md5:   f.Md5,
+			Mtime: f.Mtime,
+			FsId:  f.FsId,
+		})
+	}
+	return s, nil
+}
+
+// WriteSnapshot 将快照以 JSON 格式写入 w。
+func WriteSnapshot(w io.Writer, s *TreeSnapshot) error {
+	return writeJSON(w, s)
+}
+
+// ReadSnapshot 读取 WriteSnapshot 写入的快照。
+func ReadSnapshot(r io.Reader) (*TreeSnapshot, error) {
+	s := new(TreeSnapshot)
+	if err := json.NewDecoder(r).Decode(s); err != nil {
+		return nil, err
+	}
+	sort.Slice(s.Entries, func(i, j int) bool { return s.Entries[i].Path < s.Entries[j].Path })
+	return s, nil
+}
+
+// DiffSnapshots 按相对路径比较快照 old 和 new，因此也可以比较不同根目录的快照，例如两次备份的目录。
+// 文件的大小或md5不同，或者同一路径在一个快照中是文件、在另一个中是目录时视为修改；
+// 只有修改时间或 FsId 不同（例如内容相同的重新上传）不算修改。
+func DiffSnapshots(old, new *TreeSnapshot) *SnapshotDiff {
+	before := make(map[string]SnapshotEntry, len(old.Entries))
+	for _, e := range old.Entries {
+		before[e.Path] = e
+	}
+
+	d := &SnapshotDiff{Added: []SnapshotEntry{}, Removed: []SnapshotEntry{}, Changed: []SnapshotChange{}}
+	for _, e := range new.Entries {
+		o, ok := before[e.Path]
+		if !ok {
+			d.Added = append(d.Added, e)
+			continue
+		}
+		delete(before, e.Path)
+		if o.IsDir != e.IsDir || !o.IsDir && (o.Size != e.Size || !strings.EqualFold(o.Md5, e.Md5)) {
+			d.Changed = append(d.Changed, SnapshotChange{Path: e.Path, Old: o, New: e})
+		}
+	}
+	for _, o := range before {
+		d.Removed = append(d.Removed, o)
+	}
+
+	sort.Slice(d.Added, func(i, j int) bool { return d.Added[i].Path < d.Added[j].Path })
+	sort.Slice(d.Removed, func(i, j int) bool { return d.Removed[i].Path < d.Removed[j].Path })
+	sort.Slice(d.Changed, func(i, j int) bool { return d.Changed[i].Path < d.Changed[j].Path })
+	return d
+}
@@ -0,0 +1,84 @@
+package pcs_test
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/holys/baidu-pcs/v2"
+	"github.com/holys/baidu-pcs/v2/pcstest"
+)
+
+func paths(entries []pcs.SnapshotEntry) []string {
+	ps := []string{}
+	for _, e := range entries {
+		ps = append(ps, e.Path)
+	}
+	return ps
+}
+
+func TestSnapshotDiff(t *testing.T) {
+	ctx := context.Background()
+	srv := pcstest.NewServer()
+	defer srv.Close()
+	c := srv.NewClient()
+	srv.PutFile("/apps/t/a", []byte("alpha"))
+	srv.PutFile("/apps/t/d/b", []byte("bravo"))
+	srv.PutFile("/apps/t/d/c", []byte("charlie"))
+	srv.PutFile("/apps/t/e", []byte("echo"))
+	srv.PutFile("/apps/t/f", []byte("foxtrot"))
+
+	old, err := c.SnapshotTree(ctx, "/apps/t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a", "d", "d/b", "d/c", "e", "f"}; !reflect.DeepEqual(paths(old.Entries), want) {
+		t.Errorf("snapshot entries %v, want %v", paths(old.Entries), want)
+	}
+	var buf bytes.Buffer
+	if err := pcs.WriteSnapshot(&buf, old); err != nil {
+		t.Fatal(err)
+	}
+	if old, err = pcs.ReadSnapshot(&buf); err != nil || old.Root != "/apps/t" || len(old.Entries) != 6 {
+		t.Fatalf("ReadSnapshot = %+v, %v", old, err)
+	}
+
+	srv.PutFile("/apps/t/a", []byte("alpha"))   // 内容相同的重新上传
+	srv.PutFile("/apps/t/d/b", []byte("BRAVO")) // 修改
+	if _, err := c.Delete(ctx, "/apps/t/d/c"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Delete(ctx, "/apps/t/e"); err != nil {
+		t.Fatal(err)
+	}
+	srv.PutFile("/apps/t/e/x", []byte("x")) // 文件变为目录
+	srv.PutFile("/apps/t/g", []byte("golf"))
+
+	cur, err := c.SnapshotTree(ctx, "/apps/t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := pcs.DiffSnapshots(old, cur)
+	var changed []string
+	for _, ch := range d.Changed {
+		changed = append(changed, ch.Path)
+	}
+	if want := []string{"e/x", "g"}; !reflect.DeepEqual(paths(d.Added), want) {
+		t.Errorf("added %v, want %v", paths(d.Added), want)
+	}
+	if want := []string{"d/c"}; !reflect.DeepEqual(paths(d.Removed), want) {
+		t.Errorf("removed %v, want %v", paths(d.Removed), want)
+	}
+	if want := []string{"d/b", "e"}; !reflect.DeepEqual(changed, want) {
+		t.Errorf("changed %v, want %v", changed, want)
+	}
+	if d.Empty() || !pcs.DiffSnapshots(cur, cur).Empty() {
+		t.Error("Empty reports the wrong result")
+	}
+
+	missing, err := c.SnapshotTree(ctx, "/apps/none")
+	if err != nil || len(missing.Entries) != 0 {
+		t.Errorf("snapshot of a missing directory = %+v, %v", missing, err)
+	}
+}
@@ -0,0 +1,101 @@
+package pcs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// 保存 FileStamp 的扩展属性名
+const stampXattr = "user.baidu-pcs.stamp"
+
+// FileStamp 记录本地文件与远程文件的对应关系，保存在本地文件的扩展属性或旁路文件中。
+// 本地文件的大小和修改时间与记录一致时可以认为内容未变，同步时直接比较 Md5，
+// 不必重新计算大文件的哈希。
+type FileStamp struct {
+	RemotePath string    `json:"remote_path"`
+	Md5        string    `json:"md5"`      // 远程文件的md5，加密上传时为密文的md5
+	FsId       uint64    `json:"fs_id"`    // 远程文件的 fs_id
+	Mtime      uint64    `json:"mtime"`    // 远程文件的修改时间
+	Size       int64     `json:"size"`     // 记录时本地文件的大小
+	ModTime    time.Time `json:"mod_time"` // 记录时本地文件的修改时间
+}
+
+// Matches 报告本地文件 fi 的大小和修改时间是否与记录时一致。
+func (s *FileStamp) Matches(fi fs.FileInfo) bool {
+	return fi.Size() == s.Size && fi.ModTime().Equal(s.ModTime)
+}
+
+// stampSidecar 返回 localPath 的旁路文件，与其位于同一目录的隐藏文件。
+func stampSidecar(localPath string) string {
+	return filepath.Join(filepath.Dir(localPath), "."+filepath.Base(localPath)+".pcs-stamp")
+}
+
+// isSidecar 报告文件名 name 是否是 stampSidecar 生成的旁路文件，同步时不应上传
+func isSidecar(name string) bool {
+	return strings.HasPrefix(name, ".") && strings.HasSuffix(name, ".pcs-stamp")
+}
+
+// WriteStamp 将 s 保存到本地文件 localPath 的扩展属性中；sidecar 为 true，
+// 或者平台、文件系统不支持扩展属性时，保存到同一目录下的隐藏旁路文件中。
+func WriteStamp(localPath string, s *FileStamp, sidecar bool) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	if !sidecar {
+		err := setXattr(localPath, stampXattr, data)
+		if !errors.Is(err, errors.ErrUnsupported) {
+			return err
+		}
+	}
+	return os.WriteFile(stampSidecar(localPath), data, 0644)
+}
+
+// ReadStamp 读取 WriteStamp 为 localPath 保存的记录，先查找扩展属性，再查找旁路文件。
+// 都不存在时返回的错误满足 errors.Is(err, fs.ErrNotExist)。
+func ReadStamp(localPath string) (*FileStamp, error) {
+	data, err := getXattr(localPath, stampXattr)
+	if errors.Is(err, fs.ErrNotExist) || errors.Is(err, errors.ErrUnsupported) {
+		data, err = os.ReadFile(stampSidecar(localPath))
+	}
+	if err != nil {
+		return nil, err
+	}
+	s := new(FileStamp)
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// StampHook 返回在传输成功后为本地文件保存 FileStamp 的 TransferHook，sidecar 的含义同 WriteStamp。
+// 远程文件的信息在传输完成后通过 GetMeta 取得。跳过的任务和符号链接的标记文件不做记录。
+func StampHook(c *Client, sidecar bool) TransferHook {
+	return func(ctx context.Context, job Job) error {
+		if job.Skipped || job.isSymlinkMarker() {
+			return nil
+		}
+		meta, _, err := c.GetMeta(ctx, job.RemotePath)
+		if err != nil {
+			return err
+		}
+		fi, err := os.Stat(job.LocalPath)
+		if err != nil {
+			return err
+		}
+		return WriteStamp(job.LocalPath, &FileStamp{
+			RemotePath: job.RemotePath,
+			Md5:        meta.Md5,
+			FsId:       meta.FsId,
+			Mtime:      meta.Mtime,
+			Size:       fi.Size(),
+			ModTime:    fi.ModTime(),
+		}, sidecar)
+	}
+}
@@ -0,0 +1,33 @@
+package pcs
+
+import (
+	"errors"
+	"io/fs"
+	"syscall"
+)
+
+func setXattr(path, name string, data []byte) error {
+	err := syscall.Setxattr(path, name, data, 0)
+	if errors.Is(err, syscall.ENOTSUP) {
+		return errors.ErrUnsupported
+	}
+	return err
+}
+
+func getXattr(path, name string) ([]byte, error) {
+	for size := 256; ; size *= 2 {
+		buf := make([]byte, size)
+		n, err := syscall.Getxattr(path, name, buf)
+		switch {
+		case errors.Is(err, syscall.ERANGE):
+			continue
+		case errors.Is(err, syscall.ENODATA):
+			return nil, fs.ErrNotExist
+		case errors.Is(err, syscall.ENOTSUP):
+			return nil, errors.ErrUnsupported
+		case err != nil:
+			return nil, err
+		}
+		return buf[:n], nil
+	}
+}
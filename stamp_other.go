@@ -0,0 +1,14 @@
+//go:build !linux
+
+package pcs
+
+import "errors"
+
+// 目前只在 Linux 上使用扩展属性，其他平台使用旁路文件
+func setXattr(path, name string, data []byte) error {
+	return errors.ErrUnsupported
+}
+
+func getXattr(path, name string) ([]byte, error) {
+	return nil, errors.ErrUnsupported
+}
@@ -0,0 +1,31 @@
+package pcs
+
+import (
+	"bytes"
+	"context"
+)
+
+// StreamingPlaylist 请求将视频 path 转码为 typ 格式，返回 M3U8 播放列表的内容，
+// 其中各分段的地址可直接交给播放器。typ 的取值见 Streaming。
+func (c *Client) StreamingPlaylist(ctx context.Context, path, typ string) ([]byte, error) {
+	if !streamingTypes[typ] {
+		return nil, invalid("type", "unsupported streaming type %q", typ)
+	}
+	opt := struct {
+		Path string `url:"path"`
+		Type string `url:"type"`
+	}{path, typ}
+	u, err := c.addOptions("file", "streaming", &opt)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if _, err := c.Get(ctx, u, &buf); err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(bytes.TrimSpace(buf.Bytes()), []byte("#EXTM3U")) {
+		return nil, ErrInvalidResponse
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,128 @@
+package pcs
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// BlockListBuilder 收集分片上传的结果，按分片序号而不是完成顺序生成 CreateSuperFile 所需的
+// block_list，并在合并前检查分片是否齐全、数量是否在 CreateSuperFile 接受的范围内。
+// 各分片可以并发上传后分别调用 Add。零值可以直接使用，可以并发调用。
+//
+//	var b pcs.BlockListBuilder
+//	// 各 goroutine 上传文件中的第 i 个分片后：
+//	f, _, err := client.BlockUploadReader(ctx, io.NewSectionReader(file, off, size), size)
+//	err = b.Add(i, size, f.Md5)
+//	// 全部完成后：
+//	f, _, err = client.CreateSuperFileFromBlocks(ctx, "/apps/x/big.iso", &b, nil)
+type BlockListBuilder struct {
+	mu     sync.Mutex
+	blocks []*Block // 按序号存放，尚未添加的为 nil
+	n      int      // 已添加的分片数
+}
+
+// Add 记录序号为 index（从0开始）的分片，size 为分片大小，md5 为上传分片时返回的md5。
+// 同一序号再次添加时替换之前的结果，例如重新上传失败的分片之后。
+// 参数无效时返回的错误满足 errors.Is(err, ErrInvalidArgument)。
+func (b *BlockListBuilder) Add(index int, size int64, md5 string) error {
+	if index < 0 || index >= maxSuperFileBlocks {
+		return invalid("index", "must be in [0, %d), got %d", maxSuperFileBlocks, index)
+	}
+	if size < 0 {
+		return invalid("size", "must not be negative, got %d", size)
+	}
+	if !md5Pattern.MatchString(md5) {
+		return invalid("md5", "must be a hex encoded md5, got %q", md5)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for len(b.blocks) <= index {
+		b.blocks = append(b.blocks, nil)
+	}
+	if b.blocks[index] == nil {
+		b.n++
+	}
+	b.blocks[index] = &Block{Index: index, Size: size, Md5: strings.ToLower(md5)}
+	return nil
+}
+
+// Len 返回已添加的分片数
+func (b *BlockListBuilder) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.n
+}
+
+// Size 返回已添加的分片的总大小
+func (b *BlockListBuilder) Size() int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var size int64
+	for _, blk := range b.blocks {
+		if blk != nil {
+			size += blk.Size
+		}
+	}
+	return size
+}
+
+// Blocks 按序号返回全部分片，Offset 为分片在合并后的文件中的位置。
+// 序号不连续（有分片尚未添加）或分片数不在 CreateSuperFile 接受的2到1024之间时，
+// 返回的错误满足 errors.Is(err, ErrInvalidArgument)。
+func (b *BlockListBuilder) Blocks() ([]*Block, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.n != len(b.blocks) {
+		var missing []int
+		for i, blk := range b.blocks {
+			if blk == nil {
+				missing = append(missing, i)
+			}
+		}
+		return nil, invalid("index", "missing blocks %v of %d", missing, len(b.blocks))
+	}
+	if b.n < minSuperFileBlocks {
+		return nil, invalid("blocks", "CreateSuperFile needs %d to %d blocks, got %d", minSuperFileBlocks, maxSuperFileBlocks, b.n)
+	}
+
+	blocks := make([]*Block, len(b.blocks))
+	var offset int64
+	for i, blk := range b.blocks {
+		c := *blk
+		c.Offset = offset
+		offset += c.Size
+		blocks[i] = &c
+	}
+	return blocks, nil
+}
+
+// MD5s 按序号返回各分片的md5，即 CreateSuperFile 的 md5 参数。校验同 Blocks。
+func (b *BlockListBuilder) MD5s() ([]string, error) {
+	blocks, err := b.Blocks()
+	if err != nil {
+		return nil, err
+	}
+	md5s := make([]string, len(blocks))
+	for i, blk := range blocks {
+		md5s[i] = blk.Md5
+	}
+	return md5s, nil
+}
+
+// CreateSuperFileFromBlocks 校验 b 中的分片，并按序号将其合并为文件 targetPath。
+// opt 可以为 nil，其 Path 总是设为 targetPath。
+func (c *Client) CreateSuperFileFromBlocks(ctx context.Context, targetPath string, b *BlockListBuilder, opt *FileOptions) (*File, *http.Response, error) {
+	md5s, err := b.MD5s()
+	if err != nil {
+		return nil, nil, err
+	}
+	var o FileOptions
+	if opt != nil {
+		o = *opt
+	}
+	o.Path = targetPath
+	return c.CreateSuperFile(ctx, targetPath, md5s, &o)
+}
@@ -0,0 +1,93 @@
+package pcs_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/holys/baidu-pcs/v2"
+	"github.com/holys/baidu-pcs/v2/pcstest"
+)
+
+func TestBlockListBuilder(t *testing.T) {
+	ctx := context.Background()
+	srv := pcstest.NewServer()
+	defer srv.Close()
+	c := srv.NewClient()
+	parts := []string{"first-", "second-", "third"}
+
+	// 并发上传，完成顺序与序号无关
+	var b pcs.BlockListBuilder
+	var wg sync.WaitGroup
+	errs := make([]error, len(parts))
+	for i := len(parts) - 1; i >= 0; i-- {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			f, _, err := c.BlockUploadReader(ctx, bytes.NewReader([]byte(parts[i])), int64(len(parts[i])))
+			if err == nil {
+				err = b.Add(i, int64(len(parts[i])), f.Md5)
+			}
+			errs[i] = err
+		}(i)
+	}
+	wg.Wait()
+	if err := errors.Join(errs...); err != nil {
+		t.Fatal(err)
+	}
+
+	blocks, err := b.Blocks()
+	if err != nil || b.Len() != 3 || b.Size() != 18 {
+		t.Fatalf("Blocks = %v, %v; Len %d, Size %d", blocks, err, b.Len(), b.Size())
+	}
+	for i, off := range []int64{0, 6, 13} {
+		if blocks[i].Index != i || blocks[i].Offset != off {
+			t.Errorf("block %d = %+v, want offset %d", i, blocks[i], off)
+		}
+	}
+
+	f, _, err := c.CreateSuperFileFromBlocks(ctx, "/apps/t/big", &b, nil)
+	if err != nil || f.Size != 18 {
+		t.Fatalf("CreateSuperFileFromBlocks = %+v, %v", f, err)
+	}
+	if data, ok := srv.ReadFile("/apps/t/big"); string(data) != "first-second-third" {
+		t.Errorf("merged file = %q, %v", data, ok)
+	}
+}
+
+func TestBlockListBuilderRejects(t *testing.T) {
+	const sum = "d41d8cd98f00b204e9800998ecf8427e"
+	var b pcs.BlockListBuilder
+	for _, err := range []error{
+		b.Add(-1, 1, sum),
+		b.Add(1024, 1, sum),
+		b.Add(0, -1, sum),
+		b.Add(0, 1, "not an md5"),
+	} {
+		if !errors.Is(err, pcs.ErrInvalidArgument) {
+			t.Errorf("Add with an invalid argument: %v", err)
+		}
+	}
+	if b.Len() != 0 {
+		t.Fatalf("invalid blocks added: Len %d", b.Len())
+	}
+
+	// 分片不足两个
+	b.Add(0, 1, sum)
+	if _, err := b.Blocks(); !errors.Is(err, pcs.ErrInvalidArgument) {
+		t.Errorf("Blocks with one block: %v", err)
+	}
+	// 缺少序号1
+	b.Add(2, 1, sum)
+	if _, err := b.MD5s(); !errors.Is(err, pcs.ErrInvalidArgument) {
+		t.Errorf("MD5s with block 1 missing: %v", err)
+	}
+	// 重新添加同一序号时替换
+	b.Add(1, 1, sum)
+	b.Add(1, 5, sum)
+	if md5s, err := b.MD5s(); err != nil || len(md5s) != 3 || b.Size() != 7 {
+		t.Errorf("MD5s = %v, %v; Size %d", md5s, err, b.Size())
+	}
+}
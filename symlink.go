@@ -0,0 +1,97 @@
+package pcs
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// 标记文件的大小上限，更大的 .symlink 文件不是标记文件，按普通文件下载
+const maxSymlinkMarker = 4096
+
+// SymlinkSuffix 是 SymlinkMarker 上传的标记文件的后缀
+const SymlinkSuffix = ".symlink"
+
+// SymlinkPolicy 决定 Sync 和传输任务如何处理本地的符号链接。PCS 本身没有符号链接。
+// 零值与 SymlinkSkip 相同，即默认忽略符号链接，以免意外上传链接指向的目录树之外的内容。
+type SymlinkPolicy string
+
+const (
+	SymlinkSkip   SymlinkPolicy = "skip"   // 忽略符号链接
+	SymlinkFollow SymlinkPolicy = "follow" // 当作链接指向的文件或目录，失效的链接被忽略
+	// 上传为 <名字>.symlink 标记文件，内容为链接的目标，下载时重新创建符号链接。
+	// 目标原样保存，相对路径的目标在下载后仍相对于链接所在的目录。
+	SymlinkMarker SymlinkPolicy = "marker"
+)
+
+// symlinkMarker 返回符号链接 p 的标记文件内容
+func symlinkMarker(p string) (string, error) {
+	target, err := os.Readlink(p)
+	if err != nil {
+		return "", err
+	}
+	return filepath.ToSlash(target), nil
+}
+
+func markerMd5(target string) string {
+	sum := md5.Sum([]byte(target))
+	return hex.EncodeToString(sum[:])
+}
+
+// isSymlinkMarker 报告 j 是否是以 SymlinkMarker 传输的标记文件
+func (j *Job) isSymlinkMarker() bool {
+	return j.Symlinks == SymlinkMarker && strings.HasSuffix(j.RemotePath, SymlinkSuffix)
+}
+
+// uploadSymlinkMarker 将符号链接 j.LocalPath 上传为标记文件，j.RemotePath 加上 SymlinkSuffix。
+func (m *TransferManager) uploadSymlinkMarker(ctx context.Context, j *Job) error {
+	target, err := symlinkMarker(j.LocalPath)
+	if err != nil {
+		return err
+	}
+	err = m.update(func() {
+		if !strings.HasSuffix(j.RemotePath, SymlinkSuffix) {
+			j.RemotePath += SymlinkSuffix
+		}
+		j.Size = int64(len(target))
+	})
+	if err != nil {
+		return err
+	}
+	_, err = m.client.uploadStream(ctx, &FileOptions{Path: j.RemotePath, OnDup: j.OnDup}, nil, true, strings.NewReader(target))
+	if err != nil {
+		return err
+	}
+	return m.update(func() { j.Transferred = j.Size })
+}
+
+// downloadSymlinkMarker 读取标记文件 j.RemotePath，在 j.LocalPath 去掉 SymlinkSuffix 的位置
+// 创建符号链接，替换已有的文件。
+func (m *TransferManager) downloadSymlinkMarker(ctx context.Context, j *Job) error {
+	var buf bytes.Buffer
+	if _, err := m.client.DownloadTo(ctx, j.RemotePath, &buf); err != nil {
+		return err
+	}
+
+	link := strings.TrimSuffix(j.LocalPath, SymlinkSuffix)
+	if err := os.MkdirAll(filepath.Dir(link), 0755); err != nil {
+		return err
+	}
+	tmp := link + ".part"
+	os.Remove(tmp)
+	if err := os.Symlink(filepath.FromSlash(buf.String()), tmp); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, link); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return m.update(func() {
+		j.Size = int64(buf.Len())
+		j.Transferred = j.Size
+	})
+}
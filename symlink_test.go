@@ -0,0 +1,58 @@
+package pcs_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/holys/baidu-pcs/v2"
+	"github.com/holys/baidu-pcs/v2/pcstest"
+)
+
+// Sync 和传输任务对符号链接的默认处理相同
+func TestSymlinkPolicyDefault(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	srv := pcstest.NewServer()
+	defer srv.Close()
+	c := srv.NewClient()
+	local := t.TempDir()
+	writeFiles(t, local, map[string]string{"real.txt": "real"})
+	link := filepath.Join(local, "link.txt")
+	if err := os.Symlink("real.txt", link); err != nil {
+		t.Skip("symlinks not supported:", err)
+	}
+
+	done, err := c.Sync(ctx, local, "/apps/s", &pcs.SyncOptions{})
+	if want := "[upload real.txt]"; err != nil || actions(done) != want {
+		t.Errorf("Sync = %s, %v; want %s", actions(done), err, want)
+	}
+
+	m, err := pcs.NewTransferManager(c, filepath.Join(t.TempDir(), "jobs.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	skipped, err := m.AddUpload(link, pcs.NewFileOptions("/apps/t/skipped.txt", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	followed, err := m.AddUpload(link, pcs.NewFileOptions("/apps/t/followed.txt", ""), pcs.WithSymlinkPolicy(pcs.SymlinkFollow))
+	if err != nil {
+		t.Fatal(err)
+	}
+	go m.Run(ctx)
+	if err := m.Wait(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if j, _ := m.Job(skipped); !j.Skipped || srv.Exists("/apps/t/skipped.txt") {
+		t.Errorf("upload with the default policy: %+v", j)
+	}
+	if data, ok := srv.ReadFile("/apps/t/followed.txt"); !ok || string(data) != "real" {
+		t.Errorf("upload with SymlinkFollow = %q, %v", data, ok)
+	}
+	if j, _ := m.Job(followed); j.Skipped {
+		t.Errorf("upload with SymlinkFollow skipped: %+v", j)
+	}
+}
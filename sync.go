@@ -0,0 +1,505 @@
+package pcs
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SyncOp 是 Sync 对一个文件执行的操作
+type SyncOp string
+
+const (
+	SyncUpload SyncOp = "upload"
+	SyncMove   SyncOp = "move" // 在服务端移动内容相同的远程文件，代替删除后重新上传
+	SyncCopy   SyncOp = "copy" // 在服务端复制内容相同的远程文件，代替重新上传
+	SyncDelete SyncOp = "delete"
+	// ConflictKeepBoth 时将改名保留的远程文件下载到本地，使它在两边都存在，不会被之后的 Sync 删除
+	SyncDownload SyncOp = "download"
+)
+
+// SyncAction 是 Sync 执行（DryRun 时为计划执行）的一项操作
+type SyncAction struct {
+	Op   SyncOp `json:"op"`
+	Path string `json:"path"`           // 相对于同步根目录的路径，以 / 分隔
+	From string `json:"from,omitempty"` // SyncMove 和 SyncCopy 时源文件的相对路径
+	Size int64  `json:"size"`
+}
+
+// SyncOptions 是 Sync 的选项
+type SyncOptions struct {
+	Delete bool // 删除本地已不存在的远程文件
+	DryRun bool // 只返回将要执行的操作，不做任何修改
+
+	// 上传或移动后为本地文件保存 FileStamp，之后的 Sync 据此跳过未修改文件的哈希计算，
+	// 并发现远程文件在上次同步之后被修改的冲突。Sidecar 的含义同 WriteStamp。
+	Stamp   bool
+	Sidecar bool
+
+	// 冲突的处理方式，默认为 ConflictPreferLocal，即覆盖远程文件。ConflictKeepBoth
+	// 时先将远程文件在服务端改名为 ConflictName 生成的名字，ConflictName 为 nil 时使用 ConflictSuffix，
+	// 再将其下载为本地的同名文件，这样之后设置了 Delete 的 Sync 也会保留它。
+	Conflict     ConflictPolicy
+	ConflictName ConflictNamer
+
+	// 不为 nil 时，覆盖或删除远程文件之前先用它保存原有版本
+	Versions *Versioning
+
+	// 符号链接的处理方式，见 SymlinkPolicy。SymlinkFollow 时指向目录的链接也被遍历，
+	// 同一目录只遍历一次；SymlinkMarker 时链接同步为远程的 <名字>.symlink 标记文件，不保存 FileStamp。
+	Symlinks SymlinkPolicy
+
+	// 不为 nil 时每执行完一个操作调用一次，可与 DryRun 返回的计划一起显示进度。
+	Progress func(SyncAction)
+
+	// 排除的文件，语法同 path.Match。模式含 / 时匹配相对路径，否则匹配路径中的
+	// 任一级名称，例如 "*.tmp" 和 ".git"。排除的本地文件不上传，远程文件不删除。
+	Exclude []string
+}
+
+// Excludes 报告相对于同步根目录的路径 rel（以 / 分隔）是否被 Exclude 排除。
+func (opt *SyncOptions) Excludes(rel string) bool {
+	for _, p := range opt.Exclude {
+		if strings.Contains(p, "/") {
+			if ok, _ := path.Match(strings.TrimPrefix(p, "/"), rel); ok {
+				return true
+			}
+			continue
+		}
+		for _, name := range strings.Split(rel, "/") {
+			if ok, _ := path.Match(p, name); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// syncFile 是本地目录中的一个文件
+type syncFile struct {
+	rel   string
+	local string
+	info  fs.FileInfo
+	stamp *FileStamp // 与 info 一致的记录，没有时为 nil
+	prev  *FileStamp // 上次同步时的记录，本地文件之后可能被修改过
+	md5   string     // 按需计算
+	link  string     // SymlinkMarker 时符号链接的目标，即标记文件的内容
+
+	hashes *HashCache
+}
+
+func (f *syncFile) size() int64 {
+	if f.link != "" {
+		return int64(len(f.link))
+	}
+	return f.info.Size()
+}
+
+func (f *syncFile) open() (io.ReadCloser, error) {
+	if f.link != "" {
+		return io.NopCloser(strings.NewReader(f.link)), nil
+	}
+	return os.Open(f.local)
+}
+
+func (f *syncFile) sum() (string, error) {
+	if f.md5 == "" {
+		e, err := f.hashes.Sum(f.local)
+		if err != nil {
+			return "", err
+		}
+		f.md5 = e.Md5
+	}
+	return f.md5, nil
+}
+
+// Sync 将本地目录 localRoot 单向同步到远程目录 remoteRoot：上传新增和修改过的文件，
+// Delete 为 true 时删除本地已不存在的远程文件。需要上传的文件与某个远程文件内容相同时
+// （大小和md5一致，或本地文件的 FileStamp 指向该远程文件），改为在服务端移动或复制：
+// 源文件在本地已不存在且设置了 Delete 时移动，否则复制。这样本地整理目录结构后不必重新上传。
+// 符号链接按 opt.Symlinks 处理，其他非普通文件和空目录被忽略。
+//
+// 设置了 Cipher 时上传加密后的内容。由于无法比较密文和明文的md5，远程文件的大小与本地文件加密后
+// 的大小一致，且 FileStamp 指向它或它不早于本地文件的修改时间时视为相同；此时只有 FileStamp
+// 指向的远程文件才会用于移动和复制。
+//
+// 返回已执行的操作，出错或 ctx 被取消时也返回出错前已执行的部分。
+func (c *Client) Sync(ctx context.Context, localRoot, remoteRoot string, opt *SyncOptions) ([]SyncAction, error) {
+	if opt == nil {
+		opt = &SyncOptions{}
+	}
+	remoteRoot = CleanPath(remoteRoot)
+	if err := validateRemotePath("path", remoteRoot); err != nil {
+		return nil, err
+	}
+	for _, p := range opt.Exclude {
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, invalid("exclude", "%q: %v", p, err)
+		}
+	}
+
+	locals, err := syncLocalFiles(ctx, localRoot, c.hashes, opt.Symlinks)
+	if err != nil {
+		return nil, err
+	}
+	if len(opt.Exclude) > 0 {
+		kept := locals[:0]
+		for _, f := range locals {
+			if !opt.Excludes(f.rel) {
+				kept = append(kept, f)
+			}
+		}
+		locals = kept
+	}
+	files, err := c.Find(ctx, remoteRoot, &Filter{Kind: FindFiles})
+	if err != nil && !isNotExist(err) {
+		return nil, err
+	}
+	remotes := make(map[string]*File, len(files))
+	for _, f := range files {
+		rel := strings.TrimPrefix(f.Path, strings.TrimSuffix(remoteRoot, "/")+"/")
+		if !opt.Excludes(rel) {
+			remotes[rel] = f
+		}
+	}
+
+	plan, err := planSync(locals, remotes, remoteRoot, c.cipher, opt)
+	if err != nil {
+		return nil, err
+	}
+	if opt.DryRun {
+		return plan, nil
+	}
+
+	byRel := make(map[string]*syncFile, len(locals))
+	for _, f := range locals {
+		byRel[f.rel] = f
+	}
+	remotePath := func(rel string) string { return path.Join(remoteRoot, rel) }
+	kept := make(map[string]*File) // 冲突时改名保留的远程文件
+	var done []SyncAction
+	for _, a := range plan {
+		if v := opt.Versions; v != nil && (a.Op == SyncDelete || a.Op == SyncUpload && remotes[a.Path] != nil) {
+			if _, err := v.Save(ctx, remotePath(a.Path)); err != nil {
+				return done, err
+			}
+		}
+
+		var uploaded *File
+		switch a.Op {
+		case SyncUpload:
+			uploaded, err = c.syncUpload(ctx, byRel[a.Path], remotePath(a.Path))
+		case SyncMove:
+			_, _, err = c.Move(ctx, remotePath(a.From), remotePath(a.Path))
+			if err == nil {
+				uploaded = remotes[a.From]
+				kept[a.Path] = uploaded
+			}
+		case SyncCopy:
+			_, _, err = c.Copy(ctx, remotePath(a.From), remotePath(a.Path))
+		case SyncDelete:
+			_, err = c.Delete(ctx, remotePath(a.Path))
+		case SyncDownload:
+			err = c.syncDownload(ctx, remotePath(a.Path), filepath.Join(localRoot, filepath.FromSlash(a.Path)), kept[a.Path], opt)
+		}
+		if err != nil {
+			return done, err
+		}
+		done = append(done, a)
+
+		// 冲突时改名的远程文件没有对应的本地文件
+		if f, ok := byRel[a.Path]; ok && opt.Stamp && uploaded != nil && f.link == "" {
+			err := WriteStamp(f.local, &FileStamp{
+				RemotePath: remotePath(a.Path),
+				Md5:        uploaded.Md5,
+				FsId:       uploaded.FsId,
+				Mtime:      uploaded.Mtime,
+				Size:       f.size(),
+				ModTime:    f.info.ModTime(),
+			}, opt.Sidecar)
+			if err != nil {
+				return done, err
+			}
+		}
+		if opt.Progress != nil {
+			opt.Progress(a)
+		}
+	}
+	return done, nil
+}
+
+// syncLocalFiles 列出 root 下的全部普通文件，按相对路径排序。hashes 用于按需计算md5，可以为 nil；
+// links 为符号链接的处理方式，见 SyncOptions.Symlinks。
+func syncLocalFiles(ctx context.Context, root string, hashes *HashCache, links SymlinkPolicy) ([]*syncFile, error) {
+	var files []*syncFile
+	add := func(rel, p string, info fs.FileInfo) {
+		f := &syncFile{rel: rel, local: p, info: info, hashes: hashes}
+		if s, err := ReadStamp(p); err == nil {
+			f.prev = s
+			if s.Matches(info) {
+				f.stamp = s
+			}
+		}
+		files = append(files, f)
+	}
+
+	// 跟随符号链接时已遍历的目录，防止链接形成循环
+	visited := make(map[string]bool)
+	if real, err := filepath.EvalSymlinks(root); err == nil {
+		visited[real] = true
+	}
+
+	var walk func(dir, prefix string) error
+	walk = func(dir, prefix string) error {
+		return filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			isLink := d.Type()&fs.ModeSymlink != 0
+			if !d.Type().IsRegular() && !(isLink && p != dir) || isSidecar(d.Name()) {
+				return nil
+			}
+			rel, err := filepath.Rel(dir, p)
+			if err != nil {
+				return err
+			}
+			rel = path.Join(prefix, filepath.ToSlash(rel))
+
+			if !isLink {
+				info, err := d.Info()
+				if err != nil {
+					return err
+				}
+				add(rel, p, info)
+				return nil
+			}
+			switch links {
+			case SymlinkFollow:
+				info, err := os.Stat(p)
+				if err != nil {
+					return nil
+				}
+				if info.Mode().IsRegular() {
+					add(rel, p, info)
+				} else if real, err := filepath.EvalSymlinks(p); err == nil && info.IsDir() && !visited[real] {
+					visited[real] = true
+					return walk(real, rel)
+				}
+			case SymlinkMarker:
+				target, err := symlinkMarker(p)
+				if err != nil {
+					return err
+				}
+				info, err := d.Info()
+				if err != nil {
+					return err
+				}
+				files = append(files, &syncFile{rel: rel + SymlinkSuffix, local: p, info: info, link: target, md5: markerMd5(target)})
+			}
+			return nil
+		})
+	}
+	if err := walk(root, ""); err != nil {
+		return files, err
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].rel < files[j].rel })
+	return files, nil
+}
+
+// planSync 比较本地和远程文件，返回需要执行的操作：先移动和复制，再下载冲突时保留的文件，然后上传，最后删除。
+// ci 不为 nil 时远程文件是加密的，见 Sync。
+func planSync(locals []*syncFile, remotes map[string]*File, remoteRoot string, ci *Cipher, opt *SyncOptions) ([]SyncAction, error) {
+	del := opt.Delete
+	var changed []*syncFile
+	var moves, downloads, uploads []SyncAction
+	moved := make(map[string]bool)
+	present := make(map[string]bool, len(locals))
+	for _, f := range locals {
+		present[f.rel] = true
+		r, ok := remotes[f.rel]
+		if ok {
+			same, err := sameContent(f, r, ci)
+			if err != nil {
+				return nil, err
+			}
+			if same || ci != nil && f.stamp == nil && int64(r.Size) == ci.EncryptedSize(f.size()) &&
+				f.info.ModTime().Unix() <= int64(r.Mtime) {
+				continue
+			}
+			if f.prev != nil && f.prev.RemotePath == path.Join(remoteRoot, f.rel) &&
+				f.prev.FsId != r.FsId && f.prev.Md5 != r.Md5 {
+				// 远程文件在上次同步之后被修改过
+				switch opt.Conflict {
+				case ConflictPreferRemote:
+					continue
+				case ConflictKeepBoth:
+					keep := conflictPath(f.rel, opt.ConflictName)
+					moves = append(moves, SyncAction{Op: SyncMove, Path: keep, From: f.rel, Size: int64(r.Size)})
+					downloads = append(downloads, SyncAction{Op: SyncDownload, Path: keep, Size: int64(r.Size)})
+					moved[f.rel] = true
+				}
+			}
+		}
+		changed = append(changed, f)
+	}
+
+	// 按大小索引远程文件，只为大小相同的候选计算本地文件的md5
+	bySize := make(map[int64][]string)
+	for rel, r := range remotes {
+		bySize[int64(r.Size)] = append(bySize[int64(r.Size)], rel)
+	}
+	encSize := func(f *syncFile) int64 {
+		if ci != nil {
+			return ci.EncryptedSize(f.size())
+		}
+		return f.size()
+	}
+	for _, rels := range bySize {
+		sort.Strings(rels)
+	}
+	for _, f := range changed {
+		a := SyncAction{Op: SyncUpload, Path: f.rel, Size: f.size()}
+		if _, exists := remotes[f.rel]; !exists {
+			for _, rel := range bySize[encSize(f)] {
+				if moved[rel] {
+					continue
+				}
+				same, err := sameContent(f, remotes[rel], ci)
+				if err != nil {
+					return nil, err
+				}
+				if !same {
+					continue
+				}
+				// 本地仍存在的源文件还要保留，只能复制
+				a.Op, a.From = SyncCopy, rel
+				if del && !present[rel] {
+					a.Op = SyncMove
+					moved[rel] = true
+				}
+				break
+			}
+		}
+		if a.Op == SyncUpload {
+			uploads = append(uploads, a)
+		} else {
+			moves = append(moves, a)
+		}
+	}
+
+	plan := append(append(moves, downloads...), uploads...)
+	if del {
+		var deletes []SyncAction
+		for rel, r := range remotes {
+			if !present[rel] && !moved[rel] {
+				deletes = append(deletes, SyncAction{Op: SyncDelete, Path: rel, Size: int64(r.Size)})
+			}
+		}
+		sort.Slice(deletes, func(i, j int) bool { return deletes[i].Path < deletes[j].Path })
+		plan = append(plan, deletes...)
+	}
+	return plan, nil
+}
+
+// sameContent 报告本地文件 f 与远程文件 r 的内容是否相同。f 的 FileStamp 指向 r 时
+// 不必计算哈希。ci 不为 nil 时 r 是加密的，只能依据 FileStamp 判断。
+func sameContent(f *syncFile, r *File, ci *Cipher) (bool, error) {
+	size := f.size()
+	if ci != nil {
+		size = ci.EncryptedSize(size)
+	}
+	if int64(r.Size) != size {
+		return false, nil
+	}
+	if f.stamp != nil && (f.stamp.FsId == r.FsId || f.stamp.Md5 == r.Md5) {
+		return true, nil
+	}
+	if ci != nil {
+		return false, nil
+	}
+	sum, err := f.sum()
+	if err != nil {
+		return false, err
+	}
+	return strings.EqualFold(sum, r.Md5), nil
+}
+
+// syncDownload 将冲突时改名保留的远程文件 remote 下载到本地 local，local 已存在时返回错误。
+// r 是改名前的远程文件，下载后本地文件的修改时间设为 r.Mtime；opt.Stamp 时同时保存 FileStamp。
+func (c *Client) syncDownload(ctx context.Context, remote, local string, r *File, opt *SyncOptions) error {
+	if _, err := os.Lstat(local); err == nil {
+		return &fs.PathError{Op: "download", Path: local, Err: fs.ErrExist}
+	}
+	if err := os.MkdirAll(filepath.Dir(local), 0755); err != nil {
+		return err
+	}
+	part := local + ".part"
+	f, err := os.Create(part)
+	if err != nil {
+		return err
+	}
+	_, err = c.DownloadTo(ctx, remote, f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(part, local)
+	}
+	if err != nil {
+		os.Remove(part)
+		return err
+	}
+	if r == nil {
+		return nil
+	}
+	mtime := time.Unix(int64(r.Mtime), 0)
+	if err := os.Chtimes(local, mtime, mtime); err != nil {
+		return err
+	}
+	if !opt.Stamp {
+		return nil
+	}
+	fi, err := os.Stat(local)
+	if err != nil {
+		return err
+	}
+	return WriteStamp(local, &FileStamp{
+		RemotePath: remote,
+		Md5:        r.Md5,
+		FsId:       r.FsId,
+		Mtime:      r.Mtime,
+		Size:       fi.Size(),
+		ModTime:    fi.ModTime(),
+	}, opt.Sidecar)
+}
+
+// syncUpload 以覆盖的方式上传本地文件，设置了 Cipher 时上传加密后的内容
+func (c *Client) syncUpload(ctx context.Context, f *syncFile, remote string) (*File, error) {
+	r, err := f.open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	if c.cipher != nil {
+		cf, err := c.cipher.newFile()
+		if err != nil {
+			return nil, err
+		}
+		var src io.ReaderAt = strings.NewReader(f.link)
+		if file, ok := r.(*os.File); ok {
+			src = file
+		}
+		er := cf.encryptReaderAt(src, f.size())
+		r = io.NopCloser(io.NewSectionReader(er, 0, er.Size()))
+	}
+	return c.uploadStream(ctx, &FileOptions{Path: remote, OnDup: OnDupOverwrite}, nil, true, r)
+}
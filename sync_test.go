@@ -0,0 +1,120 @@
+package pcs_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/holys/baidu-pcs/v2"
+	"github.com/holys/baidu-pcs/v2/pcstest"
+)
+
+// actions 将 SyncAction 排序后格式化，便于比较
+func actions(acts []pcs.SyncAction) string {
+	s := make([]string, len(acts))
+	for i, a := range acts {
+		s[i] = fmt.Sprintf("%s %s", a.Op, a.Path)
+		if a.From != "" {
+			s[i] += " from " + a.From
+		}
+	}
+	sort.Strings(s)
+	return fmt.Sprint(s)
+}
+
+func TestSyncPlan(t *testing.T) {
+	ctx := context.Background()
+	srv := pcstest.NewServer()
+	defer srv.Close()
+	c := srv.NewClient()
+	local := t.TempDir()
+	writeFiles(t, local, map[string]string{"a.txt": "alpha", "d/b.txt": "bravo", "d/c.txt": "charlie", "x.tmp": "temp"})
+	exclude := []string{"*.tmp"}
+
+	done, err := c.Sync(ctx, local, "/apps/s", &pcs.SyncOptions{Exclude: exclude})
+	if want := "[upload a.txt upload d/b.txt upload d/c.txt]"; err != nil || actions(done) != want {
+		t.Fatalf("first Sync = %s, %v; want %s", actions(done), err, want)
+	}
+	if srv.Exists("/apps/s/x.tmp") {
+		t.Error("uploaded an excluded file")
+	}
+	if done, err := c.Sync(ctx, local, "/apps/s", &pcs.SyncOptions{Exclude: exclude}); err != nil || len(done) != 0 {
+		t.Errorf("Sync without changes = %s, %v", actions(done), err)
+	}
+
+	// 移动 b.txt，复制 a.txt，修改 c.txt，删除远程多出的文件
+	if err := os.Rename(filepath.Join(local, "d", "b.txt"), filepath.Join(local, "b.txt")); err != nil {
+		t.Fatal(err)
+	}
+	writeFiles(t, local, map[string]string{"e/a.txt": "alpha", "d/c.txt": "charlie, changed"})
+	srv.PutFile("/apps/s/stale.txt", []byte("stale"))
+	srv.PutFile("/apps/s/y.tmp", []byte("excluded"))
+	opt := &pcs.SyncOptions{Delete: true, DryRun: true, Exclude: exclude}
+	plan, err := c.Sync(ctx, local, "/apps/s", opt)
+	want := "[copy e/a.txt from a.txt delete stale.txt move b.txt from d/b.txt upload d/c.txt]"
+	if err != nil || actions(plan) != want {
+		t.Fatalf("planned %s, %v; want %s", actions(plan), err, want)
+	}
+	if !srv.Exists("/apps/s/d/b.txt") || srv.Exists("/apps/s/b.txt") || !srv.Exists("/apps/s/stale.txt") {
+		t.Error("DryRun changed the remote files")
+	}
+
+	opt.DryRun = false
+	done, err = c.Sync(ctx, local, "/apps/s", opt)
+	if err != nil || actions(done) != want {
+		t.Fatalf("Sync = %s, %v; want the plan %s", actions(done), err, want)
+	}
+	for p, data := range map[string]string{"b.txt": "bravo", "e/a.txt": "alpha", "a.txt": "alpha", "d/c.txt": "charlie, changed", "y.tmp": "excluded"} {
+		if got, ok := srv.ReadFile("/apps/s/" + p); !ok || string(got) != data {
+			t.Errorf("%s = %q, %v; want %q", p, got, ok, data)
+		}
+	}
+	if srv.Exists("/apps/s/d/b.txt") || srv.Exists("/apps/s/stale.txt") {
+		t.Error("moved or deleted files are still there")
+	}
+}
+
+func TestSyncKeepBothConflict(t *testing.T) {
+	ctx := context.Background()
+	srv := pcstest.NewServer()
+	defer srv.Close()
+	c := srv.NewClient()
+	local := t.TempDir()
+	writeFiles(t, local, map[string]string{"a.txt": "alpha"})
+	opt := &pcs.SyncOptions{
+		Delete:       true,
+		Stamp:        true,
+		Sidecar:      true,
+		Conflict:     pcs.ConflictKeepBoth,
+		ConflictName: func(name string, _ time.Time) string { return "conflict-" + name },
+	}
+	if _, err := c.Sync(ctx, local, "/apps/s", opt); err != nil {
+		t.Fatal(err)
+	}
+
+	// 上次同步之后两边都修改了 a.txt
+	srv.PutFile("/apps/s/a.txt", []byte("remote change"))
+	writeFiles(t, local, map[string]string{"a.txt": "local change"})
+	done, err := c.Sync(ctx, local, "/apps/s", opt)
+	want := "[download conflict-a.txt move conflict-a.txt from a.txt upload a.txt]"
+	if err != nil || actions(done) != want {
+		t.Fatalf("conflicting Sync = %s, %v; want %s", actions(done), err, want)
+	}
+	if data, err := os.ReadFile(filepath.Join(local, "conflict-a.txt")); err != nil || string(data) != "remote change" {
+		t.Errorf("local conflict copy %q, %v", data, err)
+	}
+
+	// 下一次同步不会删除保留的副本
+	if done, err := c.Sync(ctx, local, "/apps/s", opt); err != nil || len(done) != 0 {
+		t.Errorf("Sync after the conflict = %s, %v", actions(done), err)
+	}
+	for p, data := range map[string]string{"a.txt": "local change", "conflict-a.txt": "remote change"} {
+		if got, ok := srv.ReadFile("/apps/s/" + p); !ok || string(got) != data {
+			t.Errorf("%s = %q, %v; want %q", p, got, ok, data)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package pcs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+// defaultIdleTimeout bounds how long a response body may stall; without it
+// a download from an unresponsive CDN node would hang forever.
+const defaultIdleTimeout = 2 * time.Minute
+
+// ErrIdleTimeout is the cause of the *RequestError returned when no data
+// arrived on a response body for the duration set with WithIdleTimeout.
+// Like other timeouts, it reports Timeout() == true.
+var ErrIdleTimeout error = &timeoutError{"baidu-pcs: response body idle timeout"}
+
+type timeoutError struct{ msg string }
+
+func (e *timeoutError) Error() string   { return e.msg }
+func (e *timeoutError) Timeout() bool   { return true }
+func (e *timeoutError) Temporary() bool { return true }
+
+var _ net.Error = (*timeoutError)(nil)
+
+// idleTimeoutBody cancels the request, with ErrIdleTimeout as the cause,
+// when a Read does not return within d of the previous one.
+type idleTimeoutBody struct {
+	io.ReadCloser
+	d     time.Duration
+	timer *time.Timer
+}
+
+func newIdleTimeoutBody(body io.ReadCloser, d time.Duration, cancel context.CancelCauseFunc) *idleTimeoutBody {
+	return &idleTimeoutBody{
+		ReadCloser: body,
+		d:          d,
+		timer:      time.AfterFunc(d, func() { cancel(ErrIdleTimeout) }),
+	}
+}
+
+func (b *idleTimeoutBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.timer.Reset(b.d)
+	return n, err
+}
+
+func (b *idleTimeoutBody) Close() error {
+	b.timer.Stop()
+	return b.ReadCloser.Close()
+}
+
+// readError wraps an error that occurred while reading the response to req,
+// reporting ErrIdleTimeout rather than a bare cancellation when the body
+// stalled.
+func readError(ctx context.Context, req *http.Request, err error) error {
+	if cause := context.Cause(ctx); errors.Is(cause, ErrIdleTimeout) {
+		err = cause
+	}
+	return &RequestError{Method: req.Method, URL: req.URL.String(), Err: err}
+}
@@ -0,0 +1,90 @@
+package pcs
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// 离线下载任务的类型
+const (
+	TaskTypeURL     = 0 // 从 URL 下载
+	TaskTypeTorrent = 2 // 下载网盘中的BT种子
+)
+
+// TorrentFile 是BT种子中的一个文件
+type TorrentFile struct {
+	Index int    // 序号，从1开始，用于 AddTaskOptions.SelectedIdx
+	Name  string // 文件在种子中的路径
+	Size  int64
+}
+
+// TorrentInfo 是BT种子的信息
+type TorrentInfo struct {
+	Sha1  string
+	Files []TorrentFile
+}
+
+// 查询网盘中的BT种子 sourcePath 包含的文件列表
+func (c *Client) QueryTorrentInfo(ctx context.Context, sourcePath string) (*TorrentInfo, *http.Response, error) {
+	if err := validateRemotePath("source_path", sourcePath); err != nil {
+		return nil, nil, err
+	}
+	opt := struct {
+		Type       int    `url:"type"`
+		SourcePath string `url:"source_path"`
+	}{TaskTypeTorrent, sourcePath}
+
+	u, err := c.addOptions("../services/cloud_dl", "query_sinfo", &opt)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// 接口中的数值以字符串返回
+	result := struct {
+		TorrentInfo struct {
+			FileInfo []struct {
+				FileName string      `json:"file_name"`
+				Size     json.Number `json:"size"`
+			} `json:"file_info"`
+			Sha1 string `json:"sha1"`
+		} `json:"torrent_info"`
+	}{}
+	resp, err := c.PostForm(idempotent(ctx), u, nil, &result)
+	if err != nil {
+		return nil, resp, err
+	}
+
+	info := &TorrentInfo{Sha1: result.TorrentInfo.Sha1}
+	for i, f := range result.TorrentInfo.FileInfo {
+		size, _ := f.Size.Int64()
+		info.Files = append(info.Files, TorrentFile{Index: i + 1, Name: f.FileName, Size: size})
+	}
+	return info, resp, nil
+}
+
+// 添加下载BT种子 sourcePath 中部分文件的离线下载任务，文件保存到 savePath。
+// selected 为 TorrentFile.Index，为空时下载全部文件。
+func (c *Client) AddTorrentTask(ctx context.Context, sourcePath, savePath string, selected ...int) (int64, *http.Response, error) {
+	info, resp, err := c.QueryTorrentInfo(ctx, sourcePath)
+	if err != nil {
+		return 0, resp, err
+	}
+
+	idx := make([]string, len(selected))
+	for i, n := range selected {
+		if n < 1 || n > len(info.Files) {
+			return 0, nil, invalid("selected_idx", "file index %d out of range [1, %d]", n, len(info.Files))
+		}
+		idx[i] = strconv.Itoa(n)
+	}
+	return c.AddOfflineDownloadTask(ctx, &AddTaskOptions{
+		Type:        TaskTypeTorrent,
+		SourcePath:  sourcePath,
+		SavePath:    savePath,
+		SelectedIdx: strings.Join(idx, ","),
+		Sha1:        info.Sha1,
+	})
+}
@@ -0,0 +1,1087 @@
+package pcs
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+const defaultTransferConcurrency = 2
+
+var (
+	ErrJobNotFound   = errors.New("baidu-pcs: transfer job not found")
+	ErrManagerClosed = errors.New("baidu-pcs: transfer manager closed")
+
+	// errDraining 使执行中的任务在当前分片或分段完成后退出，
+	// 由 finish 放回队列。
+	errDraining = errors.New("baidu-pcs: transfer manager shutting down")
+)
+
+// TransferKind 表示传输任务的方向
+type TransferKind string
+
+const (
+	UploadJob   TransferKind = "upload"
+	DownloadJob TransferKind = "download"
+)
+
+// JobStatus 表示传输任务的状态
+type JobStatus string
+
+const (
+	JobPending JobStatus = "pending" // 等待执行，包括进程重启前未完成的任务
+	JobRunning JobStatus = "running"
+	JobPaused  JobStatus = "paused" // 已暂停，断点保留，Resume 后继续
+	JobDone    JobStatus = "done"
+	JobFailed  JobStatus = "failed"
+)
+
+// Job 是 TransferManager 中的一个上传或下载任务，连同断点信息一起持久化。
+type Job struct {
+	ID         string       `json:"id"`
+	Kind       TransferKind `json:"kind"`
+	LocalPath  string       `json:"local_path"`
+	RemotePath string       `json:"remote_path"`
+	OnDup      OnDup        `json:"ondup,omitempty"` // 上传时的同名文件处理方式，见 FileOptions
+	Status     JobStatus    `json:"status"`
+	Priority   int          `json:"priority,omitempty"` // 优先级，数值大的先执行，相同时按队列顺序
+	Err        string       `json:"error,omitempty"`    // 任务失败的原因
+	Created    time.Time    `json:"created"`
+
+	// 允许执行的时间段，为空表示不受限制。时间段外任务保持 JobPending，
+	// 执行中的任务在时间段结束时中断，下一个时间段开始后从断点继续。
+	Windows []TimeWindow `json:"windows,omitempty"`
+
+	Size        int64 `json:"size"`        // 远程文件（加密时为密文）的大小，任务开始执行后确定
+	Transferred int64 `json:"transferred"` // 已传输完成的字节数
+
+	// 上传断点：已上传分片的md5及分片大小，以及开始上传时本地文件的修改时间，
+	// 续传前文件被修改过则从头开始。
+	BlockSize int64     `json:"block_size,omitempty"`
+	Blocks    []string  `json:"blocks,omitempty"`
+	ModTime   time.Time `json:"mod_time,omitempty"`
+
+	// 下载断点：远程文件的md5，续传前远程文件变化则从头开始。
+	// 下载中的数据写入 LocalPath + ".part"，完成后再重命名。
+	Md5 string `json:"md5,omitempty"`
+
+	// 加密传输时文件的加密文件头，续传时沿用，见 WithEncryption。
+	CryptHeader []byte `json:"crypt_header,omitempty"`
+
+	// 下载时本地已有内容不同的文件的处理方式，见 WithConflictPolicy。
+	Conflict ConflictPolicy `json:"conflict,omitempty"`
+	// 因 ConflictPreferLocal 没有下载，本地文件保持原样；或按 SymlinkSkip 没有上传符号链接。
+	Skipped bool `json:"skipped,omitempty"`
+
+	// 符号链接的处理方式，见 WithSymlinkPolicy。
+	Symlinks SymlinkPolicy `json:"symlinks,omitempty"`
+
+	// 传输已经完成，之后只需执行尚未完成的 hook。
+	TransferDone bool `json:"transfer_done,omitempty"`
+	HooksDone    int  `json:"hooks_done,omitempty"` // 已成功执行的 hook 数
+}
+
+func (j *Job) clone() Job {
+	v := *j
+	v.Blocks = append([]string(nil), j.Blocks...)
+	v.Windows = append([]TimeWindow(nil), j.Windows...)
+	return v
+}
+
+// JobOption 配置 AddUpload 或 AddDownload 添加的任务。
+type JobOption func(*Job)
+
+// WithPriority 设置任务的优先级，默认为0。数值大的任务先执行，
+// 例如交互式的小文件下载可以排在批量备份之前。
+func WithPriority(p int) JobOption {
+	return func(j *Job) {
+		j.Priority = p
+	}
+}
+
+// WithTimeWindows 限制任务只在 windows 中的时间段内执行，
+// 例如只在 01:00-07:00 之间进行批量备份。
+func WithTimeWindows(windows ...TimeWindow) JobOption {
+	return func(j *Job) {
+		j.Windows = windows
+	}
+}
+
+// WithConflictPolicy 设置下载任务在本地已有内容不同的文件时的处理方式：默认的
+// ConflictPreferRemote 覆盖本地文件；ConflictPreferLocal 不下载，任务完成并标记为 Skipped；
+// ConflictKeepBoth 先将本地文件改名（见 WithConflictNamer）再保存下载的文件。
+// 对上传任务无效，上传时的同名文件处理方式见 FileOptions.OnDup。
+func WithConflictPolicy(p ConflictPolicy) JobOption {
+	return func(j *Job) {
+		j.Conflict = p
+	}
+}
+
+// WithSymlinkPolicy 设置任务对符号链接的处理方式，默认值见 SymlinkPolicy。上传时：SymlinkSkip 不上传，
+// 任务完成并标记为 Skipped；SymlinkFollow 上传链接指向的文件；SymlinkMarker 上传为标记文件，
+// 任务的 RemotePath 随之加上 SymlinkSuffix。下载时只有 SymlinkMarker 有效：以 SymlinkSuffix
+// 结尾的远程文件还原为 LocalPath（去掉 SymlinkSuffix）处的符号链接。
+func WithSymlinkPolicy(p SymlinkPolicy) JobOption {
+	return func(j *Job) {
+		j.Symlinks = p
+	}
+}
+
+// TransferOption 配置 NewTransferManager 创建的 TransferManager。
+type TransferOption func(*TransferManager)
+
+// WithTransferConcurrency 设置同时执行的任务数，默认为2。
+func WithTransferConcurrency(n int) TransferOption {
+	return func(m *TransferManager) {
+		if n > 0 {
+			m.concurrency = n
+		}
+	}
+}
+
+// WithConflictNamer 设置 ConflictKeepBoth 时本地文件的新名字，默认为 ConflictSuffix。
+func WithConflictNamer(n ConflictNamer) TransferOption {
+	return func(m *TransferManager) {
+		m.conflictName = n
+	}
+}
+
+// TransferManager 管理上传和下载任务队列：以全局并发数限制执行任务，
+// 并将队列及每个任务的断点信息保存在 statePath 中，进程重启后未完成的任务
+// 从断点继续执行。大文件按分片上传，按分段下载，每完成一个分片或分段保存一次。
+// 可被多个 goroutine 并发使用。
+type TransferManager struct {
+	client      *Client
+	statePath   string
+	concurrency int
+
+	hooks        []TransferHook
+	hookAttempts int
+	hookBackoff  time.Duration
+
+	conflictName ConflictNamer
+
+	mu      sync.Mutex
+	jobs    []*Job                        // 队列顺序
+	cancels map[string]context.CancelFunc // 正在执行的任务
+	changed chan struct{}
+	closing chan struct{} // Shutdown 时关闭
+	workers sync.WaitGroup
+	saveErr error // next 或 finish 保存队列失败的错误，Run 因此停止并返回它
+}
+
+type transferState struct {
+	Jobs []*Job `json:"jobs"`
+}
+
+// NewTransferManager 创建使用 c 执行传输的 TransferManager，并从 statePath 恢复之前保存的队列。
+// 上次退出时正在执行的任务恢复为 JobPending。
+func NewTransferManager(c *Client, statePath string, opts ...TransferOption) (*TransferManager, error) {
+	m := &TransferManager{
+		client:       c,
+		statePath:    statePath,
+		concurrency:  defaultTransferConcurrency,
+		hookAttempts: defaultHookAttempts,
+		hookBackoff:  defaultHookBackoff,
+		cancels:      make(map[string]context.CancelFunc),
+		changed:      make(chan struct{}),
+		closing:      make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	data, err := os.ReadFile(statePath)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if len(data) > 0 {
+		var state transferState
+		if err := json.Unmarshal(data, &state); err != nil {
+			return nil, err
+		}
+		for _, j := range state.Jobs {
+			if j.Status == JobRunning {
+				j.Status = JobPending
+			}
+		}
+		m.jobs = state.Jobs
+	}
+	return m, nil
+}
+
+// AddUpload 添加将本地文件 localPath 上传到 opt.Path 的任务，返回任务ID。
+// 队列中已有尚未完成（等待、执行中或暂停）的同一上传时不重复添加，返回已有任务的ID，
+// 并将 opt.OnDup 和 opts 应用于该任务。已在执行的任务（包括已暂停但尚未退出的任务）只能修改优先级，
+// OnDup、时间段或符号链接的处理方式不同时返回已有任务的ID和错误，任务保持不变。
+func (m *TransferManager) AddUpload(localPath string, opt *FileOptions, opts ...JobOption) (string, error) {
+	if opt == nil {
+		return "", invalid("path", "path is required")
+	}
+	if err := opt.Validate(); err != nil {
+		return "", err
+	}
+	return m.add(&Job{
+		Kind:       UploadJob,
+		LocalPath:  localPath,
+		RemotePath: CleanPath(opt.Path),
+		OnDup:      opt.OnDup,
+	}, opts)
+}
+
+// AddUploadIfChanged 与 AddUpload 相同，但 opt.Path 已是与 localPath 内容相同的文件时不添加任务，
+// 返回空ID，使反复执行的备份不会重复上传。大小和md5一致，或本地文件的 FileStamp 指向该远程文件时
+// 视为相同；设置了 Cipher 时只能依据 FileStamp 判断。已排队的上传不会再查询远程文件。
+func (m *TransferManager) AddUploadIfChanged(ctx context.Context, localPath string, opt *FileOptions, opts ...JobOption) (string, error) {
+	if opt == nil {
+		return "", invalid("path", "path is required")
+	}
+	if err := opt.Validate(); err != nil {
+		return "", err
+	}
+	local, err := filepath.Abs(localPath)
+	if err != nil {
+		return "", err
+	}
+	m.mu.Lock()
+	queued := m.queuedUpload(local, CleanPath(opt.Path))
+	m.mu.Unlock()
+	if queued != nil {
+		// 由 AddUpload 将选项应用于已有任务
+		return m.AddUpload(localPath, opt, opts...)
+	}
+
+	same, err := m.client.uploaded(ctx, local, opt.Path)
+	if err != nil || same {
+		return "", err
+	}
+	return m.AddUpload(localPath, opt, opts...)
+}
+
+// queuedUpload 返回尚未完成的将 local 上传到 remote 的任务，没有时返回 nil。调用时需持有 m.mu。
+func (m *TransferManager) queuedUpload(local, remote string) *Job {
+	for _, j := range m.jobs {
+		if j.Kind == UploadJob && j.LocalPath == local && j.RemotePath == remote &&
+			(j.Status == JobPending || j.Status == JobRunning || j.Status == JobPaused) {
+			return j
+		}
+	}
+	return nil
+}
+
+// uploaded 报告远程文件 remote 是否已与本地文件 local 内容相同，判断方式同 Sync。
+func (c *Client) uploaded(ctx context.Context, local, remote string) (bool, error) {
+	fi, err := os.Stat(local)
+	if err != nil {
+		return false, err
+	}
+	if !fi.Mode().IsRegular() {
+		return false, nil
+	}
+	meta, _, err := c.GetMeta(ctx, remote)
+	switch {
+	case errors.Is(err, ErrFileNotExist):
+		return false, nil
+	case err != nil:
+		return false, err
+	case meta.File == nil || meta.IsDirectory():
+		return false, nil
+	}
+
+	f := &syncFile{local: local, info: fi, hashes: c.hashes}
+	if s, err := ReadStamp(local); err == nil && s.Matches(fi) {
+		f.stamp = s
+	}
+	return sameContent(f, meta.File, c.cipher)
+}
+
+// AddDownload 添加将远程文件 remotePath 下载到本地 localPath 的任务，返回任务ID。
+func (m *TransferManager) AddDownload(remotePath, localPath string, opts ...JobOption) (string, error) {
+	if err := validateRemotePath("path", remotePath); err != nil {
+		return "", err
+	}
+	return m.add(&Job{
+		Kind:       DownloadJob,
+		LocalPath:  localPath,
+		RemotePath: CleanPath(remotePath),
+	}, opts)
+}
+
+func (m *TransferManager) add(j *Job, opts []JobOption) (string, error) {
+	for _, opt := range opts {
+		opt(j)
+	}
+	local, err := filepath.Abs(j.LocalPath)
+	if err != nil {
+		return "", err
+	}
+	id, err := newJobID()
+	if err != nil {
+		return "", err
+	}
+	j.ID = id
+	j.LocalPath = local
+	j.Status = JobPending
+	j.Created = time.Now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.draining() {
+		return "", ErrManagerClosed
+	}
+	if j.Kind == UploadJob {
+		if q := m.queuedUpload(j.LocalPath, j.RemotePath); q != nil {
+			return q.ID, m.merge(q, j.OnDup, opts)
+		}
+	}
+	m.jobs = append(m.jobs, j)
+	if err := m.save(); err != nil {
+		m.jobs = m.jobs[:len(m.jobs)-1]
+		return "", err
+	}
+	m.notify()
+	return id, nil
+}
+
+// merge 将重复添加的上传的 ondup 和 opts 应用于已有任务 q，见 AddUpload。调用时需持有 m.mu。
+func (m *TransferManager) merge(q *Job, ondup OnDup, opts []JobOption) error {
+	v := q.clone()
+	v.OnDup = ondup
+	for _, opt := range opts {
+		opt(&v)
+	}
+	if v.OnDup != q.OnDup || v.Symlinks != q.Symlinks || !slices.Equal(v.Windows, q.Windows) {
+		// 执行任务的 goroutine 不加锁地读取这些字段。Pause 之后它可能仍在退出途中，
+		// 所以依据 m.cancels 而不是状态判断
+		if _, ok := m.cancels[q.ID]; ok {
+			return invalid("opts", "upload %s is already running with other options", q.ID)
+		}
+		q.OnDup, q.Symlinks, q.Windows = v.OnDup, v.Symlinks, v.Windows
+	} else if v.Priority == q.Priority {
+		return nil
+	}
+	q.Priority = v.Priority
+	m.notify()
+	return m.save()
+}
+
+func newJobID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Jobs 按队列顺序返回全部任务的快照。
+func (m *TransferManager) Jobs() []Job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	jobs := make([]Job, len(m.jobs))
+	for i, j := range m.jobs {
+		jobs[i] = j.clone()
+	}
+	return jobs
+}
+
+// Job 返回任务 id 的快照。
+func (m *TransferManager) Job(id string) (Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j := m.find(id)
+	if j == nil {
+		return Job{}, ErrJobNotFound
+	}
+	return j.clone(), nil
+}
+
+func (m *TransferManager) find(id string) *Job {
+	if i := m.index(id); i >= 0 {
+		return m.jobs[i]
+	}
+	return nil
+}
+
+// Run 执行队列中的任务，直到 ctx 被取消；执行期间添加的任务也会被执行。
+// ctx 取消时正在执行的任务保留断点并恢复为 JobPending。ctx 设有截止时间时，
+// 按最近的传输速度缩小下载分段和新任务的上传分片，使单个请求能在截止前完成，
+// 到期时已完成的部分都保存在断点中。同一时间只应有一个 Run。
+// Shutdown 之后 Run 返回 ErrManagerClosed。开始或结束任务时无法保存队列的，
+// Run 不再开始新任务，等执行中的任务结束后返回保存时的错误，内存中的队列与 statePath 可能不一致。
+func (m *TransferManager) Run(ctx context.Context) error {
+	m.mu.Lock()
+	if m.draining() {
+		m.mu.Unlock()
+		return ErrManagerClosed
+	}
+	m.workers.Add(1)
+	m.saveErr = nil
+	m.mu.Unlock()
+	defer m.workers.Done()
+
+	// 所有 worker 退出后 schedule 也随之结束，即使 ctx 尚未取消
+	sctx, stop := context.WithCancel(ctx)
+	scheduled := make(chan struct{})
+	go func() {
+		defer close(scheduled)
+		m.schedule(sctx)
+	}()
+	var wg sync.WaitGroup
+	for i := 0; i < m.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				j, jctx := m.next(ctx)
+				if j == nil {
+					return
+				}
+				m.finish(j, m.run(jctx, j))
+			}
+		}()
+	}
+	wg.Wait()
+	stop()
+	<-scheduled
+	if m.draining() {
+		return ErrManagerClosed
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.saveErr != nil {
+		return m.saveErr
+	}
+	return ctx.Err()
+}
+
+// Shutdown 停止 TransferManager：不再接受新任务，也不再开始执行队列中的任务；
+// 执行中的任务在当前分片或分段完成后保存断点并退出，待所有任务退出并保存状态后返回。
+// ctx 先到期时中断仍在传输的分片（已完成的部分仍然保留），并返回 ctx 的错误。
+// 未完成的任务保持 JobPending，下次用同一 statePath 创建 TransferManager 时继续执行。
+func (m *TransferManager) Shutdown(ctx context.Context) error {
+	m.mu.Lock()
+	if !m.draining() {
+		close(m.closing)
+	}
+	m.notify()
+	m.mu.Unlock()
+
+	stopped := make(chan struct{})
+	go func() {
+		m.workers.Wait()
+		close(stopped)
+	}()
+
+	var err error
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		m.mu.Lock()
+		for _, cancel := range m.cancels {
+			cancel()
+		}
+		m.mu.Unlock()
+		<-stopped
+		err = ctx.Err()
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if serr := m.save(); serr != nil {
+		return serr
+	}
+	return err
+}
+
+func (m *TransferManager) draining() bool {
+	select {
+	case <-m.closing:
+		return true
+	default:
+		return false
+	}
+}
+
+// schedule 定期检查任务的时间段：中断已离开时间段的任务，并唤醒 next
+// 以执行进入时间段的任务。
+func (m *TransferManager) schedule(ctx context.Context) {
+	ticker := time.NewTicker(scheduleInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		case <-m.closing:
+			return
+		}
+
+		m.mu.Lock()
+		now := time.Now()
+		for _, j := range m.jobs {
+			if j.Status == JobRunning && !inWindows(j.Windows, now) {
+				// 状态仍为 JobRunning，finish 会将其放回队列
+				if cancel, ok := m.cancels[j.ID]; ok {
+					cancel()
+				}
+			}
+		}
+		m.notify()
+		m.mu.Unlock()
+	}
+}
+
+// Wait 等待队列中没有待执行或正在执行的任务，暂停和失败的任务不计在内。
+// Run 因无法保存队列而停止时返回保存时的错误。
+func (m *TransferManager) Wait(ctx context.Context) error {
+	for {
+		m.mu.Lock()
+		idle := true
+		for _, j := range m.jobs {
+			if j.Status == JobPending || j.Status == JobRunning {
+				idle = false
+				break
+			}
+		}
+		changed, err := m.changed, m.saveErr
+		m.mu.Unlock()
+		if idle || err != nil {
+			return err
+		}
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// SetPriority 修改任务 id 的优先级，对尚未开始执行的任务生效。
+func (m *TransferManager) SetPriority(id string, p int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j := m.find(id)
+	if j == nil {
+		return ErrJobNotFound
+	}
+	j.Priority = p
+	m.notify()
+	return m.save()
+}
+
+// MoveJob 将任务 id 移动到队列的第 pos 个位置（从0开始，超出范围时移到队尾），
+// 用于调整相同优先级的任务的执行顺序。
+func (m *TransferManager) MoveJob(id string, pos int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := m.index(id)
+	if i < 0 {
+		return ErrJobNotFound
+	}
+	if pos < 0 {
+		return invalid("pos", "must not be negative")
+	}
+
+	j := m.jobs[i]
+	m.jobs = append(m.jobs[:i], m.jobs[i+1:]...)
+	if pos > len(m.jobs) {
+		pos = len(m.jobs)
+	}
+	m.jobs = append(m.jobs, nil)
+	copy(m.jobs[pos+1:], m.jobs[pos:])
+	m.jobs[pos] = j
+	m.notify()
+	return m.save()
+}
+
+func (m *TransferManager) index(id string) int {
+	for i, j := range m.jobs {
+		if j.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// next 取出队列中处于时间段内、优先级最高的待执行任务并标记为 JobRunning，同时返回执行该任务所用的
+// context，Pause 通过取消它中断任务。ctx 取消时返回 nil。
+func (m *TransferManager) next(ctx context.Context) (*Job, context.Context) {
+	for {
+		m.mu.Lock()
+		// ctx 取消后被中断的任务恢复为 JobPending，不能再次取出
+		if m.draining() || ctx.Err() != nil || m.saveErr != nil {
+			m.mu.Unlock()
+			return nil, nil
+		}
+		var j *Job
+		now := time.Now()
+		for _, p := range m.jobs {
+			if p.Status != JobPending || !inWindows(p.Windows, now) {
+				continue
+			}
+			if j == nil || p.Priority > j.Priority {
+				j = p
+			}
+		}
+		if j != nil {
+			j.Status = JobRunning
+			if err := m.save(); err != nil {
+				// 状态文件中仍是 JobPending，不执行这个任务
+				j.Status = JobPending
+				m.saveErr = err
+				m.notify()
+				m.mu.Unlock()
+				return nil, nil
+			}
+			jctx, cancel := context.WithCancel(ctx)
+			m.cancels[j.ID] = cancel
+			m.notify()
+			if j.Kind == UploadJob {
+				m.client.publish(UploadStarted{Job: j.clone()})
+			} else {
+				m.client.publish(DownloadStarted{Job: j.clone()})
+			}
+			m.mu.Unlock()
+			return j, jctx
+		}
+		changed := m.changed
+		m.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return nil, nil
+		}
+	}
+}
+
+func (m *TransferManager) finish(j *Job, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if cancel, ok := m.cancels[j.ID]; ok {
+		cancel()
+		delete(m.cancels, j.ID)
+	}
+	switch {
+	case err == nil:
+		j.Status = JobDone
+		j.Err = ""
+	case j.Status == JobPaused:
+		// Pause 取消了任务，保持暂停状态
+	case errors.Is(err, errDraining), errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		j.Status = JobPending
+	default:
+		j.Status = JobFailed
+		j.Err = err.Error()
+	}
+	if err := m.save(); err != nil && m.saveErr == nil {
+		m.saveErr = err
+	}
+	m.notify()
+
+	switch {
+	case j.Status == JobDone && j.Kind == UploadJob:
+		m.client.publish(UploadCompleted{Job: j.clone()})
+	case j.Status == JobDone:
+		m.client.publish(DownloadCompleted{Job: j.clone()})
+	case j.Status == JobFailed && j.Kind == UploadJob:
+		m.client.publish(UploadFailed{Job: j.clone(), Err: err})
+	case j.Status == JobFailed:
+		m.client.publish(DownloadFailed{Job: j.clone(), Err: err})
+	}
+}
+
+// Pause 暂停任务 id。正在执行的任务会被中断，已完成的分片或分段保留在断点中，
+// Resume 之后从断点继续，而不是从头开始。暂停状态同样会被持久化。
+func (m *TransferManager) Pause(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j := m.find(id)
+	if j == nil {
+		return ErrJobNotFound
+	}
+	switch j.Status {
+	case JobPaused:
+		return nil
+	case JobPending, JobRunning:
+	default:
+		return invalid("id", "job %s is %s", id, j.Status)
+	}
+
+	j.Status = JobPaused
+	if cancel, ok := m.cancels[id]; ok {
+		cancel()
+	}
+	m.notify()
+	return m.save()
+}
+
+// Resume 将暂停的任务重新放入队列，从断点继续执行。失败的任务也可以通过 Resume 重试。
+func (m *TransferManager) Resume(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j := m.find(id)
+	if j == nil {
+		return ErrJobNotFound
+	}
+	switch j.Status {
+	case JobPaused, JobFailed:
+	case JobPending, JobRunning:
+		return nil
+	default:
+		return invalid("id", "job %s is %s", id, j.Status)
+	}
+
+	j.Status = JobPending
+	if _, ok := m.cancels[id]; ok {
+		// 暂停的任务尚未退出，由 finish 将其放回队列
+		j.Status = JobRunning
+	}
+	j.Err = ""
+	m.notify()
+	return m.save()
+}
+
+// update 在锁内修改任务并保存断点。
+func (m *TransferManager) update(fn func()) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	fn()
+	m.notify()
+	return m.save()
+}
+
+// notify 唤醒所有等待队列变化的 goroutine，调用时需持有 m.mu。
+func (m *TransferManager) notify() {
+	close(m.changed)
+	m.changed = make(chan struct{})
+}
+
+// save 将队列写入 statePath，调用时需持有 m.mu。
+// 先写临时文件再重命名，进程在写入过程中退出也不会损坏已有的状态。
+func (m *TransferManager) save() error {
+	data, err := json.MarshalIndent(&transferState{Jobs: m.jobs}, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := m.statePath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, m.statePath)
+}
+
+func (m *TransferManager) run(ctx context.Context, j *Job) error {
+	m.mu.Lock()
+	transferred := j.TransferDone
+	m.mu.Unlock()
+
+	if !transferred {
+		var err error
+		if j.Kind == UploadJob {
+			err = m.runUpload(ctx, j)
+		} else {
+			err = m.runDownload(ctx, j)
+		}
+		if err != nil {
+			return err
+		}
+		if err := m.update(func() { j.TransferDone = true }); err != nil {
+			return err
+		}
+	}
+	return m.runHooks(ctx, j)
+}
+
+func (m *TransferManager) runUpload(ctx context.Context, j *Job) error {
+	if j.Symlinks != SymlinkFollow {
+		fi, err := os.Lstat(j.LocalPath)
+		if err != nil {
+			return err
+		}
+		if fi.Mode()&os.ModeSymlink != 0 {
+			if j.Symlinks == SymlinkMarker {
+				return m.uploadSymlinkMarker(ctx, j)
+			}
+			return m.update(func() { j.Skipped = true })
+		}
+	}
+
+	f, err := os.Open(j.LocalPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	// 加密时上传的是密文，分片按密文切分
+	ci := m.client.cipher
+	size := stat.Size()
+	var header []byte
+	if ci != nil {
+		size = ci.EncryptedSize(size)
+		if header, err = ci.newHeader(); err != nil {
+			return err
+		}
+	}
+
+	var blockSize int64
+	var done int
+	err = m.update(func() {
+		if j.Size != size || !j.ModTime.Equal(stat.ModTime()) || (ci != nil) != (j.CryptHeader != nil) {
+			j.Size = size
+			j.ModTime = stat.ModTime()
+			j.BlockSize = uploadBlockSize(j.Size, m.client.blockTuner.sizeFor(ctx))
+			j.Blocks = nil
+			j.Transferred = 0
+			j.CryptHeader = header
+		}
+		blockSize, done, header = j.BlockSize, len(j.Blocks), j.CryptHeader
+	})
+	if err != nil {
+		return err
+	}
+	if done == 0 {
+		if err := m.client.checkQuota(ctx, size); err != nil {
+			return err
+		}
+	}
+
+	var src io.ReaderAt = f
+	if ci != nil {
+		cf, err := ci.parseHeader(header)
+		if err != nil {
+			return err
+		}
+		src = cf.encryptReaderAt(f, stat.Size())
+	}
+
+	opt := &FileOptions{Path: j.RemotePath, OnDup: j.OnDup}
+	name := filepath.Base(j.LocalPath)
+	if size <= blockSize {
+		body, contentType, err := m.client.bufferedBody(ctx, name, io.NewSectionReader(src, 0, size), size)
+		if err != nil {
+			return err
+		}
+		if _, _, err := m.client.uploadFile(ctx, body, contentType, opt); err != nil {
+			return err
+		}
+		return m.update(func() { j.Transferred = j.Size })
+	}
+
+	for _, b := range splitBlocks(size, blockSize)[done:] {
+		if m.draining() {
+			return errDraining
+		}
+		start := time.Now()
+		body, contentType, err := m.client.bufferedBody(ctx, name, io.NewSectionReader(src, b.Offset, b.Size), b.Size)
+		if err != nil {
+			return err
+		}
+		tmp, _, err := m.client.uploadBlock(ctx, body, contentType)
+		m.client.blockTuner.Observe(b.Size, time.Since(start), err)
+		if err != nil {
+			return err
+		}
+		err = m.update(func() {
+			j.Blocks = append(j.Blocks, tmp.Md5)
+			j.Transferred += b.Size
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	m.mu.Lock()
+	blocks := append([]string(nil), j.Blocks...)
+	m.mu.Unlock()
+	_, _, err = m.client.CreateSuperFile(ctx, j.RemotePath, blocks, opt)
+	return err
+}
+
+// uploadBlockSize 返回 size 字节的文件的分片大小：优先使用 preferred，
+// 但保证分片数不超过 CreateSuperFile 的上限。
+func uploadBlockSize(size, preferred int64) int64 {
+	if min := (size + maxSuperFileBlocks - 1) / maxSuperFileBlocks; preferred < min {
+		return min
+	}
+	return preferred
+}
+
+func (m *TransferManager) runDownload(ctx context.Context, j *Job) error {
+	meta, _, err := m.client.GetMeta(ctx, j.RemotePath)
+	if err != nil {
+		return err
+	}
+	if meta.IsDirectory() {
+		return invalid("path", "%q is a directory", j.RemotePath)
+	}
+	if j.isSymlinkMarker() && meta.Size <= maxSymlinkMarker {
+		return m.downloadSymlinkMarker(ctx, j)
+	}
+	size := int64(meta.Size)
+
+	var conflict bool
+	if j.Conflict == ConflictPreferLocal || j.Conflict == ConflictKeepBoth {
+		if conflict, err = localDiffers(j.LocalPath, meta.File, m.client.cipher != nil); err != nil {
+			return err
+		}
+	}
+	if conflict && j.Conflict == ConflictPreferLocal {
+		return m.update(func() { j.Skipped = true })
+	}
+
+	if err := os.MkdirAll(filepath.Dir(j.LocalPath), 0755); err != nil {
+		return err
+	}
+	part := j.LocalPath + ".part"
+	f, err := os.OpenFile(part, os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	ci := m.client.cipher
+	var (
+		offset int64
+		header []byte
+	)
+	err = m.update(func() {
+		if j.Size != size || j.Md5 != meta.Md5 || ((ci != nil) != (j.CryptHeader != nil) && j.Transferred > 0) {
+			j.Size = size
+			j.Md5 = meta.Md5
+			j.Transferred = 0
+			j.CryptHeader = nil
+		}
+		offset, header = j.Transferred, j.CryptHeader
+	})
+	if err != nil {
+		return err
+	}
+
+	// 加密的文件先取得文件头，之后按整数个分块下载并解密，
+	// offset 始终为密文中的偏移。
+	var cf *cryptFile
+	if ci != nil {
+		if header == nil {
+			var buf bytes.Buffer
+			if _, err := m.client.downloadRange(ctx, j.RemotePath, meta.Md5, 0, cryptHeaderSize-1, &buf); err != nil {
+				return err
+			}
+			header = buf.Bytes()
+		}
+		if cf, err = ci.parseHeader(header); err != nil {
+			return err
+		}
+		if offset < cryptHeaderSize {
+			offset = cryptHeaderSize
+		}
+		err = m.update(func() {
+			j.CryptHeader = header
+			j.Transferred = offset
+		})
+		if err != nil {
+			return err
+		}
+	}
+	plainOffset := func(off int64) int64 {
+		if cf == nil {
+			return off
+		}
+		return (off - cryptHeaderSize) / cf.encChunk() * cf.chunk
+	}
+
+	if stat.Size() < plainOffset(offset) {
+		offset = 0
+		if cf != nil {
+			offset = cryptHeaderSize
+		}
+	}
+	// 丢弃上次中断时写入了一部分的分段
+	if err := f.Truncate(plainOffset(offset)); err != nil {
+		return err
+	}
+	// 在开始写入前确认剩余部分放得下，加密时按密文大小估算
+	if err := CheckDiskSpace(filepath.Dir(part), size-offset); err != nil {
+		return err
+	}
+
+	for offset < size {
+		if m.draining() {
+			return errDraining
+		}
+		n := m.client.segmentTuner.sizeFor(ctx)
+		if cf != nil {
+			if n = n / cf.encChunk() * cf.encChunk(); n == 0 {
+				n = cf.encChunk()
+			}
+		}
+		if size-offset < n {
+			n = size - offset
+		}
+
+		var w io.Writer = io.NewOffsetWriter(f, plainOffset(offset))
+		var dw *decryptWriter
+		if cf != nil {
+			dw = cf.decryptWriter(w, (offset-cryptHeaderSize)/cf.encChunk())
+			w = dw
+		}
+
+		start := time.Now()
+		_, err := m.client.downloadRange(ctx, j.RemotePath, meta.Md5, offset, offset+n-1, w)
+		m.client.segmentTuner.Observe(n, time.Since(start), err)
+		if err == nil && dw != nil {
+			err = dw.finish(offset+n == size)
+		}
+		if err != nil {
+			return err
+		}
+		offset += n
+		if err := m.update(func() { j.Transferred = offset }); err != nil {
+			return err
+		}
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if conflict {
+		err := os.Rename(j.LocalPath, localConflictPath(j.LocalPath, m.conflictName))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if err := os.Rename(part, j.LocalPath); err != nil {
+		return err
+	}
+
+	// 未加密时本地文件即远端内容，加入缓存供之后的下载使用
+	if dc := m.client.cache; dc != nil && ci == nil {
+		if !dc.contains(meta.Md5) {
+			if f, err := os.Open(j.LocalPath); err == nil {
+				dc.Add(meta.Md5, f)
+				f.Close()
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,331 @@
+package pcs_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/holys/baidu-pcs/v2"
+	"github.com/holys/baidu-pcs/v2/pcstest"
+)
+
+// blockUploads 使上传请求等待 release 关闭后再发送
+type blockUploads struct {
+	release chan struct{}
+}
+
+func (b blockUploads) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Query().Get("method") == "upload" {
+		select {
+		case <-b.release:
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// waitStatus 等待任务 id 进入状态 status
+func waitStatus(t *testing.T, m *pcs.TransferManager, id string, status pcs.JobStatus) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		if j, _ := m.Job(id); j.Status == status {
+			return
+		}
+	}
+	t.Fatalf("job %s did not become %s", id, status)
+}
+
+func TestTransferDuplicateUpload(t *testing.T) {
+	srv := pcstest.NewServer()
+	defer srv.Close()
+	block := blockUploads{release: make(chan struct{})}
+	c := srv.NewClient(pcs.WithHTTPClient(&http.Client{Transport: block}))
+	local := filepath.Join(t.TempDir(), "a.txt")
+	if err := os.WriteFile(local, []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	m, err := pcs.NewTransferManager(c, filepath.Join(t.TempDir(), "jobs.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := m.AddUpload(local, pcs.NewFileOptions("/apps/t/a.txt", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dup, err := m.AddUpload(local, pcs.NewFileOptions("/apps/t/a.txt", pcs.OnDupOverwrite), pcs.WithPriority(3))
+	if err != nil || dup != id || len(m.Jobs()) != 1 {
+		t.Fatalf("duplicate AddUpload = %s, %v; want %s", dup, err, id)
+	}
+	// 没有给出的选项保持不变
+	if _, err := m.AddUpload(local, pcs.NewFileOptions("/apps/t/a.txt", pcs.OnDupOverwrite)); err != nil {
+		t.Fatal(err)
+	}
+	if j, _ := m.Job(id); j.Priority != 3 || j.OnDup != pcs.OnDupOverwrite {
+		t.Errorf("pending duplicate left the job at priority %d, ondup %q", j.Priority, j.OnDup)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go m.Run(ctx)
+	waitStatus(t, m, id, pcs.JobRunning)
+
+	dup, err = m.AddUpload(local, pcs.NewFileOptions("/apps/t/a.txt", pcs.OnDupNewCopy))
+	if dup != id || !errors.Is(err, pcs.ErrInvalidArgument) {
+		t.Errorf("running duplicate with another ondup = %s, %v", dup, err)
+	}
+	if _, err := m.AddUpload(local, pcs.NewFileOptions("/apps/t/a.txt", pcs.OnDupOverwrite), pcs.WithPriority(5)); err != nil {
+		t.Errorf("running duplicate with another priority: %v", err)
+	}
+	if j, _ := m.Job(id); j.Priority != 5 || j.OnDup != pcs.OnDupOverwrite {
+		t.Errorf("running job at priority %d, ondup %q", j.Priority, j.OnDup)
+	}
+
+	close(block.release)
+	if err := m.Wait(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if j, _ := m.Job(id); j.Status != pcs.JobDone {
+		t.Errorf("job ended %s: %s", j.Status, j.Err)
+	}
+}
+
+// holdUploads 使上传请求等待 release 关闭后再发送，请求被取消时也不提前返回
+type holdUploads struct {
+	release chan struct{}
+}
+
+func (h holdUploads) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Query().Get("method") == "upload" {
+		<-h.release
+	}
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestTransferDuplicateOfPausedUpload(t *testing.T) {
+	srv := pcstest.NewServer()
+	defer srv.Close()
+	hold := holdUploads{release: make(chan struct{})}
+	c := srv.NewClient(pcs.WithHTTPClient(&http.Client{Transport: hold}))
+	local := filepath.Join(t.TempDir(), "a.txt")
+	if err := os.WriteFile(local, []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	m, err := pcs.NewTransferManager(c, filepath.Join(t.TempDir(), "jobs.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := m.AddUpload(local, pcs.NewFileOptions("/apps/t/a.txt", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go m.Run(ctx)
+	waitStatus(t, m, id, pcs.JobRunning)
+
+	// 暂停后执行任务的 goroutine 仍停在上传请求中
+	if err := m.Pause(id); err != nil {
+		t.Fatal(err)
+	}
+	dup, err := m.AddUpload(local, pcs.NewFileOptions("/apps/t/a.txt", pcs.OnDupOverwrite))
+	if dup != id || !errors.Is(err, pcs.ErrInvalidArgument) {
+		t.Errorf("duplicate of a paused upload that is still running = %s, %v", dup, err)
+	}
+	if j, _ := m.Job(id); j.OnDup != "" {
+		t.Errorf("ondup changed to %q while the upload was running", j.OnDup)
+	}
+
+	// 退出之后可以修改
+	close(hold.release)
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(time.Millisecond) {
+		if _, err := m.AddUpload(local, pcs.NewFileOptions("/apps/t/a.txt", pcs.OnDupOverwrite)); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the paused upload did not stop")
+		}
+	}
+	if j, _ := m.Job(id); j.Status != pcs.JobPaused || j.OnDup != pcs.OnDupOverwrite {
+		t.Errorf("paused job %s with ondup %q", j.Status, j.OnDup)
+	}
+}
+
+func TestTransferStopsWhenStateCannotBeSaved(t *testing.T) {
+	srv := pcstest.NewServer()
+	defer srv.Close()
+	dir := t.TempDir()
+	local := filepath.Join(dir, "a.txt")
+	if err := os.WriteFile(local, []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	state := filepath.Join(dir, "jobs.json")
+	m, err := pcs.NewTransferManager(srv.NewClient(), state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := m.AddUpload(local, pcs.NewFileOptions("/apps/t/a.txt", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 非空目录不能被临时文件替换
+	if err := os.Remove(state); err != nil {
+		t.Fatal(err)
+	}
+	writeFiles(t, state, map[string]string{"x": ""})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := m.Run(ctx); err == nil || errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Run = %v, want the error saving the queue", err)
+	}
+	if err := m.Wait(ctx); err == nil || errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Wait = %v, want the error saving the queue", err)
+	}
+	if j, _ := m.Job(id); j.Status != pcs.JobPending {
+		t.Errorf("job %s after the failed save, want it still pending", j.Status)
+	}
+	if srv.Exists("/apps/t/a.txt") {
+		t.Error("job started although its state was not saved")
+	}
+}
+
+// countUploads 统计分片上传请求，第 pass 个之后的分片上传一直等待到请求被取消
+type countUploads struct {
+	pass int32
+	n    atomic.Int32
+}
+
+func (cu *countUploads) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Query().Get("type") == "tmpfile" && cu.n.Add(1) > cu.pass {
+		<-req.Context().Done()
+		return nil, req.Context().Err()
+	}
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestTransferQueuePersists(t *testing.T) {
+	srv := pcstest.NewServer()
+	defer srv.Close()
+	c := srv.NewClient()
+	srv.PutFile("/apps/t/remote.txt", []byte("remote"))
+	dir := t.TempDir()
+	local := filepath.Join(dir, "a.txt")
+	if err := os.WriteFile(local, []byte("local"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	state := filepath.Join(dir, "jobs.json")
+
+	m, err := pcs.NewTransferManager(c, state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	up, err := m.AddUpload(local, pcs.NewFileOptions("/apps/t/a.txt", ""), pcs.WithPriority(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	down, err := m.AddDownload("/apps/t/remote.txt", filepath.Join(dir, "out", "remote.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Pause(down); err != nil {
+		t.Fatal(err)
+	}
+
+	// 进程重启后队列原样恢复
+	m, err = pcs.NewTransferManager(c, state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if j, err := m.Job(up); err != nil || j.Status != pcs.JobPending || j.Priority != 2 || j.LocalPath != local {
+		t.Errorf("restored upload %+v, %v", j, err)
+	}
+	if j, err := m.Job(down); err != nil || j.Status != pcs.JobPaused {
+		t.Errorf("restored download %+v, %v", j, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	go m.Run(ctx)
+	if err := m.Resume(down); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Wait(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := srv.ReadFile("/apps/t/a.txt"); string(data) != "local" {
+		t.Errorf("uploaded %q", data)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "out", "remote.txt")); err != nil || string(data) != "remote" {
+		t.Errorf("downloaded %q, %v", data, err)
+	}
+}
+
+func TestTransferResumesPartialUpload(t *testing.T) {
+	srv := pcstest.NewServer()
+	defer srv.Close()
+	dir := t.TempDir()
+	data := []byte(strings.Repeat("0123456789", 10))
+	local := filepath.Join(dir, "a.txt")
+	if err := os.WriteFile(local, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	state := filepath.Join(dir, "jobs.json")
+	tuners := pcs.WithChunkTuners(pcs.FixedChunkTuner(10), nil)
+
+	// 上传3个分片后进程退出
+	first := &countUploads{pass: 3}
+	m, err := pcs.NewTransferManager(srv.NewClient(tuners, pcs.WithHTTPClient(&http.Client{Transport: first})), state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := m.AddUpload(local, pcs.NewFileOptions("/apps/t/a.txt", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- m.Run(ctx) }()
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(time.Millisecond) {
+		if j, _ := m.Job(id); len(j.Blocks) == 3 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the first blocks were not uploaded")
+		}
+	}
+	cancel()
+	<-done
+
+	second := &countUploads{pass: 100}
+	m, err = pcs.NewTransferManager(srv.NewClient(tuners, pcs.WithHTTPClient(&http.Client{Transport: second})), state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if j, _ := m.Job(id); j.Status != pcs.JobPending || len(j.Blocks) != 3 || j.Transferred != 30 {
+		t.Fatalf("restored job %+v", j)
+	}
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	go m.Run(ctx)
+	if err := m.Wait(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if j, _ := m.Job(id); j.Status != pcs.JobDone {
+		t.Fatalf("job ended %s: %s", j.Status, j.Err)
+	}
+	if n := second.n.Load(); n != 7 {
+		t.Errorf("resumed upload sent %d blocks, want the remaining 7", n)
+	}
+	if got, _ := srv.ReadFile("/apps/t/a.txt"); !bytes.Equal(got, data) {
+		t.Errorf("uploaded %q", got)
+	}
+}
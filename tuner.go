@@ -0,0 +1,112 @@
+package pcs
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	// 默认的下载分段大小
+	DefaultSegmentSize = 8 << 20
+
+	minChunkSize = 1 << 20
+	maxChunkSize = 64 << 20
+
+	// 单个分片的理想传输时长范围：过快说明链路好、可以增大分片减少请求数；
+	// 过慢则意味着失败重传的代价高，应当减小分片。
+	chunkFastDuration = 2 * time.Second
+	chunkSlowDuration = 15 * time.Second
+
+	// 设有截止时间时，单个分片最多占用剩余时间的比例，其余留给重试和合并文件
+	deadlineShare = 0.5
+)
+
+// ChunkTuner 根据最近的传输速度和错误率自动调整上传分片或下载分段的大小：
+// 稳定的快速链路上逐步增大，出错或传输缓慢时减半。可被多个 goroutine 并发使用。
+type ChunkTuner struct {
+	mu       sync.Mutex
+	size     int64
+	min, max int64
+	fixed    bool
+	rate     float64 // 最近的传输速度（字节/秒），为0表示尚无数据
+}
+
+// NewChunkTuner 创建初始大小为 initial、在 [min, max] 范围内自动调整的 ChunkTuner。
+func NewChunkTuner(initial, min, max int64) *ChunkTuner {
+	if min <= 0 {
+		min = minChunkSize
+	}
+	if max < min {
+		max = min
+	}
+	t := &ChunkTuner{min: min, max: max}
+	t.size = t.clamp(initial)
+	return t
+}
+
+// FixedChunkTuner 返回始终使用 size 的 ChunkTuner，即手动指定分片大小。
+func FixedChunkTuner(size int64) *ChunkTuner {
+	return &ChunkTuner{size: size, min: size, max: size, fixed: true}
+}
+
+// Size 返回下一个分片应使用的大小。
+func (t *ChunkTuner) Size() int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.size
+}
+
+// SizeBefore 返回预计能在 deadline 之前传输完成的分片大小：按最近的传输速度估算，
+// 不超过 Size，也不小于 minChunkSize。尚无速度数据时返回 Size。
+func (t *ChunkTuner) SizeBefore(deadline time.Time) int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.rate == 0 {
+		return t.size
+	}
+	n := int64(t.rate * time.Until(deadline).Seconds() * deadlineShare)
+	return max(min(n, t.size), min(t.size, minChunkSize))
+}
+
+// sizeFor 返回在 ctx 的截止时间内传输的分片大小，ctx 没有截止时间时即 t.Size()。
+func (t *ChunkTuner) sizeFor(ctx context.Context) int64 {
+	if deadline, ok := ctx.Deadline(); ok {
+		return t.SizeBefore(deadline)
+	}
+	return t.Size()
+}
+
+// Observe 记录一个大小为 n 的分片的传输结果，并据此调整后续分片大小。
+func (t *ChunkTuner) Observe(n int64, d time.Duration, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err == nil && d > 0 {
+		rate := float64(n) / d.Seconds()
+		if t.rate == 0 {
+			t.rate = rate
+		} else {
+			t.rate = 0.7*t.rate + 0.3*rate
+		}
+	}
+	if t.fixed {
+		return
+	}
+
+	switch {
+	case err != nil, d > chunkSlowDuration:
+		t.size = t.clamp(t.size / 2)
+	case d < chunkFastDuration && n >= t.size:
+		t.size = t.clamp(t.size * 2)
+	}
+}
+
+func (t *ChunkTuner) clamp(size int64) int64 {
+	if size < t.min {
+		return t.min
+	}
+	if size > t.max {
+		return t.max
+	}
+	return size
+}
@@ -0,0 +1,102 @@
+package pcs
+
+import (
+	"context"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var usageHeader = []string{"path", "bytes", "files"}
+
+// FolderUsage 是一个目录（包括其全部子目录）占用的空间
+type FolderUsage struct {
+	Path  string `json:"path"`
+	Bytes uint64 `json:"bytes"`
+	Files int    `json:"files"`
+}
+
+// walk 以广度优先的顺序遍历远程目录 root 下的全部文件和目录，对每一项调用 fn。
+// fn 返回错误时停止遍历并返回该错误。
+func (c *Client) walk(ctx context.Context, root string, fn func(*File) error) error {
+	for f, err := range c.Walk(ctx, root) {
+		if err != nil {
+			return err
+		}
+		if err := fn(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Usage 遍历远程目录 root，按 root 下的各个一级目录汇总占用的空间和文件数，
+// 直接位于 root 下的文件计入 root 自身。结果按占用空间从大到小排列。
+// 出错或 ctx 被取消时返回该错误以及按此前已遍历的条目汇总的结果。
+func (c *Client) Usage(ctx context.Context, root string) ([]FolderUsage, error) {
+	root = CleanPath(root)
+	prefix := strings.TrimSuffix(root, "/") + "/"
+
+	byPath := make(map[string]*FolderUsage)
+	group := func(p string) *FolderUsage {
+		if u, ok := byPath[p]; ok {
+			return u
+		}
+		u := &FolderUsage{Path: p}
+		byPath[p] = u
+		return u
+	}
+	group(root)
+
+	err := c.walk(ctx, root, func(f *File) error {
+		rel := strings.TrimPrefix(CleanPath(f.Path), prefix)
+		top, _, nested := strings.Cut(rel, "/")
+		if f.IsDirectory() {
+			if !nested {
+				group(path.Join(root, top))
+			}
+			return nil
+		}
+		u := byPath[root]
+		if nested {
+			u = group(path.Join(root, top))
+		}
+		u.Bytes += f.Size
+		u.Files++
+		return nil
+	})
+
+	usage := make([]FolderUsage, 0, len(byPath))
+	for _, u := range byPath {
+		usage = append(usage, *u)
+	}
+	sort.Slice(usage, func(i, j int) bool {
+		if usage[i].Bytes != usage[j].Bytes {
+			return usage[i].Bytes > usage[j].Bytes
+		}
+		return usage[i].Path < usage[j].Path
+	})
+	return usage, err
+}
+
+// WriteUsage 以 format 格式将 Usage 的结果写入 w。
+func WriteUsage(w io.Writer, usage []FolderUsage, format ExportFormat) error {
+	switch format {
+	case FormatJSON:
+		if usage == nil {
+			usage = []FolderUsage{}
+		}
+		return writeJSON(w, usage)
+	case FormatCSV:
+		rows := make([][]string, len(usage))
+		for i, u := range usage {
+			rows[i] = []string{u.Path, strconv.FormatUint(u.Bytes, 10), strconv.Itoa(u.Files)}
+		}
+		return writeCSV(w, usageHeader, rows)
+	case FormatNDJSON:
+		return writeNDJSON(w, usage)
+	}
+	return invalid("format", "unknown format %d", format)
+}
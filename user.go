@@ -0,0 +1,64 @@
+package pcs
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// VipType 是账号的会员类型
+type VipType int
+
+const (
+	VipNone   VipType = iota // 普通用户
+	VipNormal                // 普通会员
+	VipSuper                 // 超级会员
+)
+
+func (t VipType) String() string {
+	switch t {
+	case VipNone:
+		return "none"
+	case VipNormal:
+		return "vip"
+	case VipSuper:
+		return "svip"
+	}
+	return "unknown"
+}
+
+// UserInfo 是当前授权账号的信息
+type UserInfo struct {
+	BaiduName   string  `json:"baidu_name"`   // 百度账号名
+	NetdiskName string  `json:"netdisk_name"` // 网盘账号名
+	AvatarURL   string  `json:"avatar_url"`   // 头像地址
+	VipType     VipType `json:"vip_type"`     // 会员类型，影响下载限速
+	UK          uint64  `json:"uk"`           // 用户ID
+}
+
+// 获取当前授权账号的信息
+func (c *Client) GetUserInfo(ctx context.Context) (*UserInfo, *http.Response, error) {
+	u, err := c.addOptions("nas", "uinfo", nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	req, err := c.newRequest(func() *url.URL { return c.PanURL }, "GET", u, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// 网盘接口以 errno 而不是 error_code 表示错误
+	info := struct {
+		UserInfo
+		panStatus
+	}{}
+	resp, err := c.Do(ctx, req, &info)
+	if err != nil {
+		return nil, resp, err
+	}
+	if err := info.err(resp); err != nil {
+		return nil, resp, err
+	}
+
+	return &info.UserInfo, resp, nil
+}
@@ -0,0 +1,271 @@
+package pcs
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const maxPathLength = 1000
+
+// ValidationError 表示请求参数在发送前未通过校验。
+// errors.Is(err, ErrInvalidArgument) 对其成立。
+type ValidationError struct {
+	Field  string // 出错的参数名，与 url tag 一致
+	Reason string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("baidu-pcs: invalid argument %s: %s", e.Field, e.Reason)
+}
+
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrInvalidArgument
+}
+
+func invalid(field, format string, args ...interface{}) error {
+	return &ValidationError{Field: field, Reason: fmt.Sprintf(format, args...)}
+}
+
+// validator 由各个选项结构体实现，addOptions 在构造请求前调用。
+type validator interface {
+	Validate() error
+}
+
+var (
+	md5Pattern   = regexp.MustCompile(`^[0-9a-fA-F]{32}$`)
+	limitPattern = regexp.MustCompile(`^(\d+)-(\d+)$`)
+
+	streamingTypes = map[string]bool{
+		"M3U8_320_240": true,
+		"M3U8_480_224": true,
+		"M3U8_480_360": true,
+		"M3U8_640_480": true,
+		"M3U8_854_480": true,
+	}
+	streamFileTypes = map[string]bool{"video": true, "audio": true, "image": true, "doc": true}
+)
+
+// ValidatePath 检查远程路径是否符合 PCS 的要求：
+//   - 以 / 开头，长度不超过 1000；
+//   - 不包含 \ ? | " > < : * 等字符；
+//   - 各级文件名或目录名开头结尾不能是“.”或空白字符。
+func ValidatePath(field, p string) error {
+	if p == "" {
+		return invalid(field, "path is required")
+	}
+	if !strings.HasPrefix(p, "/") {
+		return invalid(field, "path %q must be absolute", p)
+	}
+	if utf8.RuneCountInString(p) > maxPathLength {
+		return invalid(field, "path is longer than %d characters", maxPathLength)
+	}
+	if i := strings.IndexAny(p, "\\?|\"><:*"); i >= 0 {
+		return invalid(field, "path %q contains forbidden character %q", p, p[i])
+	}
+	for _, name := range strings.Split(strings.Trim(p, "/"), "/") {
+		if name == "" {
+			continue
+		}
+		for _, r := range []rune{[]rune(name)[0], []rune(name)[utf8.RuneCountInString(name)-1]} {
+			if r == '.' || isPathSpace(r) {
+				return invalid(field, "name %q must not begin or end with '.' or white space", name)
+			}
+		}
+	}
+	return nil
+}
+
+// validateRemotePath 校验经 CleanPath 规范化之后的路径，与实际发送的值一致。
+func validateRemotePath(field, p string) error {
+	if p == "" {
+		return invalid(field, "path is required")
+	}
+	return ValidatePath(field, CleanPath(p))
+}
+
+func isPathSpace(r rune) bool {
+	switch r {
+	case '\r', '\n', '\t', ' ', '\x00', '\x0B':
+		return true
+	}
+	return false
+}
+
+func validateOnDup(field string, ondup OnDup) error {
+	switch ondup {
+	case "", OnDupOverwrite, OnDupNewCopy:
+		return nil
+	}
+	return invalid(field, "must be overwrite or newcopy, got %q", ondup)
+}
+
+func validateOneOf(field, v string, allowed ...string) error {
+	if v == "" {
+		return nil
+	}
+	for _, a := range allowed {
+		if v == a {
+			return nil
+		}
+	}
+	return invalid(field, "must be one of %s, got %q", strings.Join(allowed, ", "), v)
+}
+
+func validateNonNegative(field string, v int) error {
+	if v < 0 {
+		return invalid(field, "must not be negative, got %d", v)
+	}
+	return nil
+}
+
+func validateNumeric(field, v string) error {
+	if v == "" {
+		return nil
+	}
+	if n, err := strconv.Atoi(v); err != nil || n < 0 {
+		return invalid(field, "must be a non-negative integer, got %q", v)
+	}
+	return nil
+}
+
+func (opt *FileOptions) Validate() error {
+	if err := validateRemotePath("path", opt.Path); err != nil {
+		return err
+	}
+	return validateOnDup("ondup", opt.OnDup)
+}
+
+func (opt *ListFilesOptions) Validate() error {
+	if opt.Path == "" {
+		return invalid("path", "path is required")
+	}
+	if err := validateOneOf("order", opt.Order, "asc", "desc"); err != nil {
+		return err
+	}
+	if err := validateOneOf("by", opt.By, "time", "name", "size"); err != nil {
+		return err
+	}
+	if opt.Limit != "" {
+		m := limitPattern.FindStringSubmatch(opt.Limit)
+		if m == nil {
+			return invalid("limit", "must have the form n1-n2, got %q", opt.Limit)
+		}
+		n1, _ := strconv.Atoi(m[1])
+		n2, _ := strconv.Atoi(m[2])
+		if n1 >= n2 {
+			return invalid("limit", "n1 must be less than n2, got %q", opt.Limit)
+		}
+	}
+	return nil
+}
+
+func (opt *SearchOptions) Validate() error {
+	if opt.Path == "" {
+		return invalid("path", "path is required")
+	}
+	if opt.Word == "" {
+		return invalid("wd", "keyword is required")
+	}
+	return validateOneOf("re", opt.Re, "0", "1")
+}
+
+func (opt *ThumbnailOptions) Validate() error {
+	if opt.Path == "" {
+		return invalid("path", "path is required")
+	}
+	if opt.Quality < 0 || opt.Quality > 100 {
+		return invalid("quality", "must be in (0, 100], got %d", opt.Quality)
+	}
+	if opt.Height <= 0 || opt.Height > 1600 {
+		return invalid("height", "must be in (0, 1600], got %d", opt.Height)
+	}
+	if opt.Width <= 0 || opt.Width > 1600 {
+		return invalid("width", "must be in (0, 1600], got %d", opt.Width)
+	}
+	return nil
+}
+
+func (opt *ListStreamOptions) Validate() error {
+	if !streamFileTypes[opt.Type] {
+		return invalid("type", "must be one of video, audio, image, doc, got %q", opt.Type)
+	}
+	if err := validateNumeric("start", opt.Start); err != nil {
+		return err
+	}
+	return validateNumeric("limit", opt.Limit)
+}
+
+func (opt *RapiduUploadOptions) Validate() error {
+	if err := validateRemotePath("path", opt.Path); err != nil {
+		return err
+	}
+	if !md5Pattern.MatchString(opt.ContentMd5) {
+		return invalid("content-md5", "must be a hex encoded md5, got %q", opt.ContentMd5)
+	}
+	if !md5Pattern.MatchString(opt.SliceMd5) {
+		return invalid("slice-md5", "must be a hex encoded md5, got %q", opt.SliceMd5)
+	}
+	return validateOnDup("ondup", opt.Ondup)
+}
+
+func (opt *AddTaskOptions) Validate() error {
+	if err := validateRemotePath("save_path", opt.SavePath); err != nil {
+		return err
+	}
+	if opt.Type == TaskTypeTorrent {
+		if err := validateRemotePath("source_path", opt.SourcePath); err != nil {
+			return err
+		}
+	} else if opt.SourceURL == "" {
+		return invalid("source_url", "source url is required")
+	} else if IsMagnetLink(opt.SourceURL) && !strings.Contains(strings.ToLower(opt.SourceURL), "xt=urn:btih:") {
+		return invalid("source_url", "magnet link without a btih info hash: %q", opt.SourceURL)
+	}
+	if err := validateNonNegative("rate_limit", opt.RateLimit); err != nil {
+		return err
+	}
+	return validateNonNegative("timeout", opt.Timeout)
+}
+
+func (opt *QueryTaskOptions) Validate() error {
+	if opt.TaskIds == "" {
+		return invalid("task_ids", "at least one task id is required")
+	}
+	if opt.OpType != 0 && opt.OpType != 1 {
+		return invalid("op_type", "must be 0 or 1, got %d", opt.OpType)
+	}
+	return nil
+}
+
+func (opt *ListTaskOptions) Validate() error {
+	if err := validateNonNegative("start", opt.Start); err != nil {
+		return err
+	}
+	if err := validateNonNegative("limit", opt.Limit); err != nil {
+		return err
+	}
+	if opt.Asc != 0 && opt.Asc != 1 {
+		return invalid("asc", "must be 0 or 1, got %d", opt.Asc)
+	}
+	if opt.NeedTaskInfo != 0 && opt.NeedTaskInfo != 1 {
+		return invalid("need_task_info", "must be 0 or 1, got %d", opt.NeedTaskInfo)
+	}
+	return nil
+}
+
+func (opt *CancelTaskOptions) Validate() error {
+	if opt.TaskId == "" {
+		return invalid("task_id", "task id is required")
+	}
+	return nil
+}
+
+func (opt *ListRecycleOptions) Validate() error {
+	if err := validateNonNegative("start", opt.Start); err != nil {
+		return err
+	}
+	return validateNonNegative("limit", opt.Limit)
+}
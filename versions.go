@@ -0,0 +1,205 @@
+package pcs
+
+import (
+	"context"
+	"errors"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 版本文件名中的时间格式，按字典序排列即按时间排列
+const versionTimeFormat = "20060102-150405.000"
+
+// Version 是 Versioning 保存的一个历史版本
+type Version struct {
+	Path string    // 版本文件的远程路径
+	Time time.Time // 保存该版本的时间
+	Size uint64
+	Md5  string
+}
+
+// Versioning 在覆盖或删除远程文件之前，在服务端将原有内容复制到版本目录 root 中保存，
+// 文件 p 的各个版本保存为 root/p/<时间>。PCS 本身不保存文件的历史版本。
+// 复制在服务端完成，不消耗带宽，但版本文件占用空间配额，可用 Prune 清理。
+type Versioning struct {
+	client *Client
+	root   string
+}
+
+// NewVersioning 创建在远程目录 root 中保存版本的 Versioning。root 不应位于需要保存版本的目录之内，
+// 否则同步或遍历时会把版本文件当作普通文件。PCS 不允许以 . 开头的文件名，
+// 因此 root 不能是 .versions 这样的隐藏目录。
+func NewVersioning(c *Client, root string) (*Versioning, error) {
+	if err := validateRemotePath("root", root); err != nil {
+		return nil, err
+	}
+	return &Versioning{client: c, root: CleanPath(root)}, nil
+}
+
+// dir 返回保存 p 的各个版本的目录
+func (v *Versioning) dir(p string) string {
+	return path.Join(v.root, CleanPath(p))
+}
+
+// Save 将远程文件 p 的当前内容保存为一个新版本，应在覆盖或删除 p 之前调用。
+// p 不存在时返回 nil, nil。
+func (v *Versioning) Save(ctx context.Context, p string) (*Version, error) {
+	meta, _, err := v.client.GetMeta(ctx, p)
+	if isNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if meta.IsDirectory() {
+		return nil, invalid("path", "%q is a directory", p)
+	}
+
+	now := time.Now().UTC()
+	ver := &Version{
+		Path: path.Join(v.dir(p), now.Format(versionTimeFormat)),
+		Time: now,
+		Size: meta.Size,
+		Md5:  meta.Md5,
+	}
+	if _, _, err := v.client.Copy(ctx, p, ver.Path); err != nil {
+		return nil, err
+	}
+	return ver, nil
+}
+
+// List 返回远程文件 p 已保存的全部版本，最新的在前。没有版本时返回空切片。
+func (v *Versioning) List(ctx context.Context, p string) ([]Version, error) {
+	files, err := v.client.listDir(ctx, v.dir(p))
+	if isNotExist(err) {
+		return []Version{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make([]Version, 0, len(files))
+	for _, f := range files {
+		t, err := time.Parse(versionTimeFormat, path.Base(f.Path))
+		if f.IsDirectory() || err != nil {
+			continue
+		}
+		versions = append(versions, Version{Path: f.Path, Time: t, Size: f.Size, Md5: f.Md5})
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i].Time.After(versions[j].Time) })
+	return versions, nil
+}
+
+// Restore 用版本 ver 替换远程文件 p 的内容。p 的当前内容先保存为一个新版本，
+// 因此 Restore 本身也可以撤销。ver 先被复制到 p 旁边的临时文件，再替换 p，
+// 复制失败时 p 保持不变；替换失败时将刚保存的当前版本放回 p，放回也失败时它仍在版本目录中。
+func (v *Versioning) Restore(ctx context.Context, p string, ver Version) error {
+	cur, err := v.Save(ctx, p)
+	if err != nil {
+		return err
+	}
+	if cur == nil {
+		_, _, err = v.client.Copy(ctx, ver.Path, p)
+		return err
+	}
+
+	tmp := p + ".restore-" + cur.Time.Format(versionTimeFormat)
+	if _, _, err := v.client.Copy(ctx, ver.Path, tmp); err != nil {
+		return err
+	}
+	// ctx 被取消时也要清理临时文件或放回 p
+	cleanup := context.WithoutCancel(ctx)
+	if _, err := v.client.Delete(ctx, p); err != nil {
+		v.client.Delete(cleanup, tmp)
+		return err
+	}
+	if _, _, err := v.client.Move(ctx, tmp, p); err != nil {
+		if _, _, rerr := v.client.Copy(cleanup, cur.Path, p); rerr != nil {
+			return errors.Join(err, rerr)
+		}
+		v.client.Delete(cleanup, tmp)
+		return err
+	}
+	return nil
+}
+
+// Prune 删除远程文件 p 的旧版本：只保留最新的 keep 个，并删除早于 maxAge 之前保存的版本。
+// keep 或 maxAge 为0时不按该条件删除。返回删除的版本数。
+func (v *Versioning) Prune(ctx context.Context, p string, keep int, maxAge time.Duration) (int, error) {
+	versions, err := v.List(ctx, p)
+	if err != nil {
+		return 0, err
+	}
+
+	var stale []string
+	for i, ver := range versions {
+		if (keep > 0 && i >= keep) || (maxAge > 0 && time.Since(ver.Time) > maxAge) {
+			stale = append(stale, ver.Path)
+		}
+	}
+	if len(stale) == 0 {
+		return 0, nil
+	}
+	if _, err := v.client.BatchDelete(ctx, stale); err != nil {
+		return 0, err
+	}
+	return len(stale), nil
+}
+
+// Snapshot 返回远程目录 dir 在时间 at 时的状态，可用 RestoreBackup 恢复到本地。
+// 对 dir 下（包括已被删除）的每个文件，at 之后保存的最早版本即 at 时的内容；
+// 没有这样的版本时使用当前文件，但修改时间晚于 at 的当前文件视为 at 时还不存在。
+// 只有通过 Versioning 覆盖或删除的文件才能找回之前的内容；在 at 之后才创建、
+// 随后又被覆盖的文件无法与之前就存在的文件区分，会以其最早的版本出现在结果中。
+//
+// 返回的 BackupSet 的 Name 和 Time 对应 at，Cursor 为空，Files 中的 Md5 取自服务端。
+func (v *Versioning) Snapshot(ctx context.Context, dir string, at time.Time) (*BackupSet, error) {
+	dir = CleanPath(dir)
+	chosen := make(map[string]*File)
+	vtime := make(map[string]time.Time)
+
+	vdir := v.dir(dir)
+	vprefix := strings.TrimSuffix(vdir, "/") + "/"
+	err := v.client.walk(ctx, vdir, func(f *File) error {
+		t, err := time.Parse(versionTimeFormat, path.Base(f.Path))
+		if f.IsDirectory() || err != nil || !t.After(at) {
+			return nil
+		}
+		rel := strings.TrimPrefix(path.Dir(f.Path), vprefix)
+		if old, ok := vtime[rel]; !ok || t.Before(old) {
+			chosen[rel], vtime[rel] = f, t
+		}
+		return nil
+	})
+	if err != nil && !isNotExist(err) {
+		return nil, err
+	}
+
+	prefix := strings.TrimSuffix(dir, "/") + "/"
+	err = v.client.walk(ctx, dir, func(f *File) error {
+		rel := strings.TrimPrefix(f.Path, prefix)
+		if _, ok := chosen[rel]; !f.IsDirectory() && !ok && int64(f.Mtime) <= at.Unix() {
+			chosen[rel] = f
+		}
+		return nil
+	})
+	if err != nil && !isNotExist(err) {
+		return nil, err
+	}
+
+	at = at.UTC()
+	set := &BackupSet{Name: at.Format(backupNameFormat), Time: at, Files: make([]BackupEntry, 0, len(chosen))}
+	for rel, f := range chosen {
+		set.Files = append(set.Files, BackupEntry{
+			Path:    rel,
+			Size:    int64(f.Size),
+			Md5:     f.Md5,
+			ModTime: time.Unix(int64(f.Mtime), 0),
+			Remote:  f.Path,
+		})
+	}
+	sort.Slice(set.Files, func(i, j int) bool { return set.Files[i].Path < set.Files[j].Path })
+	return set, nil
+}
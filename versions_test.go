@@ -0,0 +1,181 @@
+package pcs_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"path"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/holys/baidu-pcs/v2"
+	"github.com/holys/baidu-pcs/v2/pcstest"
+)
+
+// failNth 使第 n 个 method 请求在发送前失败
+type failNth struct {
+	method string
+	n      int32
+	count  atomic.Int32
+}
+
+var errInjected = errors.New("injected failure")
+
+func (f *failNth) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Query().Get("method") == f.method && f.count.Add(1) == f.n {
+		return nil, errInjected
+	}
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func newVersioning(t *testing.T, c *pcs.Client) *pcs.Versioning {
+	t.Helper()
+	v, err := pcs.NewVersioning(c, "/apps/versions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestVersioningSaveAndList(t *testing.T) {
+	ctx := context.Background()
+	srv := pcstest.NewServer()
+	defer srv.Close()
+	v := newVersioning(t, srv.NewClient())
+
+	if ver, err := v.Save(ctx, "/apps/t/a.txt"); ver != nil || err != nil {
+		t.Errorf("Save of a missing file = %+v, %v", ver, err)
+	}
+	srv.PutFile("/apps/t/a.txt", []byte("one"))
+	first, err := v.Save(ctx, "/apps/t/a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(first.Path, "/apps/versions/apps/t/a.txt/") || first.Size != 3 {
+		t.Errorf("Save = %+v", first)
+	}
+	time.Sleep(2 * time.Millisecond)
+	srv.PutFile("/apps/t/a.txt", []byte("two!"))
+	second, err := v.Save(ctx, "/apps/t/a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := v.List(ctx, "/apps/t/a.txt")
+	if err != nil || len(list) != 2 || list[0].Path != second.Path || list[1].Path != first.Path {
+		t.Fatalf("List = %+v, %v; want the newest first", list, err)
+	}
+	if data, _ := srv.ReadFile(list[1].Path); string(data) != "one" {
+		t.Errorf("first version holds %q", data)
+	}
+	if list, err := v.List(ctx, "/apps/t/none"); err != nil || list == nil || len(list) != 0 {
+		t.Errorf("List without versions = %v, %v", list, err)
+	}
+	srv.Mkdir("/apps/t/d")
+	if _, err := v.Save(ctx, "/apps/t/d"); !errors.Is(err, pcs.ErrInvalidArgument) {
+		t.Errorf("Save of a directory: %v", err)
+	}
+}
+
+func TestVersioningPrune(t *testing.T) {
+	ctx := context.Background()
+	srv := pcstest.NewServer()
+	defer srv.Close()
+	v := newVersioning(t, srv.NewClient())
+	dir := "/apps/versions/apps/t/a.txt"
+	now := time.Now().UTC()
+	for _, age := range []time.Duration{time.Minute, time.Hour, 48 * time.Hour, 72 * time.Hour} {
+		srv.PutFile(path.Join(dir, now.Add(-age).Format("20060102-150405.000")), []byte("v"))
+	}
+
+	for _, tt := range []struct {
+		keep   int
+		maxAge time.Duration
+		pruned int
+		left   int
+	}{
+		{0, 0, 0, 4},
+		{3, 0, 1, 3},
+		{0, 24 * time.Hour, 1, 2},
+		{1, 0, 1, 1},
+		{0, time.Second, 1, 0},
+	} {
+		n, err := v.Prune(ctx, "/apps/t/a.txt", tt.keep, tt.maxAge)
+		list, _ := v.List(ctx, "/apps/t/a.txt")
+		if err != nil || n != tt.pruned || len(list) != tt.left {
+			t.Errorf("Prune(%d, %s) = %d, %v, leaving %d; want %d, leaving %d", tt.keep, tt.maxAge, n, err, len(list), tt.pruned, tt.left)
+		}
+	}
+}
+
+func TestVersioningRestore(t *testing.T) {
+	ctx := context.Background()
+	srv := pcstest.NewServer()
+	defer srv.Close()
+	v := newVersioning(t, srv.NewClient())
+	srv.PutFile("/apps/t/a.txt", []byte("old"))
+	old, err := v.Save(ctx, "/apps/t/a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(2 * time.Millisecond)
+	srv.PutFile("/apps/t/a.txt", []byte("new"))
+
+	if err := v.Restore(ctx, "/apps/t/a.txt", *old); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := srv.ReadFile("/apps/t/a.txt"); string(data) != "old" {
+		t.Errorf("restored %q", data)
+	}
+	// 被替换的内容保存为新版本，Restore 可以撤销
+	list, err := v.List(ctx, "/apps/t/a.txt")
+	if err != nil || len(list) != 2 {
+		t.Fatalf("List = %+v, %v", list, err)
+	}
+	if data, _ := srv.ReadFile(list[0].Path); string(data) != "new" {
+		t.Errorf("newest version holds %q", data)
+	}
+
+	// 文件已被删除时直接复制
+	if _, err := srv.NewClient().Delete(ctx, "/apps/t/a.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Restore(ctx, "/apps/t/a.txt", list[0]); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := srv.ReadFile("/apps/t/a.txt"); string(data) != "new" {
+		t.Errorf("restored deleted file %q", data)
+	}
+}
+
+func TestVersioningRestoreFailure(t *testing.T) {
+	ctx := context.Background()
+	for _, fail := range []*failNth{
+		{method: "copy", n: 2}, // 复制版本，第1个是保存当前版本
+		{method: "delete", n: 1},
+		{method: "move", n: 1},
+	} {
+		srv := pcstest.NewServer()
+		srv.PutFile("/apps/t/a.txt", []byte("old"))
+		srv.PutFile("/apps/versions/apps/t/a.txt/20200101-000000.000", []byte("version"))
+		v := newVersioning(t, srv.NewClient(pcs.WithHTTPClient(&http.Client{Transport: fail})))
+		list, err := v.List(ctx, "/apps/t/a.txt")
+		if err != nil || len(list) != 1 {
+			t.Fatalf("List = %+v, %v", list, err)
+		}
+
+		if err := v.Restore(ctx, "/apps/t/a.txt", list[0]); !errors.Is(err, errInjected) {
+			t.Errorf("%s failed, Restore = %v", fail.method, err)
+		}
+		if data, ok := srv.ReadFile("/apps/t/a.txt"); !ok || string(data) != "old" {
+			t.Errorf("%s failed, left %q, %v", fail.method, data, ok)
+		}
+		files, _, err := srv.NewClient().ListFiles(ctx, &pcs.ListFilesOptions{Path: "/apps/t"})
+		if err != nil || len(files) != 1 {
+			t.Errorf("%s failed, left %d files in /apps/t, %v", fail.method, len(files), err)
+		}
+		srv.Close()
+	}
+}